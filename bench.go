@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-collector-prometheus/prometheus"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// benchPayload is a scrape body the pipeline is benchmarked on
+type benchPayload struct {
+	name string
+	body []byte
+}
+
+// payloadDownloader answers every scrape with the same body
+type payloadDownloader struct {
+	body []byte
+}
+
+func (d payloadDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	return bytes.NewReader(d.body), nil
+}
+
+func (d payloadDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "http://bench/metrics", nil
+}
+
+// runBench runs the conversion pipeline over synthetic or recorded payloads
+// and reports its throughput and memory use, so performance can be compared
+// between plugin versions. It returns the process exit code.
+func runBench(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.Bool("bench", true, "benchmark the conversion pipeline and exit")
+	sizes := flags.String("bench-sizes", "10x10,100x10,100x100,1000x100", "comma separated synthetic payload sizes as <families>x<series>")
+	recording := flags.String("bench-recording", "", "recording_dir whose recorded scrapes are benchmarked instead of synthetic payloads")
+	corpus := flags.String("bench-corpus", "", "benchmark an exposition corpus instead of synthetic payloads: builtin, or a corpus directory as --selftest reads")
+	duration := flags.Duration("bench-time", 3*time.Second, "how long each payload is benchmarked")
+	configPath := flags.String("config", "", "JSON file holding the task config the pipeline runs with")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	flag.Set("logtostderr", "true")
+
+	config, err := loadDaemonConfig(*configPath)
+	if err != nil {
+		glog.Errorf("Unable to load config: %s", err.Error())
+		return 1
+	}
+	var payloads []benchPayload
+	switch {
+	case *recording != "":
+		payloads, err = recordedPayloads(*recording)
+	case *corpus != "":
+		payloads, err = corpusPayloads(*corpus)
+	default:
+		payloads, err = syntheticPayloads(*sizes)
+	}
+	if err != nil {
+		glog.Errorf("Unable to prepare payloads: %s", err.Error())
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PAYLOAD\tBYTES\tSAMPLES\tSCRAPES\tSAMPLES/SEC\tALLOCS/SCRAPE\tBYTES/SCRAPE\tPEAK RSS")
+	for _, payload := range payloads {
+		if err := benchPayloadRun(w, payload, config, *duration); err != nil {
+			glog.Errorf("Unable to benchmark %s: %s", payload.name, err.Error())
+			return 1
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+func benchPayloadRun(w io.Writer, payload benchPayload, config plugin.Config, duration time.Duration) error {
+	collector := prometheus.New().(*prometheus.PrometheusCollector)
+	collector.Downloader = payloadDownloader{payload.body}
+	mts := []plugin.Metric{{Config: config}}
+
+	// the first collection validates the config and warms caches up
+	metrics, err := collector.CollectMetrics(mts)
+	if err != nil {
+		return err
+	}
+	samples := len(metrics)
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	scrapes := 0
+	for time.Since(start) < duration {
+		if _, err := collector.CollectMetrics(mts); err != nil {
+			return err
+		}
+		scrapes++
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.0f\t%d\t%d\t%s\n",
+		payload.name,
+		len(payload.body),
+		samples,
+		scrapes,
+		float64(samples*scrapes)/elapsed.Seconds(),
+		(after.Mallocs-before.Mallocs)/uint64(scrapes),
+		(after.TotalAlloc-before.TotalAlloc)/uint64(scrapes),
+		formatBytes(peakRSS()))
+	return nil
+}
+
+func syntheticPayloads(sizes string) ([]benchPayload, error) {
+	var payloads []benchPayload
+	for _, size := range strings.Split(sizes, ",") {
+		size = strings.TrimSpace(size)
+		families, series, err := prometheus.ParseSyntheticSize(size)
+		if err != nil {
+			return nil, err
+		}
+		var body bytes.Buffer
+		if err := prometheus.WriteSynthetic(&body, families, series, 0); err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, benchPayload{name: size, body: body.Bytes()})
+	}
+	return payloads, nil
+}
+
+// corpusPayloads returns the payloads of the built-in corpus, or of a corpus
+// directory
+func corpusPayloads(corpus string) ([]benchPayload, error) {
+	if corpus == "builtin" {
+		corpus = ""
+	}
+	entries, err := loadCorpus(corpus)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]benchPayload, 0, len(entries))
+	for _, entry := range entries {
+		payloads = append(payloads, benchPayload{name: entry.Name(), body: entry.Body})
+	}
+	return payloads, nil
+}
+
+// recordedPayloads returns the first recorded scrape of every target of a
+// recording
+func recordedPayloads(dir string) ([]benchPayload, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.prom"))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var payloads []benchPayload
+	for _, file := range files {
+		target := filepath.Dir(file)
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(target)
+		if url, err := ioutil.ReadFile(filepath.Join(target, "url")); err == nil {
+			name = strings.TrimSpace(string(url))
+		}
+		payloads = append(payloads, benchPayload{name: name, body: body})
+	}
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("no recorded scrapes in %s", dir)
+	}
+	return payloads, nil
+}
+
+func formatBytes(n int64) string {
+	if n <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+}
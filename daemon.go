@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-collector-prometheus/emitter"
+	"github.com/jpra1113/snap-plugin-collector-prometheus/prometheus"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// runDaemon collects on its own schedule without snapteld, handing every
+// collection to an emitter. It returns the process exit code.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.Bool("daemon", true, "run standalone without snapteld")
+	configPath := flags.String("config", "", "JSON file holding the task config (same keys as the Snap task)")
+	interval := flags.Duration("interval", 10*time.Second, "collection interval")
+	once := flags.Bool("once", false, "collect a single time and exit")
+	emitterKind := flags.String("emitter", "stdout", "where to send metrics: stdout, influxdb, statsd or remote_write")
+	var opts emitter.Options
+	flags.StringVar(&opts.Address, "emitter-address", "", "URL or host:port of the emitter sink")
+	flags.StringVar(&opts.Database, "emitter-database", "", "InfluxDB database")
+	flags.StringVar(&opts.Prefix, "emitter-prefix", "", "statsd metric name prefix")
+	flags.BoolVar(&opts.Tags, "emitter-tags", false, "send DogStatsD style tags to statsd")
+	flags.IntVar(&opts.BatchSize, "emitter-batch-size", 500, "max samples per remote write request")
+	flags.IntVar(&opts.Retries, "emitter-retries", 3, "retries of a failed remote write request")
+	maxBatch := flags.Int("emitter-max-batch", 0, "max metrics per emitter write, 0 writes each collection at once")
+	bufferSize := flags.Int("emitter-buffer-size", 0, "metrics kept in memory while the emitter sink is unreachable, 0 disables buffering")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	flag.Set("logtostderr", "true")
+
+	config, err := loadDaemonConfig(*configPath)
+	if err != nil {
+		glog.Errorf("Unable to load config: %s", err.Error())
+		return 1
+	}
+
+	sink, err := emitter.New(*emitterKind, opts)
+	if err != nil {
+		glog.Errorf("Unable to create emitter: %s", err.Error())
+		return 1
+	}
+	sink = emitter.NewBuffered(emitter.NewChunked(sink, *maxBatch), *bufferSize)
+	defer sink.Close()
+
+	collector := prometheus.New(collectorOptions()...)
+	mts, err := collector.GetMetricTypes(config)
+	if err != nil {
+		glog.Errorf("Unable to get metric types: %s", err.Error())
+		return 1
+	}
+	for i := range mts {
+		mts[i].Config = config
+	}
+
+	collect := func() {
+		metrics, err := collector.CollectMetrics(mts)
+		if err != nil {
+			glog.Warningf("Unable to collect metrics: %s", err.Error())
+			return
+		}
+		if err := sink.Emit(metrics); err != nil {
+			glog.Warningf("Unable to emit %d metrics: %s", len(metrics), err.Error())
+		}
+	}
+
+	collect()
+	if *once {
+		return 0
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			collect()
+		case <-stop:
+			return 0
+		}
+	}
+}
+
+// loadDaemonConfig reads a flat JSON object into a plugin config on top of
+// the policy defaults
+func loadDaemonConfig(path string) (plugin.Config, error) {
+	if path == "" {
+		return prometheus.DefaultConfig(), nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return prometheus.ParseConfig(content)
+}
@@ -0,0 +1,65 @@
+package emitter
+
+import (
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// bufferedEmitter keeps metrics that could not be written in a bounded
+// in-memory buffer and writes them ahead of the next collection once the
+// sink is reachable again, so a brief sink restart doesn't leave a gap in
+// rate-sensitive counters. When the buffer is full the oldest metrics are
+// dropped. Only the metrics a failed write didn't get to are kept, and
+// metrics the sink refused for good are dropped.
+type bufferedEmitter struct {
+	Emitter
+	capacity int
+	pending  []plugin.Metric
+}
+
+// NewBuffered wraps e with a buffer holding up to capacity unwritten metrics
+func NewBuffered(e Emitter, capacity int) Emitter {
+	if capacity <= 0 {
+		return e
+	}
+	return &bufferedEmitter{
+		Emitter:  e,
+		capacity: capacity,
+	}
+}
+
+func (e *bufferedEmitter) Emit(metrics []plugin.Metric) error {
+	e.pending = append(e.pending, metrics...)
+	if overflow := len(e.pending) - e.capacity; overflow > 0 {
+		glog.Warningf("Emit buffer is full, dropping %d oldest metrics", overflow)
+		e.pending = append(e.pending[:0], e.pending[overflow:]...)
+	}
+
+	var rejectErr error
+	for len(e.pending) > 0 {
+		err := e.Emitter.Emit(e.pending)
+		if err == nil {
+			e.pending = e.pending[:0]
+			break
+		}
+
+		sent, rejected := emitProgress(err)
+		e.pending = append(e.pending[:0], e.pending[sent+rejected:]...)
+		if rejected == 0 {
+			glog.Warningf("Unable to emit, keeping %d metrics buffered: %s", len(e.pending), err.Error())
+			return err
+		}
+		glog.Warningf("Dropping %d metrics rejected by the sink: %s", rejected, err.Error())
+		rejectErr = err
+	}
+	return rejectErr
+}
+
+func (e *bufferedEmitter) Close() error {
+	if len(e.pending) > 0 {
+		if err := e.Emitter.Emit(e.pending); err != nil {
+			glog.Warningf("Dropping %d buffered metrics on close: %s", len(e.pending), err.Error())
+		}
+	}
+	return e.Emitter.Close()
+}
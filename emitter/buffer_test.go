@@ -0,0 +1,97 @@
+package emitter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type flakyEmitter struct {
+	down    bool
+	emitted []plugin.Metric
+}
+
+func (e *flakyEmitter) Emit(metrics []plugin.Metric) error {
+	if e.down {
+		return errors.New("sink is down")
+	}
+	e.emitted = append(e.emitted, metrics...)
+	return nil
+}
+
+func (e *flakyEmitter) Close() error {
+	return nil
+}
+
+// batchEmitter fails its failAt-th write with err
+type batchEmitter struct {
+	writes  int
+	failAt  int
+	err     error
+	emitted []plugin.Metric
+}
+
+func (e *batchEmitter) Emit(metrics []plugin.Metric) error {
+	e.writes++
+	if e.writes == e.failAt {
+		return e.err
+	}
+	e.emitted = append(e.emitted, metrics...)
+	return nil
+}
+
+func (e *batchEmitter) Close() error {
+	return nil
+}
+
+func TestBufferedEmitter(t *testing.T) {
+	Convey("Buffered emitter should hold metrics while the sink is down", t, func() {
+		sink := &flakyEmitter{down: true}
+		e := NewBuffered(sink, 5)
+		metrics := testMetrics()
+
+		So(e.Emit(metrics), ShouldNotBeNil)
+		So(e.Emit(metrics), ShouldNotBeNil)
+		So(len(sink.emitted), ShouldEqual, 0)
+
+		sink.down = false
+		So(e.Emit(metrics[:1]), ShouldBeNil)
+		Convey("So the oldest metrics should be dropped beyond capacity", func() {
+			So(len(sink.emitted), ShouldEqual, 5)
+			So(sink.emitted[0].Namespace, ShouldResemble, metrics[2].Namespace)
+			So(sink.emitted[4].Namespace, ShouldResemble, metrics[0].Namespace)
+		})
+
+		So(e.Emit(metrics[:1]), ShouldBeNil)
+		So(len(sink.emitted), ShouldEqual, 6)
+	})
+
+	Convey("Buffered emitter should only keep the chunks not written", t, func() {
+		sink := &batchEmitter{failAt: 2, err: errors.New("sink is down")}
+		e := NewBuffered(NewChunked(sink, 1), 10)
+		metrics := testMetrics()
+
+		So(e.Emit(metrics), ShouldNotBeNil)
+		So(e.Emit(nil), ShouldBeNil)
+		So(sink.emitted, ShouldResemble, metrics)
+	})
+
+	Convey("Buffered emitter should drop the chunks the sink rejects", t, func() {
+		sink := &batchEmitter{failAt: 2, err: &EmitError{Err: errors.New("out of order sample"), Rejected: 1}}
+		e := NewBuffered(NewChunked(sink, 1), 10)
+		metrics := testMetrics()
+
+		So(e.Emit(metrics), ShouldNotBeNil)
+		So(sink.emitted, ShouldResemble, []plugin.Metric{metrics[0], metrics[2]})
+		So(e.Emit(nil), ShouldBeNil)
+		So(len(sink.emitted), ShouldEqual, 2)
+	})
+
+	Convey("A zero capacity should not wrap the emitter", t, func() {
+		sink := &flakyEmitter{}
+		So(NewBuffered(sink, 0), ShouldEqual, sink)
+	})
+}
@@ -0,0 +1,41 @@
+package emitter
+
+import (
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// chunkedEmitter splits a collection into writes of at most size metrics,
+// so a collection of hundreds of thousands of metrics doesn't turn into a
+// single huge request body.
+type chunkedEmitter struct {
+	Emitter
+	size int
+}
+
+// NewChunked wraps e so every write carries at most size metrics
+func NewChunked(e Emitter, size int) Emitter {
+	if size <= 0 {
+		return e
+	}
+	return &chunkedEmitter{
+		Emitter: e,
+		size:    size,
+	}
+}
+
+func (e *chunkedEmitter) Emit(metrics []plugin.Metric) error {
+	for start := 0; start < len(metrics); start += e.size {
+		end := start + e.size
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		if err := e.Emitter.Emit(metrics[start:end]); err != nil {
+			sent, rejected := emitProgress(err)
+			if inner, ok := err.(*EmitError); ok {
+				err = inner.Err
+			}
+			return &EmitError{Err: err, Sent: start + sent, Rejected: rejected}
+		}
+	}
+	return nil
+}
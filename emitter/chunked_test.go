@@ -0,0 +1,31 @@
+package emitter
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type countingEmitter struct {
+	writes []int
+}
+
+func (e *countingEmitter) Emit(metrics []plugin.Metric) error {
+	e.writes = append(e.writes, len(metrics))
+	return nil
+}
+
+func (e *countingEmitter) Close() error {
+	return nil
+}
+
+func TestChunkedEmitter(t *testing.T) {
+	Convey("Chunked emitter should split large collections", t, func() {
+		sink := &countingEmitter{}
+		e := NewChunked(sink, 2)
+		So(e.Emit(append(testMetrics(), testMetrics()...)), ShouldBeNil)
+		So(sink.writes, ShouldResemble, []int{2, 2, 2})
+	})
+}
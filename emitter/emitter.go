@@ -0,0 +1,116 @@
+// Package emitter writes collected metrics to sinks other than Snap, so the
+// collector can run as a lightweight scraper when snapteld isn't present.
+package emitter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// Emitter writes converted metrics to a sink
+type Emitter interface {
+	Emit(metrics []plugin.Metric) error
+	Close() error
+}
+
+// EmitError reports how far a write got before failing, so a caller
+// holding on to unwritten metrics doesn't write the same metrics twice
+type EmitError struct {
+	Err error
+	// Sent is the number of leading metrics written, or skipped as
+	// unsupported by the sink, before the failure
+	Sent int
+	// Rejected is the number of metrics after those the sink refused for
+	// good, e.g. with a 4xx status; 0 when the failure is worth retrying
+	Rejected int
+}
+
+func (e *EmitError) Error() string {
+	return e.Err.Error()
+}
+
+// emitProgress returns the metrics written and rejected by a failed write,
+// none for errors that aren't an EmitError
+func emitProgress(err error) (sent int, rejected int) {
+	if e, ok := err.(*EmitError); ok {
+		return e.Sent, e.Rejected
+	}
+	return 0, 0
+}
+
+// Options configures an emitter. Not every field applies to every emitter.
+type Options struct {
+	// Address is the URL (influxdb) or host:port (statsd) of the sink
+	Address string
+	// Database is the InfluxDB database to write into
+	Database string
+	// Prefix is prepended to statsd metric names
+	Prefix string
+	// Tags enables DogStatsD style tags on statsd packets
+	Tags bool
+	// Timeout bounds a single write to the sink
+	Timeout time.Duration
+	// BatchSize caps the number of samples per remote write request
+	BatchSize int
+	// Retries is how many times a failed remote write is retried
+	Retries int
+}
+
+// New returns the emitter registered under kind
+func New(kind string, opts Options) (Emitter, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	switch kind {
+	case "stdout":
+		return newWriterEmitter(os.Stdout), nil
+	case "influxdb":
+		return newInfluxEmitter(opts)
+	case "statsd":
+		return newStatsdEmitter(opts)
+	case "remote_write":
+		return newRemoteWriteEmitter(opts)
+	default:
+		return nil, fmt.Errorf("Unknown emitter: %s", kind)
+	}
+}
+
+func metricName(metric plugin.Metric, sep string) string {
+	return strings.Join(metric.Namespace.Strings(), sep)
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toFloat(data interface{}) (float64, bool) {
+	switch v := data.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
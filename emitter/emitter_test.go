@@ -0,0 +1,128 @@
+package emitter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testMetrics() []plugin.Metric {
+	return []plugin.Metric{
+		{
+			Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"),
+			Timestamp: time.Unix(0, 1500000000000000000),
+			Tags:      map[string]string{"method": "get", "handler": "/api v1"},
+			Data:      21.0,
+		},
+		{
+			Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "go_goroutines"),
+			Data:      int64(437),
+		},
+		{
+			Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "not_numeric"),
+			Data:      "text",
+		},
+	}
+}
+
+func TestEmitters(t *testing.T) {
+	Convey("Influxdb emitter should write line protocol", t, func() {
+		var body []byte
+		var query string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = ioutil.ReadAll(r.Body)
+			query = r.URL.RawQuery
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		e, err := New("influxdb", Options{Address: server.URL, Database: "metrics"})
+		So(err, ShouldBeNil)
+		So(e.Emit(testMetrics()), ShouldBeNil)
+		So(query, ShouldContainSubstring, "db=metrics")
+		So(string(body), ShouldEqual,
+			`hyperpilot/prometheus/http_requests_total,handler=/api\ v1,method=get value=21 1500000000000000000`+"\n"+
+				"hyperpilot/prometheus/go_goroutines value=437\n")
+	})
+
+	Convey("Statsd emitter should send gauges", t, func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		e, err := New("statsd", Options{Address: conn.LocalAddr().String(), Prefix: "snap.", Tags: true})
+		So(err, ShouldBeNil)
+		defer e.Close()
+		So(e.Emit(testMetrics()), ShouldBeNil)
+
+		buf := make([]byte, maxPacketSize)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		So(err, ShouldBeNil)
+		lines := bytes.Split(buf[:n], []byte("\n"))
+		So(len(lines), ShouldEqual, 2)
+		So(string(lines[0]), ShouldEqual, "snap.hyperpilot.prometheus.http_requests_total:21|g|#handler:/api v1,method:get")
+		So(string(lines[1]), ShouldEqual, "snap.hyperpilot.prometheus.go_goroutines:437|g")
+	})
+
+	Convey("Influxdb emitter should skip non-finite values", t, func() {
+		buf := new(bytes.Buffer)
+		So(newWriterEmitter(buf).Emit([]plugin.Metric{
+			{Namespace: plugin.NewNamespace("a"), Data: math.NaN()},
+			{Namespace: plugin.NewNamespace("b"), Data: math.Inf(1)},
+			{Namespace: plugin.NewNamespace("c"), Data: 1.5},
+		}), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "c value=1.5\n")
+	})
+
+	Convey("Influxdb emitter should report points refused with a 4xx as rejected", t, func() {
+		status := http.StatusBadRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		e, err := New("influxdb", Options{Address: server.URL, Database: "metrics"})
+		So(err, ShouldBeNil)
+		sent, rejected := emitProgress(e.Emit(testMetrics()))
+		So(sent, ShouldEqual, 0)
+		So(rejected, ShouldEqual, 3)
+
+		status = http.StatusServiceUnavailable
+		err = e.Emit(testMetrics())
+		So(err, ShouldNotBeNil)
+		_, rejected = emitProgress(err)
+		So(rejected, ShouldEqual, 0)
+	})
+
+	Convey("Statsd emitter should reset gauges before negative values and skip NaN", t, func() {
+		e := &statsdEmitter{}
+		So(e.formatLine(plugin.Metric{Namespace: plugin.NewNamespace("temp"), Data: -3.5}), ShouldEqual, "temp:0|g\ntemp:-3.5|g")
+		So(e.formatLine(plugin.Metric{Namespace: plugin.NewNamespace("temp"), Data: math.NaN()}), ShouldEqual, "")
+		So(e.formatLine(plugin.Metric{Namespace: plugin.NewNamespace("temp"), Data: 2.0}), ShouldEqual, "temp:2|g")
+
+		e.tags = true
+		So(e.formatLine(plugin.Metric{Namespace: plugin.NewNamespace("temp"), Tags: map[string]string{"room": "a"}, Data: int64(-1)}),
+			ShouldEqual, "temp:0|g|#room:a\ntemp:-1|g|#room:a")
+	})
+
+	Convey("Writer emitter should print line protocol", t, func() {
+		buf := new(bytes.Buffer)
+		So(newWriterEmitter(buf).Emit(testMetrics()[1:]), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "hyperpilot/prometheus/go_goroutines value=437\n")
+	})
+
+	Convey("Unknown emitters should be rejected", t, func() {
+		_, err := New("carrier-pigeon", Options{})
+		So(err, ShouldNotBeNil)
+	})
+}
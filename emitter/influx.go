@@ -0,0 +1,125 @@
+package emitter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// influxEmitter writes metrics in InfluxDB line protocol over HTTP
+type influxEmitter struct {
+	writeURL string
+	client   *http.Client
+}
+
+func newInfluxEmitter(opts Options) (*influxEmitter, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("influxdb emitter requires an address")
+	}
+	if opts.Database == "" {
+		return nil, fmt.Errorf("influxdb emitter requires a database")
+	}
+
+	query := url.Values{}
+	query.Set("db", opts.Database)
+	query.Set("precision", "ns")
+	return &influxEmitter{
+		writeURL: strings.TrimRight(opts.Address, "/") + "/write?" + query.Encode(),
+		client:   &http.Client{Timeout: opts.Timeout},
+	}, nil
+}
+
+func (e *influxEmitter) Emit(metrics []plugin.Metric) error {
+	body := new(bytes.Buffer)
+	for _, metric := range metrics {
+		writeLine(body, metric)
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	resp, err := e.client.Post(e.writeURL, "text/plain; charset=utf-8", body)
+	if err != nil {
+		return fmt.Errorf("Unable to write to influxdb: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		err := fmt.Errorf("Unable to write to influxdb: status code: %d response: %s", resp.StatusCode, msg)
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			// influxdb refuses malformed points for good
+			return &EmitError{Err: err, Rejected: len(metrics)}
+		}
+		return err
+	}
+	return nil
+}
+
+func (e *influxEmitter) Close() error {
+	return nil
+}
+
+// writerEmitter prints metrics in line protocol, used to inspect what would
+// be forwarded
+type writerEmitter struct {
+	w io.Writer
+}
+
+func newWriterEmitter(w io.Writer) *writerEmitter {
+	return &writerEmitter{w: w}
+}
+
+func (e *writerEmitter) Emit(metrics []plugin.Metric) error {
+	buf := new(bytes.Buffer)
+	for _, metric := range metrics {
+		writeLine(buf, metric)
+	}
+	_, err := buf.WriteTo(e.w)
+	return err
+}
+
+func (e *writerEmitter) Close() error {
+	return nil
+}
+
+// writeLine appends a single line protocol point to buf. Metrics without a
+// numeric value are skipped, as are NaN and infinite values, which line
+// protocol can't represent.
+func writeLine(buf *bytes.Buffer, metric plugin.Metric) {
+	value, ok := toFloat(metric.Data)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	buf.WriteString(measurementEscaper.Replace(metricName(metric, "/")))
+	for _, key := range sortedTagKeys(metric.Tags) {
+		if metric.Tags[key] == "" {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(tagEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(tagEscaper.Replace(metric.Tags[key]))
+	}
+	buf.WriteString(" value=")
+	buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	if !metric.Timestamp.IsZero() {
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(metric.Timestamp.UnixNano(), 10))
+	}
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,213 @@
+package emitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/snappy"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	defaultBatchSize    = 500
+	initialRetryBackoff = 500 * time.Millisecond
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// remoteWriteEmitter pushes samples to a Prometheus remote_write receiver
+// (Cortex, Mimir, Thanos receive). Samples are batched per request and
+// retried with exponential backoff; nothing is kept once retries run out.
+type remoteWriteEmitter struct {
+	url       string
+	client    *http.Client
+	batchSize int
+	retries   int
+}
+
+func newRemoteWriteEmitter(opts Options) (*remoteWriteEmitter, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("remote_write emitter requires an address")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	return &remoteWriteEmitter{
+		url:       opts.Address,
+		client:    &http.Client{Timeout: opts.Timeout},
+		batchSize: opts.BatchSize,
+		retries:   opts.Retries,
+	}, nil
+}
+
+// Emit sends metrics batch by batch. A failed batch ends the write with an
+// EmitError, counting the batches before it as sent.
+func (e *remoteWriteEmitter) Emit(metrics []plugin.Metric) error {
+	batch := make([]plugin.Metric, 0, e.batchSize)
+	// start is the index in metrics of the first metric of batch
+	start := 0
+	for i, metric := range metrics {
+		if _, ok := toFloat(metric.Data); !ok {
+			continue
+		}
+		batch = append(batch, metric)
+		if len(batch) == e.batchSize {
+			if err := e.sendBatch(batch, start, i+1); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			start = i + 1
+		}
+	}
+	if len(batch) > 0 {
+		return e.sendBatch(batch, start, len(metrics))
+	}
+	return nil
+}
+
+// sendBatch sends the batch of metrics[start:end]
+func (e *remoteWriteEmitter) sendBatch(batch []plugin.Metric, start int, end int) error {
+	retryable, err := e.send(batch)
+	if err == nil {
+		return nil
+	}
+	rejected := 0
+	if !retryable {
+		rejected = end - start
+	}
+	return &EmitError{Err: err, Sent: start, Rejected: rejected}
+}
+
+// send posts batch, retrying with backoff, and reports whether the last
+// failure was worth retrying
+func (e *remoteWriteEmitter) send(batch []plugin.Metric) (bool, error) {
+	body := snappy.Encode(nil, encodeWriteRequest(batch))
+
+	backoff := initialRetryBackoff
+	var err error
+	for attempt := 0; attempt <= e.retries; attempt++ {
+		if attempt > 0 {
+			glog.Warningf("Retrying remote write in %s: %s", backoff, err.Error())
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retryable bool
+		retryable, err = e.post(body)
+		if err == nil || !retryable {
+			return retryable, err
+		}
+	}
+	return true, err
+}
+
+// post sends a single request, reporting whether a failure is worth retrying
+func (e *remoteWriteEmitter) post(body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("Unable to remote write: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("Unable to remote write: status code: %d response: %s", resp.StatusCode, msg)
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+func (e *remoteWriteEmitter) Close() error {
+	return nil
+}
+
+// encodeWriteRequest hand encodes a prometheus.WriteRequest protobuf:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(metrics []plugin.Metric) []byte {
+	var request, series, label, sample []byte
+	for _, metric := range metrics {
+		value, _ := toFloat(metric.Data)
+		timestamp := metric.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
+		series = series[:0]
+		labels := seriesLabels(metric)
+		for _, name := range sortedTagKeys(labels) {
+			label = appendString(label[:0], 1, name)
+			label = appendString(label, 2, labels[name])
+			series = appendBytes(series, 1, label)
+		}
+
+		sample = appendTag(sample[:0], 1, 1)
+		sample = appendFixed64(sample, math.Float64bits(value))
+		sample = appendTag(sample, 2, 0)
+		sample = appendVarint(sample, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+		series = appendBytes(series, 2, sample)
+
+		request = appendBytes(request, 1, series)
+	}
+	return request
+}
+
+// seriesLabels maps a metric to remote write labels. The namespace becomes
+// __name__, and labels must be sorted by name on the wire.
+func seriesLabels(metric plugin.Metric) map[string]string {
+	labels := make(map[string]string, len(metric.Tags)+1)
+	for key, value := range metric.Tags {
+		if value != "" {
+			labels[invalidNameChars.ReplaceAllString(key, "_")] = value
+		}
+	}
+	labels["__name__"] = invalidNameChars.ReplaceAllString(metricName(metric, "_"), "_")
+	return labels
+}
+
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return appendVarint(buf, uint64(field<<3|wireType))
+}
+
+func appendBytes(buf []byte, field int, value []byte) []byte {
+	buf = appendTag(buf, field, 2)
+	buf = appendVarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendString(buf []byte, field int, value string) []byte {
+	buf = appendTag(buf, field, 2)
+	buf = appendVarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendVarint(buf []byte, value uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], value)
+	return append(buf, b[:n]...)
+}
+
+func appendFixed64(buf []byte, value uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], value)
+	return append(buf, b[:]...)
+}
@@ -0,0 +1,71 @@
+package emitter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRemoteWriteEmitter(t *testing.T) {
+	Convey("Remote write emitter should push snappy compressed protobuf", t, func() {
+		requests := 0
+		var body []byte
+		var headers http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			headers = r.Header
+			compressed, _ := ioutil.ReadAll(r.Body)
+			body, _ = snappy.Decode(nil, compressed)
+		}))
+		defer server.Close()
+
+		e, err := New("remote_write", Options{Address: server.URL, Retries: 1, BatchSize: 10})
+		So(err, ShouldBeNil)
+		So(e.Emit(testMetrics()[:1]), ShouldBeNil)
+		So(requests, ShouldEqual, 2)
+		So(headers.Get("Content-Encoding"), ShouldEqual, "snappy")
+		So(body, ShouldResemble, encodeWriteRequest(testMetrics()[:1]))
+		So(string(body), ShouldContainSubstring, "hyperpilot_prometheus_http_requests_total")
+	})
+
+	Convey("Remote write emitter should not retry client errors", t, func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		e, err := New("remote_write", Options{Address: server.URL, Retries: 3, Timeout: time.Second})
+		So(err, ShouldBeNil)
+		err = e.Emit(testMetrics())
+		So(err, ShouldNotBeNil)
+		So(requests, ShouldEqual, 1)
+		So(err.(*EmitError).Rejected, ShouldEqual, len(testMetrics()))
+	})
+
+	Convey("Write requests should be encoded as protobuf", t, func() {
+		metrics := testMetrics()[1:2]
+		metrics[0].Timestamp = time.Unix(1, 0)
+		So(encodeWriteRequest(metrics), ShouldResemble, []byte{
+			0x0a, 0x3f, // timeseries, 63 bytes
+			0x0a, 0x2f, // label, 47 bytes
+			0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_',
+			0x12, 0x23, 'h', 'y', 'p', 'e', 'r', 'p', 'i', 'l', 'o', 't', '_', 'p', 'r', 'o', 'm', 'e', 't', 'h', 'e', 'u', 's', '_',
+			'g', 'o', '_', 'g', 'o', 'r', 'o', 'u', 't', 'i', 'n', 'e', 's',
+			0x12, 0x0c, // sample, 12 bytes
+			0x09, 0, 0, 0, 0, 0, 0x50, 0x7b, 0x40, // 437.0
+			0x10, 0xe8, 0x07, // 1000ms
+		})
+	})
+}
@@ -0,0 +1,102 @@
+package emitter
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// maxPacketSize keeps statsd datagrams below common network MTUs
+const maxPacketSize = 1432
+
+var statsdEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", "\n", "_")
+
+// statsdEmitter sends every metric as a statsd gauge over UDP
+type statsdEmitter struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+func newStatsdEmitter(opts Options) (*statsdEmitter, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("statsd emitter requires an address")
+	}
+
+	conn, err := net.DialTimeout("udp", opts.Address, opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to statsd: " + err.Error())
+	}
+	return &statsdEmitter{
+		conn:   conn,
+		prefix: opts.Prefix,
+		tags:   opts.Tags,
+	}, nil
+}
+
+func (e *statsdEmitter) Emit(metrics []plugin.Metric) error {
+	packet := new(bytes.Buffer)
+	for _, metric := range metrics {
+		line := e.formatLine(metric)
+		if line == "" {
+			continue
+		}
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > maxPacketSize {
+			if err := e.flush(packet); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return e.flush(packet)
+}
+
+func (e *statsdEmitter) flush(packet *bytes.Buffer) error {
+	if packet.Len() == 0 {
+		return nil
+	}
+	defer packet.Reset()
+	if _, err := e.conn.Write(packet.Bytes()); err != nil {
+		return fmt.Errorf("Unable to write to statsd: " + err.Error())
+	}
+	return nil
+}
+
+// formatLine returns the gauge of metric, empty for metrics without a
+// finite value. statsd reads a signed gauge as a change of the current
+// value, so negative values are sent after resetting the gauge to 0, in the
+// same packet.
+func (e *statsdEmitter) formatLine(metric plugin.Metric) string {
+	value, ok := toFloat(metric.Data)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+
+	name := statsdEscaper.Replace(e.prefix + metricName(metric, "."))
+	suffix := ""
+	if e.tags && len(metric.Tags) > 0 {
+		tags := make([]string, 0, len(metric.Tags))
+		for _, key := range sortedTagKeys(metric.Tags) {
+			tags = append(tags, statsdEscaper.Replace(key)+":"+statsdEscaper.Replace(metric.Tags[key]))
+		}
+		suffix = "|#" + strings.Join(tags, ",")
+	}
+
+	line := name + ":" + strconv.FormatFloat(value, 'g', -1, 64) + "|g" + suffix
+	if value < 0 {
+		return name + ":0|g" + suffix + "\n" + line
+	}
+	return line
+}
+
+func (e *statsdEmitter) Close() error {
+	return e.conn.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-collector-prometheus/prometheus"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+func main() {
+	if hasFlag(os.Args[1:], "print-config-schema") {
+		os.Exit(printConfigSchema(os.Args[1:]))
+	}
+	if hasFlag(os.Args[1:], "daemon") {
+		os.Exit(runDaemon(os.Args[1:]))
+	}
+	if hasFlag(os.Args[1:], "bench") {
+		os.Exit(runBench(os.Args[1:]))
+	}
+	if hasFlag(os.Args[1:], "selftest") {
+		os.Exit(runSelftest(os.Args[1:]))
+	}
+	if hasFlag(os.Args[1:], "serve-synthetic") {
+		os.Exit(serveSynthetic(os.Args[1:]))
+	}
+
+	plugin.StartCollector(prometheus.New(collectorOptions()...), prometheus.PluginName, prometheus.PluginVersion)
+}
+
+// collectorOptions returns the options of the collector, set by the
+// operator through the environment so tasks can't override them.
+// SNAP_PROMETHEUS_SAFE_MODE runs every task in safe mode and
+// SNAP_PROMETHEUS_DENIED_CIDRS refuses networks to every task.
+func collectorOptions() []prometheus.Option {
+	var opts []prometheus.Option
+	if safe, _ := strconv.ParseBool(os.Getenv("SNAP_PROMETHEUS_SAFE_MODE")); safe {
+		opts = append(opts, prometheus.WithSafeMode())
+	}
+	if denied := os.Getenv("SNAP_PROMETHEUS_DENIED_CIDRS"); denied != "" {
+		opts = append(opts, prometheus.WithDeniedCIDRs(denied))
+	}
+	return opts
+}
+
+// hasFlag reports whether the flag name is set in args, --name=false
+// leaving a boolean flag unset. Snap passes its own arguments to the plugin,
+// so standalone modes are detected before handing over to the plugin
+// library.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == name {
+			return true
+		}
+		if value := strings.TrimPrefix(arg, name+"="); value != arg {
+			// Flags taking a value, such as serve-synthetic, are set
+			// whatever it is
+			set, err := strconv.ParseBool(value)
+			return set || err != nil
+		}
+	}
+	return false
+}
+
+// printConfigSchema documents every task config key and returns the
+// process exit code
+func printConfigSchema(args []string) int {
+	flags := flag.NewFlagSet("print-config-schema", flag.ContinueOnError)
+	flags.Bool("print-config-schema", true, "print the task config schema and exit")
+	format := flags.String("schema-format", "json", "schema format: json (JSON schema) or text")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := prometheus.PrintConfigSchema(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// staleIntervals is how many of its collection intervals a task config goes
+// uncollected before the state kept for it is dropped, its task having been
+// removed or reconfigured
+const staleIntervals = 10
+
+// minStaleInterval is the interval assumed for configs collected only once,
+// or more often than this
+const minStaleInterval = time.Minute
+
+// configActivity is when a task config was last collected, and the time
+// between its last two collections
+type configActivity struct {
+	collected time.Time
+	interval  time.Duration
+}
+
+// activityTracker tracks the collections of every task config, so the
+// state kept per config doesn't outlive the tasks using it
+type activityTracker struct {
+	mutex   sync.Mutex
+	configs map[string]*configActivity
+}
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{
+		configs: make(map[string]*configActivity),
+	}
+}
+
+// collected records a collection of config and returns the fingerprints of
+// the configs that went staleIntervals of their interval without one, which
+// are forgotten
+func (t *activityTracker) collected(config plugin.Config, currentTime time.Time) []string {
+	if t == nil {
+		return nil
+	}
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	activity, ok := t.configs[key]
+	if !ok {
+		activity = &configActivity{}
+		t.configs[key] = activity
+	} else {
+		activity.interval = currentTime.Sub(activity.collected)
+	}
+	activity.collected = currentTime
+
+	var stale []string
+	for key, activity := range t.configs {
+		interval := activity.interval
+		if interval < minStaleInterval {
+			interval = minStaleInterval
+		}
+		if currentTime.Sub(activity.collected) > staleIntervals*interval {
+			stale = append(stale, key)
+			delete(t.configs, key)
+		}
+	}
+	return stale
+}
+
+// forgetStale records a collection of config and drops the state kept for
+// the task configs no longer collected
+func (c *PrometheusCollector) forgetStale(config plugin.Config, currentTime time.Time) {
+	for _, key := range c.activity.collected(config, currentTime) {
+		c.families.forget(key)
+		c.tombstones.forget(key)
+		c.counterRates.forget(key)
+	}
+}
+
+// targetURLs returns the set of the URLs of targets
+func targetURLs(targets []target) map[string]bool {
+	urls := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		urls[t.URL] = true
+	}
+	return urls
+}
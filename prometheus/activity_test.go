@@ -0,0 +1,41 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestActivityTracker(t *testing.T) {
+	Convey("Configs should go stale after staleIntervals of their interval", t, func() {
+		tracker := newActivityTracker()
+		fast := plugin.Config{"job": "fast"}
+		slow := plugin.Config{"job": "slow"}
+		start := time.Now()
+
+		So(tracker.collected(slow, start), ShouldBeEmpty)
+		So(tracker.collected(slow, start.Add(time.Hour)), ShouldBeEmpty)
+		So(tracker.collected(fast, start.Add(time.Hour)), ShouldBeEmpty)
+		So(tracker.collected(fast, start.Add(time.Hour+time.Minute)), ShouldBeEmpty)
+
+		// fast is collected every minute, slow every hour
+		So(tracker.collected(slow, start.Add(time.Hour+time.Minute+staleIntervals*time.Minute+time.Second)), ShouldResemble, []string{configFingerprint(fast)})
+		So(tracker.collected(slow, start.Add(3*time.Hour)), ShouldBeEmpty)
+	})
+
+	Convey("State of stale configs should be dropped", t, func() {
+		c := New().(*PrometheusCollector)
+		config := plugin.Config{"emit_tombstones": true, "counter_rates": "rate"}
+		start := time.Now()
+		c.forgetStale(config, start)
+		c.tombstones.series[configFingerprint(config)] = map[string]map[string]plugin.Metric{}
+		c.counterRates.counters[configFingerprint(config)] = map[string]map[string]counterSample{}
+
+		c.forgetStale(plugin.Config{}, start.Add(staleIntervals*minStaleInterval+time.Second))
+		So(c.tombstones.series, ShouldBeEmpty)
+		So(c.counterRates.counters, ShouldBeEmpty)
+	})
+}
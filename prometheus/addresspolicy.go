@@ -0,0 +1,172 @@
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// addressPolicy restricts the addresses scrapes connect to. Denied networks
+// win over allowed networks; any address not denied is allowed when there
+// are no allowed networks.
+type addressPolicy struct {
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+}
+
+// WithDeniedCIDRs refuses connections to the comma separated networks in
+// every task, on top of the task's own denied_cidrs, which can't allow them
+// back
+func WithDeniedCIDRs(cidrs string) Option {
+	return func(c *PrometheusCollector) {
+		c.deniedCIDRs = cidrs
+	}
+}
+
+// parseCIDRs parses a comma separated list of CIDRs, single addresses
+// standing for themselves
+func parseCIDRs(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range splitList(value) {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR %q", item)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func newAddressPolicy(allowed string, denied string) (addressPolicy, error) {
+	var policy addressPolicy
+	var err error
+	if policy.allowed, err = parseCIDRs(allowed); err != nil {
+		return policy, err
+	}
+	if policy.denied, err = parseCIDRs(denied); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// addressPolicies returns the policies a task dials under: its allowed and
+// denied networks, and only private networks in safe mode
+func addressPolicies(allowed string, denied string, safeMode bool) ([]addressPolicy, error) {
+	policy, err := newAddressPolicy(allowed, denied)
+	if err != nil {
+		return nil, err
+	}
+	policies := []addressPolicy{policy}
+	if safeMode {
+		policies = append(policies, addressPolicy{allowed: privateNetworks})
+	}
+	return policies, nil
+}
+
+func (p addressPolicy) check(ip net.IP) error {
+	if containsIP(p.denied, ip) {
+		return fmt.Errorf("Refusing to connect to denied address %s", ip)
+	}
+	if len(p.allowed) > 0 && !containsIP(p.allowed, ip) {
+		return fmt.Errorf("Refusing to connect to %s outside the allowed networks", ip)
+	}
+	return nil
+}
+
+// addressControl returns a dialer Control hook enforcing policies. It runs
+// after DNS resolution, so host names resolving to refused addresses are
+// refused too.
+func addressControl(policies ...addressPolicy) func(network string, address string, c syscall.RawConn) error {
+	return func(network string, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("Refusing to connect to unresolved address %s", host)
+		}
+		for _, policy := range policies {
+			if err := policy.check(ip); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checkHost checks every address host resolves to against policies
+func checkHost(req *http.Request, host string, policies []addressPolicy) error {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(req.Context(), host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		for _, policy := range policies {
+			if err := policy.check(ip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// policyProxy wraps the proxy function of a transport to check the target
+// of proxied requests against policies. The dialer only sees the proxy's
+// address, so without it a proxy would reach any target.
+func policyProxy(proxy func(*http.Request) (*url.URL, error), policies []addressPolicy) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		if err := checkHost(req, req.URL.Hostname(), policies); err != nil {
+			return nil, err
+		}
+		return proxyURL, nil
+	}
+}
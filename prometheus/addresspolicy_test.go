@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAddressPolicy(t *testing.T) {
+	Convey("Address policies should check denied then allowed networks", t, func() {
+		policy, err := newAddressPolicy("10.0.0.0/8, 192.168.1.10", "10.0.5.0/24")
+		So(err, ShouldBeNil)
+		So(policy.check(net.ParseIP("10.1.2.3")), ShouldBeNil)
+		So(policy.check(net.ParseIP("192.168.1.10")), ShouldBeNil)
+		So(policy.check(net.ParseIP("192.168.1.11")), ShouldNotBeNil)
+		So(policy.check(net.ParseIP("10.0.5.1")), ShouldNotBeNil)
+
+		policy, err = newAddressPolicy("", optionDefault("denied_cidrs").(string))
+		So(err, ShouldBeNil)
+		So(policy.check(net.ParseIP("169.254.169.254")), ShouldNotBeNil)
+		So(policy.check(net.ParseIP("fe80::1")), ShouldNotBeNil)
+		So(policy.check(net.ParseIP("8.8.8.8")), ShouldBeNil)
+	})
+
+	Convey("Address policies should be enforced when scraping", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "up 1\n")
+		}))
+		defer server.Close()
+
+		downloader := NewHTTPMetricsDownloader()
+		_, err := downloader.GetMetricsReader(server.URL, plugin.Config{"denied_cidrs": "127.0.0.0/8"})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader(server.URL, plugin.Config{"allowed_cidrs": "10.0.0.0/8"})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader(server.URL, plugin.Config{"allowed_cidrs": "127.0.0.1"})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Address policies should check the target of proxied requests", t, func() {
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "up 1\n")
+		}))
+		defer proxy.Close()
+
+		downloader := NewHTTPMetricsDownloader()
+		_, err := downloader.GetMetricsReader("http://169.254.169.254/metrics", plugin.Config{"proxy_url": proxy.URL})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader("http://10.0.0.1/metrics", plugin.Config{"proxy_url": proxy.URL, "denied_cidrs": "10.0.0.0/8"})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader("http://10.0.0.1/metrics", plugin.Config{"proxy_url": proxy.URL})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Networks denied by the plugin should stay denied whatever the task says", t, func() {
+		c := New(WithDeniedCIDRs("10.0.0.0/8")).(*PrometheusCollector)
+		config := c.enforce(plugin.Config{"denied_cidrs": "", "allowed_cidrs": "10.0.0.0/8"})
+		policy, err := newAddressPolicy(getStringConfig(config, "allowed_cidrs"), getStringConfig(config, "denied_cidrs"))
+		So(err, ShouldBeNil)
+		So(policy.check(net.ParseIP("10.1.2.3")), ShouldNotBeNil)
+
+		config = c.enforce(plugin.Config{})
+		policy, err = newAddressPolicy("", getStringConfig(config, "denied_cidrs"))
+		So(err, ShouldBeNil)
+		So(policy.check(net.ParseIP("10.1.2.3")), ShouldNotBeNil)
+		So(policy.check(net.ParseIP("169.254.169.254")), ShouldNotBeNil)
+	})
+
+	Convey("Invalid address policies should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"allowed_cidrs": "10.0.0.0/33"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"denied_cidrs": "metadata.google.internal"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,306 @@
+package prometheus
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// pauseRegistry holds the jobs and targets operators paused. It is keyed by
+// job name and target address rather than by task config, so pauses survive
+// config reloads.
+type pauseRegistry struct {
+	mutex   sync.Mutex
+	jobs    map[string]bool
+	targets map[string]bool
+}
+
+func newPauseRegistry() *pauseRegistry {
+	return &pauseRegistry{
+		jobs:    make(map[string]bool),
+		targets: make(map[string]bool),
+	}
+}
+
+func (r *pauseRegistry) set(kind, name string, paused bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	names := r.jobs
+	if kind == "target" {
+		names = r.targets
+	}
+	if paused {
+		names[name] = true
+	} else {
+		delete(names, name)
+	}
+}
+
+func (r *pauseRegistry) jobPaused(config plugin.Config) bool {
+	if r == nil {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.jobs[getStringConfig(config, "job")]
+}
+
+// activeTargets returns targets without the paused ones. Targets are paused
+// by their URL or their address.
+func (r *pauseRegistry) activeTargets(targets []target) []target {
+	if r == nil {
+		return targets
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.targets) == 0 {
+		return targets
+	}
+	active := make([]target, 0, len(targets))
+	for _, t := range targets {
+		if r.targets[t.URL] || r.targets[targetAddress(t)] {
+			continue
+		}
+		active = append(active, t)
+	}
+	return active
+}
+
+func (r *pauseRegistry) paused() map[string][]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	state := map[string][]string{"jobs": {}, "targets": {}}
+	for job := range r.jobs {
+		state["jobs"] = append(state["jobs"], job)
+	}
+	for t := range r.targets {
+		state["targets"] = append(state["targets"], t)
+	}
+	sort.Strings(state["jobs"])
+	sort.Strings(state["targets"])
+	return state
+}
+
+// adminServer serves the admin endpoint of the plugin, pausing and resuming
+// jobs and targets, changing the log verbosity and writing debug dumps. It is
+// started by the first task configuring admin_listen and shared by all tasks.
+type adminServer struct {
+	mutex   sync.Mutex
+	listen  string
+	dumpDir string
+	// token is the bearer token requests must carry, none when empty
+	token string
+	// auditLog is the audit log requests are recorded to, none when empty
+	auditLog string
+	handler  http.Handler
+	registry *pauseRegistry
+	// dump writes a debug dump to a new file in a directory and returns its
+	// path
+	dump func(dir string) (string, error)
+}
+
+func newAdminServer(registry *pauseRegistry, dump func(dir string) (string, error)) *adminServer {
+	s := &adminServer{
+		registry: registry,
+		dump:     dump,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pause", s.pauseHandler(true))
+	mux.HandleFunc("/resume", s.pauseHandler(false))
+	mux.HandleFunc("/paused", s.pausedHandler)
+	mux.HandleFunc("/loglevel", s.logLevelHandler)
+	mux.HandleFunc("/debug-dump", s.debugDumpHandler)
+	s.handler = s.authorize(mux)
+	return s
+}
+
+// authorize refuses requests without the admin token when one is set. It
+// also refuses requests sent by browsers, which carry an Origin header, so
+// a page open on the host can't drive the endpoint.
+func (s *adminServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		s.mutex.Lock()
+		token := s.token
+		s.mutex.Unlock()
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// audit appends the admin request r did to the audit log of the task that
+// started the server, if any
+func (s *adminServer) audit(r *http.Request, entry auditEvent) {
+	s.mutex.Lock()
+	path := s.auditLog
+	s.mutex.Unlock()
+	if path == "" {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.Remote = r.RemoteAddr
+	writeAuditEvent(path, entry)
+}
+
+// isLoopbackAddress tells whether the host of a listen address only accepts
+// connections from the local host
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ensureStarted starts listening on the task's admin_listen address unless
+// the server already runs
+func (s *adminServer) ensureStarted(config plugin.Config) error {
+	address := getStringConfig(config, "admin_listen")
+	if s == nil || address == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.listen != "" {
+		if s.listen != address {
+			glog.Warningf("Admin endpoint already listening on %s, ignoring admin_listen %s", s.listen, address)
+		}
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("Unable to start admin endpoint: " + err.Error())
+	}
+	s.listen = address
+	s.dumpDir = getStringConfig(config, "admin_dump_dir")
+	s.token = getStringConfig(config, "admin_token")
+	s.auditLog = getStringConfig(config, "audit_log")
+	glog.Infof("Admin endpoint listening on %s", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, s.handler); err != nil {
+			glog.Errorf("Admin endpoint stopped: %s", err.Error())
+		}
+	}()
+	return nil
+}
+
+// pauseHandler pauses or resumes the job or target given as query parameter,
+// e.g. POST /pause?job=api or POST /resume?target=10.0.0.1:9100
+func (s *adminServer) pauseHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		job, t := r.URL.Query().Get("job"), r.URL.Query().Get("target")
+		action := "resumed"
+		if paused {
+			action = "paused"
+		}
+		switch {
+		case job != "" && t == "":
+			s.registry.set("job", job, paused)
+			s.audit(r, auditEvent{Event: "job_" + action, Job: job})
+		case t != "" && job == "":
+			s.registry.set("target", t, paused)
+			s.audit(r, auditEvent{Event: "target_" + action, Targets: []string{t}})
+		default:
+			http.Error(w, "exactly one of job or target required", http.StatusBadRequest)
+			return
+		}
+		glog.Infof("Admin request %s job=%q target=%q", r.URL.Path, job, t)
+		s.pausedHandler(w, r)
+	}
+}
+
+func (s *adminServer) pausedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.registry.paused())
+}
+
+// logLevelHandler reports the glog verbosity, or changes it with
+// POST /loglevel?v=2, so more detail can be logged while investigating
+// without restarting the plugin
+func (s *adminServer) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	verbosity := flag.Lookup("v")
+	if verbosity == nil {
+		http.Error(w, "log verbosity flag not registered", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == "POST" {
+		level := r.URL.Query().Get("v")
+		if _, err := strconv.ParseUint(level, 10, 8); err != nil {
+			http.Error(w, "v must be a verbosity level, e.g. 2", http.StatusBadRequest)
+			return
+		}
+		if err := verbosity.Value.Set(level); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		glog.Infof("Admin request set log verbosity to %s", level)
+		s.audit(r, auditEvent{Event: "loglevel_changed", Detail: level})
+	}
+	fmt.Fprintf(w, "%s\n", verbosity.Value.String())
+}
+
+// debugDumpHandler writes a debug dump to admin_dump_dir on POST and
+// responds with its path
+func (s *adminServer) debugDumpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mutex.Lock()
+	dir := s.dumpDir
+	s.mutex.Unlock()
+	path, err := s.dump(dir)
+	if err != nil {
+		http.Error(w, "Unable to write debug dump: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("Admin request wrote debug dump to %s", path)
+	s.audit(r, auditEvent{Event: "debug_dump_written", Detail: path})
+	fmt.Fprintf(w, "%s\n", path)
+}
+
+func checkAdmin(config plugin.Config) ([]string, error) {
+	address := getStringConfig(config, "admin_listen")
+	if address == "" {
+		return nil, nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("Invalid admin_listen: " + err.Error())
+	}
+	if getBoolConfig(config, "safe_mode") {
+		return nil, fmt.Errorf("admin_listen is not allowed in safe mode")
+	}
+	if isLoopbackAddress(address) {
+		return nil, nil
+	}
+	if getStringConfig(config, "admin_token") == "" {
+		return nil, fmt.Errorf("admin_listen %s is reachable from other hosts, set admin_token or listen on a loopback address", address)
+	}
+	return []string{fmt.Sprintf("admin endpoint on %s is reachable from other hosts over plain HTTP, guarded by admin_token only", address)}, nil
+}
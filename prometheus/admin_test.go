@@ -0,0 +1,142 @@
+package prometheus
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdminServer(t *testing.T) {
+	Convey("Jobs and targets should be paused and resumed through the admin endpoint", t, func() {
+		registry := newPauseRegistry()
+		server := httptest.NewServer(newAdminServer(registry, nil).handler)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/pause?job=api", "", nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(registry.jobPaused(plugin.Config{"job": "api"}), ShouldBeTrue)
+		So(registry.jobPaused(plugin.Config{"job": "db"}), ShouldBeFalse)
+
+		resp, _ = http.Post(server.URL+"/pause?target=10.0.0.1:9100", "", nil)
+		resp.Body.Close()
+		targets := registry.activeTargets([]target{
+			{URL: "http://10.0.0.1:9100/metrics"},
+			{URL: "http://10.0.0.2:9100/metrics"},
+		})
+		So(len(targets), ShouldEqual, 1)
+		So(targets[0].URL, ShouldEqual, "http://10.0.0.2:9100/metrics")
+
+		resp, _ = http.Post(server.URL+"/resume?job=api", "", nil)
+		resp.Body.Close()
+		So(registry.jobPaused(plugin.Config{"job": "api"}), ShouldBeFalse)
+	})
+
+	Convey("Admin requests should be validated", t, func() {
+		server := httptest.NewServer(newAdminServer(newPauseRegistry(), nil).handler)
+		defer server.Close()
+
+		resp, _ := http.Get(server.URL + "/pause?job=api")
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+		resp, _ = http.Post(server.URL+"/pause", "", nil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("The log verbosity should be changed at runtime", t, func() {
+		verbosity := flag.Lookup("v")
+		previous := verbosity.Value.String()
+		defer verbosity.Value.Set(previous)
+
+		server := httptest.NewServer(newAdminServer(newPauseRegistry(), nil).handler)
+		defer server.Close()
+		resp, err := http.Post(server.URL+"/loglevel?v=3", "", nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(verbosity.Value.String(), ShouldEqual, "3")
+
+		resp, _ = http.Post(server.URL+"/loglevel?v=loud", "", nil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("Debug dumps should be written on request", t, func() {
+		dumped := ""
+		dump := func(dir string) (string, error) {
+			dumped = "dump.txt"
+			return dumped, nil
+		}
+		server := httptest.NewServer(newAdminServer(newPauseRegistry(), dump).handler)
+		defer server.Close()
+		resp, err := http.Post(server.URL+"/debug-dump", "", nil)
+		So(err, ShouldBeNil)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		So(string(body), ShouldEqual, "dump.txt\n")
+	})
+
+	Convey("Admin requests should carry the admin token when one is set", t, func() {
+		admin := newAdminServer(newPauseRegistry(), nil)
+		admin.token = "s3cret"
+		server := httptest.NewServer(admin.handler)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/pause?job=api", "", nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		So(admin.registry.jobPaused(plugin.Config{"job": "api"}), ShouldBeFalse)
+
+		req, _ := http.NewRequest("POST", server.URL+"/pause?job=api", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		So(admin.registry.jobPaused(plugin.Config{"job": "api"}), ShouldBeTrue)
+	})
+
+	Convey("Admin requests from browsers should be refused", t, func() {
+		registry := newPauseRegistry()
+		server := httptest.NewServer(newAdminServer(registry, nil).handler)
+		defer server.Close()
+
+		req, _ := http.NewRequest("POST", server.URL+"/pause?job=api", nil)
+		req.Header.Set("Origin", "http://evil.example")
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		So(registry.jobPaused(plugin.Config{"job": "api"}), ShouldBeFalse)
+	})
+
+	Convey("Non-loopback admin_listen should require admin_token", t, func() {
+		_, err := checkAdmin(plugin.Config{"admin_listen": ":9998"})
+		So(err, ShouldNotBeNil)
+		_, err = checkAdmin(plugin.Config{"admin_listen": "10.0.0.5:9998"})
+		So(err, ShouldNotBeNil)
+		warnings, err := checkAdmin(plugin.Config{"admin_listen": "10.0.0.5:9998", "admin_token": "s3cret"})
+		So(err, ShouldBeNil)
+		So(warnings, ShouldHaveLength, 1)
+		warnings, err = checkAdmin(plugin.Config{"admin_listen": "localhost:9998"})
+		So(err, ShouldBeNil)
+		So(warnings, ShouldBeEmpty)
+	})
+
+	Convey("admin_listen should be refused in safe mode", t, func() {
+		_, err := checkAdmin(plugin.Config{"admin_listen": "127.0.0.1:9998", "safe_mode": true})
+		So(err, ShouldNotBeNil)
+		_, err = checkAdmin(plugin.Config{"admin_listen": "9998"})
+		So(err, ShouldNotBeNil)
+		_, err = checkAdmin(plugin.Config{"admin_listen": "127.0.0.1:9998"})
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,158 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// alertRule compares every selected series against a threshold. A series
+// meeting the comparison for at least For is firing.
+type alertRule struct {
+	Name string `json:"name"`
+	seriesSelector
+	Op          string  `json:"op"`
+	Threshold   float64 `json:"threshold"`
+	For         string  `json:"for"`
+	forDuration time.Duration
+}
+
+var alertOps = map[string]func(value float64, threshold float64) bool{
+	">":  func(value float64, threshold float64) bool { return value > threshold },
+	">=": func(value float64, threshold float64) bool { return value >= threshold },
+	"<":  func(value float64, threshold float64) bool { return value < threshold },
+	"<=": func(value float64, threshold float64) bool { return value <= threshold },
+	"==": func(value float64, threshold float64) bool { return value == threshold },
+	"!=": func(value float64, threshold float64) bool { return value != threshold },
+}
+
+func compileAlertRules(spec string) ([]alertRule, error) {
+	var rules []alertRule
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		return nil, fmt.Errorf("alert_rules must be a JSON list of rules: %s", err.Error())
+	}
+	names := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" || rule.Metric == "" {
+			return nil, fmt.Errorf("Invalid alert rule %d: name and metric must be set", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("Invalid alert rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if _, ok := alertOps[rule.Op]; !ok {
+			return nil, fmt.Errorf("Invalid alert rule %s: op must be one of >, >=, <, <=, == and !=", rule.Name)
+		}
+		if rule.For != "" {
+			duration, err := time.ParseDuration(rule.For)
+			if err != nil || duration < 0 {
+				return nil, fmt.Errorf("Invalid alert rule %s: for must be a duration", rule.Name)
+			}
+			rule.forDuration = duration
+		}
+	}
+	return rules, nil
+}
+
+// metricName returns the family name of a converted metric
+func metricName(metric plugin.Metric) string {
+	return metric.Namespace[len(metric.Namespace)-1].Value
+}
+
+// seriesKey identifies the series of a converted metric by namespace and tags
+func seriesKey(metric plugin.Metric) string {
+	keys := make([]string, 0, len(metric.Tags))
+	for key := range metric.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString(strings.Join(metric.Namespace.Strings(), "/"))
+	for _, key := range keys {
+		b.WriteString("\xff")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(metric.Tags[key])
+	}
+	return b.String()
+}
+
+// alertTracker remembers since when the series of every task config have
+// been firing their alert rule comparisons
+type alertTracker struct {
+	mutex  sync.Mutex
+	active map[string]map[string]time.Time
+}
+
+func newAlertTracker() *alertTracker {
+	return &alertTracker{
+		active: make(map[string]map[string]time.Time),
+	}
+}
+
+// evaluate returns a 0/1 alert_state metric, tagged with alertname and the
+// series tags, for every series of the metrics the rules watch
+func (t *alertTracker) evaluate(config plugin.Config, rules []alertRule, prefix []string, currentTime time.Time, metrics []plugin.Metric) []plugin.Metric {
+	if len(rules) == 0 || t == nil {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	previous := t.active[key]
+	active := make(map[string]time.Time)
+
+	namespace := prefixedNamespace(prefix, "alert_state")
+	var states []plugin.Metric
+	for i := range rules {
+		rule := &rules[i]
+		for _, metric := range metrics {
+			if !rule.matches(metric) {
+				continue
+			}
+			value, ok := metric.Data.(float64)
+			if !ok {
+				continue
+			}
+
+			state := 0.0
+			if alertOps[rule.Op](value, rule.Threshold) {
+				series := rule.Name + "\xfe" + seriesKey(metric)
+				since, ok := previous[series]
+				if !ok {
+					since = currentTime
+				}
+				active[series] = since
+				if currentTime.Sub(since) >= rule.forDuration {
+					state = 1.0
+				}
+			}
+
+			tags := make(map[string]string, len(metric.Tags)+1)
+			for key, value := range metric.Tags {
+				tags[key] = value
+			}
+			tags["alertname"] = rule.Name
+			states = append(states, plugin.Metric{
+				Namespace:   namespace,
+				Timestamp:   currentTime,
+				Description: "1 if the alert rule is firing for the series, 0 otherwise",
+				Version:     pluginVersion,
+				Tags:        tags,
+				Data:        state,
+			})
+		}
+	}
+
+	t.active[key] = active
+	return states
+}
@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func errorsMetric(code string, value float64) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_errors_total"),
+		Tags:      map[string]string{"code": code},
+		Data:      value,
+	}
+}
+
+func TestAlertRules(t *testing.T) {
+	Convey("Alert rules should fire after their for duration", t, func() {
+		config := plugin.Config{"alert_rules": `[{"name": "HighErrors", "metric": "http_errors_total", "match": {"code": "500"}, "op": ">", "threshold": 10, "for": "1m"}]`}
+		rules, err := compileConversionRules(config)
+		So(err, ShouldBeNil)
+		tracker := newAlertTracker()
+		prefix := []string{"hyperpilot", "prometheus"}
+		start := time.Now()
+
+		evaluate := func(at time.Time, value float64) []plugin.Metric {
+			return tracker.evaluate(config, rules.alertRules, prefix, at, []plugin.Metric{errorsMetric("500", value), errorsMetric("404", 100)})
+		}
+
+		states := evaluate(start, 20)
+		So(states, ShouldHaveLength, 1)
+		So(states[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "alert_state"})
+		So(states[0].Tags, ShouldResemble, map[string]string{"code": "500", "alertname": "HighErrors"})
+		So(states[0].Data, ShouldEqual, 0.0)
+
+		So(evaluate(start.Add(30*time.Second), 20)[0].Data, ShouldEqual, 0.0)
+		So(evaluate(start.Add(time.Minute), 20)[0].Data, ShouldEqual, 1.0)
+
+		Convey("and reset once the condition stops holding", func() {
+			So(evaluate(start.Add(2*time.Minute), 5)[0].Data, ShouldEqual, 0.0)
+			So(evaluate(start.Add(3*time.Minute), 20)[0].Data, ShouldEqual, 0.0)
+		})
+	})
+
+	Convey("Alert rules without for should fire immediately", t, func() {
+		config := plugin.Config{"alert_rules": `[{"name": "NoErrors", "metric": "http_errors_total", "op": "==", "threshold": 0}]`}
+		rules, err := compileConversionRules(config)
+		So(err, ShouldBeNil)
+		states := newAlertTracker().evaluate(config, rules.alertRules, nil, time.Now(), []plugin.Metric{errorsMetric("500", 0), errorsMetric("404", 1)})
+		So(states, ShouldHaveLength, 2)
+		So(states[0].Data, ShouldEqual, 1.0)
+		So(states[1].Data, ShouldEqual, 0.0)
+	})
+
+	Convey("Invalid alert rules should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"alert_rules": `[{"name": "A", "metric": "up", "op": "=~", "threshold": 0}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"alert_rules": `[{"metric": "up", "op": "==", "threshold": 0}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"alert_rules": `[{"name": "A", "metric": "up", "op": "==", "threshold": 0, "for": "soon"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"alert_rules": `[{"name": "A", "metric": "up", "op": "=="}, {"name": "A", "metric": "up", "op": "<"}]`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,131 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// anomalyTag flags the samples deviating from their series' usual values
+const anomalyTag = "anomaly"
+
+// ewmaStats are the exponentially weighted moving mean and variance of a
+// series, and how many samples they were computed from
+type ewmaStats struct {
+	mean     float64
+	variance float64
+	samples  int64
+}
+
+// score returns how many standard deviations value is from the mean. Any
+// change of a so far flat series is infinitely far from it.
+func (s ewmaStats) score(value float64) float64 {
+	if s.variance <= 0 {
+		if value == s.mean {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return math.Abs(value-s.mean) / math.Sqrt(s.variance)
+}
+
+// update adds value to the stats, weighted by alpha
+func (s ewmaStats) update(value, alpha float64) ewmaStats {
+	if s.samples == 0 {
+		return ewmaStats{mean: value, samples: 1}
+	}
+	diff := value - s.mean
+	increment := alpha * diff
+	return ewmaStats{
+		mean:     s.mean + increment,
+		variance: (1 - alpha) * (s.variance + diff*increment),
+		samples:  s.samples + 1,
+	}
+}
+
+// anomalyTracker keeps the moving stats of the series of anomaly_families
+// of every task config. Series a collection no longer has are forgotten.
+type anomalyTracker struct {
+	mutex sync.Mutex
+	stats map[string]map[string]ewmaStats
+}
+
+func newAnomalyTracker() *anomalyTracker {
+	return &anomalyTracker{
+		stats: make(map[string]map[string]ewmaStats),
+	}
+}
+
+// flag tags the samples of anomaly_families whose z-score against the moving
+// stats of their series exceeds anomaly_threshold with anomaly=true, once
+// the series has anomaly_min_samples samples, then adds them to the stats.
+// With anomaly_score it also returns an anomaly_score metric per sample,
+// tagged with the sample's tags and metric name.
+func (t *anomalyTracker) flag(config plugin.Config, families *regexp.Regexp, prefix []string, currentTime time.Time, metrics []plugin.Metric) []plugin.Metric {
+	if t == nil || families == nil {
+		return nil
+	}
+	threshold := getFloatConfig(config, "anomaly_threshold")
+	alpha := getFloatConfig(config, "anomaly_alpha")
+	minSamples := getIntConfig(config, "anomaly_min_samples")
+	withScore := getBoolConfig(config, "anomaly_score")
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	previous := t.stats[key]
+	current := make(map[string]ewmaStats, len(previous))
+
+	var scores []plugin.Metric
+	namespace := prefixedNamespace(prefix, "anomaly_score")
+	for i := range metrics {
+		value, ok := metrics[i].Data.(float64)
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) || !families.MatchString(metricName(metrics[i])) {
+			continue
+		}
+		series := seriesKey(metrics[i])
+		stats := previous[series]
+		score := stats.score(value)
+		if stats.samples >= minSamples && score > threshold {
+			// The tags are copied, tombstones keeping the series of the
+			// scrape by their original tags
+			tags := copyTags(metrics[i].Tags)
+			tags[anomalyTag] = "true"
+			metrics[i].Tags = tags
+		}
+		current[series] = stats.update(value, alpha)
+
+		if withScore && stats.samples >= minSamples {
+			tags := copyTags(metrics[i].Tags)
+			delete(tags, anomalyTag)
+			tags["metric_name"] = metricName(metrics[i])
+			scores = append(scores, plugin.Metric{
+				Namespace:   namespace,
+				Timestamp:   currentTime,
+				Description: "standard deviations a sample is from the moving mean of its series",
+				Version:     pluginVersion,
+				Tags:        tags,
+				Data:        score,
+			})
+		}
+	}
+	t.stats[key] = current
+	return scores
+}
+
+func checkAnomalies(config plugin.Config) ([]string, error) {
+	if getStringConfig(config, "anomaly_families") == "" {
+		return nil, nil
+	}
+	if alpha := getFloatConfig(config, "anomaly_alpha"); alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("anomaly_alpha must be in (0, 1]")
+	}
+	if getFloatConfig(config, "anomaly_threshold") <= 0 {
+		return nil, fmt.Errorf("anomaly_threshold must be positive")
+	}
+	return nil, nil
+}
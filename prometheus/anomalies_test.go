@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAnomalies(t *testing.T) {
+	prefix := []string{"hyperpilot", "prometheus"}
+	sample := func(name string, value float64) []plugin.Metric {
+		return []plugin.Metric{{
+			Namespace: prefixedNamespace(prefix, name),
+			Tags:      map[string]string{"instance": "10.0.0.1:9100"},
+			Data:      value,
+		}}
+	}
+
+	Convey("Samples far from the moving mean of their series should be flagged", t, func() {
+		tracker := newAnomalyTracker()
+		config := plugin.Config{"anomaly_families": "queue_.*", "anomaly_min_samples": int64(5), "anomaly_score": true}
+		So(validateConfig(config), ShouldBeNil)
+		families := regexp.MustCompile("^(?:queue_.*)$")
+
+		for i := 0; i < 20; i++ {
+			metrics := sample("queue_depth", 10+float64(i%3))
+			scores := tracker.flag(config, families, prefix, time.Now(), metrics)
+			So(metrics[0].Tags, ShouldNotContainKey, anomalyTag)
+			if i < 5 {
+				So(scores, ShouldBeEmpty)
+			} else {
+				So(scores, ShouldHaveLength, 1)
+			}
+		}
+
+		metrics := sample("queue_depth", 100)
+		scores := tracker.flag(config, families, prefix, time.Now(), metrics)
+		So(metrics[0].Tags[anomalyTag], ShouldEqual, "true")
+		So(scores[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "anomaly_score"})
+		So(scores[0].Tags["metric_name"], ShouldEqual, "queue_depth")
+		So(scores[0].Data.(float64), ShouldBeGreaterThan, 3)
+
+		Convey("and other families should be left alone", func() {
+			metrics := sample("jobs_total", 1e9)
+			So(tracker.flag(config, families, prefix, time.Now(), metrics), ShouldBeEmpty)
+			So(metrics[0].Tags, ShouldNotContainKey, anomalyTag)
+		})
+	})
+
+	Convey("A spike in a flat series should be flagged", t, func() {
+		tracker := newAnomalyTracker()
+		config := plugin.Config{"anomaly_families": "queue_.*", "anomaly_min_samples": int64(5)}
+		families := regexp.MustCompile("^(?:queue_.*)$")
+
+		for i := 0; i < 10; i++ {
+			metrics := sample("queue_depth", 10)
+			tracker.flag(config, families, prefix, time.Now(), metrics)
+			So(metrics[0].Tags, ShouldNotContainKey, anomalyTag)
+		}
+
+		metrics := sample("queue_depth", 11)
+		tracker.flag(config, families, prefix, time.Now(), metrics)
+		So(metrics[0].Tags[anomalyTag], ShouldEqual, "true")
+	})
+
+	Convey("Invalid anomaly settings should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"anomaly_families": "queue_(", "anomaly_alpha": 0.5}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"anomaly_families": "queue_.*", "anomaly_alpha": 0.0}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"anomaly_families": "queue_.*", "anomaly_threshold": -1.0}), ShouldNotBeNil)
+	})
+}
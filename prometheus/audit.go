@@ -0,0 +1,150 @@
+package prometheus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// auditEvent is a line of the audit log
+type auditEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Job   string    `json:"job,omitempty"`
+	// Config identifies the task config without logging its secrets, empty
+	// for admin requests
+	Config  string   `json:"config,omitempty"`
+	Targets []string `json:"targets,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	// Detail and Remote are the value set by an admin request and the
+	// address it came from
+	Detail string `json:"detail,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+// auditLogs are the audit log files opened so far, shared by all tasks
+// writing to the same file
+var auditLogs = struct {
+	sync.Mutex
+	writers map[string]io.Writer
+}{writers: map[string]io.Writer{}}
+
+// audit appends an event to the audit_log of config, if any. Failing to
+// write the audit log is logged and doesn't fail the collection.
+func audit(config plugin.Config, event string, targets []string, eventErr error) {
+	path := getStringConfig(config, "audit_log")
+	if path == "" {
+		return
+	}
+
+	hash := sha256.Sum256([]byte(configFingerprint(config)))
+	entry := auditEvent{
+		Time:    time.Now().UTC(),
+		Event:   event,
+		Job:     getStringConfig(config, "job"),
+		Config:  hex.EncodeToString(hash[:6]),
+		Targets: targets,
+	}
+	if eventErr != nil {
+		entry.Error = eventErr.Error()
+	}
+	writeAuditEvent(path, entry)
+}
+
+// writeAuditEvent appends entry to the audit log at path
+func writeAuditEvent(path string, entry auditEvent) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		glog.Warningf("Unable to encode audit event: %s", err.Error())
+		return
+	}
+
+	auditLogs.Lock()
+	defer auditLogs.Unlock()
+	writer, ok := auditLogs.writers[path]
+	if !ok {
+		if path == "stdout" {
+			writer = os.Stdout
+		} else {
+			if err := checkAuditPath(path); err != nil {
+				glog.Warningf("Unable to open audit log: %s", err.Error())
+				return
+			}
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+			if err != nil {
+				glog.Warningf("Unable to open audit log %s: %s", path, err.Error())
+				return
+			}
+			writer = file
+		}
+		auditLogs.writers[path] = writer
+	}
+	if _, err := writer.Write(append(line, '\n')); err != nil {
+		glog.Warningf("Unable to write audit log %s: %s", path, err.Error())
+	}
+}
+
+// checkAuditPath refuses audit logs that aren't an absolute path to a
+// regular file, so a task can't append to a device or through a symlink
+func checkAuditPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("audit_log %s must be stdout or an absolute path", path)
+	}
+	info, err := os.Lstat(path)
+	if err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("audit_log %s is not a regular file", path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to check audit_log: " + err.Error())
+	}
+	return nil
+}
+
+func checkAuditLog(config plugin.Config) ([]string, error) {
+	path := getStringConfig(config, "audit_log")
+	if path == "" || path == "stdout" {
+		return nil, nil
+	}
+	return nil, checkAuditPath(path)
+}
+
+// auditTargetChanges logs the targets added and removed between two
+// discoveries
+func auditTargetChanges(config plugin.Config, previous []target, current []target) {
+	before := make(map[string]bool, len(previous))
+	for _, t := range previous {
+		before[t.URL] = true
+	}
+	after := make(map[string]bool, len(current))
+	var added []string
+	for _, t := range current {
+		after[t.URL] = true
+		if !before[t.URL] {
+			added = append(added, t.URL)
+		}
+	}
+	var removed []string
+	for url := range before {
+		if !after[url] {
+			removed = append(removed, url)
+		}
+	}
+	sort.Strings(removed)
+
+	if len(added) > 0 {
+		audit(config, "targets_added", added, nil)
+	}
+	if len(removed) > 0 {
+		audit(config, "targets_removed", removed, nil)
+	}
+}
@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func readAuditLog(path string) []auditEvent {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var events []auditEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event auditEvent
+		if json.Unmarshal([]byte(line), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Loaded task configs should be audited once", t, func() {
+		path := filepath.Join(dir, "configs.log")
+		validator := newConfigValidator()
+		config := plugin.Config{"audit_log": path, "job": "node", "nomad_token": "secret"}
+		So(validator.validate(config), ShouldBeNil)
+		So(validator.validate(config), ShouldBeNil)
+		So(validator.validate(plugin.Config{"audit_log": path, "job": "a/b"}), ShouldNotBeNil)
+
+		events := readAuditLog(path)
+		So(events, ShouldHaveLength, 2)
+		So(events[0].Event, ShouldEqual, "config_loaded")
+		So(events[0].Job, ShouldEqual, "node")
+		So(events[0].Config, ShouldHaveLength, 12)
+		So(events[1].Error, ShouldNotBeEmpty)
+
+		data, _ := ioutil.ReadFile(path)
+		So(string(data), ShouldNotContainSubstring, "secret")
+	})
+
+	Convey("Discovered target changes should be audited", t, func() {
+		path := filepath.Join(dir, "targets.log")
+		config := plugin.Config{"audit_log": path}
+		auditTargetChanges(config, nil, []target{{URL: "http://a/metrics"}, {URL: "http://b/metrics"}})
+		auditTargetChanges(config, []target{{URL: "http://a/metrics"}, {URL: "http://b/metrics"}}, []target{{URL: "http://b/metrics"}, {URL: "http://c/metrics"}})
+		auditTargetChanges(config, []target{{URL: "http://c/metrics"}}, []target{{URL: "http://c/metrics"}})
+
+		events := readAuditLog(path)
+		So(events, ShouldHaveLength, 3)
+		So(events[0].Event, ShouldEqual, "targets_added")
+		So(events[0].Targets, ShouldResemble, []string{"http://a/metrics", "http://b/metrics"})
+		So(events[1].Event, ShouldEqual, "targets_added")
+		So(events[1].Targets, ShouldResemble, []string{"http://c/metrics"})
+		So(events[2].Event, ShouldEqual, "targets_removed")
+		So(events[2].Targets, ShouldResemble, []string{"http://a/metrics"})
+	})
+
+	Convey("Admin requests should be audited", t, func() {
+		path := filepath.Join(dir, "admin.log")
+		admin := newAdminServer(newPauseRegistry(), nil)
+		admin.auditLog = path
+		server := httptest.NewServer(admin.handler)
+		defer server.Close()
+
+		resp, err := http.Post(server.URL+"/pause?job=api", "", nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		resp, err = http.Post(server.URL+"/resume?target=10.0.0.1:9100", "", nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+
+		events := readAuditLog(path)
+		So(events, ShouldHaveLength, 2)
+		So(events[0].Event, ShouldEqual, "job_paused")
+		So(events[0].Job, ShouldEqual, "api")
+		So(events[0].Remote, ShouldNotBeEmpty)
+		So(events[1].Event, ShouldEqual, "target_resumed")
+		So(events[1].Targets, ShouldResemble, []string{"10.0.0.1:9100"})
+	})
+
+	Convey("Audit logs should be absolute paths to regular files", t, func() {
+		So(validateConfig(plugin.Config{"audit_log": "audit.log"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"audit_log": dir}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"audit_log": "stdout"}), ShouldBeNil)
+		So(validateConfig(plugin.Config{"audit_log": filepath.Join(dir, "new.log")}), ShouldBeNil)
+
+		link := filepath.Join(dir, "link.log")
+		So(os.Symlink(filepath.Join(dir, "target.log"), link), ShouldBeNil)
+		So(validateConfig(plugin.Config{"audit_log": link}), ShouldNotBeNil)
+		audit(plugin.Config{"audit_log": link}, "config_loaded", nil, nil)
+		_, err := os.Stat(filepath.Join(dir, "target.log"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
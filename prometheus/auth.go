@@ -0,0 +1,59 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// setAuthorization adds the bearer token or basic auth credentials of the
+// task to a scrape request. Token files are read at every scrape so rotated
+// tokens are picked up.
+func setAuthorization(req *http.Request, config plugin.Config) error {
+	token := getStringConfig(config, "bearer_token")
+	if tokenFile := getStringConfig(config, "bearer_token_file"); tokenFile != "" {
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("Unable to read bearer token: " + err.Error())
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	if username := getStringConfig(config, "username"); username != "" {
+		req.SetBasicAuth(username, getStringConfig(config, "password"))
+	}
+	return nil
+}
+
+func checkAuth(config plugin.Config) ([]string, error) {
+	token := getStringConfig(config, "bearer_token") != ""
+	tokenFile := getStringConfig(config, "bearer_token_file") != ""
+	username := getStringConfig(config, "username") != ""
+	password := getStringConfig(config, "password") != ""
+
+	if token && tokenFile {
+		return nil, fmt.Errorf("bearer_token and bearer_token_file are mutually exclusive")
+	}
+	if (token || tokenFile) && (username || password) {
+		return nil, fmt.Errorf("bearer token and basic auth are mutually exclusive")
+	}
+	if password && !username {
+		return nil, fmt.Errorf("password needs username")
+	}
+	if (token || tokenFile || username) && isKubeProxy(config) {
+		return nil, fmt.Errorf("scrapes through the Kubernetes API server authenticate with kube_token_file")
+	}
+	if token || tokenFile || username {
+		if endpoint, err := config.GetString("endpoint"); err == nil && strings.HasPrefix(endpoint, "http://") {
+			return []string{"credentials are sent over plain HTTP to " + endpoint}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAuthorization(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("GET", "https://10.0.0.1:9100/metrics", nil)
+		return req
+	}
+
+	Convey("Bearer tokens should be sent inline or from a file", t, func() {
+		req := newRequest()
+		So(setAuthorization(req, plugin.Config{"bearer_token": "abc"}), ShouldBeNil)
+		So(req.Header.Get("Authorization"), ShouldEqual, "Bearer abc")
+
+		file, err := ioutil.TempFile("", "token")
+		So(err, ShouldBeNil)
+		defer os.Remove(file.Name())
+		file.WriteString("from-file\n")
+		file.Close()
+		req = newRequest()
+		So(setAuthorization(req, plugin.Config{"bearer_token_file": file.Name()}), ShouldBeNil)
+		So(req.Header.Get("Authorization"), ShouldEqual, "Bearer from-file")
+
+		So(setAuthorization(newRequest(), plugin.Config{"bearer_token_file": file.Name() + ".missing"}), ShouldNotBeNil)
+	})
+
+	Convey("Basic auth should be sent with the username and password", t, func() {
+		req := newRequest()
+		So(setAuthorization(req, plugin.Config{"username": "snap", "password": "secret"}), ShouldBeNil)
+		username, password, ok := req.BasicAuth()
+		So(ok, ShouldBeTrue)
+		So(username, ShouldEqual, "snap")
+		So(password, ShouldEqual, "secret")
+	})
+
+	Convey("Requests should be unauthenticated by default", t, func() {
+		req := newRequest()
+		So(setAuthorization(req, plugin.Config{}), ShouldBeNil)
+		So(req.Header.Get("Authorization"), ShouldBeEmpty)
+	})
+
+	Convey("Conflicting credentials should fail validation", t, func() {
+		_, err := checkAuth(plugin.Config{"bearer_token": "abc", "bearer_token_file": "/token"})
+		So(err, ShouldNotBeNil)
+		_, err = checkAuth(plugin.Config{"bearer_token": "abc", "username": "snap"})
+		So(err, ShouldNotBeNil)
+		_, err = checkAuth(plugin.Config{"password": "secret"})
+		So(err, ShouldNotBeNil)
+		warnings, err := checkAuth(plugin.Config{"username": "snap", "endpoint": "http://10.0.0.1:9100"})
+		So(err, ShouldBeNil)
+		So(len(warnings), ShouldEqual, 1)
+	})
+}
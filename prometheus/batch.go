@@ -0,0 +1,39 @@
+package prometheus
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// batchSequence numbers the collections of the plugin process
+var batchSequence uint64
+
+// batchMetadata returns the collection_batch metric describing a
+// collection, so pipelines can check every batch arrived whole: its data is
+// the number of other metrics in the batch, its tags the batch id, the
+// number of targets scraped and failed, whether the batch is truncated by
+// failed scrapes and the collection duration
+func batchMetadata(config plugin.Config, prefix []string, currentTime time.Time, targets int, failed int, samples int) []plugin.Metric {
+	if !getBoolConfig(config, "emit_batch_metadata") {
+		return nil
+	}
+	return []plugin.Metric{{
+		Namespace:   prefixedNamespace(prefix, "collection_batch"),
+		Timestamp:   currentTime,
+		Description: "metadata of the collection batch, its data is the number of other metrics in the batch",
+		Version:     pluginVersion,
+		Tags: map[string]string{
+			"batch_id":         fmt.Sprintf("%d-%d", currentTime.UnixNano(), atomic.AddUint64(&batchSequence, 1)),
+			"target_count":     strconv.Itoa(targets),
+			"failed_targets":   strconv.Itoa(failed),
+			"sample_count":     strconv.Itoa(samples),
+			"truncated":        strconv.FormatBool(failed > 0),
+			"duration_seconds": strconv.FormatFloat(time.Since(currentTime).Seconds(), 'f', 3, 64),
+		},
+		Data: int64(samples),
+	}}
+}
@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatchMetadata(t *testing.T) {
+	Convey("Every collection should end with its batch metadata", t, func() {
+		downloader := &downURLsDownloader{down: map[string]bool{}}
+		collector := &PrometheusCollector{Downloader: downloader}
+		mts := []plugin.Metric{{Config: plugin.Config{"endpoints": "http://a:9100,http://b:9100", "emit_batch_metadata": true}}}
+
+		metrics, err := collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		batch := metrics[len(metrics)-1]
+		So(metricName(batch), ShouldEqual, "collection_batch")
+		So(batch.Data, ShouldEqual, len(metrics)-1)
+		So(batch.Tags["target_count"], ShouldEqual, "2")
+		So(batch.Tags["truncated"], ShouldEqual, "false")
+
+		downloader.down["http://b:9100/metrics"] = true
+		metrics, err = collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		next := metrics[len(metrics)-1]
+		So(next.Tags["failed_targets"], ShouldEqual, "1")
+		So(next.Tags["truncated"], ShouldEqual, "true")
+		So(next.Tags["batch_id"], ShouldNotEqual, batch.Tags["batch_id"])
+	})
+
+	Convey("Batch metadata should be off by default", t, func() {
+		So(batchMetadata(plugin.Config{}, nil, time.Now(), 1, 0, 10), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// probeMetricTypes scrapes the first target of cfg and returns a metric type
+// per family it exposes, with its HELP as description and the unit its name
+// suffix implies, so task authors can browse the families in the catalog.
+// It also returns the endpoint probed and its families, for the catalog
+// cache.
+func (c *PrometheusCollector) probeMetricTypes(cfg plugin.Config) ([]plugin.Metric, string, map[string]bool, error) {
+	targets, err := c.getTargets(cfg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(targets) == 0 {
+		return nil, "", nil, fmt.Errorf("no target to probe")
+	}
+	prefix, err := getNamespacePrefix(cfg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	metricFamilies, err := c.Collect(targets[0].URL, cfg)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	names := make([]string, 0, len(metricFamilies))
+	families := make(map[string]bool, len(metricFamilies))
+	for name := range metricFamilies {
+		names = append(names, name)
+		families[name] = true
+	}
+	sort.Strings(names)
+	mts := make([]plugin.Metric, 0, len(names))
+	for _, name := range names {
+		mt := c.createMetricFromFamily(time.Time{}, prefix, metricFamilies[name])
+		mt.Unit = familyUnit(name)
+		mts = append(mts, mt)
+	}
+	return mts, targets[0].URL, families, nil
+}
+
+// familyUnit returns the unit the name of a family implies through its unit
+// suffix, "" when it has none
+func familyUnit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	for suffix, conversion := range defaultUnitConversions {
+		if strings.HasSuffix(name, suffix) {
+			return conversion.Unit
+		}
+	}
+	return ""
+}
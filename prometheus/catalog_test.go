@@ -0,0 +1,75 @@
+package prometheus
+
+import (
+	"io"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// countingDownloader counts the scrapes going through it
+type countingDownloader struct {
+	MetricsDownloader
+	scrapes int
+}
+
+func (d *countingDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	d.scrapes++
+	return d.MetricsDownloader.GetMetricsReader(url, config)
+}
+
+func TestProbeMetricTypes(t *testing.T) {
+	Convey("Probed catalogs should list every family of the first target", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+		mts, err := collector.GetMetricTypes(plugin.Config{"probe_metric_types": true})
+		So(err, ShouldBeNil)
+		So(len(mts), ShouldBeGreaterThan, 1)
+		So(mts[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus"})
+
+		var goroutines *plugin.Metric
+		for i := range mts {
+			if mts[i].Namespace.Strings()[len(mts[i].Namespace)-1] == "go_goroutines" {
+				goroutines = &mts[i]
+			}
+		}
+		So(goroutines, ShouldNotBeNil)
+		So(goroutines.Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "go_goroutines"})
+		So(goroutines.Description, ShouldEqual, "Number of goroutines that currently exist.")
+	})
+
+	Convey("Probed catalogs should be reused until a scrape finds new families", t, func() {
+		downloader := &countingDownloader{MetricsDownloader: &MockMetricsDownloader{}}
+		collector := &PrometheusCollector{Downloader: downloader, catalog: newCatalogCache()}
+		config := plugin.Config{"probe_metric_types": true}
+		first, err := collector.GetMetricTypes(config)
+		So(err, ShouldBeNil)
+		second, err := collector.GetMetricTypes(config)
+		So(err, ShouldBeNil)
+		So(second, ShouldResemble, first)
+		So(downloader.scrapes, ShouldEqual, 1)
+
+		endpoint, _ := downloader.GetEndpoint(config)
+		collector.catalog.observe(endpoint, map[string]*dto.MetricFamily{"go_goroutines": {}})
+		collector.GetMetricTypes(config)
+		So(downloader.scrapes, ShouldEqual, 1)
+
+		collector.catalog.observe(endpoint, map[string]*dto.MetricFamily{"windows_service_state": {}})
+		collector.GetMetricTypes(config)
+		So(downloader.scrapes, ShouldEqual, 2)
+	})
+
+	Convey("Catalogs should only list the prefix unless probing", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+		mts, err := collector.GetMetricTypes(plugin.Config{})
+		So(err, ShouldBeNil)
+		So(mts, ShouldHaveLength, 1)
+	})
+
+	Convey("Units should follow the family name suffixes", t, func() {
+		So(familyUnit("process_cpu_seconds_total"), ShouldEqual, "s")
+		So(familyUnit("go_memstats_alloc_bytes"), ShouldEqual, "B")
+		So(familyUnit("go_goroutines"), ShouldEqual, "")
+	})
+}
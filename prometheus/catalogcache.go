@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// catalogEntry is the metric catalog of a task config, with the endpoint
+// it was probed from and the families it lists
+type catalogEntry struct {
+	endpoint string
+	families map[string]bool
+	metrics  []plugin.Metric
+	expires  time.Time
+}
+
+// catalogCache keeps the metric catalog of every task config for
+// catalog_ttl, so Snap refreshing the catalog often doesn't rebuild it, nor
+// scrape the endpoints it is probed from, every time. A probed catalog is
+// dropped as soon as a collection finds families of its endpoint it doesn't
+// list.
+type catalogCache struct {
+	mutex   sync.Mutex
+	entries map[string]*catalogEntry
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{
+		entries: make(map[string]*catalogEntry),
+	}
+}
+
+// get returns the catalog of cfg unless it expired
+func (c *catalogCache) get(cfg plugin.Config) ([]plugin.Metric, bool) {
+	if c == nil {
+		return nil, false
+	}
+	key := configFingerprint(cfg)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.metrics, true
+}
+
+// put keeps the catalog of cfg, probed from endpoint listing families, and
+// drops the expired catalogs of other task configs
+func (c *catalogCache) put(cfg plugin.Config, endpoint string, families map[string]bool, mts []plugin.Metric) {
+	ttl, _ := getDurationConfig(cfg, "catalog_ttl")
+	if c == nil || ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[configFingerprint(cfg)] = &catalogEntry{
+		endpoint: endpoint,
+		families: families,
+		metrics:  mts,
+		expires:  now.Add(ttl),
+	}
+}
+
+// observe drops the catalogs probed from endpoint missing one of the
+// families of a scrape of it
+func (c *catalogCache) observe(endpoint string, metricFamilies map[string]*dto.MetricFamily) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, entry := range c.entries {
+		if entry.endpoint == "" || entry.endpoint != endpoint {
+			continue
+		}
+		for name := range metricFamilies {
+			if !entry.families[name] {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatalogCache(t *testing.T) {
+	mts := []plugin.Metric{{Namespace: plugin.NewNamespace("hyperpilot", "prometheus")}}
+	endpoint := "http://10.0.0.1:9100/metrics"
+
+	Convey("Catalogs should be kept until a scrape finds new families", t, func() {
+		cache := newCatalogCache()
+		config := plugin.Config{}
+		cache.put(config, endpoint, map[string]bool{"go_goroutines": true}, mts)
+		cached, ok := cache.get(config)
+		So(ok, ShouldBeTrue)
+		So(cached, ShouldResemble, mts)
+
+		cache.observe(endpoint, map[string]*dto.MetricFamily{"go_goroutines": {}})
+		_, ok = cache.get(config)
+		So(ok, ShouldBeTrue)
+
+		cache.observe("http://10.0.0.2:9100/metrics", map[string]*dto.MetricFamily{"node_load1": {}})
+		_, ok = cache.get(config)
+		So(ok, ShouldBeTrue)
+
+		cache.observe(endpoint, map[string]*dto.MetricFamily{"node_load1": {}})
+		_, ok = cache.get(config)
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Catalogs should expire after catalog_ttl", t, func() {
+		cache := newCatalogCache()
+		config := plugin.Config{"catalog_ttl": "1ns"}
+		cache.put(config, endpoint, nil, mts)
+		_, ok := cache.get(config)
+		So(ok, ShouldBeFalse)
+		So(cache.entries, ShouldBeEmpty)
+
+		cache.put(plugin.Config{"catalog_ttl": "0s"}, endpoint, nil, mts)
+		So(cache.entries, ShouldBeEmpty)
+	})
+}
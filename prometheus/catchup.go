@@ -0,0 +1,160 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// catchupTimeout bounds the queries to the Prometheus server a task catches
+// up from
+const catchupTimeout = 30 * time.Second
+
+type rangeQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string        `json:"resultType"`
+		Result     []rangeSeries `json:"result"`
+	} `json:"data"`
+}
+
+// rangeSeries is a series of a range query result, its values being
+// [unix time, "value"] pairs
+type rangeSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"`
+}
+
+// catchUp returns the samples of the catchup_selectors series over the
+// catchup_window before now, read from the catchup_url Prometheus server.
+// It runs on the first collection of a task; a failed catch-up is logged and
+// not retried.
+func (c *PrometheusCollector) catchUp(config plugin.Config, prefix []string, now time.Time) []plugin.Metric {
+	server := getStringConfig(config, "catchup_url")
+	window, err := getDurationConfig(config, "catchup_window")
+	if server == "" || err != nil || window <= 0 {
+		return nil
+	}
+	step, err := getDurationConfig(config, "catchup_step")
+	if err != nil {
+		return nil
+	}
+	maxSamples := int(getIntConfig(config, "catchup_max_samples"))
+	client, err := newPolicyClient(config, catchupTimeout)
+	if err != nil {
+		glog.Warningf("Unable to catch up from %s: %s", server, err.Error())
+		return nil
+	}
+
+	var metrics []plugin.Metric
+	for _, selector := range strings.Split(getStringConfig(config, "catchup_selectors"), ";") {
+		if selector = strings.TrimSpace(selector); selector == "" {
+			continue
+		}
+		series, err := queryRange(client, server, selector, now.Add(-window), now, step)
+		if err != nil {
+			glog.Warningf("Unable to catch up on %s from %s: %s", selector, server, err.Error())
+			continue
+		}
+		for _, s := range series {
+			metrics = append(metrics, c.catchupMetrics(prefix, s.Metric, s.Values)...)
+			if len(metrics) >= maxSamples {
+				glog.Warningf("Catch-up stopped at catchup_max_samples (%d) samples", maxSamples)
+				return metrics[:maxSamples]
+			}
+		}
+	}
+	return metrics
+}
+
+func queryRange(client *http.Client, server string, selector string, start time.Time, end time.Time, step time.Duration) ([]rangeSeries, error) {
+	query := url.Values{}
+	query.Set("query", selector)
+	query.Set("start", strconv.FormatInt(start.Unix(), 10))
+	query.Set("end", strconv.FormatInt(end.Unix(), 10))
+	query.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	resp, err := client.Get(strings.TrimRight(server, "/") + "/api/v1/query_range?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result rangeQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Unable to decode query response (status %d): %s", resp.StatusCode, err.Error())
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", result.Error)
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %s", result.Data.ResultType)
+	}
+	return result.Data.Result, nil
+}
+
+// catchupMetrics converts the samples of a queried series, named by its
+// __name__ label and tagged with the others
+func (c *PrometheusCollector) catchupMetrics(prefix []string, labels map[string]string, values [][2]interface{}) []plugin.Metric {
+	name := labels["__name__"]
+	if name == "" {
+		return nil
+	}
+	namespace := prefixedNamespace(prefix, c.sanitizer.sanitize(name))
+
+	metrics := make([]plugin.Metric, 0, len(values))
+	for _, value := range values {
+		timestamp, ok := value[0].(float64)
+		if !ok {
+			continue
+		}
+		sample, ok := value[1].(string)
+		if !ok {
+			continue
+		}
+		data, err := strconv.ParseFloat(sample, 64)
+		if err != nil || math.IsNaN(data) {
+			continue
+		}
+
+		tags := make(map[string]string, len(labels))
+		for key, label := range labels {
+			if key != "__name__" {
+				tags[key] = label
+			}
+		}
+		seconds, fraction := math.Modf(timestamp)
+		metrics = append(metrics, plugin.Metric{
+			Namespace: namespace,
+			Timestamp: time.Unix(int64(seconds), int64(fraction*1e9)),
+			Version:   pluginVersion,
+			Tags:      tags,
+			Data:      data,
+		})
+	}
+	return metrics
+}
+
+func checkCatchup(config plugin.Config) ([]string, error) {
+	window, _ := getDurationConfig(config, "catchup_window")
+	if getStringConfig(config, "catchup_url") == "" || window <= 0 {
+		return nil, nil
+	}
+	step, _ := getDurationConfig(config, "catchup_step")
+	if step <= 0 {
+		return nil, fmt.Errorf("catchup_step must be positive")
+	}
+	if window < step {
+		return nil, fmt.Errorf("catchup_window must be at least catchup_step")
+	}
+	return nil, nil
+}
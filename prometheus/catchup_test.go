@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCatchUp(t *testing.T) {
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if r.URL.Path != "/api/v1/query_range" || r.URL.Query().Get("step") != "60" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status": "error", "error": "bad query"}`)
+			return
+		}
+		if r.URL.Query().Get("query") != `up{job="node"}` {
+			fmt.Fprint(w, `{"status": "error", "error": "unknown series"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status": "success", "data": {"resultType": "matrix", "result": [
+			{"metric": {"__name__": "up", "job": "node", "instance": "10.0.0.1:9100"}, "values": [[1514764800, "1"], [1514764860.5, "0"]]},
+			{"metric": {"__name__": "up", "job": "node", "instance": "10.0.0.2:9100"}, "values": [[1514764800, "1"]]}
+		]}}`)
+	}))
+	defer server.Close()
+
+	Convey("The first collection of a task should catch up", t, func() {
+		queries = 0
+		c := &PrometheusCollector{}
+		config := plugin.Config{
+			"catchup_url":       server.URL,
+			"catchup_selectors": `up{job="node"}; missing`,
+			"catchup_window":    "10m",
+		}
+		prefix := []string{"hyperpilot", "prometheus"}
+
+		metrics := c.catchUp(config, prefix, time.Now())
+		So(queries, ShouldEqual, 2)
+		So(metrics, ShouldHaveLength, 3)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "up"})
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"job": "node", "instance": "10.0.0.1:9100"})
+		So(metrics[0].Data, ShouldEqual, 1.0)
+		So(metrics[1].Timestamp, ShouldResemble, time.Unix(1514764860, 500000000))
+		So(metrics[1].Data, ShouldEqual, 0.0)
+
+		Convey("only once", func() {
+			c := &PrometheusCollector{Downloader: windowTestDownloader{}, firstCollections: newFirstCollectionTracker()}
+			for i := 0; i < 3; i++ {
+				_, err := c.CollectMetrics([]plugin.Metric{{Config: config}})
+				So(err, ShouldBeNil)
+			}
+			So(queries, ShouldEqual, 4)
+		})
+
+		Convey("bounded by catchup_max_samples", func() {
+			config["catchup_max_samples"] = int64(2)
+			So(c.catchUp(config, prefix, time.Now()), ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Tasks without a catch-up window should not catch up", t, func() {
+		queries = 0
+		c := &PrometheusCollector{}
+		So(c.catchUp(plugin.Config{"catchup_url": server.URL, "catchup_selectors": "up"}, nil, time.Now()), ShouldBeEmpty)
+		So(queries, ShouldEqual, 0)
+	})
+
+	Convey("Catch-up steps should be positive and fit in the window", t, func() {
+		config := plugin.Config{"catchup_url": server.URL, "catchup_window": "10m"}
+		So(validateConfig(config), ShouldBeNil)
+		config["catchup_step"] = "0s"
+		So(validateConfig(config), ShouldNotBeNil)
+		config["catchup_step"] = "1h"
+		So(validateConfig(config), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,426 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// clientOptions are the task settings shaping the HTTP client used for
+// scraping. Tasks with equal options share a client and its connection pool.
+type clientOptions struct {
+	// connectionMaxAge forces idle connections to be re-established
+	// periodically, so long-lived keep-alive connections follow DNS and load
+	// balancer changes; 0 keeps connections for as long as they're reused
+	connectionMaxAge time.Duration
+	// ipFamily restricts or orders the address families dialed, see
+	// ipFamilies
+	ipFamily string
+	// fallbackDelay is how long a dial waits before racing the other address
+	// family; 0 uses the Go default of 300ms and a negative value disables
+	// the fallback
+	fallbackDelay time.Duration
+	// caFile is a PEM bundle verifying the server instead of the system roots
+	caFile string
+	// certFile and keyFile are the PEM client certificate and key presented
+	// to targets requiring mutual TLS
+	certFile string
+	keyFile  string
+	// insecureSkipVerify doesn't verify the server certificate
+	insecureSkipVerify bool
+	// allowedCIDRs and deniedCIDRs are the address policy enforced when
+	// dialing, see addressPolicy
+	allowedCIDRs string
+	deniedCIDRs  string
+	// safeMode only dials private addresses, directly rather than through a
+	// proxy, and doesn't follow redirects
+	safeMode bool
+	// timeout bounds a whole scrape, from dialing to reading the body; 0
+	// doesn't time out
+	timeout time.Duration
+	// readTimeout bounds the wait for every read of a connection instead,
+	// timeout then only bounding the wait for the response headers; 0
+	// doesn't time reads out
+	readTimeout time.Duration
+	// proxyURL is the proxy scrapes go through, the environment's proxy
+	// settings are used when empty
+	proxyURL string
+	// noProxy lists the targets scraped without proxyURL
+	noProxy string
+	// socketPath is the unix socket every scrape dials, for unix://
+	// endpoints
+	socketPath string
+	// maxRedirects is how many redirects a scrape follows, 0 doesn't follow
+	// them
+	maxRedirects int64
+	// tlsServerName overrides the server name verified against the target's
+	// certificate
+	tlsServerName string
+	// retryAddresses dials the next address of a host resolving to several
+	// when one fails, see retryingDialer
+	retryAddresses bool
+}
+
+// ipFamilies are the accepted ip_family values, mapped to the network
+// dialed first and the network raced after fallback_delay
+var ipFamilies = map[string][2]string{
+	"any":         {"tcp", ""},
+	"ipv4":        {"tcp4", ""},
+	"ipv6":        {"tcp6", ""},
+	"prefer_ipv4": {"tcp4", "tcp6"},
+	"prefer_ipv6": {"tcp6", "tcp4"},
+}
+
+func getClientOptions(config plugin.Config) (clientOptions, error) {
+	var opts clientOptions
+	var err error
+	if opts.connectionMaxAge, err = getDurationConfig(config, "connection_max_age"); err != nil {
+		return opts, err
+	}
+	if opts.fallbackDelay, err = getDurationConfig(config, "fallback_delay"); err != nil {
+		return opts, err
+	}
+	opts.caFile = getStringConfig(config, "ca_file")
+	if isKubeProxy(config) {
+		opts.caFile = kubeCAFile(config)
+	}
+	opts.certFile = getStringConfig(config, "cert_file")
+	opts.keyFile = getStringConfig(config, "key_file")
+	if (opts.certFile == "") != (opts.keyFile == "") {
+		return opts, fmt.Errorf("cert_file and key_file must be set together")
+	}
+	opts.insecureSkipVerify = getBoolConfig(config, "insecure_skip_verify")
+	opts.allowedCIDRs = getStringConfig(config, "allowed_cidrs")
+	opts.deniedCIDRs = getStringConfig(config, "denied_cidrs")
+	opts.safeMode = getBoolConfig(config, "safe_mode")
+	if opts.timeout, err = getDurationConfig(config, "timeout"); err != nil {
+		return opts, err
+	}
+	if opts.readTimeout, err = getDurationConfig(config, "read_timeout"); err != nil {
+		return opts, err
+	}
+	opts.proxyURL = getStringConfig(config, "proxy_url")
+	if opts.proxyURL != "" {
+		if _, err := url.Parse(opts.proxyURL); err != nil {
+			return opts, fmt.Errorf("Invalid proxy_url: " + err.Error())
+		}
+		opts.noProxy = getNoProxy(getStringConfig(config, "no_proxy"))
+	}
+	opts.maxRedirects = getIntConfig(config, "max_redirects")
+	opts.tlsServerName = getStringConfig(config, "tls_server_name")
+	opts.retryAddresses = getBoolConfig(config, "retry_addresses")
+	opts.ipFamily = getStringConfig(config, "ip_family")
+	if _, ok := ipFamilies[opts.ipFamily]; !ok {
+		return opts, fmt.Errorf("Unknown ip_family: %s", opts.ipFamily)
+	}
+	return opts, nil
+}
+
+type cachedClient struct {
+	client    *http.Client
+	transport *http.Transport
+	recycled  time.Time
+	// health is the circuit breaker of the client's addresses, nil unless
+	// it retries addresses
+	health *addressHealth
+}
+
+// clientCache builds one HTTP client per distinct clientOptions
+type clientCache struct {
+	mutex   sync.Mutex
+	clients map[clientOptions]*cachedClient
+}
+
+func newClientCache() *clientCache {
+	return &clientCache{
+		clients: make(map[clientOptions]*cachedClient),
+	}
+}
+
+func (c *clientCache) get(opts clientOptions) (*http.Client, error) {
+	if c == nil {
+		cached, err := newClient(opts)
+		if err != nil {
+			return nil, err
+		}
+		return cached.client, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cached, ok := c.clients[opts]
+	if !ok {
+		var err error
+		if cached, err = newClient(opts); err != nil {
+			return nil, err
+		}
+		c.clients[opts] = cached
+	}
+
+	// Connections in use when recycling return to the pool afterwards, so a
+	// connection lives at most about twice connectionMaxAge
+	if opts.connectionMaxAge > 0 && time.Since(cached.recycled) > opts.connectionMaxAge {
+		cached.transport.CloseIdleConnections()
+		cached.recycled = time.Now()
+	}
+	return cached.client, nil
+}
+
+// healthOf returns the circuit breaker of the cached client of opts, nil
+// when it doesn't retry addresses
+func (c *clientCache) healthOf(opts clientOptions) *addressHealth {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, ok := c.clients[opts]; ok {
+		return cached.health
+	}
+	return nil
+}
+
+func newClient(opts clientOptions) (*cachedClient, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         opts.tlsServerName,
+		InsecureSkipVerify: opts.insecureSkipVerify,
+	}
+	if opts.certFile != "" {
+		// Loaded at every handshake so rotated certificates are picked up
+		if _, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile); err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: " + err.Error())
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cert, nil
+		}
+	}
+	if opts.caFile != "" {
+		pem, err := ioutil.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA file: " + err.Error())
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA file %s", opts.caFile)
+		}
+	}
+
+	policies, err := addressPolicies(opts.allowedCIDRs, opts.deniedCIDRs, opts.safeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: opts.fallbackDelay,
+		Control:       addressControl(policies...),
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           familyDialer(dialer, opts.ipFamily),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+	var health *addressHealth
+	if opts.retryAddresses {
+		health = newAddressHealth()
+		transport.DialContext = retryingDialer(dialer, opts.ipFamily, health, transport.DialContext)
+	}
+	if opts.socketPath != "" {
+		transport.DialContext = unixDialer(opts.socketPath)
+	}
+	if opts.proxyURL != "" {
+		proxy, err := url.Parse(opts.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy_url: " + err.Error())
+		}
+		transport.Proxy = proxyBypassing(proxy, opts.noProxy)
+	}
+	client := &http.Client{
+		Transport:     transport,
+		Timeout:       opts.timeout,
+		CheckRedirect: limitRedirects(opts.maxRedirects),
+	}
+	if opts.readTimeout > 0 {
+		// A large body arriving slowly but steadily only fails when it
+		// stalls for readTimeout
+		transport.ResponseHeaderTimeout = opts.timeout
+		transport.DialContext = idleReadDialer(transport.DialContext, opts.readTimeout)
+		client.Timeout = 0
+	}
+	transport.Proxy = policyProxy(transport.Proxy, policies)
+	if opts.safeMode || opts.socketPath != "" {
+		transport.Proxy = nil
+	}
+	if opts.safeMode {
+		client.CheckRedirect = refuseRedirect
+	}
+	return &cachedClient{
+		client:    client,
+		transport: transport,
+		recycled:  time.Now(),
+		health:    health,
+	}, nil
+}
+
+// newPolicyClient returns a client for the requests the collector makes on
+// its own behalf, such as discovery and catch-up queries, dialing under the
+// address policy of config like scrapes do
+func newPolicyClient(config plugin.Config, timeout time.Duration) (*http.Client, error) {
+	policies, err := addressPolicies(getStringConfig(config, "allowed_cidrs"), getStringConfig(config, "denied_cidrs"), getBoolConfig(config, "safe_mode"))
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   addressControl(policies...),
+	}
+	transport := &http.Transport{
+		Proxy:                 policyProxy(http.ProxyFromEnvironment, policies),
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func checkTLS(config plugin.Config) ([]string, error) {
+	opts, err := getClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	if opts.certFile != "" {
+		if _, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile); err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: " + err.Error())
+		}
+	}
+	if opts.caFile != "" && !isKubeProxy(config) {
+		pem, err := ioutil.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA file: " + err.Error())
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA file %s", opts.caFile)
+		}
+	}
+	if opts.insecureSkipVerify {
+		return []string{"insecure_skip_verify disables verification of target certificates"}, nil
+	}
+	return nil, nil
+}
+
+// limitRedirects returns a redirect policy following at most max redirects.
+// Past that the redirect response itself is returned, failing the scrape on
+// its status code.
+func limitRedirects(max int64) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if int64(len(via)) > max {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+}
+
+// familyDialer wraps dialer to honor ip_family. Forced families dial only
+// that family; preferred families dial it first and race the other family
+// once the fallback delay passes or the preferred dial fails, for
+// environments where one family is broken and the default order stalls.
+func familyDialer(dialer *net.Dialer, family string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	networks, ok := ipFamilies[family]
+	if !ok || networks[0] == "tcp" {
+		return dialer.DialContext
+	}
+	if networks[1] == "" {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, networks[0], addr)
+		}
+	}
+
+	delay := dialer.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if delay < 0 {
+			conn, err := dialer.DialContext(ctx, networks[0], addr)
+			if err == nil {
+				return conn, nil
+			}
+			return dialer.DialContext(ctx, networks[1], addr)
+		}
+		return raceDial(ctx, dialer, networks, addr, delay)
+	}
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+func raceDial(ctx context.Context, dialer *net.Dialer, networks [2]string, addr string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	dial := func(network string) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		results <- dialResult{conn, err}
+	}
+	go dial(networks[0])
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	started, finished := 1, 0
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if started == 1 {
+				started++
+				go dial(networks[1])
+			}
+		case result := <-results:
+			finished++
+			if result.err == nil {
+				if started > finished {
+					// Close the losing connection if it still succeeds
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if started == 1 {
+				started++
+				go dial(networks[1])
+			} else if finished == started {
+				return nil, firstErr
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientCache(t *testing.T) {
+	Convey("Tasks with the same client settings should share a client", t, func() {
+		clients := newClientCache()
+		opts, err := getClientOptions(plugin.Config{})
+		So(err, ShouldBeNil)
+		first, err := clients.get(opts)
+		So(err, ShouldBeNil)
+		second, err := clients.get(opts)
+		So(err, ShouldBeNil)
+		So(first, ShouldEqual, second)
+
+		other, err := getClientOptions(plugin.Config{"connection_max_age": "1m"})
+		So(err, ShouldBeNil)
+		So(other.connectionMaxAge, ShouldEqual, time.Minute)
+		third, err := clients.get(other)
+		So(err, ShouldBeNil)
+		So(third, ShouldNotEqual, first)
+	})
+
+	Convey("Connections should be recycled after connection_max_age", t, func() {
+		clients := newClientCache()
+		opts := clientOptions{connectionMaxAge: time.Millisecond}
+		clients.get(opts)
+		recycled := clients.clients[opts].recycled
+		time.Sleep(5 * time.Millisecond)
+		clients.get(opts)
+		So(clients.clients[opts].recycled, ShouldHappenAfter, recycled)
+	})
+}
+
+func TestPerJobClients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/metrics", http.StatusFound)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(TEST_DATA))
+	}))
+	defer server.Close()
+
+	Convey("Jobs with different client settings should get their own client", t, func() {
+		clients := newClientCache()
+		plain, err := getClientOptions(plugin.Config{})
+		So(err, ShouldBeNil)
+		external, err := getClientOptions(plugin.Config{
+			"timeout":         "5s",
+			"proxy_url":       "http://proxy:3128",
+			"tls_server_name": "metrics.example.com",
+		})
+		So(err, ShouldBeNil)
+		first, _ := clients.get(plain)
+		second, _ := clients.get(external)
+		So(second, ShouldNotEqual, first)
+		So(second.Timeout, ShouldEqual, 5*time.Second)
+		So(second.Transport.(*http.Transport).TLSClientConfig.ServerName, ShouldEqual, "metrics.example.com")
+	})
+
+	Convey("Scrapes should time out after timeout", t, func() {
+		opts, err := getClientOptions(plugin.Config{})
+		So(err, ShouldBeNil)
+		So(opts.timeout, ShouldEqual, 10*time.Second)
+
+		opts, _ = getClientOptions(plugin.Config{"timeout": "10ms"})
+		client, err := newClientCache().get(opts)
+		So(err, ShouldBeNil)
+		_, err = client.Get(server.URL + "/metrics")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Redirects should only be followed up to max_redirects", t, func() {
+		opts, _ := getClientOptions(plugin.Config{})
+		client, _ := newClientCache().get(opts)
+		resp, err := client.Get(server.URL + "/old")
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		opts, _ = getClientOptions(plugin.Config{"max_redirects": int64(0)})
+		client, _ = newClientCache().get(opts)
+		resp, err = client.Get(server.URL + "/old")
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusFound)
+	})
+}
+
+func TestFamilyDialer(t *testing.T) {
+	Convey("Unknown ip families should be rejected", t, func() {
+		_, err := getClientOptions(plugin.Config{"ip_family": "ipv5"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Preferred families should fall back to the other family", t, func() {
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+		go func() {
+			if conn, err := listener.Accept(); err == nil {
+				conn.Close()
+			}
+		}()
+
+		dial := familyDialer(&net.Dialer{Timeout: time.Second}, "prefer_ipv6")
+		conn, err := dial(context.Background(), "tcp", listener.Addr().String())
+		So(err, ShouldBeNil)
+		So(conn.RemoteAddr().String(), ShouldEqual, listener.Addr().String())
+		conn.Close()
+	})
+
+	Convey("Forced families should not fall back", t, func() {
+		listener, err := net.Listen("tcp4", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+
+		dial := familyDialer(&net.Dialer{Timeout: time.Second}, "ipv6")
+		_, err = dial(context.Background(), "tcp", listener.Addr().String())
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// writeClientCert writes a self-signed client certificate and its key to
+// dir, returning their paths
+func writeClientCert(dir string) (string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "snap-collector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+func TestTLSScrapes(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(TEST_DATA))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := writeClientCert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("HTTPS targets should be scraped with a custom CA and a client certificate", t, func() {
+		downloader := NewHTTPMetricsDownloader()
+		_, err := downloader.GetMetricsReader(server.URL+"/metrics", plugin.Config{
+			"ca_file":   caFile,
+			"cert_file": certFile,
+			"key_file":  keyFile,
+		})
+		So(err, ShouldBeNil)
+
+		_, err = downloader.GetMetricsReader(server.URL+"/metrics", plugin.Config{"ca_file": caFile})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader(server.URL+"/metrics", plugin.Config{"cert_file": certFile, "key_file": keyFile})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader(server.URL+"/metrics", plugin.Config{
+			"insecure_skip_verify": true,
+			"cert_file":            certFile,
+			"key_file":             keyFile,
+		})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("TLS settings should be validated", t, func() {
+		_, err := checkTLS(plugin.Config{"cert_file": certFile})
+		So(err, ShouldNotBeNil)
+		_, err = checkTLS(plugin.Config{"ca_file": keyFile})
+		So(err, ShouldNotBeNil)
+		warnings, err := checkTLS(plugin.Config{"insecure_skip_verify": true})
+		So(err, ShouldBeNil)
+		So(len(warnings), ShouldEqual, 1)
+		_, err = checkSafeMode(plugin.Config{"safe_mode": true, "insecure_skip_verify": true})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,1437 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	stringOption  = "string"
+	integerOption = "integer"
+	booleanOption = "boolean"
+	numberOption  = "number"
+
+	durationFormat = "duration"
+)
+
+// configOption describes a single task config key. The config policy, the
+// printed config schema and DefaultConfig are all built from configOptions,
+// so documentation can't drift from what the plugin accepts.
+type configOption struct {
+	Key         string
+	Type        string
+	Default     interface{}
+	Required    bool
+	Description string
+	// Minimum and Maximum bound integer and number options, nil when unbounded
+	Minimum interface{}
+	Maximum interface{}
+	// Enum lists the accepted values of a string option, for documentation
+	Enum []string
+	// Format marks string options holding structured values, e.g. "duration"
+	Format string
+	// Secret options are redacted from debug dumps
+	Secret bool
+}
+
+var configOptions = []configOption{
+	{
+		Key:         "endpoint",
+		Type:        stringOption,
+		Default:     prometheusEndpoint,
+		Description: "URL of the target; /metrics is appended unless the URL already contains it. Exporters on a unix socket are scraped with unix:///path/to.sock:/metrics",
+	},
+	{
+		Key:         "quantile_format",
+		Type:        stringOption,
+		Default:     "legacy",
+		Enum:        []string{"legacy", "percent", "fraction", "tag"},
+		Description: "how schema 1 names summary quantiles: legacy quantile_99 truncating 0.999 to 99, percent quantile_99_9, fraction quantile_0_999, or tag summary=quantile with a quantile=0.999 tag",
+	},
+	{
+		Key:         "quantile_digits",
+		Type:        integerOption,
+		Default:     int64(6),
+		Minimum:     int64(1),
+		Maximum:     int64(17),
+		Description: "significant digits quantiles are rounded to with the percent and fraction quantile_format",
+	},
+	{
+		Key:         "nan_policy",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "drop", "zero", "pass"},
+		Description: "how NaN and infinite values of every metric type are handled: drop the metric, zero the value, or pass them through; when empty NaN quantiles are dropped and other values pass",
+	},
+	{
+		Key:         "namespace_prefix",
+		Type:        stringOption,
+		Default:     "",
+		Description: "namespace root of collected metrics, e.g. /acme/monitoring, /hyperpilot/prometheus when empty",
+	},
+	{
+		Key:         "job",
+		Type:        stringOption,
+		Description: "name of the scrape job, added to the namespace after the prefix",
+	},
+	{
+		Key:         "catalog_ttl",
+		Type:        stringOption,
+		Default:     "10m",
+		Format:      durationFormat,
+		Description: "how long the metric catalog of a task config is reused before it is built again, sooner when a collection finds families it doesn't list; 0s builds it every time",
+	},
+	{
+		Key:         "static_targets",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of targets with their tags, e.g. [{"address": "10.0.0.1:9100", "labels": {"rack": "a1"}}]; the address is host:port or a full URL`,
+	},
+	{
+		Key:         "endpoints",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated or JSON list of endpoint URLs scraped instead of endpoint, each metric tagged with its endpoint",
+	},
+	{
+		Key:         "fallback_endpoints",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated or JSON list of endpoint URLs scraped only while every primary target fails, metrics tagged with scrape_source primary or secondary",
+	},
+	{
+		Key:         "scrape_concurrency",
+		Type:        integerOption,
+		Default:     int64(1),
+		Minimum:     int64(1),
+		Description: "number of targets of the task scraped at once",
+	},
+	{
+		Key:         "probe_metric_types",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "scrape the first target when the plugin loads and list each family it exposes in the metric catalog, with its description and unit",
+	},
+	{
+		Key:         "preflight",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "check the DNS, TCP, TLS, auth, HTTP status and content of every target on the task's first collection, logging a report and emitting preflight_ready per target",
+	},
+	{
+		Key:         "scrape_health_metrics",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "emit up (1 or 0) and scrape_duration_seconds per target, tagged like the target's series",
+	},
+	{
+		Key:         "parse_concurrency",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "number of bodies parsed at once in a pool of their own, so scrape workers only download, 0 parses on the scrape workers",
+	},
+	{
+		Key:         "parse_queue_size",
+		Type:        integerOption,
+		Default:     int64(4),
+		Minimum:     int64(0),
+		Description: "downloaded bodies waiting for a parser before scrape workers block, with parse_concurrency",
+	},
+	{
+		Key:         "target_timeout",
+		Type:        stringOption,
+		Default:     "0s",
+		Format:      durationFormat,
+		Description: "time limit of a whole target scrape including parsing, only of its download with parse_concurrency, enforced whatever the downloader, 0s for none",
+	},
+	{
+		Key:         "max_concurrent_scrapes",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "scrapes running at once across all tasks, waiting scrapes taking turns by job, unbounded when 0; the latest task's value applies",
+	},
+	{
+		Key:         "downloader",
+		Type:        stringOption,
+		Default:     "",
+		Description: "name of a downloader registered by the embedding program with RegisterDownloader, the plugin's HTTP downloader when empty",
+	},
+	{
+		Key:         "accept_encoding",
+		Type:        stringOption,
+		Default:     "gzip",
+		Enum:        []string{"gzip", "identity"},
+		Description: "encoding asked for, gzip bodies are decompressed before size limits apply, identity asks for uncompressed bodies",
+	},
+	{
+		Key:         "exposition_format",
+		Type:        stringOption,
+		Default:     "text",
+		Enum:        []string{"text", "protobuf"},
+		Description: "exposition format asked for, protobuf is cheaper to parse on large endpoints and falls back to text when targets don't support it",
+	},
+	{
+		Key:         "method",
+		Type:        stringOption,
+		Default:     "GET",
+		Enum:        []string{"GET", "POST"},
+		Description: "HTTP method scraping the targets",
+	},
+	{
+		Key:         "request_body",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: `body sent with POST scrapes, e.g. {"tenant": "team-a"}`,
+	},
+	{
+		Key:         "request_content_type",
+		Type:        stringOption,
+		Default:     "application/json",
+		Description: "content type of request_body",
+	},
+	{
+		Key:         "bearer_token",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "bearer token sent to the targets",
+	},
+	{
+		Key:         "bearer_token_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "file holding the bearer token sent to the targets, read at every scrape",
+	},
+	{
+		Key:         "username",
+		Type:        stringOption,
+		Default:     "",
+		Description: "basic auth username sent to the targets",
+	},
+	{
+		Key:         "password",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "basic auth password of username",
+	},
+	{
+		Key:         "signature_algorithm",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "hmac-sha256", "ed25519"},
+		Description: "algorithm of the detached payload signature to verify, empty to skip verification",
+	},
+	{
+		Key:         "signature_key",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "HMAC secret, or base64 Ed25519 public key, verifying payload signatures",
+	},
+	{
+		Key:         "signature_header",
+		Type:        stringOption,
+		Default:     "X-Signature",
+		Description: "response header carrying the base64 payload signature",
+	},
+	{
+		Key:         "discovery",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "nomad", "azure", "gce", "etcd", "kubernetes", "file"},
+		Description: "service discovery backend finding the targets, scrape endpoint when empty",
+	},
+	{
+		Key:         "targets_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON targets file of the file discovery, in the file_sd format [{"targets": ["10.0.0.1:9100"], "labels": {"rack": "a1"}}], reread when it changes; ` + configFile + ` when empty`,
+	},
+	{
+		Key:         "discovery_refresh_interval",
+		Type:        stringOption,
+		Default:     "30s",
+		Format:      durationFormat,
+		Description: "how often discovered targets are refreshed",
+	},
+	{
+		Key:         "scheme",
+		Type:        stringOption,
+		Default:     "http",
+		Enum:        []string{"http", "https"},
+		Description: "scheme used to scrape discovered targets",
+	},
+	{
+		Key:         "metrics_path",
+		Type:        stringOption,
+		Default:     "/metrics",
+		Description: "path scraped on discovered targets, and on an endpoint without a path when append_metrics_path is false",
+	},
+	{
+		Key:         "append_metrics_path",
+		Type:        booleanOption,
+		Default:     true,
+		Description: "append /metrics to the endpoint unless it contains /metrics, false scrapes the endpoint as given",
+	},
+	{
+		Key:         "nomad_address",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Nomad API address, NOMAD_ADDR or http://127.0.0.1:4646 when empty",
+	},
+	{
+		Key:         "nomad_token",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "Nomad ACL token, NOMAD_TOKEN when empty",
+	},
+	{
+		Key:         "nomad_namespace",
+		Type:        stringOption,
+		Default:     "default",
+		Description: "Nomad namespace to discover services in, * for all namespaces",
+	},
+	{
+		Key:         "nomad_tag",
+		Type:        stringOption,
+		Default:     "",
+		Description: "only scrape Nomad services carrying this tag",
+	},
+	{
+		Key:         "azure_subscription_id",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Azure subscription holding azure_resource_group",
+	},
+	{
+		Key:         "azure_resource_group",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Azure resource group whose VMs and scale set instances are scraped",
+	},
+	{
+		Key:         "azure_tag",
+		Type:        stringOption,
+		Default:     "",
+		Description: "only scrape Azure VMs carrying this tag, as key or key=value",
+	},
+	{
+		Key:         "azure_port",
+		Type:        integerOption,
+		Default:     int64(9100),
+		Minimum:     int64(1),
+		Maximum:     int64(65535),
+		Description: "port scraped on discovered Azure VMs",
+	},
+	{
+		Key:         "azure_client_id",
+		Type:        stringOption,
+		Default:     "",
+		Description: "client ID of a user assigned managed identity, or of the service principal when azure_client_secret is set",
+	},
+	{
+		Key:         "azure_tenant_id",
+		Type:        stringOption,
+		Default:     "",
+		Description: "tenant of the service principal",
+	},
+	{
+		Key:         "azure_client_secret",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "service principal secret, the VM's managed identity is used when empty",
+	},
+	{
+		Key:         "gce_project",
+		Type:        stringOption,
+		Default:     "",
+		Description: "GCE project to discover instances in, from the credentials or metadata server when empty",
+	},
+	{
+		Key:         "gce_zone",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated GCE zones to discover instances in",
+	},
+	{
+		Key:         "gce_filter",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Compute API filter selecting instances, e.g. labels.env=prod",
+	},
+	{
+		Key:         "gce_port",
+		Type:        integerOption,
+		Default:     int64(9100),
+		Minimum:     int64(1),
+		Maximum:     int64(65535),
+		Description: "port scraped on discovered GCE instances",
+	},
+	{
+		Key:         "gce_labels",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated GCE labels copied to gce_label_<name> tags",
+	},
+	{
+		Key:         "gce_credentials_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "service account JSON key, the metadata server (instance service account or workload identity) when empty",
+	},
+	{
+		Key:         "etcd_endpoints",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated etcd v3 client URLs holding the target registry",
+	},
+	{
+		Key:         "etcd_prefix",
+		Type:        stringOption,
+		Default:     "",
+		Description: "etcd key prefix whose keys hold target JSON entries",
+	},
+	{
+		Key:         "targets_file_export",
+		Type:        stringOption,
+		Default:     "",
+		Description: "path the active targets are written to in Prometheus file_sd JSON format, not written when empty",
+	},
+	{
+		Key:         "admin_listen",
+		Type:        stringOption,
+		Default:     "",
+		Description: "address of the admin endpoint pausing and resuming jobs and targets (e.g. 127.0.0.1:9998), shared by all tasks, not served when empty; a non-loopback address requires admin_token",
+	},
+	{
+		Key:         "admin_token",
+		Type:        stringOption,
+		Default:     "",
+		Secret:      true,
+		Description: "bearer token every admin endpoint request must carry in its Authorization header, none when empty",
+	},
+	{
+		Key:         "admin_dump_dir",
+		Type:        stringOption,
+		Default:     "",
+		Description: "directory debug dumps requested from the admin endpoint are written to, the system temp directory when empty",
+	},
+	{
+		Key:         "active_windows",
+		Type:        stringOption,
+		Default:     "",
+		Description: "';' separated windows the task collects in, e.g. Mon-Fri 09:00-18:00; Sat 10:00-12:00, always when empty",
+	},
+	{
+		Key:         "active_windows_timezone",
+		Type:        stringOption,
+		Default:     "Local",
+		Description: "IANA time zone of active_windows, e.g. Europe/Amsterdam",
+	},
+	{
+		Key:         "outside_window",
+		Type:        stringOption,
+		Default:     "skip",
+		Enum:        []string{"skip", "up_only"},
+		Description: "outside active_windows, skip collecting or scrape targets and only emit their up metric",
+	},
+	{
+		Key:         "warmup_delay",
+		Type:        stringOption,
+		Default:     "0s",
+		Format:      durationFormat,
+		Description: "how long after the plugin starts tasks wait before collecting",
+	},
+	{
+		Key:         "warmup_tag",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "tag the metrics of the first collection of a task with warmup=true",
+	},
+	{
+		Key:         "catchup_url",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Prometheus server the first collection of a task backfills catchup_selectors from",
+	},
+	{
+		Key:         "catchup_selectors",
+		Type:        stringOption,
+		Default:     "",
+		Description: "';' separated series selectors backfilled from catchup_url, e.g. up{job=\"node\"}; http_requests_total",
+	},
+	{
+		Key:         "catchup_window",
+		Type:        stringOption,
+		Default:     "0s",
+		Format:      durationFormat,
+		Description: "how far back the first collection backfills, disabled when 0s",
+	},
+	{
+		Key:         "catchup_step",
+		Type:        stringOption,
+		Default:     "1m",
+		Format:      durationFormat,
+		Description: "resolution of backfilled samples",
+	},
+	{
+		Key:         "catchup_max_samples",
+		Type:        integerOption,
+		Default:     int64(100000),
+		Minimum:     int64(1),
+		Description: "maximum number of backfilled samples",
+	},
+	{
+		Key:         "host_tags",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated collector host metadata tagged on every metric: hostname, node_name and instance_id (from the AWS, GCE or Azure metadata service)",
+	},
+	{
+		Key:         "extra_tags",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON object of tags added to every metric, e.g. {"cluster": "prod", "region": "us-east"}; tags a metric already has win`,
+	},
+	{
+		Key:         "node_name_env",
+		Type:        stringOption,
+		Default:     "NODE_NAME",
+		Description: "environment variable holding the node name, set from spec.nodeName through the downward API",
+	},
+	{
+		Key:         "topology_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "CSV or JSON file mapping target host:port or host to tags such as site, rack and region, reloaded when it changes",
+	},
+	{
+		Key:         "schema",
+		Type:        integerOption,
+		Default:     int64(1),
+		Minimum:     int64(1),
+		Maximum:     int64(2),
+		Description: "how summaries and histograms map to metrics: 1 tags their parts within the family namespace, 2 splits _sum, _count and _bucket namespaces like Prometheus",
+	},
+	{
+		Key:         "schema_tag",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "tag every metric with its schema version, always on during a migration window",
+	},
+	{
+		Key:         "schema_migration_from",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Maximum:     int64(2),
+		Description: "previous schema also emitted until schema_migration_until, 0 for none",
+	},
+	{
+		Key:         "schema_migration_until",
+		Type:        stringOption,
+		Default:     "",
+		Description: "RFC 3339 time the migration window from schema_migration_from ends, e.g. 2026-12-01T00:00:00Z",
+	},
+	{
+		Key:         "tag_extractions",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of regexes whose named groups become tags, matched on a tag or on __name__, e.g. [{"source": "queue_name", "regex": "(?P<queue>[^.]+)\\.(?P<shard>\\d+)"}]`,
+	},
+	{
+		Key:         "value_mappings",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON object of tag name to value mappings, the first matching equals, range or regex wins, e.g. {"code": [{"range": [200, 299], "value": "2xx"}]}`,
+	},
+	{
+		Key:         "convert_units",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "convert values of metrics named with a unit suffix, e.g. _milliseconds or _kilobytes, to seconds or bytes and set their unit",
+	},
+	{
+		Key:         "unit_mappings",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON object of name suffix to unit and factor extending the convert_units table, e.g. {"_minutes": {"unit": "s", "factor": 60}}`,
+	},
+	{
+		Key:         "emit_tombstones",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "emit a series_removed metric once for every series that disappeared since the previous scrape",
+	},
+	{
+		Key:         "emit_new_families",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "emit a new_metric_family metric once for every family a target starts exposing",
+	},
+	{
+		Key:         "emit_batch_metadata",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "emit a collection_batch metric per collection with its batch id, target, failure and sample counts, truncated flag and duration",
+	},
+	{
+		Key:         "emit_collection_duration",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "emit a collection_duration_seconds histogram of the task's collection durations, usable by slos and alert_rules",
+	},
+	{
+		Key:         "collection_duration_buckets",
+		Type:        stringOption,
+		Default:     "0.1,0.25,0.5,1,2.5,5,10,30",
+		Description: "comma separated upper bounds in seconds of the collection_duration_seconds buckets",
+	},
+	{
+		Key:         "counter_rates",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "rate", "delta"},
+		Description: "also emit every counter's per second rate as <counter>_rate, or its increase as <counter>_delta, since the previous collection, with reset detection",
+	},
+	{
+		Key:         "downsample_window",
+		Type:        stringOption,
+		Default:     "",
+		Format:      durationFormat,
+		Description: "window, usually the task interval, over which counters are emitted as their average rate per second tagged downsampled=avg_rate instead of a point-in-time sample",
+	},
+	{
+		Key:         "downsample_scrapes",
+		Type:        integerOption,
+		Default:     int64(5),
+		Minimum:     int64(2),
+		Description: "number of scrapes per downsample_window, spread evenly over it",
+	},
+	{
+		Key:         "relabel_configs",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of Prometheus style relabel configs run on converted metrics, actions replace, keep, drop and labelmap, e.g. [{"source_labels": ["pod"], "regex": "(.+)-[a-z0-9]+", "target_label": "app"}]`,
+	},
+	{
+		Key:         "exporter",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "auto", "node_exporter", "kube-state-metrics", "cadvisor", "envoy"},
+		Description: "exporter the targets are, tagged as exporter: auto detects node_exporter, kube-state-metrics, cadvisor and envoy from their families, an exporter tag of the target wins, empty for none",
+	},
+	{
+		Key:         "exporter_profile",
+		Type:        booleanOption,
+		Default:     true,
+		Description: "run the built-in relabel configs of the detected exporter before relabel_configs",
+	},
+	{
+		Key:         "max_tag_bytes",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "largest size in bytes of a metric's tag names and values, larger tags are trimmed in tag_trim_order and tagged trimmed=true, unlimited when 0",
+	},
+	{
+		Key:         "tag_trim_order",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated tags dropped first, in order, from metrics over max_tag_bytes; the largest remaining tags are dropped next",
+	},
+	{
+		Key:         "max_line_length",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "longest exposition line in bytes parsed, longer lines are handled by long_lines and counted in a warning, unlimited when 0",
+	},
+	{
+		Key:         "long_lines",
+		Type:        stringOption,
+		Default:     "skip",
+		Enum:        []string{"skip", "truncate"},
+		Description: "skip lines longer than max_line_length, or truncate them when they are HELP lines and skip the others",
+	},
+	{
+		Key:         "filtered_summary_interval",
+		Type:        stringOption,
+		Default:     "0s",
+		Format:      durationFormat,
+		Description: "how often to emit filtered_samples_total, the samples include_metrics, exclude_metrics, keep_label_matchers and drop_label_matchers dropped since the task started, tagged with the rule; disabled when 0s",
+	},
+	{
+		Key:         "include_metrics",
+		Type:        stringOption,
+		Default:     "",
+		Description: "anchored regex of the scraped family names kept, every family when empty",
+	},
+	{
+		Key:         "exclude_metrics",
+		Type:        stringOption,
+		Default:     "",
+		Description: "anchored regex of the scraped family names dropped before conversion, applied after include_metrics",
+	},
+	{
+		Key:         "keep_label_matchers",
+		Type:        stringOption,
+		Default:     "",
+		Description: "JSON object of label name to anchored regex, e.g. {\"container\": \"app\"}, only series matching all of them are kept",
+	},
+	{
+		Key:         "drop_label_matchers",
+		Type:        stringOption,
+		Default:     "",
+		Description: "JSON object of label name to anchored regex, e.g. {\"namespace\": \"kube-system\"}, series matching all of them are dropped",
+	},
+	{
+		Key:         "routing_rules",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of metric name regexes tagging matching metrics with a route for downstream processors and publishers, first match wins, e.g. [{"metric": "http_.*", "route": "kafka"}]`,
+	},
+	{
+		Key:         "routing_tag",
+		Type:        stringOption,
+		Default:     "route",
+		Description: "tag carrying the route of routing_rules",
+	},
+	{
+		Key:         "labels_in_namespace",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated labels also appended to the namespace as dynamic elements, for publishers needing them there",
+	},
+	{
+		Key:         "namespace_routes",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of routes moving the metrics whose label matches a regex under a namespace sub-prefix, first match wins, e.g. [{"label": "team", "value": "payments|billing", "prefix": "$0"}]`,
+	},
+	{
+		Key:         "timestamp_copy",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "tag", "data"},
+		Description: "also copy metric timestamps in milliseconds into a tag or into the data as {value, ts_ms}, for publishers ignoring timestamps",
+	},
+	{
+		Key:         "timestamp_tag",
+		Type:        stringOption,
+		Default:     "ts_ms",
+		Description: "tag carrying the timestamp when timestamp_copy is tag",
+	},
+	{
+		Key:         "default_route",
+		Type:        stringOption,
+		Default:     "",
+		Description: "route of metrics no routing rule matches, untagged when empty",
+	},
+	{
+		Key:         "recording_rules",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of rules recording a rate and/or sum, avg, min, max or count by tags of a metric as a new metric, e.g. [{"record": "job:http_requests:rate", "metric": "http_requests_total", "rate": true, "aggregate": "sum", "by": ["job"]}]`,
+	},
+	{
+		Key:         "anomaly_families",
+		Type:        stringOption,
+		Default:     "",
+		Description: "regex of the families whose samples are tagged anomaly=true when their z-score against the moving mean and variance of their series exceeds anomaly_threshold",
+	},
+	{
+		Key:         "anomaly_threshold",
+		Type:        numberOption,
+		Default:     3.0,
+		Description: "z-score above which a sample of anomaly_families is an anomaly",
+	},
+	{
+		Key:         "anomaly_alpha",
+		Type:        numberOption,
+		Default:     0.1,
+		Minimum:     0.0,
+		Maximum:     1.0,
+		Description: "weight of the latest sample in the moving mean and variance of anomaly_families, higher adapts faster",
+	},
+	{
+		Key:         "anomaly_min_samples",
+		Type:        integerOption,
+		Default:     int64(10),
+		Minimum:     int64(1),
+		Description: "samples a series of anomaly_families needs before its samples are flagged",
+	},
+	{
+		Key:         "anomaly_score",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "also emit anomaly_score, the z-score of every sample of anomaly_families, tagged with its metric_name",
+	},
+	{
+		Key:         "slos",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of objectives emitting slo_ratio and slo_burn_rate per window from good and total event counters, e.g. [{"name": "api", "good": {"metric": "http_requests_total", "match": {"code": "200"}}, "total": {"metric": "http_requests_total"}, "objective": 0.999, "windows": ["5m", "1h"]}]`,
+	},
+	{
+		Key:         "alert_rules",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON list of threshold rules emitting a 0/1 alert_state metric per series, e.g. [{"name": "HighErrors", "metric": "http_errors_total", "match": {"code": "500"}, "op": ">", "threshold": 10, "for": "5m"}]`,
+	},
+	{
+		Key:         "recording_mode",
+		Type:        stringOption,
+		Default:     "",
+		Enum:        []string{"", "record", "replay"},
+		Description: "record saves every scrape to recording_dir, replay serves the recorded scrapes instead of scraping targets",
+	},
+	{
+		Key:         "recording_dir",
+		Type:        stringOption,
+		Default:     "",
+		Description: "directory holding the scrapes of recording_mode",
+	},
+	{
+		Key:         "recording_max_scrapes",
+		Type:        integerOption,
+		Default:     int64(1000),
+		Minimum:     int64(1),
+		Description: "scrapes recorded per target at most, later scrapes are served without being recorded",
+	},
+	{
+		Key:         "fault_rate",
+		Type:        numberOption,
+		Default:     0.0,
+		Minimum:     0.0,
+		Maximum:     1.0,
+		Description: "share of scrapes faults are injected into, for testing error handling in staging",
+	},
+	{
+		Key:         "fault_kinds",
+		Type:        stringOption,
+		Default:     "delay,error,truncate,corrupt",
+		Description: "comma separated faults injected: delay, error, truncate and corrupt",
+	},
+	{
+		Key:         "fault_delay",
+		Type:        stringOption,
+		Default:     "5s",
+		Format:      durationFormat,
+		Description: "how long delay faults hold a scrape",
+	},
+	{
+		Key:         "audit_log",
+		Type:        stringOption,
+		Default:     "",
+		Description: "absolute path of a regular file, or stdout, the JSON lines of loaded task configs, added or removed discovered targets and admin endpoint requests are appended to",
+	},
+	{
+		Key:         "allowed_cidrs",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated networks scrapes may connect to after DNS resolution, e.g. 10.0.0.0/8, any address not denied when empty",
+	},
+	{
+		Key:         "denied_cidrs",
+		Type:        stringOption,
+		Default:     "169.254.0.0/16,fe80::/10",
+		Description: "comma separated networks scrapes may not connect to after DNS resolution, link-local and cloud metadata addresses by default",
+	},
+	{
+		Key:         "safe_mode",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "for less-trusted task configs: only scrape private addresses without proxy or redirects, cap body size, samples and labels, and refuse options reading local files, service discovery and catch-up; SNAP_PROMETHEUS_SAFE_MODE enforces it on every task",
+	},
+	{
+		Key:         "low_memory",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "profile for edge gateways: bodies over 256KiB spilled to disk, 4MiB bodies and 10000 samples at most, no descriptions, more frequent GC",
+	},
+	{
+		Key:         "spill_threshold",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "response bodies larger than this many bytes are streamed to a temp file before parsing, 0 keeps every body in memory",
+	},
+	{
+		Key:         "spill_dir",
+		Type:        stringOption,
+		Default:     "",
+		Description: "directory for spilled response bodies, the system temp dir when empty",
+	},
+	{
+		Key:         "timeout",
+		Type:        stringOption,
+		Default:     "10s",
+		Format:      durationFormat,
+		Description: "time limit of a scrape including reading the body, so a hung target can't stall the collection, 0s for none",
+	},
+	{
+		Key:         "read_timeout",
+		Type:        stringOption,
+		Default:     "0s",
+		Format:      durationFormat,
+		Description: "longest a scrape waits for the next bytes of the target; when set, timeout only bounds the wait for the response headers and bodies are parsed as they arrive, so large, slow but progressing bodies such as windows_exporter's aren't cut off",
+	},
+	{
+		Key:         "proxy_url",
+		Type:        stringOption,
+		Default:     "",
+		Description: "proxy scrapes go through, e.g. http://proxy:3128, the HTTP_PROXY environment variables are used when empty; targets are checked against the address policy before going through it, so they must resolve locally",
+	},
+	{
+		Key:         "no_proxy",
+		Type:        stringOption,
+		Default:     "",
+		Description: "comma separated hosts, domains, IPs and CIDRs scraped without proxy_url, NO_PROXY when empty",
+	},
+	{
+		Key:         "scrape_retries",
+		Type:        integerOption,
+		Default:     int64(0),
+		Minimum:     int64(0),
+		Description: "times a scrape failing without a response, or with a 5xx or 429 status, is retried before the target is given up on for the collection",
+	},
+	{
+		Key:         "scrape_retry_backoff",
+		Type:        stringOption,
+		Default:     "100ms",
+		Format:      durationFormat,
+		Description: "wait before the first scrape retry, doubled for every following retry",
+	},
+	{
+		Key:         "scrape_retry_max_backoff",
+		Type:        stringOption,
+		Default:     "2s",
+		Format:      durationFormat,
+		Description: "longest wait between scrape retries",
+	},
+	{
+		Key:         "success_status_codes",
+		Type:        stringOption,
+		Default:     "200",
+		Description: "comma separated HTTP status codes or classes a scrape succeeds with, e.g. 200,203,206 or 2xx; other statuses fail the scrape",
+	},
+	{
+		Key:         "target_success_status_codes",
+		Type:        stringOption,
+		Default:     "",
+		Description: `JSON object of target URL or host:port to the status codes its scrapes succeed with, overriding success_status_codes, e.g. {"10.0.0.1:9100": "200,206"}`,
+	},
+	{
+		Key:         "max_redirects",
+		Type:        integerOption,
+		Default:     int64(10),
+		Minimum:     int64(0),
+		Description: "redirects a scrape follows, 0 to fail scrapes answering with a redirect",
+	},
+	{
+		Key:         "ca_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "PEM CA bundle verifying HTTPS targets, the system roots when empty",
+	},
+	{
+		Key:         "cert_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "PEM client certificate presented to HTTPS targets, reloaded at every TLS handshake",
+	},
+	{
+		Key:         "key_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "PEM private key of cert_file",
+	},
+	{
+		Key:         "insecure_skip_verify",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "don't verify the certificate of HTTPS targets",
+	},
+	{
+		Key:         "tls_server_name",
+		Type:        stringOption,
+		Default:     "",
+		Description: "server name verified against the certificate of HTTPS targets, the target host when empty",
+	},
+	{
+		Key:         "connection_max_age",
+		Type:        stringOption,
+		Default:     "",
+		Format:      durationFormat,
+		Description: "re-establish idle connections to the target this often (e.g. 5m) so scrapes follow DNS and load balancer changes, never when empty",
+	},
+	{
+		Key:         "ip_family",
+		Type:        stringOption,
+		Default:     "any",
+		Enum:        []string{"any", "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6"},
+		Description: "address families dialed: any follows DNS order, ipv4/ipv6 dial only that family, prefer_* dial it first and fall back to the other",
+	},
+	{
+		Key:         "retry_addresses",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "when a target host resolves to several addresses, dial them in turn until one connects, skipping addresses failing repeatedly for 30s",
+	},
+	{
+		Key:         "fallback_delay",
+		Type:        stringOption,
+		Default:     "",
+		Format:      durationFormat,
+		Description: "how long a dial waits before racing the other address family, 300ms when empty, a negative duration disables racing",
+	},
+	{
+		Key:         "kube_pod",
+		Type:        stringOption,
+		Default:     "",
+		Description: "scrape this pod through the Kubernetes API server proxy instead of endpoint",
+	},
+	{
+		Key:         "kube_service",
+		Type:        stringOption,
+		Default:     "",
+		Description: "scrape this service through the Kubernetes API server proxy instead of endpoint",
+	},
+	{
+		Key:         "kube_namespace",
+		Type:        stringOption,
+		Default:     "default",
+		Description: "namespace of kube_pod or kube_service",
+	},
+	{
+		Key:         "kube_port",
+		Type:        stringOption,
+		Default:     "",
+		Description: "port name or number of kube_pod or kube_service, the default port when empty",
+	},
+	{
+		Key:         "kube_metrics_path",
+		Type:        stringOption,
+		Default:     "/metrics",
+		Description: "metrics path on kube_pod or kube_service",
+	},
+	{
+		Key:         "kube_apiserver",
+		Type:        stringOption,
+		Default:     "",
+		Description: "Kubernetes API server URL, the in-cluster API server when empty",
+	},
+	{
+		Key:         "kube_token_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "bearer token file authenticating to the API server, the pod's service account token when empty",
+	},
+	{
+		Key:         "kube_ca_file",
+		Type:        stringOption,
+		Default:     "",
+		Description: "CA bundle verifying the API server, the pod's service account CA when empty",
+	},
+	{
+		Key:         "kube_discovery_role",
+		Type:        stringOption,
+		Default:     "pod",
+		Enum:        []string{"pod", "service"},
+		Description: "kind of object annotated with prometheus.io/scrape=true the kubernetes discovery scrapes",
+	},
+	{
+		Key:         "kube_discovery_namespace",
+		Type:        stringOption,
+		Default:     "",
+		Description: "namespace the kubernetes discovery looks in, every namespace when empty",
+	},
+	{
+		Key:         "node_local_only",
+		Type:        booleanOption,
+		Default:     false,
+		Description: "keep only the discovered pods scheduled on the node named by node_name_env, for collectors deployed as a DaemonSet",
+	},
+}
+
+// DefaultConfig returns the config a task gets when it sets nothing, for
+// running the collector outside of Snap
+func DefaultConfig() plugin.Config {
+	config := plugin.Config{}
+	for _, option := range configOptions {
+		if option.Default != nil {
+			config[option.Key] = option.Default
+		}
+	}
+	return config
+}
+
+// ParseConfig reads a flat JSON object into a plugin config on top of
+// DefaultConfig, for running the collector outside of Snap. Values are
+// converted to the type of their option as Snap would pass them, so 3.0 is
+// a float for a number option and 3 for an integer one.
+func ParseConfig(content []byte) (plugin.Config, error) {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string, len(configOptions))
+	for _, option := range configOptions {
+		types[option.Key] = option.Type
+	}
+	config := DefaultConfig()
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			if types[key] != "" && types[key] != stringOption {
+				return nil, fmt.Errorf("config key %s must be a %s", key, types[key])
+			}
+			config[key] = v
+		case bool:
+			if types[key] != "" && types[key] != booleanOption {
+				return nil, fmt.Errorf("config key %s must be a %s", key, types[key])
+			}
+			config[key] = v
+		case float64:
+			switch types[key] {
+			case numberOption:
+				config[key] = v
+			case integerOption:
+				if v != math.Trunc(v) {
+					return nil, fmt.Errorf("config key %s must be an integer", key)
+				}
+				config[key] = int64(v)
+			case "":
+				// Unknown keys are left to validation, whole numbers
+				// becoming ints
+				if v == math.Trunc(v) {
+					config[key] = int64(v)
+				} else {
+					config[key] = v
+				}
+			default:
+				return nil, fmt.Errorf("config key %s must be a %s", key, types[key])
+			}
+		default:
+			return nil, fmt.Errorf("config key %s must be a string, number or boolean", key)
+		}
+	}
+	return config, nil
+}
+
+// enforce returns config with the settings the collector imposes on every
+// task whatever its config: safe mode with WithSafeMode, and the networks of
+// WithDeniedCIDRs added to its denied_cidrs
+func (c *PrometheusCollector) enforce(config plugin.Config) plugin.Config {
+	if !c.safeMode && c.deniedCIDRs == "" {
+		return config
+	}
+	enforced := make(plugin.Config, len(config)+2)
+	for key, value := range config {
+		enforced[key] = value
+	}
+	if c.safeMode {
+		enforced["safe_mode"] = true
+	}
+	if c.deniedCIDRs != "" {
+		denied := getStringConfig(config, "denied_cidrs")
+		if denied != "" {
+			denied += ","
+		}
+		enforced["denied_cidrs"] = denied + c.deniedCIDRs
+	}
+	return enforced
+}
+
+// getStringConfig returns a string option, falling back to its default
+// when the task doesn't set it
+func getStringConfig(config plugin.Config, key string) string {
+	if value, err := config.GetString(key); err == nil {
+		return value
+	}
+	value, _ := optionDefault(key).(string)
+	return value
+}
+
+// getIntConfig returns an integer option, falling back to its default when
+// the task doesn't set it
+func getIntConfig(config plugin.Config, key string) int64 {
+	if value, err := config.GetInt(key); err == nil {
+		return value
+	}
+	value, _ := optionDefault(key).(int64)
+	return value
+}
+
+// getBoolConfig returns a boolean option, falling back to its default when
+// the task doesn't set it
+func getBoolConfig(config plugin.Config, key string) bool {
+	if value, err := config.GetBool(key); err == nil {
+		return value
+	}
+	value, _ := optionDefault(key).(bool)
+	return value
+}
+
+// getFloatConfig returns a number option, falling back to its default when
+// the task doesn't set it
+func getFloatConfig(config plugin.Config, key string) float64 {
+	if value, err := config.GetFloat(key); err == nil {
+		return value
+	}
+	value, _ := optionDefault(key).(float64)
+	return value
+}
+
+// getDurationConfig parses a duration option such as "10s". An empty value
+// means the zero duration.
+func getDurationConfig(config plugin.Config, key string) (time.Duration, error) {
+	value := getStringConfig(config, key)
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 10s: %s", key, err.Error())
+	}
+	return duration, nil
+}
+
+// splitList splits a comma separated config value, dropping empty items
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func optionDefault(key string) interface{} {
+	for _, option := range configOptions {
+		if option.Key == key {
+			return option.Default
+		}
+	}
+	return nil
+}
+
+// GetConfigPolicy returns a ConfigPolicyTree for testing
+func (c *PrometheusCollector) GetConfigPolicy() (plugin.ConfigPolicy, error) {
+	policy := plugin.NewConfigPolicy()
+
+	// namespace
+	configKey := namespacePrefix
+	for _, option := range configOptions {
+		if err := addConfigRule(policy, configKey, option); err != nil {
+			return *policy, err
+		}
+	}
+
+	return *policy, nil
+}
+
+func addConfigRule(policy *plugin.ConfigPolicy, configKey []string, option configOption) error {
+	switch option.Type {
+	case stringOption:
+		if option.Default == nil {
+			return policy.AddNewStringRule(configKey, option.Key, option.Required)
+		}
+		return policy.AddNewStringRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultString(option.Default.(string)))
+
+	case integerOption:
+		return addIntRule(policy, configKey, option)
+
+	case booleanOption:
+		if option.Default == nil {
+			return policy.AddNewBoolRule(configKey, option.Key, option.Required)
+		}
+		return policy.AddNewBoolRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultBool(option.Default.(bool)))
+
+	case numberOption:
+		return addFloatRule(policy, configKey, option)
+
+	default:
+		return fmt.Errorf("Unknown type %s of config option %s", option.Type, option.Key)
+	}
+}
+
+// addIntRule adds the rule of an integer option, its bounds applying with or
+// without a default
+func addIntRule(policy *plugin.ConfigPolicy, configKey []string, option configOption) error {
+	if option.Default == nil {
+		switch {
+		case option.Minimum != nil && option.Maximum != nil:
+			return policy.AddNewIntRule(configKey, option.Key, option.Required,
+				plugin.SetMinInt(option.Minimum.(int64)),
+				plugin.SetMaxInt(option.Maximum.(int64)))
+		case option.Minimum != nil:
+			return policy.AddNewIntRule(configKey, option.Key, option.Required,
+				plugin.SetMinInt(option.Minimum.(int64)))
+		case option.Maximum != nil:
+			return policy.AddNewIntRule(configKey, option.Key, option.Required,
+				plugin.SetMaxInt(option.Maximum.(int64)))
+		}
+		return policy.AddNewIntRule(configKey, option.Key, option.Required)
+	}
+
+	switch {
+	case option.Minimum != nil && option.Maximum != nil:
+		return policy.AddNewIntRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultInt(option.Default.(int64)),
+			plugin.SetMinInt(option.Minimum.(int64)),
+			plugin.SetMaxInt(option.Maximum.(int64)))
+	case option.Minimum != nil:
+		return policy.AddNewIntRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultInt(option.Default.(int64)),
+			plugin.SetMinInt(option.Minimum.(int64)))
+	case option.Maximum != nil:
+		return policy.AddNewIntRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultInt(option.Default.(int64)),
+			plugin.SetMaxInt(option.Maximum.(int64)))
+	}
+	return policy.AddNewIntRule(configKey, option.Key, option.Required,
+		plugin.SetDefaultInt(option.Default.(int64)))
+}
+
+// addFloatRule adds the rule of a number option, its bounds applying with or
+// without a default
+func addFloatRule(policy *plugin.ConfigPolicy, configKey []string, option configOption) error {
+	if option.Default == nil {
+		switch {
+		case option.Minimum != nil && option.Maximum != nil:
+			return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+				plugin.SetMinFloat(option.Minimum.(float64)),
+				plugin.SetMaxFloat(option.Maximum.(float64)))
+		case option.Minimum != nil:
+			return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+				plugin.SetMinFloat(option.Minimum.(float64)))
+		case option.Maximum != nil:
+			return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+				plugin.SetMaxFloat(option.Maximum.(float64)))
+		}
+		return policy.AddNewFloatRule(configKey, option.Key, option.Required)
+	}
+
+	switch {
+	case option.Minimum != nil && option.Maximum != nil:
+		return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultFloat(option.Default.(float64)),
+			plugin.SetMinFloat(option.Minimum.(float64)),
+			plugin.SetMaxFloat(option.Maximum.(float64)))
+	case option.Minimum != nil:
+		return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultFloat(option.Default.(float64)),
+			plugin.SetMinFloat(option.Minimum.(float64)))
+	case option.Maximum != nil:
+		return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+			plugin.SetDefaultFloat(option.Default.(float64)),
+			plugin.SetMaxFloat(option.Maximum.(float64)))
+	}
+	return policy.AddNewFloatRule(configKey, option.Key, option.Required,
+		plugin.SetDefaultFloat(option.Default.(float64)))
+}
+
+// PrintConfigSchema writes every config key with its type, default and
+// constraints, either as a JSON schema ("json") or as a table ("text")
+func PrintConfigSchema(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return printJSONSchema(w)
+	case "text":
+		return printTextSchema(w)
+	default:
+		return fmt.Errorf("Unknown schema format: %s", format)
+	}
+}
+
+func printJSONSchema(w io.Writer) error {
+	properties := make(map[string]map[string]interface{}, len(configOptions))
+	required := []string{}
+	for _, option := range configOptions {
+		property := map[string]interface{}{
+			"type":        option.Type,
+			"description": option.Description,
+		}
+		if option.Default != nil {
+			property["default"] = option.Default
+		}
+		if option.Minimum != nil {
+			property["minimum"] = option.Minimum
+		}
+		if option.Maximum != nil {
+			property["maximum"] = option.Maximum
+		}
+		if len(option.Enum) > 0 {
+			property["enum"] = option.Enum
+		}
+		if option.Format != "" {
+			property["format"] = option.Format
+		}
+		properties[option.Key] = property
+		if option.Required {
+			required = append(required, option.Key)
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-04/schema#",
+		"title":                PluginName + " task config",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(schema)
+}
+
+func printTextSchema(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, option := range configOptions {
+		defaultValue := "-"
+		if option.Required {
+			defaultValue = "(required)"
+		} else if option.Default != nil {
+			defaultValue = fmt.Sprintf("%v", option.Default)
+		}
+
+		description := option.Description
+		if option.Minimum != nil {
+			description += fmt.Sprintf(", min %v", option.Minimum)
+		}
+		if option.Maximum != nil {
+			description += fmt.Sprintf(", max %v", option.Maximum)
+		}
+		if len(option.Enum) > 0 {
+			description += fmt.Sprintf(", one of %v", option.Enum)
+		}
+		if option.Format != "" {
+			description += fmt.Sprintf(", a %s", option.Format)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", option.Key, option.Type, defaultValue, description)
+	}
+	return table.Flush()
+}
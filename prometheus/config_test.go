@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfigSchema(t *testing.T) {
+	Convey("Every config option should have a known type and a description", t, func() {
+		for _, option := range configOptions {
+			So(option.Description, ShouldNotBeEmpty)
+			So(option.Type, ShouldBeIn, []string{stringOption, integerOption, booleanOption, numberOption})
+		}
+	})
+
+	Convey("The JSON schema should describe every config option", t, func() {
+		buf := new(bytes.Buffer)
+		So(PrintConfigSchema(buf, "json"), ShouldBeNil)
+
+		schema := struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+		}{}
+		So(json.Unmarshal(buf.Bytes(), &schema), ShouldBeNil)
+		So(len(schema.Properties), ShouldEqual, len(configOptions))
+		So(schema.Properties["endpoint"]["default"], ShouldEqual, prometheusEndpoint)
+	})
+
+	Convey("The text schema should list every config option", t, func() {
+		buf := new(bytes.Buffer)
+		So(PrintConfigSchema(buf, "text"), ShouldBeNil)
+		for _, option := range configOptions {
+			So(buf.String(), ShouldContainSubstring, option.Key)
+		}
+	})
+
+	Convey("Default config should hold the option defaults", t, func() {
+		So(DefaultConfig()["endpoint"], ShouldEqual, prometheusEndpoint)
+		_, ok := DefaultConfig()["job"]
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	Convey("Parsed values should get the type of their option", t, func() {
+		config, err := ParseConfig([]byte(`{"anomaly_threshold": 3.0, "anomaly_min_samples": 10, "safe_mode": true, "job": "node", "custom": 2}`))
+		So(err, ShouldBeNil)
+		So(config["anomaly_threshold"], ShouldEqual, float64(3))
+		So(config["anomaly_min_samples"], ShouldEqual, int64(10))
+		So(config["safe_mode"], ShouldEqual, true)
+		So(config["job"], ShouldEqual, "node")
+		So(config["custom"], ShouldEqual, int64(2))
+		So(config["endpoint"], ShouldEqual, prometheusEndpoint)
+		So(validateConfig(config), ShouldBeNil)
+	})
+
+	Convey("Values of the wrong type should be rejected", t, func() {
+		_, err := ParseConfig([]byte(`{"anomaly_min_samples": 1.5}`))
+		So(err, ShouldNotBeNil)
+		_, err = ParseConfig([]byte(`{"safe_mode": "yes"}`))
+		So(err, ShouldNotBeNil)
+		_, err = ParseConfig([]byte(`{"job": 1}`))
+		So(err, ShouldNotBeNil)
+		_, err = ParseConfig([]byte(`{"job": ["a"]}`))
+		So(err, ShouldNotBeNil)
+	})
+}
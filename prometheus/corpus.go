@@ -0,0 +1,267 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	"github.com/prometheus/common/expfmt"
+)
+
+// CorpusPayload is a scrape body of an exporter in one of the exposition
+// variants exporters serve the same metrics in
+type CorpusPayload struct {
+	Exporter string
+	Variant  string
+	Body     []byte
+}
+
+// Name returns the exporter/variant name of a payload
+func (p CorpusPayload) Name() string {
+	return p.Exporter + "/" + p.Variant
+}
+
+// corpusExpositions are the built-in payloads, as exporters serve them in
+// the text format. Corpus derives their other variants.
+var corpusExpositions = []struct {
+	exporter string
+	body     string
+}{
+	{"node_exporter", `# HELP node_cpu_seconds_total Seconds the CPUs spent in each mode.
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 3.14159e+06
+node_cpu_seconds_total{cpu="0",mode="user"} 12345.67
+node_cpu_seconds_total{cpu="1",mode="idle"} 3.10001e+06
+node_cpu_seconds_total{cpu="1",mode="user"} 15001.2
+# HELP node_filesystem_avail_bytes Filesystem space available to non-root users in bytes.
+# TYPE node_filesystem_avail_bytes gauge
+node_filesystem_avail_bytes{device="/dev/sda1",fstype="ext4",mountpoint="/"} 2.1474836e+10
+node_filesystem_avail_bytes{device="tmpfs",fstype="tmpfs",mountpoint="/run"} 8.388608e+08
+# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 0.42
+# HELP node_uname_info Labeled system information as provided by the uname system call.
+# TYPE node_uname_info gauge
+node_uname_info{machine="x86_64",nodename="host-1",release="5.4.0",sysname="Linux"} 1
+`},
+	{"client_golang", `# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 42
+# HELP http_request_duration_seconds Latency of HTTP requests.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{handler="/api",le="0.05"} 120
+http_request_duration_seconds_bucket{handler="/api",le="0.1"} 180
+http_request_duration_seconds_bucket{handler="/api",le="0.5"} 199
+http_request_duration_seconds_bucket{handler="/api",le="+Inf"} 200
+http_request_duration_seconds_sum{handler="/api"} 11.3
+http_request_duration_seconds_count{handler="/api"} 200
+# HELP rpc_durations_seconds RPC latency distributions.
+# TYPE rpc_durations_seconds summary
+rpc_durations_seconds{service="exponential",quantile="0.5"} 7.2e-07
+rpc_durations_seconds{service="exponential",quantile="0.9"} 2.3e-06
+rpc_durations_seconds{service="exponential",quantile="0.99"} 4.6e-06
+rpc_durations_seconds_sum{service="exponential"} 0.0018
+rpc_durations_seconds_count{service="exponential"} 1500
+# HELP process_start_time_seconds Start time of the process since unix epoch in seconds.
+# TYPE process_start_time_seconds gauge
+process_start_time_seconds 1.60000000012e+09
+legacy_requests_total{path="/index.html",code="200"} 1027
+`},
+	{"envoy", `# TYPE envoy_cluster_upstream_rq_total counter
+envoy_cluster_upstream_rq_total{envoy_cluster_name="backend"} 1537
+envoy_cluster_upstream_rq_total{envoy_cluster_name="auth"} 88
+# TYPE envoy_server_live gauge
+envoy_server_live 1
+# TYPE envoy_server_uptime gauge
+envoy_server_uptime 86400
+# TYPE envoy_cluster_upstream_rq_time histogram
+envoy_cluster_upstream_rq_time_bucket{envoy_cluster_name="backend",le="0.5"} 10
+envoy_cluster_upstream_rq_time_bucket{envoy_cluster_name="backend",le="1"} 25
+envoy_cluster_upstream_rq_time_bucket{envoy_cluster_name="backend",le="+Inf"} 30
+envoy_cluster_upstream_rq_time_sum{envoy_cluster_name="backend"} 21.5
+envoy_cluster_upstream_rq_time_count{envoy_cluster_name="backend"} 30
+`},
+}
+
+// corpusTimestamp is the explicit timestamp of the timestamped variants
+const corpusTimestamp = "1600000000000"
+
+// Corpus returns the built-in payloads, every exporter in each of the
+// variants exporters in the wild serve: the text format as is, with
+// explicit timestamps, without HELP lines, and as delimited protobuf
+func Corpus() ([]CorpusPayload, error) {
+	payloads := []CorpusPayload{}
+	for _, exposition := range corpusExpositions {
+		body := []byte(exposition.body)
+		protobuf, err := textToProtobuf(body)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s corpus payload: %s", exposition.exporter, err.Error())
+		}
+		payloads = append(payloads,
+			CorpusPayload{Exporter: exposition.exporter, Variant: "text", Body: body},
+			CorpusPayload{Exporter: exposition.exporter, Variant: "text-timestamps", Body: mapSampleLines(body, func(line string) string {
+				return line + " " + corpusTimestamp
+			})},
+			CorpusPayload{Exporter: exposition.exporter, Variant: "text-no-help", Body: mapSampleLines(body, nil)},
+			CorpusPayload{Exporter: exposition.exporter, Variant: "protobuf", Body: protobuf},
+		)
+	}
+	return payloads, nil
+}
+
+// mapSampleLines rewrites the sample lines of a text body with sample, and
+// drops its HELP lines when sample is nil
+func mapSampleLines(body []byte, sample func(line string) string) []byte {
+	var mapped bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# HELP") && sample == nil:
+			continue
+		case !strings.HasPrefix(line, "#") && line != "" && sample != nil:
+			line = sample(line)
+		}
+		mapped.WriteString(line + "\n")
+	}
+	return mapped.Bytes()
+}
+
+// textToProtobuf encodes a text body as delimited protobuf, families sorted
+// by name
+func textToProtobuf(body []byte) ([]byte, error) {
+	var parser expfmt.TextParser
+	metricFamilies, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(metricFamilies))
+	for name := range metricFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var encoded bytes.Buffer
+	encoder := expfmt.NewEncoder(&encoded, expfmt.FmtProtoDelim)
+	for _, name := range names {
+		if err := encoder.Encode(metricFamilies[name]); err != nil {
+			return nil, err
+		}
+	}
+	return encoded.Bytes(), nil
+}
+
+// LoadCorpus reads a corpus directory. Every subdirectory is an exporter and
+// every file in it a variant named after the file, e.g.
+// node_exporter/text.prom and node_exporter/protobuf.pb. Files at the top
+// of the directory are exporters of their own.
+func LoadCorpus(dir string) ([]CorpusPayload, error) {
+	payloads := []CorpusPayload{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		payload := CorpusPayload{Exporter: filepath.Base(filepath.Dir(path)), Variant: name, Body: body}
+		if filepath.Dir(path) == filepath.Clean(dir) {
+			payload.Exporter = name
+		}
+		payloads = append(payloads, payload)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("no corpus payloads in %s", dir)
+	}
+	return payloads, nil
+}
+
+// CorpusResult is the outcome of a replayed payload. Missing and Extra are
+// the series it converts to that the first variant of its exporter didn't,
+// and the other way around.
+type CorpusResult struct {
+	Payload CorpusPayload
+	Samples int
+	Err     error
+	Missing []string
+	Extra   []string
+}
+
+// Consistent tells whether a payload converted like the first variant of
+// its exporter
+func (r CorpusResult) Consistent() bool {
+	return r.Err == nil && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// corpusDownloader answers every scrape with the same body
+type corpusDownloader struct {
+	body []byte
+}
+
+func (d corpusDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	return bytes.NewReader(d.body), nil
+}
+
+func (d corpusDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "http://corpus/metrics", nil
+}
+
+// ReplayCorpus runs every payload through the pipeline with config, each on
+// a collector of its own, and compares the series of every variant with
+// those of the first variant of the same exporter, so a config can be
+// checked to behave the same whatever format its exporters serve
+func ReplayCorpus(config plugin.Config, payloads []CorpusPayload) []CorpusResult {
+	results := make([]CorpusResult, 0, len(payloads))
+	baselines := map[string]map[string]bool{}
+	for _, payload := range payloads {
+		result := CorpusResult{Payload: payload}
+		collector := New(WithDownloader(corpusDownloader{payload.Body})).(*PrometheusCollector)
+		metrics, err := collector.CollectMetrics([]plugin.Metric{{Config: config}})
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		result.Samples = len(metrics)
+
+		series := make(map[string]bool, len(metrics))
+		for _, metric := range metrics {
+			series[seriesKey(metric)] = true
+		}
+		baseline, ok := baselines[payload.Exporter]
+		if !ok {
+			baselines[payload.Exporter] = series
+			results = append(results, result)
+			continue
+		}
+		result.Missing = seriesDifference(baseline, series)
+		result.Extra = seriesDifference(series, baseline)
+		results = append(results, result)
+	}
+	return results
+}
+
+// seriesDifference returns the series of a not in b, sorted and readable
+func seriesDifference(a, b map[string]bool) []string {
+	difference := []string{}
+	for key := range a {
+		if !b[key] {
+			difference = append(difference, strings.Replace(key, "\xff", " ", -1))
+		}
+	}
+	sort.Strings(difference)
+	return difference
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCorpus(t *testing.T) {
+	Convey("Every variant of the built-in corpus should convert alike", t, func() {
+		payloads, err := Corpus()
+		So(err, ShouldBeNil)
+		So(len(payloads), ShouldEqual, 4*len(corpusExpositions))
+
+		for _, result := range ReplayCorpus(DefaultConfig(), payloads) {
+			So(result.Err, ShouldBeNil)
+			So(result.Samples, ShouldBeGreaterThan, 0)
+			So(result.Missing, ShouldBeEmpty)
+			So(result.Extra, ShouldBeEmpty)
+		}
+	})
+
+	Convey("A variant losing series should be reported", t, func() {
+		payloads := []CorpusPayload{
+			{Exporter: "app", Variant: "text", Body: []byte("# TYPE requests_total counter\nrequests_total{code=\"200\"} 1\nrequests_total{code=\"500\"} 2\n")},
+			{Exporter: "app", Variant: "broken", Body: []byte("# TYPE requests_total counter\nrequests_total{code=\"200\"} 1\n")},
+		}
+		results := ReplayCorpus(DefaultConfig(), payloads)
+		So(results[0].Consistent(), ShouldBeTrue)
+		So(results[1].Consistent(), ShouldBeFalse)
+		So(len(results[1].Missing), ShouldEqual, 1)
+		So(results[1].Missing[0], ShouldContainSubstring, "code=500")
+	})
+
+	Convey("A corpus directory should be read as exporters of variants", t, func() {
+		dir, err := ioutil.TempDir("", "corpus")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(os.Mkdir(filepath.Join(dir, "app"), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "app", "text.prom"), []byte("up 1\n"), 0644), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, "single.prom"), []byte("up 1\n"), 0644), ShouldBeNil)
+
+		payloads, err := LoadCorpus(dir)
+		So(err, ShouldBeNil)
+		names := []string{}
+		for _, payload := range payloads {
+			names = append(names, payload.Name())
+		}
+		So(strings.Join(names, ","), ShouldEqual, "app/text,single/single")
+	})
+}
@@ -0,0 +1,107 @@
+package prometheus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterRateTracker remembers the counter values of the previous scrape of
+// every target of every task config, which counter_rates are computed from
+type counterRateTracker struct {
+	mutex    sync.Mutex
+	counters map[string]map[string]map[string]counterSample
+}
+
+func newCounterRateTracker() *counterRateTracker {
+	return &counterRateTracker{
+		counters: make(map[string]map[string]map[string]counterSample),
+	}
+}
+
+// derive returns a <counter>_rate series, the per second increase, or a
+// <counter>_delta series, the increase, of every counter of the scrape of t
+// since its previous scrape, as counter_rates asks. Resets count the value
+// after the reset as the increase. Counters first seen have no series yet.
+func (r *counterRateTracker) derive(config plugin.Config, c *PrometheusCollector, currentTime time.Time, prefix []string, t target, metricFamilies map[string]*dto.MetricFamily) []plugin.Metric {
+	mode := getStringConfig(config, "counter_rates")
+	if r == nil || mode == "" {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	targets, ok := r.counters[key]
+	if !ok {
+		targets = make(map[string]map[string]counterSample)
+		r.counters[key] = targets
+	}
+	previous := targets[t.URL]
+	counters := make(map[string]counterSample, len(previous))
+	var metrics []plugin.Metric
+	for name, metricFamily := range metricFamilies {
+		if metricFamily.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		for _, metricItem := range metricFamily.GetMetric() {
+			increase, elapsed, ok := counterIncrease(previous, counters, familySeriesKey(name, metricItem), metricItem.GetCounter().GetValue(), currentTime)
+			if !ok {
+				continue
+			}
+			metric := c.createMetric(currentTime, prefix, name+"_"+mode, metricFamily.GetHelp())
+			metric.Tags = c.getTagsOfMetric(metricItem, t.Tags)
+			metric.Data = increase
+			if mode == "rate" {
+				metric.Data = increase / elapsed.Seconds()
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	targets[t.URL] = counters
+	return metrics
+}
+
+// forget drops the counters of every target of the config with fingerprint
+// key
+func (r *counterRateTracker) forget(key string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.counters, key)
+}
+
+// retain drops the counters of the targets of config not in targets
+func (r *counterRateTracker) retain(config plugin.Config, targets []target) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	urls := targetURLs(targets)
+	byURL := r.counters[configFingerprint(config)]
+	for url := range byURL {
+		if !urls[url] {
+			delete(byURL, url)
+		}
+	}
+}
+
+func checkCounterRates(config plugin.Config) ([]string, error) {
+	switch mode := getStringConfig(config, "counter_rates"); mode {
+	case "":
+		return nil, nil
+	case "rate", "delta":
+		if window, _ := getDurationConfig(config, "downsample_window"); window > 0 {
+			return []string{"counter_rates is computed on the average rates of downsample_window, not on raw counters"}, nil
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown counter_rates: %s", mode)
+	}
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCounterRates(t *testing.T) {
+	c := &PrometheusCollector{}
+	prefix := []string{"hyperpilot", "prometheus"}
+	start := time.Now()
+	target := target{URL: "http://a:9100/metrics", Tags: map[string]string{"instance": "a:9100"}}
+
+	Convey("Counters should get a rate series from their second scrape on", t, func() {
+		tracker := newCounterRateTracker()
+		config := plugin.Config{"counter_rates": "rate"}
+		So(tracker.derive(config, c, start, prefix, target, counterFamilies(100)), ShouldBeEmpty)
+
+		metrics := tracker.derive(config, c, start.Add(10*time.Second), prefix, target, counterFamilies(150))
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "requests_total_rate"})
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"instance": "a:9100", "code": "200"})
+		So(metrics[0].Data, ShouldEqual, 5)
+
+		metrics = tracker.derive(config, c, start.Add(20*time.Second), prefix, target, counterFamilies(20))
+		So(metrics[0].Data, ShouldEqual, 2)
+	})
+
+	Convey("Counters should get a delta series when asked", t, func() {
+		tracker := newCounterRateTracker()
+		config := plugin.Config{"counter_rates": "delta"}
+		tracker.derive(config, c, start, prefix, target, counterFamilies(100))
+		metrics := tracker.derive(config, c, start.Add(10*time.Second), prefix, target, counterFamilies(150))
+		So(metricName(metrics[0]), ShouldEqual, "requests_total_delta")
+		So(metrics[0].Data, ShouldEqual, 50)
+	})
+
+	Convey("Unknown counter_rates modes should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"counter_rates": "irate"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,221 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// seriesSelector selects the series of a metric, optionally narrowed by
+// exact tag values
+type seriesSelector struct {
+	Metric string            `json:"metric"`
+	Match  map[string]string `json:"match"`
+}
+
+// matches tells whether metric is a selected series
+func (s *seriesSelector) matches(metric plugin.Metric) bool {
+	if metricName(metric) != s.Metric {
+		return false
+	}
+	for key, value := range s.Match {
+		if metric.Tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// recordingRule records an aggregation of the selected series as a new
+// metric, like a PromQL recording rule such as
+// sum by (job) (rate(http_requests_total[1m]))
+type recordingRule struct {
+	Record string `json:"record"`
+	seriesSelector
+	// Rate turns counters into per second rates since the previous
+	// collection before aggregating
+	Rate bool `json:"rate"`
+	// Aggregate is one of sum, avg, min, max and count. Series are kept
+	// apart when empty.
+	Aggregate string   `json:"aggregate"`
+	By        []string `json:"by"`
+}
+
+var aggregations = map[string]func(values []float64) float64{
+	"sum": func(values []float64) float64 {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		return sum
+	},
+	"avg": func(values []float64) float64 {
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		return sum / float64(len(values))
+	},
+	"min": func(values []float64) float64 {
+		min := math.Inf(1)
+		for _, value := range values {
+			min = math.Min(min, value)
+		}
+		return min
+	},
+	"max": func(values []float64) float64 {
+		max := math.Inf(-1)
+		for _, value := range values {
+			max = math.Max(max, value)
+		}
+		return max
+	},
+	"count": func(values []float64) float64 {
+		return float64(len(values))
+	},
+}
+
+func compileRecordingRules(spec string) ([]recordingRule, error) {
+	var rules []recordingRule
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		return nil, fmt.Errorf("recording_rules must be a JSON list of rules: %s", err.Error())
+	}
+	for i, rule := range rules {
+		if rule.Record == "" || rule.Metric == "" {
+			return nil, fmt.Errorf("Invalid recording rule %d: record and metric must be set", i)
+		}
+		if err := checkNamespaceElement(rule.Record); err != nil {
+			return nil, fmt.Errorf("Invalid recording rule: record " + err.Error())
+		}
+		if _, ok := aggregations[rule.Aggregate]; !ok && rule.Aggregate != "" {
+			return nil, fmt.Errorf("Invalid recording rule %s: aggregate must be one of sum, avg, min, max and count", rule.Record)
+		}
+		if rule.Aggregate == "" && len(rule.By) > 0 {
+			return nil, fmt.Errorf("Invalid recording rule %s: by needs an aggregate", rule.Record)
+		}
+		if rule.Aggregate == "" && !rule.Rate {
+			return nil, fmt.Errorf("Invalid recording rule %s: rate or aggregate must be set", rule.Record)
+		}
+	}
+	return rules, nil
+}
+
+// counterSample is a counter value seen at a collection
+type counterSample struct {
+	value float64
+	time  time.Time
+}
+
+// counterIncrease records the value of a counter series in counters and
+// returns its increase since the value in previous, along with the time
+// elapsed. There's no increase for a series seen for the first time.
+func counterIncrease(previous map[string]counterSample, counters map[string]counterSample, series string, value float64, currentTime time.Time) (float64, time.Duration, bool) {
+	counters[series] = counterSample{value: value, time: currentTime}
+	last, ok := previous[series]
+	elapsed := currentTime.Sub(last.time)
+	if !ok || elapsed <= 0 {
+		return 0, 0, false
+	}
+	if value < last.value {
+		// the counter was reset in between
+		return value, elapsed, true
+	}
+	return value - last.value, elapsed, true
+}
+
+// derivedTracker keeps the counter values of every task config seen at the
+// previous collection, which rates are computed from
+type derivedTracker struct {
+	mutex    sync.Mutex
+	counters map[string]map[string]counterSample
+}
+
+func newDerivedTracker() *derivedTracker {
+	return &derivedTracker{
+		counters: make(map[string]map[string]counterSample),
+	}
+}
+
+// record returns the metrics recorded by rules from metrics
+func (t *derivedTracker) record(config plugin.Config, rules []recordingRule, prefix []string, currentTime time.Time, metrics []plugin.Metric) []plugin.Metric {
+	if len(rules) == 0 || t == nil {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	previous := t.counters[key]
+	counters := make(map[string]counterSample)
+
+	var recorded []plugin.Metric
+	for i := range rules {
+		rule := &rules[i]
+		namespace := prefixedNamespace(prefix, rule.Record)
+
+		groups := map[string][]float64{}
+		groupTags := map[string]map[string]string{}
+		for _, metric := range metrics {
+			if !rule.matches(metric) {
+				continue
+			}
+			value, ok := metric.Data.(float64)
+			if !ok {
+				continue
+			}
+
+			if rule.Rate {
+				increase, elapsed, ok := counterIncrease(previous, counters, seriesKey(metric), value, currentTime)
+				if !ok {
+					continue
+				}
+				value = increase / elapsed.Seconds()
+			}
+
+			tags := metric.Tags
+			if rule.Aggregate != "" {
+				tags = make(map[string]string, len(rule.By))
+				for _, label := range rule.By {
+					if value, ok := metric.Tags[label]; ok {
+						tags[label] = value
+					}
+				}
+			}
+			group := seriesKey(plugin.Metric{Namespace: namespace, Tags: tags})
+			groups[group] = append(groups[group], value)
+			groupTags[group] = tags
+		}
+
+		keys := make([]string, 0, len(groups))
+		for group := range groups {
+			keys = append(keys, group)
+		}
+		sort.Strings(keys)
+		for _, group := range keys {
+			values := groups[group]
+			value := values[0]
+			if rule.Aggregate != "" {
+				value = aggregations[rule.Aggregate](values)
+			}
+			tags := make(map[string]string, len(groupTags[group]))
+			for key, value := range groupTags[group] {
+				tags[key] = value
+			}
+			recorded = append(recorded, plugin.Metric{
+				Namespace: namespace,
+				Timestamp: currentTime,
+				Version:   pluginVersion,
+				Tags:      tags,
+				Data:      value,
+			})
+		}
+	}
+
+	t.counters[key] = counters
+	return recorded
+}
@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func requestsMetric(job string, instance string, value float64) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"),
+		Tags:      map[string]string{"job": job, "instance": instance},
+		Data:      value,
+	}
+}
+
+func TestRecordingRules(t *testing.T) {
+	prefix := []string{"hyperpilot", "prometheus"}
+
+	Convey("Recording rules should aggregate by tags", t, func() {
+		config := plugin.Config{"recording_rules": `[
+			{"record": "job:http_requests:sum", "metric": "http_requests_total", "aggregate": "sum", "by": ["job"]},
+			{"record": "http_requests:max", "metric": "http_requests_total", "aggregate": "max"}
+		]`}
+		rules, err := compileConversionRules(config)
+		So(err, ShouldBeNil)
+
+		recorded := newDerivedTracker().record(config, rules.recordingRules, prefix, time.Now(), []plugin.Metric{
+			requestsMetric("api", "a", 10), requestsMetric("api", "b", 5), requestsMetric("web", "c", 1),
+		})
+		So(recorded, ShouldHaveLength, 3)
+		So(recorded[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "job:http_requests:sum"})
+		So(recorded[0].Tags, ShouldResemble, map[string]string{"job": "api"})
+		So(recorded[0].Data, ShouldEqual, 15.0)
+		So(recorded[1].Tags, ShouldResemble, map[string]string{"job": "web"})
+		So(recorded[1].Data, ShouldEqual, 1.0)
+		So(recorded[2].Tags, ShouldBeEmpty)
+		So(recorded[2].Data, ShouldEqual, 10.0)
+	})
+
+	Convey("Rate rules should compute per second rates between collections", t, func() {
+		config := plugin.Config{"recording_rules": `[
+			{"record": "job:http_requests:rate", "metric": "http_requests_total", "rate": true, "aggregate": "sum", "by": ["job"]},
+			{"record": "http_requests:rate", "metric": "http_requests_total", "match": {"instance": "a"}, "rate": true}
+		]`}
+		rules, err := compileConversionRules(config)
+		So(err, ShouldBeNil)
+		tracker := newDerivedTracker()
+		start := time.Now()
+
+		So(tracker.record(config, rules.recordingRules, prefix, start, []plugin.Metric{
+			requestsMetric("api", "a", 100), requestsMetric("api", "b", 50),
+		}), ShouldBeEmpty)
+
+		recorded := tracker.record(config, rules.recordingRules, prefix, start.Add(10*time.Second), []plugin.Metric{
+			requestsMetric("api", "a", 200), requestsMetric("api", "b", 20),
+		})
+		So(recorded, ShouldHaveLength, 2)
+		So(recorded[0].Data, ShouldEqual, 12.0)
+		So(recorded[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "http_requests:rate"})
+		So(recorded[1].Tags, ShouldResemble, map[string]string{"job": "api", "instance": "a"})
+		So(recorded[1].Data, ShouldEqual, 10.0)
+	})
+
+	Convey("Invalid recording rules should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"recording_rules": `[{"record": "a", "metric": "up", "aggregate": "median"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"recording_rules": `[{"record": "a", "metric": "up"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"recording_rules": `[{"record": "a", "metric": "up", "rate": true, "by": ["job"]}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"recording_rules": `[{"record": "a/b", "metric": "up", "aggregate": "sum"}]`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,141 @@
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// target is a single scrape endpoint of a task, with the tags added to
+// every metric scraped from it
+type target struct {
+	URL  string
+	Tags map[string]string
+}
+
+// discoverer finds the current targets of a task in a service registry
+type discoverer interface {
+	discover() ([]target, error)
+}
+
+// discoverers builds the discoverer of each supported discovery backend
+var discoverers = map[string]func(config plugin.Config, client *http.Client) (discoverer, error){
+	"nomad":      newNomadDiscoverer,
+	"azure":      newAzureDiscoverer,
+	"gce":        newGCEDiscoverer,
+	"etcd":       newEtcdDiscoverer,
+	"kubernetes": newKubernetesDiscoverer,
+	"file":       newFileDiscoverer,
+}
+
+// discoveryTimeout bounds requests to service registries
+const discoveryTimeout = 10 * time.Second
+
+// discoveryManager keeps the discovered targets of every task config and
+// refreshes them every discovery_refresh_interval. When a refresh fails the
+// previous targets are kept.
+type discoveryManager struct {
+	mutex  sync.Mutex
+	states map[string]*discoveryState
+}
+
+type discoveryState struct {
+	mutex      sync.Mutex
+	discoverer discoverer
+	targets    []target
+	refreshed  time.Time
+}
+
+func newDiscoveryManager() *discoveryManager {
+	return &discoveryManager{
+		states: make(map[string]*discoveryState),
+	}
+}
+
+func (m *discoveryManager) targets(config plugin.Config) ([]target, error) {
+	interval, err := getDurationConfig(config, "discovery_refresh_interval")
+	if err != nil {
+		return nil, err
+	}
+
+	key := configFingerprint(config)
+	m.mutex.Lock()
+	state, ok := m.states[key]
+	if !ok {
+		d, err := newDiscoverer(config)
+		if err != nil {
+			m.mutex.Unlock()
+			return nil, err
+		}
+		state = &discoveryState{discoverer: d}
+		m.states[key] = state
+	}
+	m.mutex.Unlock()
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if !state.refreshed.IsZero() && time.Since(state.refreshed) < interval {
+		return state.targets, nil
+	}
+
+	targets, err := state.discoverer.discover()
+	if err != nil {
+		if state.refreshed.IsZero() {
+			return nil, err
+		}
+		glog.Warningf("Unable to refresh targets, keeping %d previous targets: %s", len(state.targets), err.Error())
+		return state.targets, nil
+	}
+	auditTargetChanges(config, state.targets, targets)
+	state.targets = targets
+	state.refreshed = time.Now()
+	return targets, nil
+}
+
+func newDiscoverer(config plugin.Config) (discoverer, error) {
+	backend := getStringConfig(config, "discovery")
+	build, ok := discoverers[backend]
+	if !ok {
+		return nil, fmt.Errorf("Unknown discovery backend: %s", backend)
+	}
+	client, err := newPolicyClient(config, discoveryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return build(config, client)
+}
+
+// newAddressTarget returns the target scraping an address given as
+// host:port, tagged with its instance
+func newAddressTarget(config plugin.Config, address string, tags map[string]string) target {
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	tags["instance"] = address
+	return target{
+		URL:  getStringConfig(config, "scheme") + "://" + address + getStringConfig(config, "metrics_path"),
+		Tags: tags,
+	}
+}
+
+// newTarget returns the target scraping host:port with the task's scheme
+// and metrics path, tagged with its instance
+func newTarget(config plugin.Config, host string, port int, tags map[string]string) target {
+	return newAddressTarget(config, net.JoinHostPort(host, strconv.Itoa(port)), tags)
+}
+
+// size returns the number of task configs with discovery state, for debug dumps
+func (m *discoveryManager) size() int {
+	if m == nil {
+		return 0
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.states)
+}
@@ -0,0 +1,316 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	azureManagementURL = "https://management.azure.com"
+	azureIMDSTokenURL  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureLoginURL      = "https://login.microsoftonline.com"
+	azureComputeAPI    = "2021-03-01"
+	azureNetworkAPI    = "2018-10-01"
+)
+
+// azureDiscoverer finds the VMs and scale set instances of a resource group,
+// optionally keeping only those carrying a tag, and scrapes their primary
+// private IP on a configured port
+type azureDiscoverer struct {
+	config         plugin.Config
+	client         *http.Client
+	managementURL  string
+	subscription   string
+	resourceGroup  string
+	port           int
+	tagKey         string
+	tagValue       string
+	clientID       string
+	tenantID       string
+	clientSecret   string
+	token          string
+	tokenExpiresAt time.Time
+}
+
+type azureVM struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Location string            `json:"location"`
+	Zones    []string          `json:"zones"`
+	Tags     map[string]string `json:"tags"`
+	// InstanceID is only set on scale set instances
+	InstanceID string `json:"instanceId"`
+	Properties struct {
+		NetworkProfile struct {
+			NetworkInterfaces []struct {
+				ID         string `json:"id"`
+				Properties struct {
+					Primary bool `json:"primary"`
+				} `json:"properties"`
+			} `json:"networkInterfaces"`
+		} `json:"networkProfile"`
+	} `json:"properties"`
+}
+
+type azureInterface struct {
+	Properties struct {
+		Primary          bool `json:"primary"`
+		IPConfigurations []struct {
+			Properties struct {
+				Primary          bool   `json:"primary"`
+				PrivateIPAddress string `json:"privateIPAddress"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+func newAzureDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	d := &azureDiscoverer{
+		config:        config,
+		client:        client,
+		managementURL: azureManagementURL,
+		subscription:  getStringConfig(config, "azure_subscription_id"),
+		resourceGroup: getStringConfig(config, "azure_resource_group"),
+		port:          int(getIntConfig(config, "azure_port")),
+		clientID:      getStringConfig(config, "azure_client_id"),
+		tenantID:      getStringConfig(config, "azure_tenant_id"),
+		clientSecret:  getStringConfig(config, "azure_client_secret"),
+	}
+	if d.subscription == "" || d.resourceGroup == "" {
+		return nil, fmt.Errorf("Azure discovery requires azure_subscription_id and azure_resource_group")
+	}
+	if d.clientSecret != "" && (d.tenantID == "" || d.clientID == "") {
+		return nil, fmt.Errorf("Azure service principal auth requires azure_tenant_id and azure_client_id")
+	}
+	if tag := getStringConfig(config, "azure_tag"); tag != "" {
+		parts := strings.SplitN(tag, "=", 2)
+		d.tagKey = parts[0]
+		if len(parts) == 2 {
+			d.tagValue = parts[1]
+		}
+	}
+	return d, nil
+}
+
+func (d *azureDiscoverer) discover() ([]target, error) {
+	groupPath := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute",
+		url.PathEscape(d.subscription), url.PathEscape(d.resourceGroup))
+
+	targets := []target{}
+	var vms []azureVM
+	if err := d.list(groupPath+"/virtualMachines?api-version="+azureComputeAPI, &vms); err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		if !d.matches(vm.Tags) {
+			continue
+		}
+		address, err := d.vmAddress(vm, "")
+		if err != nil {
+			return nil, err
+		}
+		if address != "" {
+			targets = append(targets, d.newTarget(vm, address, ""))
+		}
+	}
+
+	var scaleSets []azureVM
+	if err := d.list(groupPath+"/virtualMachineScaleSets?api-version="+azureComputeAPI, &scaleSets); err != nil {
+		return nil, err
+	}
+	for _, scaleSet := range scaleSets {
+		var instances []azureVM
+		instancesPath := groupPath + "/virtualMachineScaleSets/" + url.PathEscape(scaleSet.Name)
+		if err := d.list(instancesPath+"/virtualMachines?api-version="+azureComputeAPI, &instances); err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			if instance.Tags == nil {
+				instance.Tags = scaleSet.Tags
+			}
+			if !d.matches(instance.Tags) {
+				continue
+			}
+			address, err := d.vmAddress(instance, instancesPath+"/virtualMachines/"+url.PathEscape(instance.InstanceID))
+			if err != nil {
+				return nil, err
+			}
+			if address != "" {
+				targets = append(targets, d.newTarget(instance, address, scaleSet.Name))
+			}
+		}
+	}
+	return targets, nil
+}
+
+func (d *azureDiscoverer) matches(tags map[string]string) bool {
+	if d.tagKey == "" {
+		return true
+	}
+	value, ok := tags[d.tagKey]
+	return ok && (d.tagValue == "" || value == d.tagValue)
+}
+
+func (d *azureDiscoverer) newTarget(vm azureVM, address string, scaleSet string) target {
+	tags := map[string]string{
+		"azure_resource_group": d.resourceGroup,
+		"azure_vm_name":        vm.Name,
+		"azure_location":       vm.Location,
+	}
+	if len(vm.Zones) > 0 {
+		tags["azure_zone"] = vm.Zones[0]
+	}
+	if scaleSet != "" {
+		tags["azure_scale_set"] = scaleSet
+	}
+	return newTarget(d.config, address, d.port, tags)
+}
+
+// vmAddress returns the primary private IP of a VM. Scale set instances list
+// their interfaces under the instance path instead of as top level resources.
+func (d *azureDiscoverer) vmAddress(vm azureVM, instancePath string) (string, error) {
+	var interfaces []azureInterface
+	if instancePath != "" {
+		if err := d.list(instancePath+"/networkInterfaces?api-version="+azureNetworkAPI, &interfaces); err != nil {
+			return "", err
+		}
+	} else {
+		for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+			if !ref.Properties.Primary && len(vm.Properties.NetworkProfile.NetworkInterfaces) > 1 {
+				continue
+			}
+			var nic azureInterface
+			if err := d.get(ref.ID+"?api-version="+azureNetworkAPI, &nic); err != nil {
+				return "", err
+			}
+			nic.Properties.Primary = true
+			interfaces = append(interfaces, nic)
+		}
+	}
+
+	for _, nic := range interfaces {
+		if !nic.Properties.Primary && len(interfaces) > 1 {
+			continue
+		}
+		for _, ipConfig := range nic.Properties.IPConfigurations {
+			if ipConfig.Properties.Primary || len(nic.Properties.IPConfigurations) == 1 {
+				return ipConfig.Properties.PrivateIPAddress, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// list follows nextLink pages of an ARM list call, appending every page's
+// values to result
+func (d *azureDiscoverer) list(path string, result interface{}) error {
+	values := []json.RawMessage{}
+	next := d.managementURL + path
+	for next != "" {
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		if err := d.getURL(next, &page); err != nil {
+			return err
+		}
+		values = append(values, page.Value...)
+		next = page.NextLink
+	}
+
+	content, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, result)
+}
+
+func (d *azureDiscoverer) get(path string, result interface{}) error {
+	return d.getURL(d.managementURL+path, result)
+}
+
+func (d *azureDiscoverer) getURL(u string, result interface{}) error {
+	token, err := d.accessToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to query Azure: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to query Azure: status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// accessToken returns a management API token, from the service principal
+// when a client secret is configured and from the VM's managed identity
+// otherwise. Tokens are reused until shortly before they expire.
+func (d *azureDiscoverer) accessToken() (string, error) {
+	if d.token != "" && time.Now().Before(d.tokenExpiresAt) {
+		return d.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if d.clientSecret != "" {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", d.clientID)
+		form.Set("client_secret", d.clientSecret)
+		form.Set("resource", azureManagementURL+"/")
+		req, err = http.NewRequest("POST", azureLoginURL+"/"+url.PathEscape(d.tenantID)+"/oauth2/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{}
+		query.Set("api-version", "2018-02-01")
+		query.Set("resource", azureManagementURL+"/")
+		if d.clientID != "" {
+			query.Set("client_id", d.clientID)
+		}
+		req, err = http.NewRequest("GET", azureIMDSTokenURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata", "true")
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Unable to get Azure token: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unable to get Azure token: status code: %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	expiresOn, _ := strconv.ParseInt(token.ExpiresOn, 10, 64)
+	d.token = token.AccessToken
+	d.tokenExpiresAt = time.Unix(expiresOn, 0).Add(-time.Minute)
+	return d.token, nil
+}
@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAzureDiscovery(t *testing.T) {
+	Convey("Azure discovery should find tagged VMs and scale set instances", t, func() {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			group := "/subscriptions/sub/resourceGroups/rg"
+			switch r.URL.Path {
+			case group + "/providers/Microsoft.Compute/virtualMachines":
+				w.Write([]byte(`{"value": [
+					{"name": "vm-1", "location": "eastus", "zones": ["2"], "tags": {"scrape": "yes"},
+					 "properties": {"networkProfile": {"networkInterfaces": [{"id": "` + group + `/providers/Microsoft.Network/networkInterfaces/nic-1"}]}}},
+					{"name": "vm-2", "location": "eastus", "tags": {}}
+				]}`))
+			case group + "/providers/Microsoft.Network/networkInterfaces/nic-1":
+				w.Write([]byte(`{"properties": {"ipConfigurations": [{"properties": {"primary": true, "privateIPAddress": "10.1.0.4"}}]}}`))
+			case group + "/providers/Microsoft.Compute/virtualMachineScaleSets":
+				w.Write([]byte(`{"value": [{"name": "pool", "tags": {"scrape": "yes"}}]}`))
+			case group + "/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines":
+				if r.URL.Query().Get("page") == "" {
+					w.Write([]byte(`{"value": [], "nextLink": "` + server.URL + r.URL.Path + `?page=2"}`))
+					return
+				}
+				w.Write([]byte(`{"value": [{"name": "pool_0", "instanceId": "0", "location": "eastus"}]}`))
+			case group + "/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/0/networkInterfaces":
+				w.Write([]byte(`{"value": [{"properties": {"primary": true, "ipConfigurations": [{"properties": {"privateIPAddress": "10.1.1.4"}}]}}]}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		d, err := newAzureDiscoverer(plugin.Config{
+			"azure_subscription_id": "sub",
+			"azure_resource_group":  "rg",
+			"azure_tag":             "scrape=yes",
+			"azure_port":            int64(9182),
+		}, http.DefaultClient)
+		So(err, ShouldBeNil)
+		azure := d.(*azureDiscoverer)
+		azure.managementURL = server.URL
+		azure.token = "token"
+		azure.tokenExpiresAt = time.Now().Add(time.Hour)
+
+		targets, err := azure.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 2)
+		So(targets[0].URL, ShouldEqual, "http://10.1.0.4:9182/metrics")
+		So(targets[0].Tags["azure_vm_name"], ShouldEqual, "vm-1")
+		So(targets[0].Tags["azure_zone"], ShouldEqual, "2")
+		So(targets[1].URL, ShouldEqual, "http://10.1.1.4:9182/metrics")
+		So(targets[1].Tags["azure_scale_set"], ShouldEqual, "pool")
+	})
+
+	Convey("Azure discovery should require a subscription and resource group", t, func() {
+		_, err := newAzureDiscoverer(plugin.Config{"azure_resource_group": "rg"}, http.DefaultClient)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// etcdDiscoverer reads targets from the keys under a prefix of an etcd v3
+// cluster, through its JSON gateway. Every key holds either a single target,
+// {"address": "host:port", "labels": {...}}, or a file_sd style group,
+// {"targets": ["host:port", ...], "labels": {...}}.
+type etcdDiscoverer struct {
+	config    plugin.Config
+	client    *http.Client
+	endpoints []string
+	prefix    string
+}
+
+type etcdEntry struct {
+	Address string            `json:"address"`
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+func newEtcdDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	d := &etcdDiscoverer{
+		config:    config,
+		client:    client,
+		endpoints: splitList(getStringConfig(config, "etcd_endpoints")),
+		prefix:    getStringConfig(config, "etcd_prefix"),
+	}
+	if len(d.endpoints) == 0 {
+		return nil, fmt.Errorf("etcd discovery requires etcd_endpoints")
+	}
+	if d.prefix == "" {
+		return nil, fmt.Errorf("etcd discovery requires etcd_prefix")
+	}
+	return d, nil
+}
+
+func (d *etcdDiscoverer) discover() ([]target, error) {
+	request, err := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(d.prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd([]byte(d.prefix))),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Any member can serve the range, try them in turn
+	var response struct {
+		Kvs []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	err = fmt.Errorf("no etcd endpoint")
+	for _, endpoint := range d.endpoints {
+		if err = d.post(endpoint, request, &response); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []target{}
+	for _, kv := range response.Kvs {
+		key, _ := base64.StdEncoding.DecodeString(kv.Key)
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			continue
+		}
+
+		var entry etcdEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			glog.Warningf("Skipping etcd key %s holding invalid target JSON: %s", key, err.Error())
+			continue
+		}
+		addresses := entry.Targets
+		if entry.Address != "" {
+			addresses = append(addresses, entry.Address)
+		}
+		for _, address := range addresses {
+			tags := map[string]string{"etcd_key": string(key)}
+			for name, value := range entry.Labels {
+				tags[name] = value
+			}
+			targets = append(targets, newAddressTarget(d.config, address, tags))
+		}
+	}
+	return targets, nil
+}
+
+func (d *etcdDiscoverer) post(endpoint string, request []byte, response interface{}) error {
+	resp, err := d.client.Post(strings.TrimRight(endpoint, "/")+"/v3/kv/range", "application/json", bytes.NewReader(request))
+	if err != nil {
+		return fmt.Errorf("Unable to query etcd: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to query etcd: status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// prefixRangeEnd returns the etcd range end covering every key with prefix
+func prefixRangeEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix is all 0xff, range to the end of the keyspace
+	return []byte{0}
+}
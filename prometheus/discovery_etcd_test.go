@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEtcdDiscovery(t *testing.T) {
+	Convey("etcd discovery should read targets under the prefix", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request map[string]string
+			json.NewDecoder(r.Body).Decode(&request)
+			if r.URL.Path != "/v3/kv/range" ||
+				request["key"] != base64.StdEncoding.EncodeToString([]byte("/registry/")) ||
+				request["range_end"] != base64.StdEncoding.EncodeToString([]byte("/registry0")) {
+				http.NotFound(w, r)
+				return
+			}
+
+			kv := func(key, value string) map[string]string {
+				return map[string]string{
+					"key":   base64.StdEncoding.EncodeToString([]byte(key)),
+					"value": base64.StdEncoding.EncodeToString([]byte(value)),
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"kvs": []map[string]string{
+				kv("/registry/api", `{"address": "10.3.0.1:8080", "labels": {"service": "api"}}`),
+				kv("/registry/workers", `{"targets": ["10.3.0.2:9100", "10.3.0.3:9100"]}`),
+				kv("/registry/broken", `not json`),
+			}})
+		}))
+		defer server.Close()
+
+		d, err := newEtcdDiscoverer(plugin.Config{
+			"etcd_endpoints": "http://127.0.0.1:1," + server.URL,
+			"etcd_prefix":    "/registry/",
+		}, http.DefaultClient)
+		So(err, ShouldBeNil)
+
+		targets, err := d.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 3)
+		So(targets[0].URL, ShouldEqual, "http://10.3.0.1:8080/metrics")
+		So(targets[0].Tags["service"], ShouldEqual, "api")
+		So(targets[0].Tags["etcd_key"], ShouldEqual, "/registry/api")
+		So(targets[2].Tags["instance"], ShouldEqual, "10.3.0.3:9100")
+	})
+
+	Convey("Prefix range ends should cover every key with the prefix", t, func() {
+		So(string(prefixRangeEnd([]byte("/a/"))), ShouldEqual, "/a0")
+		So(prefixRangeEnd([]byte{'a', 0xff}), ShouldResemble, []byte{'b'})
+		So(prefixRangeEnd([]byte{0xff}), ShouldResemble, []byte{0})
+	})
+}
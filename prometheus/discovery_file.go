@@ -0,0 +1,103 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// fileTargetGroup is an entry of a targets file, in the JSON format of
+// Prometheus' file_sd_configs. The __scheme__ and __metrics_path__ labels
+// override the task's scheme and metrics_path for the group's targets.
+type fileTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// fileDiscoverer reads the targets of a task from targets_file, so targets
+// can be added or removed without restarting the plugin or redefining the
+// task. The file is only read again when its modification time or size
+// changed, so refreshing often is cheap.
+type fileDiscoverer struct {
+	config  plugin.Config
+	path    string
+	modTime time.Time
+	size    int64
+	targets []target
+}
+
+func newFileDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	path := getStringConfig(config, "targets_file")
+	if path == "" {
+		path = configFile
+	}
+	return &fileDiscoverer{config: config, path: path}, nil
+}
+
+func (d *fileDiscoverer) discover() ([]target, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+	if d.targets != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return d.targets, nil
+	}
+
+	content, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := parseTargetGroups(d.config, content)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid targets file %s: %s", d.path, err.Error())
+	}
+	if d.targets != nil {
+		glog.Infof("Reloaded %d targets from %s", len(targets), d.path)
+	}
+	d.targets, d.modTime, d.size = targets, info.ModTime(), info.Size()
+	return targets, nil
+}
+
+// parseTargetGroups returns the targets of a targets file, each tagged with
+// its group's labels and its instance
+func parseTargetGroups(config plugin.Config, content []byte) ([]target, error) {
+	var groups []fileTargetGroup
+	if err := json.Unmarshal(content, &groups); err != nil {
+		return nil, fmt.Errorf("must be a JSON list of {targets, labels} groups: %s", err.Error())
+	}
+
+	targets := []target{}
+	for _, group := range groups {
+		scheme := getStringConfig(config, "scheme")
+		path := getStringConfig(config, "metrics_path")
+		labels := make(map[string]string, len(group.Labels))
+		for name, value := range group.Labels {
+			switch name {
+			case "__scheme__":
+				scheme = value
+			case "__metrics_path__":
+				path = value
+			default:
+				labels[name] = value
+			}
+		}
+		for _, address := range group.Targets {
+			if address == "" {
+				return nil, fmt.Errorf("target group with an empty target")
+			}
+			tags := make(map[string]string, len(labels)+1)
+			for name, value := range labels {
+				tags[name] = value
+			}
+			tags["instance"] = address
+			targets = append(targets, target{URL: scheme + "://" + address + path, Tags: tags})
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,59 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileDiscovery(t *testing.T) {
+	Convey("File discovery should read targets from targets_file", t, func() {
+		dir, err := ioutil.TempDir("", "targets")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "targets.json")
+		So(ioutil.WriteFile(path, []byte(`[
+			{"targets": ["10.0.0.1:9100", "10.0.0.2:9100"], "labels": {"rack": "a1"}},
+			{"targets": ["10.0.0.3:8443"], "labels": {"__scheme__": "https", "__metrics_path__": "/stats"}}
+		]`), 0644), ShouldBeNil)
+
+		config := DefaultConfig()
+		config["discovery"] = "file"
+		config["targets_file"] = path
+		So(validateConfig(config), ShouldBeNil)
+
+		d, err := newFileDiscoverer(config, nil)
+		So(err, ShouldBeNil)
+		targets, err := d.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 3)
+		So(targets[0].URL, ShouldEqual, "http://10.0.0.1:9100/metrics")
+		So(targets[0].Tags, ShouldResemble, map[string]string{"rack": "a1", "instance": "10.0.0.1:9100"})
+		So(targets[2].URL, ShouldEqual, "https://10.0.0.3:8443/stats")
+		So(targets[2].Tags, ShouldResemble, map[string]string{"instance": "10.0.0.3:8443"})
+
+		Convey("and reload it when it changes", func() {
+			So(ioutil.WriteFile(path, []byte(`[{"targets": ["10.0.0.4:9100"]}]`), 0644), ShouldBeNil)
+			later := time.Now().Add(time.Second)
+			So(os.Chtimes(path, later, later), ShouldBeNil)
+
+			targets, err := d.discover()
+			So(err, ShouldBeNil)
+			So(len(targets), ShouldEqual, 1)
+			So(targets[0].Tags["instance"], ShouldEqual, "10.0.0.4:9100")
+		})
+
+		Convey("and reject an invalid file", func() {
+			So(ioutil.WriteFile(path, []byte(`{"targets": "10.0.0.4:9100"}`), 0644), ShouldBeNil)
+			later := time.Now().Add(time.Second)
+			So(os.Chtimes(path, later, later), ShouldBeNil)
+
+			_, err := d.discover()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,295 @@
+package prometheus
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	gceComputeURL     = "https://compute.googleapis.com/compute/v1"
+	gceMetadataURL    = "http://metadata.google.internal/computeMetadata/v1"
+	gceComputeScope   = "https://www.googleapis.com/auth/compute.readonly"
+	gceJWTBearerGrant = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// gceDiscoverer finds the running instances of a project's zones matching a
+// Compute API filter, and scrapes their primary internal IP on a configured
+// port
+type gceDiscoverer struct {
+	config      plugin.Config
+	client      *http.Client
+	computeURL  string
+	metadataURL string
+	project     string
+	zones       []string
+	filter      string
+	port        int
+	labels      []string
+	credentials *gceCredentials
+	token       string
+	tokenExpiry time.Time
+}
+
+// gceCredentials is the part of a service account JSON key needed to sign
+// token requests
+type gceCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	ProjectID   string `json:"project_id"`
+	key         *rsa.PrivateKey
+}
+
+type gceInstance struct {
+	Name              string            `json:"name"`
+	Zone              string            `json:"zone"`
+	Status            string            `json:"status"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP string `json:"networkIP"`
+	} `json:"networkInterfaces"`
+}
+
+func newGCEDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	d := &gceDiscoverer{
+		config:      config,
+		client:      client,
+		computeURL:  gceComputeURL,
+		metadataURL: gceMetadataURL,
+		project:     getStringConfig(config, "gce_project"),
+		filter:      getStringConfig(config, "gce_filter"),
+		port:        int(getIntConfig(config, "gce_port")),
+		zones:       splitList(getStringConfig(config, "gce_zone")),
+		labels:      splitList(getStringConfig(config, "gce_labels")),
+	}
+	if len(d.zones) == 0 {
+		return nil, fmt.Errorf("GCE discovery requires gce_zone")
+	}
+
+	if credentialsFile := getStringConfig(config, "gce_credentials_file"); credentialsFile != "" {
+		credentials, err := loadGCECredentials(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		d.credentials = credentials
+		if d.project == "" {
+			d.project = credentials.ProjectID
+		}
+	}
+	return d, nil
+}
+
+func loadGCECredentials(file string) (*gceCredentials, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read GCE credentials: " + err.Error())
+	}
+	credentials := &gceCredentials{}
+	if err := json.Unmarshal(content, credentials); err != nil {
+		return nil, fmt.Errorf("Unable to parse GCE credentials: " + err.Error())
+	}
+
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("No private key found in GCE credentials %s", file)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse GCE private key: " + err.Error())
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GCE private key in %s is not an RSA key", file)
+	}
+	credentials.key = rsaKey
+	if credentials.TokenURI == "" {
+		credentials.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return credentials, nil
+}
+
+func (d *gceDiscoverer) discover() ([]target, error) {
+	if d.project == "" {
+		project, err := d.metadata("/project/project-id")
+		if err != nil {
+			return nil, fmt.Errorf("gce_project is not set and could not be read from the metadata server: " + err.Error())
+		}
+		d.project = project
+	}
+
+	targets := []target{}
+	for _, zone := range d.zones {
+		pageToken := ""
+		for {
+			query := url.Values{}
+			if d.filter != "" {
+				query.Set("filter", d.filter)
+			}
+			if pageToken != "" {
+				query.Set("pageToken", pageToken)
+			}
+			var page struct {
+				Items         []gceInstance `json:"items"`
+				NextPageToken string        `json:"nextPageToken"`
+			}
+			instancesURL := fmt.Sprintf("%s/projects/%s/zones/%s/instances?%s",
+				d.computeURL, url.PathEscape(d.project), url.PathEscape(zone), query.Encode())
+			if err := d.get(instancesURL, &page); err != nil {
+				return nil, err
+			}
+
+			for _, instance := range page.Items {
+				if instance.Status != "RUNNING" || len(instance.NetworkInterfaces) == 0 {
+					continue
+				}
+				targets = append(targets, d.newTarget(instance))
+			}
+			if page.NextPageToken == "" {
+				break
+			}
+			pageToken = page.NextPageToken
+		}
+	}
+	return targets, nil
+}
+
+func (d *gceDiscoverer) newTarget(instance gceInstance) target {
+	tags := map[string]string{
+		"gce_instance": instance.Name,
+		"gce_zone":     path.Base(instance.Zone),
+		"gce_project":  d.project,
+	}
+	for _, label := range d.labels {
+		if value, ok := instance.Labels[label]; ok {
+			tags["gce_label_"+label] = value
+		}
+	}
+	return newTarget(d.config, instance.NetworkInterfaces[0].NetworkIP, d.port, tags)
+}
+
+func (d *gceDiscoverer) get(u string, result interface{}) error {
+	token, err := d.accessToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to query GCE: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to query GCE: status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (d *gceDiscoverer) metadata(p string) (string, error) {
+	req, err := http.NewRequest("GET", d.metadataURL+p, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	return string(content), err
+}
+
+// accessToken returns a Compute API token, signed with the service account
+// key when gce_credentials_file is set and from the metadata server (the
+// instance's service account or workload identity) otherwise
+func (d *gceDiscoverer) accessToken() (string, error) {
+	if d.token != "" && time.Now().Before(d.tokenExpiry) {
+		return d.token, nil
+	}
+
+	var resp *http.Response
+	var err error
+	if d.credentials != nil {
+		var assertion string
+		assertion, err = d.credentials.signAssertion(time.Now())
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{}
+		form.Set("grant_type", gceJWTBearerGrant)
+		form.Set("assertion", assertion)
+		resp, err = d.client.PostForm(d.credentials.TokenURI, form)
+	} else {
+		var req *http.Request
+		req, err = http.NewRequest("GET", d.metadataURL+"/instance/service-accounts/default/token", nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		resp, err = d.client.Do(req)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Unable to get GCE token: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unable to get GCE token: status code: %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	d.token = token.AccessToken
+	d.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return d.token, nil
+}
+
+// signAssertion builds the RS256 signed JWT exchanged for an access token
+func (c *gceCredentials) signAssertion(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   c.ClientEmail,
+		"scope": gceComputeScope,
+		"aud":   c.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,99 @@
+package prometheus
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGCEDiscovery(t *testing.T) {
+	Convey("GCE discovery should find running instances with a service account key", t, func() {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/token":
+				r.ParseForm()
+				if r.Form.Get("grant_type") != gceJWTBearerGrant || len(strings.Split(r.Form.Get("assertion"), ".")) != 3 {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+			case "/projects/proj/zones/us-central1-a/instances":
+				if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("filter") != "labels.env=prod" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(`{"items": [
+					{"name": "node-1", "zone": "https://www.googleapis.com/compute/v1/projects/proj/zones/us-central1-a",
+					 "status": "RUNNING", "labels": {"env": "prod", "team": "infra"}, "networkInterfaces": [{"networkIP": "10.2.0.3"}]},
+					{"name": "node-2", "status": "TERMINATED", "networkInterfaces": [{"networkIP": "10.2.0.4"}]}
+				]}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "gce-test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		So(err, ShouldBeNil)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		So(err, ShouldBeNil)
+		credentials, _ := json.Marshal(map[string]string{
+			"client_email": "scraper@proj.iam.gserviceaccount.com",
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"token_uri":    server.URL + "/token",
+			"project_id":   "proj",
+		})
+		credentialsFile := filepath.Join(dir, "key.json")
+		So(ioutil.WriteFile(credentialsFile, credentials, 0600), ShouldBeNil)
+
+		d, err := newGCEDiscoverer(plugin.Config{
+			"gce_zone":             "us-central1-a",
+			"gce_filter":           "labels.env=prod",
+			"gce_labels":           "team",
+			"gce_credentials_file": credentialsFile,
+		}, http.DefaultClient)
+		So(err, ShouldBeNil)
+		gce := d.(*gceDiscoverer)
+		gce.computeURL = server.URL
+
+		targets, err := gce.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+		So(targets[0].URL, ShouldEqual, "http://10.2.0.3:9100/metrics")
+		So(targets[0].Tags, ShouldResemble, map[string]string{
+			"instance":       "10.2.0.3:9100",
+			"gce_instance":   "node-1",
+			"gce_zone":       "us-central1-a",
+			"gce_project":    "proj",
+			"gce_label_team": "infra",
+		})
+
+		// Once the token expires, an unreachable token URI fails discovery
+		server.Close()
+		gce.token = ""
+		_, err = gce.discover()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("GCE discovery should require a zone", t, func() {
+		_, err := newGCEDiscoverer(plugin.Config{"gce_project": "proj"}, http.DefaultClient)
+		So(err, ShouldNotBeNil)
+	})
+}
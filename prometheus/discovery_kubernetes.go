@@ -0,0 +1,238 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// Annotations opting pods and services into scraping, as understood by
+// Prometheus' own kubernetes_sd examples
+const (
+	kubeScrapeAnnotation = "prometheus.io/scrape"
+	kubePortAnnotation   = "prometheus.io/port"
+	kubePathAnnotation   = "prometheus.io/path"
+	kubeSchemeAnnotation = "prometheus.io/scheme"
+)
+
+// kubernetesDiscoverer finds the pods or services annotated with
+// prometheus.io/scrape=true through the Kubernetes API server. Pod churn is
+// followed by listing them again every discovery_refresh_interval.
+type kubernetesDiscoverer struct {
+	config    plugin.Config
+	client    *http.Client
+	server    string
+	role      string
+	namespace string
+	// node restricts the pods to those scheduled on it when node_local_only
+	// is set
+	node string
+}
+
+type kubeObjectMeta struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+type kubePodList struct {
+	Items []struct {
+		Metadata kubeObjectMeta
+		Spec     struct {
+			NodeName   string
+			Containers []struct {
+				Ports []struct {
+					ContainerPort int
+				}
+			}
+		}
+		Status struct {
+			Phase string
+			PodIP string
+		}
+	}
+}
+
+type kubeServiceList struct {
+	Items []struct {
+		Metadata kubeObjectMeta
+		Spec     struct {
+			Ports []struct {
+				Port int
+			}
+		}
+	}
+}
+
+func newKubernetesDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	server, err := kubeAPIServer(config)
+	if err != nil {
+		return nil, err
+	}
+	if caFile := kubeCAFile(config); caFile != "" {
+		cached, err := newClient(clientOptions{caFile: caFile, timeout: client.Timeout})
+		if err != nil {
+			return nil, err
+		}
+		client = cached.client
+	}
+
+	return &kubernetesDiscoverer{
+		config:    config,
+		client:    client,
+		server:    server,
+		role:      getStringConfig(config, "kube_discovery_role"),
+		namespace: getStringConfig(config, "kube_discovery_namespace"),
+		node:      kubeLocalNode(config),
+	}, nil
+}
+
+// kubeLocalNode returns the node the collector runs on when the task keeps
+// the pods of that node only, read from node_name_env like the node_name
+// host tag
+func kubeLocalNode(config plugin.Config) string {
+	if !getBoolConfig(config, "node_local_only") {
+		return ""
+	}
+	return os.Getenv(getStringConfig(config, "node_name_env"))
+}
+
+// checkNodeLocalOnly rejects node_local_only when the collector couldn't
+// tell its pods from the others' and would scrape every node's instead
+func checkNodeLocalOnly(config plugin.Config) ([]string, error) {
+	if !getBoolConfig(config, "node_local_only") {
+		return nil, nil
+	}
+	if getStringConfig(config, "discovery") != "kubernetes" || getStringConfig(config, "kube_discovery_role") != "pod" {
+		return nil, fmt.Errorf("node_local_only needs the kubernetes discovery of pods")
+	}
+	if kubeLocalNode(config) == "" {
+		return nil, fmt.Errorf("node_local_only needs the node name in $%s", getStringConfig(config, "node_name_env"))
+	}
+	return nil, nil
+}
+
+func (d *kubernetesDiscoverer) discover() ([]target, error) {
+	path := "/api/v1/"
+	if d.namespace != "" {
+		path += "namespaces/" + url.PathEscape(d.namespace) + "/"
+	}
+
+	targets := []target{}
+	switch d.role {
+	case "service":
+		var services kubeServiceList
+		if err := d.get(path+"services", &services); err != nil {
+			return nil, err
+		}
+		for _, service := range services.Items {
+			if service.Metadata.Annotations[kubeScrapeAnnotation] != "true" {
+				continue
+			}
+			port := 0
+			if len(service.Spec.Ports) > 0 {
+				port = service.Spec.Ports[0].Port
+			}
+			host := service.Metadata.Name + "." + service.Metadata.Namespace + ".svc"
+			if t, ok := d.newTarget(service.Metadata, host, port, map[string]string{
+				"kube_namespace": service.Metadata.Namespace,
+				"kube_service":   service.Metadata.Name,
+			}); ok {
+				targets = append(targets, t)
+			}
+		}
+	default:
+		query := ""
+		if d.node != "" {
+			// The API server filters the pods of other nodes out, so a
+			// DaemonSet doesn't list every pod of the cluster on every node
+			query = "?fieldSelector=" + url.QueryEscape("spec.nodeName="+d.node)
+		}
+		var pods kubePodList
+		if err := d.get(path+"pods"+query, &pods); err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			if pod.Metadata.Annotations[kubeScrapeAnnotation] != "true" || pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+				continue
+			}
+			if d.node != "" && pod.Spec.NodeName != d.node {
+				continue
+			}
+			port := 0
+			for _, container := range pod.Spec.Containers {
+				if len(container.Ports) > 0 {
+					port = container.Ports[0].ContainerPort
+					break
+				}
+			}
+			if t, ok := d.newTarget(pod.Metadata, pod.Status.PodIP, port, map[string]string{
+				"kube_namespace": pod.Metadata.Namespace,
+				"kube_pod":       pod.Metadata.Name,
+				"kube_node":      pod.Spec.NodeName,
+			}); ok {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// newTarget returns the target of an annotated pod or service, with the
+// port, path and scheme annotations overriding the declared port and the
+// task's metrics_path and scheme. Objects without any port are skipped.
+func (d *kubernetesDiscoverer) newTarget(metadata kubeObjectMeta, host string, port int, tags map[string]string) (target, bool) {
+	if annotation := metadata.Annotations[kubePortAnnotation]; annotation != "" {
+		var err error
+		if port, err = strconv.Atoi(annotation); err != nil {
+			return target{}, false
+		}
+	}
+	if port <= 0 {
+		return target{}, false
+	}
+	path := getStringConfig(d.config, "metrics_path")
+	if annotation := metadata.Annotations[kubePathAnnotation]; annotation != "" {
+		path = annotation
+	}
+	scheme := getStringConfig(d.config, "scheme")
+	if annotation := metadata.Annotations[kubeSchemeAnnotation]; annotation == "http" || annotation == "https" {
+		scheme = annotation
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	tags["instance"] = address
+	for name, value := range metadata.Labels {
+		tags["kube_label_"+name] = value
+	}
+	return target{URL: scheme + "://" + address + path, Tags: tags}, true
+}
+
+func (d *kubernetesDiscoverer) get(path string, result interface{}) error {
+	req, err := http.NewRequest("GET", d.server+path, nil)
+	if err != nil {
+		return err
+	}
+	token, err := kubeToken(d.config)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to query Kubernetes: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to query Kubernetes: status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
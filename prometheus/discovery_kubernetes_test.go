@@ -0,0 +1,149 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const kubePodsResponse = `{"items": [
+	{"metadata": {"name": "api-1", "namespace": "shop", "labels": {"app": "api"},
+		"annotations": {"prometheus.io/scrape": "true", "prometheus.io/port": "8080", "prometheus.io/path": "/internal/metrics"}},
+	 "spec": {"nodeName": "node-a", "containers": [{"ports": [{"containerPort": 80}]}]},
+	 "status": {"phase": "Running", "podIP": "10.4.0.1"}},
+	{"metadata": {"name": "worker-1", "namespace": "shop", "annotations": {"prometheus.io/scrape": "true"}},
+	 "spec": {"nodeName": "node-b", "containers": [{"ports": []}, {"ports": [{"containerPort": 9100}]}]},
+	 "status": {"phase": "Running", "podIP": "10.4.0.2"}},
+	{"metadata": {"name": "pending-1", "namespace": "shop", "annotations": {"prometheus.io/scrape": "true"}},
+	 "spec": {"containers": [{"ports": [{"containerPort": 9100}]}]},
+	 "status": {"phase": "Pending"}},
+	{"metadata": {"name": "db-1", "namespace": "shop"},
+	 "spec": {"containers": [{"ports": [{"containerPort": 5432}]}]},
+	 "status": {"phase": "Running", "podIP": "10.4.0.3"}}
+]}`
+
+const kubeServicesResponse = `{"items": [
+	{"metadata": {"name": "api", "namespace": "shop", "annotations": {"prometheus.io/scrape": "true", "prometheus.io/scheme": "https"}},
+	 "spec": {"ports": [{"port": 443}]}},
+	{"metadata": {"name": "db", "namespace": "shop"}, "spec": {"ports": [{"port": 5432}]}}
+]}`
+
+func TestKubernetesDiscovery(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "kube-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	fmt.Fprint(tokenFile, "secret-token\n")
+	tokenFile.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/pods":
+			if selector := r.URL.Query().Get("fieldSelector"); selector != "" {
+				if selector != "spec.nodeName=node-a" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				// Answer as if the API server ignored the selector, the
+				// discoverer filters too
+			}
+			fmt.Fprint(w, kubePodsResponse)
+		case "/api/v1/namespaces/shop/services":
+			fmt.Fprint(w, kubeServicesResponse)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := func(role, namespace string) plugin.Config {
+		return plugin.Config{
+			"discovery":                "kubernetes",
+			"kube_apiserver":           server.URL,
+			"kube_token_file":          tokenFile.Name(),
+			"kube_discovery_role":      role,
+			"kube_discovery_namespace": namespace,
+		}
+	}
+
+	Convey("Kubernetes discovery should find running annotated pods", t, func() {
+		d, err := newKubernetesDiscoverer(config("pod", ""), http.DefaultClient)
+		So(err, ShouldBeNil)
+
+		targets, err := d.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 2)
+		So(targets[0].URL, ShouldEqual, "http://10.4.0.1:8080/internal/metrics")
+		So(targets[0].Tags["kube_pod"], ShouldEqual, "api-1")
+		So(targets[0].Tags["kube_node"], ShouldEqual, "node-a")
+		So(targets[0].Tags["kube_label_app"], ShouldEqual, "api")
+		So(targets[1].URL, ShouldEqual, "http://10.4.0.2:9100/metrics")
+	})
+
+	Convey("Kubernetes discovery should keep the pods of its own node with node_local_only", t, func() {
+		os.Setenv("NODE_NAME", "node-a")
+		defer os.Unsetenv("NODE_NAME")
+		c := config("pod", "")
+		c["node_local_only"] = true
+		c["node_name_env"] = "NODE_NAME"
+		d, err := newKubernetesDiscoverer(c, http.DefaultClient)
+		So(err, ShouldBeNil)
+
+		targets, err := d.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+		So(targets[0].Tags["kube_pod"], ShouldEqual, "api-1")
+
+		_, err = checkNodeLocalOnly(c)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("node_local_only should be rejected when the node is unknown", t, func() {
+		os.Unsetenv("NODE_NAME")
+		c := config("pod", "")
+		c["node_local_only"] = true
+		c["node_name_env"] = "NODE_NAME"
+		_, err := checkNodeLocalOnly(c)
+		So(err, ShouldNotBeNil)
+
+		os.Setenv("NODE_NAME", "node-a")
+		defer os.Unsetenv("NODE_NAME")
+		c = config("service", "shop")
+		c["node_local_only"] = true
+		c["node_name_env"] = "NODE_NAME"
+		_, err = checkNodeLocalOnly(c)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Kubernetes discovery should find annotated services of a namespace", t, func() {
+		d, err := newKubernetesDiscoverer(config("service", "shop"), http.DefaultClient)
+		So(err, ShouldBeNil)
+
+		targets, err := d.discover()
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+		So(targets[0].URL, ShouldEqual, "https://api.shop.svc:443/metrics")
+		So(targets[0].Tags["kube_service"], ShouldEqual, "api")
+	})
+
+	Convey("Kubernetes discovery should fail without a token", t, func() {
+		c := config("pod", "")
+		c["kube_token_file"] = tokenFile.Name() + ".missing"
+		d, err := newKubernetesDiscoverer(c, http.DefaultClient)
+		So(err, ShouldBeNil)
+		_, err = d.discover()
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,130 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// nomadDiscoverer finds targets among the services registered in Nomad's
+// native service discovery, optionally keeping only services with a tag
+type nomadDiscoverer struct {
+	config    plugin.Config
+	client    *http.Client
+	address   string
+	token     string
+	namespace string
+	tag       string
+}
+
+type nomadServiceList struct {
+	Namespace string
+	Services  []struct {
+		ServiceName string
+		Tags        []string
+	}
+}
+
+type nomadRegistration struct {
+	ServiceName string
+	Namespace   string
+	NodeID      string
+	Datacenter  string
+	JobID       string
+	AllocID     string
+	Tags        []string
+	Address     string
+	Port        int
+}
+
+func newNomadDiscoverer(config plugin.Config, client *http.Client) (discoverer, error) {
+	address := getStringConfig(config, "nomad_address")
+	if address == "" {
+		address = os.Getenv("NOMAD_ADDR")
+	}
+	if address == "" {
+		address = "http://127.0.0.1:4646"
+	}
+	token := getStringConfig(config, "nomad_token")
+	if token == "" {
+		token = os.Getenv("NOMAD_TOKEN")
+	}
+
+	return &nomadDiscoverer{
+		config:    config,
+		client:    client,
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+		namespace: getStringConfig(config, "nomad_namespace"),
+		tag:       getStringConfig(config, "nomad_tag"),
+	}, nil
+}
+
+func (d *nomadDiscoverer) discover() ([]target, error) {
+	var lists []nomadServiceList
+	if err := d.get("/v1/services", &lists); err != nil {
+		return nil, err
+	}
+
+	targets := []target{}
+	for _, list := range lists {
+		for _, service := range list.Services {
+			if d.tag != "" && !containsString(service.Tags, d.tag) {
+				continue
+			}
+
+			var registrations []nomadRegistration
+			if err := d.get("/v1/service/"+url.PathEscape(service.ServiceName), &registrations); err != nil {
+				return nil, err
+			}
+			for _, r := range registrations {
+				if d.tag != "" && !containsString(r.Tags, d.tag) {
+					continue
+				}
+				targets = append(targets, newTarget(d.config, r.Address, r.Port, map[string]string{
+					"nomad_service":    r.ServiceName,
+					"nomad_namespace":  r.Namespace,
+					"nomad_datacenter": r.Datacenter,
+					"nomad_node":       r.NodeID,
+					"nomad_job":        r.JobID,
+					"nomad_alloc":      r.AllocID,
+				}))
+			}
+		}
+	}
+	return targets, nil
+}
+
+func (d *nomadDiscoverer) get(path string, result interface{}) error {
+	req, err := http.NewRequest("GET", d.address+path+"?namespace="+url.QueryEscape(d.namespace), nil)
+	if err != nil {
+		return err
+	}
+	if d.token != "" {
+		req.Header.Set("X-Nomad-Token", d.token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to query Nomad: " + err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unable to query Nomad: status code: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
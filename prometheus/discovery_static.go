@@ -0,0 +1,82 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// staticTarget is an entry of the static_targets config. The address is
+// either host:port, scraped with scheme and metrics_path, or a full URL.
+type staticTarget struct {
+	Address string            `json:"address"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// parseStaticTargets returns the targets listed inline in the task config,
+// e.g. [{"address": "10.0.0.1:9100", "labels": {"rack": "a1"}}]
+func parseStaticTargets(config plugin.Config) ([]target, error) {
+	var entries []staticTarget
+	if err := json.Unmarshal([]byte(getStringConfig(config, "static_targets")), &entries); err != nil {
+		return nil, fmt.Errorf("static_targets must be a JSON list of {address, labels} entries: %s", err.Error())
+	}
+
+	targets := make([]target, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Address == "" {
+			return nil, fmt.Errorf("static_targets entry without an address")
+		}
+		tags := make(map[string]string, len(entry.Labels)+1)
+		for name, value := range entry.Labels {
+			tags[name] = value
+		}
+
+		u, err := url.Parse(entry.Address)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			targets = append(targets, newAddressTarget(config, entry.Address, tags))
+			continue
+		}
+		tags["instance"] = u.Host
+		targets = append(targets, target{URL: entry.Address, Tags: tags})
+	}
+	return targets, nil
+}
+
+// parseEndpoints returns the targets of the endpoints config, a comma
+// separated or JSON list of URLs, e.g. ["http://10.0.0.1:9100", ...]. URLs
+// are completed like endpoint, following append_metrics_path, and every
+// target is tagged with its endpoint.
+func parseEndpoints(config plugin.Config) ([]target, error) {
+	return parseEndpointList(config, "endpoints")
+}
+
+// parseEndpointList returns the targets of the endpoint list in key
+func parseEndpointList(config plugin.Config, key string) ([]target, error) {
+	spec := strings.TrimSpace(getStringConfig(config, key))
+	var endpoints []string
+	if strings.HasPrefix(spec, "[") {
+		if err := json.Unmarshal([]byte(spec), &endpoints); err != nil {
+			return nil, fmt.Errorf("%s must be a comma separated or JSON list of URLs: %s", key, err.Error())
+		}
+	} else {
+		endpoints = splitList(spec)
+	}
+
+	targets := make([]target, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if _, _, ok := parseUnixEndpoint(endpoint); ok {
+			targets = append(targets, target{URL: endpoint, Tags: map[string]string{"endpoint": endpoint}})
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid %s entry %q, expecting a URL", key, endpoint)
+		}
+		endpoint = endpointURL(config, endpoint)
+		targets = append(targets, target{URL: endpoint, Tags: map[string]string{"endpoint": endpoint}})
+	}
+	return targets, nil
+}
@@ -0,0 +1,69 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStaticTargets(t *testing.T) {
+	Convey("Static targets should be read with their labels", t, func() {
+		config := DefaultConfig()
+		config["static_targets"] = `[
+			{"address": "10.0.0.1:9100", "labels": {"rack": "a1"}},
+			{"address": "https://etcd-0:2379/metrics"}
+		]`
+		So(validateConfig(config), ShouldBeNil)
+
+		targets, err := parseStaticTargets(config)
+		So(err, ShouldBeNil)
+		So(targets, ShouldResemble, []target{
+			{URL: "http://10.0.0.1:9100/metrics", Tags: map[string]string{"rack": "a1", "instance": "10.0.0.1:9100"}},
+			{URL: "https://etcd-0:2379/metrics", Tags: map[string]string{"instance": "etcd-0:2379"}},
+		})
+	})
+
+	Convey("Invalid static targets should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"static_targets": `{"address": "10.0.0.1:9100"}`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"static_targets": `[{"labels": {}}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"static_targets": `[]`, "discovery": "nomad"}), ShouldNotBeNil)
+	})
+}
+
+func TestEndpoints(t *testing.T) {
+	Convey("Endpoints should be read from a comma separated list", t, func() {
+		targets, err := parseEndpoints(plugin.Config{"endpoints": "http://10.0.0.1:9100, https://10.0.0.2:8443/custom/metrics"})
+		So(err, ShouldBeNil)
+		So(targets, ShouldResemble, []target{
+			{URL: "http://10.0.0.1:9100/metrics", Tags: map[string]string{"endpoint": "http://10.0.0.1:9100/metrics"}},
+			{URL: "https://10.0.0.2:8443/custom/metrics", Tags: map[string]string{"endpoint": "https://10.0.0.2:8443/custom/metrics"}},
+		})
+	})
+
+	Convey("Endpoints should be read from a JSON list", t, func() {
+		targets, err := parseEndpoints(plugin.Config{"endpoints": `["http://10.0.0.1:9100/metrics"]`})
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+	})
+
+	Convey("Endpoints should be scraped as given without append_metrics_path", t, func() {
+		targets, err := parseEndpoints(plugin.Config{
+			"endpoints":           "http://10.0.0.1:9100/stats, http://10.0.0.2:9100",
+			"append_metrics_path": false,
+			"metrics_path":        "/probe",
+		})
+		So(err, ShouldBeNil)
+		So(targets[0].URL, ShouldEqual, "http://10.0.0.1:9100/stats")
+		So(targets[1].URL, ShouldEqual, "http://10.0.0.2:9100/probe")
+	})
+
+	Convey("Invalid endpoints should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"endpoints": "10.0.0.1:9100"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoints": `["http://10.0.0.1:9100"`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoints": "http://10.0.0.1:9100", "discovery": "nomad"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoints": "http://10.0.0.1:9100", "endpoint": "http://10.0.0.2:9100"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoints": "http://10.0.0.1:9100"}), ShouldBeNil)
+	})
+}
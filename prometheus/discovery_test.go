@@ -0,0 +1,117 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newNomadServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/services":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"Namespace": "default",
+				"Services": []map[string]interface{}{
+					{"ServiceName": "api", "Tags": []string{"metrics"}},
+					{"ServiceName": "db", "Tags": []string{}},
+				},
+			}})
+		case "/v1/service/api":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"ServiceName": "api",
+				"Namespace":   "default",
+				"Datacenter":  "dc1",
+				"NodeID":      "node-1",
+				"JobID":       "api-job",
+				"AllocID":     "alloc-1",
+				"Tags":        []string{"metrics"},
+				"Address":     "10.0.0.5",
+				"Port":        9100,
+			}})
+		case "/v1/service/db":
+			w.Write([]byte("[]"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNomadDiscovery(t *testing.T) {
+	Convey("Nomad discovery should find tagged services", t, func() {
+		server := newNomadServer()
+		defer server.Close()
+
+		config := DefaultConfig()
+		config["discovery"] = "nomad"
+		config["nomad_address"] = server.URL
+		config["nomad_tag"] = "metrics"
+		So(validateConfig(config), ShouldBeNil)
+
+		targets, err := newDiscoveryManager().targets(config)
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+		So(targets[0].URL, ShouldEqual, "http://10.0.0.5:9100/metrics")
+		So(targets[0].Tags["nomad_job"], ShouldEqual, "api-job")
+		So(targets[0].Tags["nomad_alloc"], ShouldEqual, "alloc-1")
+		So(targets[0].Tags["instance"], ShouldEqual, "10.0.0.5:9100")
+	})
+
+	Convey("Discovery should keep previous targets when a refresh fails", t, func() {
+		server := newNomadServer()
+		config := DefaultConfig()
+		config["discovery"] = "nomad"
+		config["nomad_address"] = server.URL
+		config["discovery_refresh_interval"] = "0s"
+
+		manager := newDiscoveryManager()
+		targets, err := manager.targets(config)
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+
+		server.Close()
+		targets, err = manager.targets(config)
+		So(err, ShouldBeNil)
+		So(len(targets), ShouldEqual, 1)
+	})
+
+	Convey("Discovered targets should be scraped and tagged", t, func() {
+		exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(TEST_DATA))
+		}))
+		defer exporter.Close()
+		address := strings.TrimPrefix(exporter.URL, "http://")
+
+		collector := New().(*PrometheusCollector)
+		discoverers["static-test"] = func(config plugin.Config, client *http.Client) (discoverer, error) {
+			return staticTestDiscoverer{target{URL: exporter.URL + "/metrics", Tags: map[string]string{"instance": address}}}, nil
+		}
+		defer delete(discoverers, "static-test")
+
+		mts, _ := collector.GetMetricTypes(plugin.Config{})
+		mts[0].Config = plugin.Config{"discovery": "static-test"}
+		metrics, err := collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		So(len(metrics), ShouldBeGreaterThan, 0)
+		for _, metric := range metrics {
+			So(metric.Tags["instance"], ShouldEqual, address)
+		}
+	})
+
+	Convey("Discovery and a static endpoint should be mutually exclusive", t, func() {
+		So(validateConfig(plugin.Config{"discovery": "nomad", "endpoint": "http://10.0.0.1:9100"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"discovery": "zeroconf"}), ShouldNotBeNil)
+	})
+}
+
+type staticTestDiscoverer []target
+
+func (d staticTestDiscoverer) discover() ([]target, error) {
+	return d, nil
+}
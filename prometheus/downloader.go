@@ -0,0 +1,313 @@
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+type MetricsDownloader interface {
+	GetMetricsReader(url string, config plugin.Config) (io.Reader, error)
+	GetEndpoint(config plugin.Config) (string, error)
+}
+
+type HTTPMetricsDownloader struct {
+	clients *clientCache
+}
+
+// NewHTTPMetricsDownloader returns a downloader sharing HTTP clients between
+// tasks with the same client settings
+func NewHTTPMetricsDownloader() HTTPMetricsDownloader {
+	return HTTPMetricsDownloader{
+		clients: newClientCache(),
+	}
+}
+
+func (downloader HTTPMetricsDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	if isKubeProxy(config) {
+		return kubeProxyEndpoint(config)
+	}
+
+	address, err := config.GetString("endpoint")
+	if err != nil {
+		return "", err
+	}
+
+	return endpointURL(config, address), nil
+}
+
+// endpointURL returns the URL scraping a configured endpoint. With
+// append_metrics_path endpoints without /metrics get it appended, otherwise
+// they're scraped as given, metrics_path only completing endpoints without
+// a path.
+func endpointURL(config plugin.Config, address string) string {
+	// The HTTP path of unix socket endpoints follows a colon, /metrics when
+	// it's left out
+	if strings.HasPrefix(address, unixScheme) {
+		return address
+	}
+
+	if !getBoolConfig(config, "append_metrics_path") {
+		if parsed, err := url.Parse(address); err == nil && strings.Trim(parsed.Path, "/") == "" {
+			return strings.TrimSuffix(address, "/") + getStringConfig(config, "metrics_path")
+		}
+		return address
+	}
+
+	if strings.Contains(address, "/metrics") {
+		return address
+	}
+	return address + "/metrics"
+}
+
+func (downloader HTTPMetricsDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	opts, err := getClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	if socket, path, ok := parseUnixEndpoint(url); ok {
+		if opts.safeMode {
+			return nil, fmt.Errorf("safe_mode doesn't scrape unix sockets")
+		}
+		opts.socketPath = socket
+		url = "http://localhost" + path
+	}
+
+	client, err := downloader.clients.get(opts)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := getSignatureVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	success, err := getSuccessStatusCodes(config, url)
+	if err != nil {
+		return nil, err
+	}
+	retries := getIntConfig(config, "scrape_retries")
+	backoff, err := getDurationConfig(config, "scrape_retry_backoff")
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := getDurationConfig(config, "scrape_retry_max_backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	// Retries stop with the target_timeout of the scrape, which gives up on
+	// it by then anyway
+	var deadline time.Time
+	if timeout, _ := getDurationConfig(config, "target_timeout"); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for attempt := int64(0); ; attempt++ {
+		reader, retryable, err := downloader.scrape(client, url, config, opts, verifier, success)
+		if err == nil || !retryable || attempt >= retries {
+			return reader, err
+		}
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			return reader, err
+		}
+		glog.V(2).Infof("Scrape %d of %s failed, retrying in %s: %s", attempt+1, url, backoff, err.Error())
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// scrape makes a single scrape request, and tells whether its failure is
+// worth retrying: a request that timed out or whose connection was refused
+// or reset, or that got a 5xx or 429 status
+func (downloader HTTPMetricsDownloader) scrape(client *http.Client, url string, config plugin.Config, opts clientOptions, verifier signatureVerifier, success statusCodes) (io.Reader, bool, error) {
+	req, err := newScrapeRequest(url, config)
+	if err != nil {
+		return nil, false, err
+	}
+	if isKubeProxy(config) {
+		token, err := kubeToken(config)
+		if err != nil {
+			return nil, false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if err := setAuthorization(req, config); err != nil {
+		return nil, false, err
+	}
+
+	// The address a response came from, for the circuit breaker of hosts
+	// resolving to several addresses to also open on failed statuses
+	health := downloader.clients.healthOf(opts)
+	var remote net.Addr
+	if health != nil {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { remote = info.Conn.RemoteAddr() },
+		}))
+	}
+
+	resp, err := client.Do(req)
+	if err == nil && health != nil && remote != nil {
+		if ip, _, splitErr := net.SplitHostPort(remote.String()); splitErr == nil {
+			if success.accepts(resp.StatusCode) {
+				health.record(ip, nil)
+			} else {
+				health.record(ip, fmt.Errorf("status code %d", resp.StatusCode))
+			}
+		}
+	}
+	if err != nil {
+		return nil, retryableError(err), err
+	} else if success.accepts(resp.StatusCode) {
+		// With a read timeout the body is parsed as it arrives, the
+		// connection only timing out when it stalls
+		streamed := opts.readTimeout > 0 && verifier == nil
+		if !streamed {
+			defer resp.Body.Close()
+		}
+
+		body, err := decodeBody(resp, resp.Body)
+		if err != nil {
+			if streamed {
+				resp.Body.Close()
+			}
+			return nil, false, err
+		}
+		threshold := getIntConfig(config, "spill_threshold")
+		if opts.safeMode {
+			body = &limitedBody{reader: body, limit: safeModeMaxBodySize}
+		}
+		if isLowMemory(config) {
+			body = &limitedBody{reader: body, limit: lowMemoryMaxBodySize}
+			threshold = lowMemorySpillThresholdOf(config)
+		}
+		if verifier != nil {
+			reader, err := verifySignature(verifier, resp.Header, getStringConfig(config, "signature_header"), body)
+			return reader, false, err
+		}
+		if streamed {
+			return &streamedBody{reader: body, body: resp.Body}, false, nil
+		}
+		reader, err := readBody(body, threshold, getStringConfig(config, "spill_dir"))
+		return reader, false, err
+	} else {
+		resp.Body.Close()
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, fmt.Errorf("Status code: %d Response: %v\n", resp.StatusCode, resp)
+	}
+}
+
+// retryableError tells whether a request failed on a network timeout or a
+// refused or reset connection. Other failures, such as a certificate that
+// doesn't verify, an address denied by allowed_cidrs or a refused redirect,
+// fail again on retry.
+func retryableError(err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// newScrapeRequest returns the request scraping url with the task's method
+// and request body, for gateways selecting the exposition from a POSTed body
+func newScrapeRequest(url string, config plugin.Config) (*http.Request, error) {
+	method := getStringConfig(config, "method")
+	body := getStringConfig(config, "request_body")
+	var req *http.Request
+	var err error
+	if body == "" {
+		req, err = http.NewRequest(method, url, nil)
+	} else {
+		req, err = http.NewRequest(method, url, strings.NewReader(body))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", getStringConfig(config, "request_content_type"))
+	}
+	if getStringConfig(config, "exposition_format") == "protobuf" {
+		req.Header.Set("Accept", protobufAccept)
+	}
+	// Setting Accept-Encoding ourselves turns the transport's transparent
+	// decompression off, GetMetricsReader decompresses instead
+	req.Header.Set("Accept-Encoding", getStringConfig(config, "accept_encoding"))
+	return req, nil
+}
+
+// decodeBody returns the decompressed body of a response compressed with
+// gzip, and body itself otherwise. Body size limits apply to what it
+// returns, so a small compressed body can't inflate past them.
+func decodeBody(resp *http.Response, body io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return body, nil
+	}
+	decompressed, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decompress gzip body: %s", err.Error())
+	}
+	return decompressed, nil
+}
+
+// readBody copies a response body so the connection can be released before
+// parsing. Bodies larger than threshold bytes are streamed to a temp file in
+// dir instead of memory; the returned reader is then an io.Closer removing
+// the file. A threshold of 0 keeps every body in memory.
+func readBody(body io.Reader, threshold int64, dir string) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	if threshold <= 0 {
+		if _, err := buf.ReadFrom(body); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf.Bytes()), nil
+	}
+
+	if _, err := io.CopyN(buf, body, threshold+1); err == io.EOF {
+		return bytes.NewReader(buf.Bytes()), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile(dir, "snap-prometheus-scrape-")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to spill body to disk: " + err.Error())
+	}
+	spilled := &spillFile{file}
+	if _, err := buf.WriteTo(file); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spilled.Close()
+		return nil, err
+	}
+	return spilled, nil
+}
+
+// spillFile is a scrape body spilled to disk, removed once closed
+type spillFile struct {
+	*os.File
+}
+
+func (f *spillFile) Close() error {
+	f.File.Close()
+	return os.Remove(f.Name())
+}
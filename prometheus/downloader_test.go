@@ -0,0 +1,209 @@
+package prometheus
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadBody(t *testing.T) {
+	Convey("Small bodies should be kept in memory", t, func() {
+		reader, err := readBody(strings.NewReader(TEST_DATA), int64(len(TEST_DATA)), "")
+		So(err, ShouldBeNil)
+		_, spilled := reader.(io.Closer)
+		So(spilled, ShouldBeFalse)
+	})
+
+	Convey("Bodies above the threshold should be spilled to disk", t, func() {
+		dir, err := ioutil.TempDir("", "spill-test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		reader, err := readBody(strings.NewReader(TEST_DATA), 100, dir)
+		So(err, ShouldBeNil)
+		closer, spilled := reader.(io.Closer)
+		So(spilled, ShouldBeTrue)
+
+		content, err := ioutil.ReadAll(reader)
+		So(err, ShouldBeNil)
+		So(string(content), ShouldEqual, TEST_DATA)
+
+		So(closer.Close(), ShouldBeNil)
+		files, _ := ioutil.ReadDir(dir)
+		So(len(files), ShouldEqual, 0)
+	})
+}
+
+func TestNewScrapeRequest(t *testing.T) {
+	Convey("Scrapes should default to GET without a body", t, func() {
+		req, err := newScrapeRequest("http://localhost:9100/metrics", plugin.Config{})
+		So(err, ShouldBeNil)
+		So(req.Method, ShouldEqual, "GET")
+		So(req.Body, ShouldBeNil)
+		So(req.Header.Get("Accept-Encoding"), ShouldEqual, "gzip")
+	})
+
+	Convey("POST scrapes should send the request body", t, func() {
+		config := plugin.Config{
+			"method":       "POST",
+			"request_body": `{"tenant": "team-a"}`,
+		}
+		req, err := newScrapeRequest("http://localhost:9100/metrics", config)
+		So(err, ShouldBeNil)
+		So(req.Method, ShouldEqual, "POST")
+		So(req.Header.Get("Content-Type"), ShouldEqual, "application/json")
+		body, err := ioutil.ReadAll(req.Body)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, `{"tenant": "team-a"}`)
+	})
+
+	Convey("Scrapes should ask for protobuf when configured", t, func() {
+		req, err := newScrapeRequest("http://localhost:9100/metrics", plugin.Config{})
+		So(err, ShouldBeNil)
+		So(req.Header.Get("Accept"), ShouldEqual, "")
+		req, err = newScrapeRequest("http://localhost:9100/metrics", plugin.Config{"exposition_format": "protobuf"})
+		So(err, ShouldBeNil)
+		So(req.Header.Get("Accept"), ShouldEqual, protobufAccept)
+	})
+
+	Convey("A request body should need POST", t, func() {
+		_, err := checkScrapeMethod(plugin.Config{"request_body": "{}"})
+		So(err, ShouldNotBeNil)
+		_, err = checkScrapeMethod(plugin.Config{"method": "PUT"})
+		So(err, ShouldNotBeNil)
+		_, err = checkScrapeMethod(plugin.Config{"method": "POST", "request_body": "{}"})
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestGetEndpoint(t *testing.T) {
+	downloader := NewHTTPMetricsDownloader()
+	endpoint := func(config plugin.Config) string {
+		url, err := downloader.GetEndpoint(config)
+		So(err, ShouldBeNil)
+		return url
+	}
+
+	Convey("/metrics should be appended unless the endpoint contains it", t, func() {
+		So(endpoint(plugin.Config{"endpoint": "http://localhost:9100"}), ShouldEqual, "http://localhost:9100/metrics")
+		So(endpoint(plugin.Config{"endpoint": "http://localhost:9100/internal/metricstore"}), ShouldEqual, "http://localhost:9100/internal/metricstore")
+		So(endpoint(plugin.Config{"endpoint": "http://localhost:9100/stats"}), ShouldEqual, "http://localhost:9100/stats/metrics")
+	})
+
+	Convey("Endpoints should be scraped as given without append_metrics_path", t, func() {
+		config := plugin.Config{"append_metrics_path": false, "endpoint": "http://localhost:9100/stats"}
+		So(endpoint(config), ShouldEqual, "http://localhost:9100/stats")
+		config["endpoint"] = "http://localhost:9100/"
+		So(endpoint(config), ShouldEqual, "http://localhost:9100/metrics")
+		config["metrics_path"] = "/federate"
+		So(endpoint(config), ShouldEqual, "http://localhost:9100/federate")
+	})
+}
+
+func TestGzipBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			io.WriteString(w, TEST_DATA)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		compressed := gzip.NewWriter(w)
+		io.WriteString(compressed, TEST_DATA)
+		compressed.Close()
+	}))
+	defer server.Close()
+	downloader := NewHTTPMetricsDownloader()
+
+	Convey("Gzip bodies should be decompressed", t, func() {
+		for _, encoding := range []string{"gzip", "identity"} {
+			reader, err := downloader.GetMetricsReader(server.URL, plugin.Config{"accept_encoding": encoding})
+			So(err, ShouldBeNil)
+			body, err := ioutil.ReadAll(reader)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, TEST_DATA)
+		}
+	})
+
+	Convey("Corrupt gzip bodies should fail the scrape", t, func() {
+		resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}}
+		_, err := decodeBody(resp, strings.NewReader(TEST_DATA))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestScrapeRetries(t *testing.T) {
+	var requests int32
+	failures := int32(2)
+	status := int32(http.StatusBadGateway)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failures) {
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+			return
+		}
+		io.WriteString(w, TEST_DATA)
+	}))
+	defer server.Close()
+	downloader := NewHTTPMetricsDownloader()
+	config := plugin.Config{"scrape_retries": int64(2), "scrape_retry_backoff": "1ms"}
+
+	Convey("Transient failures should be retried", t, func() {
+		So(validateConfig(config), ShouldBeNil)
+		atomic.StoreInt32(&requests, 0)
+		reader, err := downloader.GetMetricsReader(server.URL, config)
+		So(err, ShouldBeNil)
+		closeBody(reader)
+		So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+	})
+
+	Convey("Scrapes should give up after scrape_retries", t, func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failures, 5)
+		_, err := downloader.GetMetricsReader(server.URL, config)
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+	})
+
+	Convey("Client errors should not be retried", t, func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&status, http.StatusNotFound)
+		_, err := downloader.GetMetricsReader(server.URL, config)
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+	})
+
+	Convey("Retries should stop at the target_timeout", t, func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&status, http.StatusBadGateway)
+		_, err := downloader.GetMetricsReader(server.URL, plugin.Config{
+			"scrape_retries":       int64(5),
+			"scrape_retry_backoff": "60ms",
+			"target_timeout":       "100ms",
+		})
+		So(err, ShouldNotBeNil)
+		So(atomic.LoadInt32(&requests), ShouldEqual, 2)
+	})
+
+	Convey("Only timeouts and refused or reset connections should be retried", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		listener.Close()
+		_, err = http.Get("http://" + listener.Addr().String())
+		So(retryableError(err), ShouldBeTrue)
+
+		tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer tlsServer.Close()
+		_, err = http.Get(tlsServer.URL)
+		So(err, ShouldNotBeNil)
+		So(retryableError(err), ShouldBeFalse)
+	})
+}
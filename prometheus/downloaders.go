@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// Option customizes a collector built by New
+type Option func(c *PrometheusCollector)
+
+// WithDownloader replaces the default downloader, which scrapes over HTTP
+// with fault injection and recording, e.g. to embed the collector behind a
+// custom transport
+func WithDownloader(downloader MetricsDownloader) Option {
+	return func(c *PrometheusCollector) {
+		c.Downloader = downloader
+	}
+}
+
+var (
+	downloadersMutex sync.Mutex
+	// downloaders are the downloaders tasks can select by name with the
+	// downloader option
+	downloaders = map[string]MetricsDownloader{}
+)
+
+// RegisterDownloader makes downloader selectable by tasks setting the
+// downloader option to name. It's meant to be called before the plugin
+// starts, registering a name again replaces its downloader.
+func RegisterDownloader(name string, downloader MetricsDownloader) {
+	downloadersMutex.Lock()
+	defer downloadersMutex.Unlock()
+	downloaders[name] = downloader
+}
+
+func registeredDownloader(name string) (MetricsDownloader, bool) {
+	downloadersMutex.Lock()
+	defer downloadersMutex.Unlock()
+	downloader, ok := downloaders[name]
+	return downloader, ok
+}
+
+// downloaderOf returns the downloader selected by the task, the collector's
+// own when the downloader option is empty
+func (c *PrometheusCollector) downloaderOf(config plugin.Config) (MetricsDownloader, error) {
+	name := getStringConfig(config, "downloader")
+	if name == "" {
+		return c.Downloader, nil
+	}
+	downloader, ok := registeredDownloader(name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown downloader: %s", name)
+	}
+	return downloader, nil
+}
+
+func checkDownloader(config plugin.Config) ([]string, error) {
+	name := getStringConfig(config, "downloader")
+	if name == "" {
+		return nil, nil
+	}
+	if _, ok := registeredDownloader(name); !ok {
+		return nil, fmt.Errorf("Unknown downloader: %s", name)
+	}
+	return nil, nil
+}
@@ -0,0 +1,36 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDownloaderSelection(t *testing.T) {
+	Convey("WithDownloader should replace the default downloader", t, func() {
+		collector := New(WithDownloader(&MockMetricsDownloader{})).(*PrometheusCollector)
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{})
+		So(err, ShouldBeNil)
+		So(families, ShouldContainKey, "go_goroutines")
+	})
+
+	Convey("Tasks should select registered downloaders by name", t, func() {
+		RegisterDownloader("test-down", &downURLsDownloader{down: map[string]bool{"http://a:9100/metrics": true}})
+		defer func() {
+			downloadersMutex.Lock()
+			delete(downloaders, "test-down")
+			downloadersMutex.Unlock()
+		}()
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+
+		_, err := collector.Collect("http://a:9100/metrics", plugin.Config{"downloader": "test-down"})
+		So(err, ShouldNotBeNil)
+		_, err = collector.Collect("http://a:9100/metrics", plugin.Config{})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Unknown downloaders should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"downloader": "missing"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,165 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// downsampleSeries accumulates the increase of a counter series over the
+// samples of the current window
+type downsampleSeries struct {
+	last     counterSample
+	increase float64
+	elapsed  time.Duration
+}
+
+// downsampleTarget is the downsampling state of a target of a task config
+type downsampleTarget struct {
+	series map[string]*downsampleSeries
+	timers []*time.Timer
+}
+
+// downsampler scrapes the targets of tasks with a downsample_window a few
+// times between collections, so collections can emit the average rate of
+// counters over the whole window instead of a point-in-time sample, which
+// aliases bursty traffic at long intervals
+type downsampler struct {
+	mutex   sync.Mutex
+	targets map[string]*downsampleTarget
+}
+
+func newDownsampler() *downsampler {
+	return &downsampler{
+		targets: make(map[string]*downsampleTarget),
+	}
+}
+
+// familySeriesKey identifies a series of a scraped family
+func familySeriesKey(name string, metric *dto.Metric) string {
+	labels := make([]string, 0, len(metric.GetLabel()))
+	for _, label := range metric.GetLabel() {
+		labels = append(labels, label.GetName()+"="+label.GetValue())
+	}
+	sort.Strings(labels)
+
+	var b bytes.Buffer
+	b.WriteString(name)
+	for _, label := range labels {
+		b.WriteString("\xff")
+		b.WriteString(label)
+	}
+	return b.String()
+}
+
+// record adds the counter values of a scrape to the window of a target.
+// It's called with the mutex held.
+func (d *downsampler) record(state *downsampleTarget, metricFamilies map[string]*dto.MetricFamily, sampleTime time.Time) {
+	previous := make(map[string]counterSample, len(state.series))
+	for key, series := range state.series {
+		previous[key] = series.last
+	}
+	counters := make(map[string]counterSample, len(state.series))
+	for name, metricFamily := range metricFamilies {
+		if metricFamily.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		for _, metric := range metricFamily.GetMetric() {
+			key := familySeriesKey(name, metric)
+			increase, elapsed, ok := counterIncrease(previous, counters, key, metric.GetCounter().GetValue(), sampleTime)
+			series, seen := state.series[key]
+			if !seen {
+				state.series[key] = &downsampleSeries{last: counters[key]}
+				continue
+			}
+			if ok {
+				series.increase += increase
+				series.elapsed += elapsed
+				series.last = counters[key]
+			}
+		}
+	}
+}
+
+// apply records the scrape of a collection and replaces the value of every
+// counter with its average rate per second over the window, tagged with
+// downsampled=avg_rate. Counters without a complete window yet are dropped.
+// It then schedules the scrapes of the next window.
+func (d *downsampler) apply(config plugin.Config, url string, metricFamilies map[string]*dto.MetricFamily, currentTime time.Time,
+	scrape func(url string, config plugin.Config) (map[string]*dto.MetricFamily, error)) error {
+	window, err := getDurationConfig(config, "downsample_window")
+	if d == nil || err != nil || window <= 0 {
+		return err
+	}
+	scrapes := getIntConfig(config, "downsample_scrapes")
+
+	key := configFingerprint(config) + "\x00" + url
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	state, ok := d.targets[key]
+	if !ok {
+		state = &downsampleTarget{series: make(map[string]*downsampleSeries)}
+		d.targets[key] = state
+	}
+	for _, timer := range state.timers {
+		timer.Stop()
+	}
+	d.record(state, metricFamilies, currentTime)
+
+	seen := make(map[string]bool, len(state.series))
+	for name, metricFamily := range metricFamilies {
+		if metricFamily.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		rates := metricFamily.Metric[:0]
+		for _, metric := range metricFamily.GetMetric() {
+			seriesKey := familySeriesKey(name, metric)
+			seen[seriesKey] = true
+			series := state.series[seriesKey]
+			if series.elapsed <= 0 {
+				continue
+			}
+			metric.Counter.Value = proto.Float64(series.increase / series.elapsed.Seconds())
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String("downsampled"), Value: proto.String("avg_rate")})
+			series.increase, series.elapsed = 0, 0
+			rates = append(rates, metric)
+		}
+		metricFamily.Metric = rates
+	}
+	for seriesKey := range state.series {
+		if !seen[seriesKey] {
+			delete(state.series, seriesKey)
+		}
+	}
+
+	state.timers = state.timers[:0]
+	for i := int64(1); i < scrapes; i++ {
+		state.timers = append(state.timers, time.AfterFunc(window*time.Duration(i)/time.Duration(scrapes), func() {
+			families, err := scrape(url, config)
+			if err != nil {
+				return
+			}
+			d.mutex.Lock()
+			defer d.mutex.Unlock()
+			d.record(state, families, time.Now())
+		}))
+	}
+	return nil
+}
+
+func checkDownsample(config plugin.Config) ([]string, error) {
+	window, err := getDurationConfig(config, "downsample_window")
+	if err != nil || window <= 0 {
+		return nil, err
+	}
+	if window < 10*time.Second {
+		return nil, fmt.Errorf("downsample_window must be at least 10s")
+	}
+	return nil, nil
+}
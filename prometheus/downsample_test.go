@@ -0,0 +1,77 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func counterFamilies(value float64) map[string]*dto.MetricFamily {
+	return map[string]*dto.MetricFamily{
+		"requests_total": {
+			Name: proto.String("requests_total"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("code"), Value: proto.String("200")}},
+				Counter: &dto.Counter{Value: proto.Float64(value)},
+			}},
+		},
+		"temperature": {
+			Name:   proto.String("temperature"),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(21)}}},
+		},
+	}
+}
+
+func TestDownsampler(t *testing.T) {
+	config := plugin.Config{"downsample_window": "5m", "downsample_scrapes": int64(2)}
+	noScrape := func(url string, config plugin.Config) (map[string]*dto.MetricFamily, error) {
+		return counterFamilies(0), nil
+	}
+	start := time.Now()
+
+	Convey("Counters should be emitted as their average rate over the window", t, func() {
+		d := newDownsampler()
+		first := counterFamilies(100)
+		So(d.apply(config, "http://a/metrics", first, start, noScrape), ShouldBeNil)
+		So(first["requests_total"].GetMetric(), ShouldBeEmpty)
+		So(first["temperature"].GetMetric(), ShouldHaveLength, 1)
+
+		second := counterFamilies(400)
+		So(d.apply(config, "http://a/metrics", second, start.Add(5*time.Minute), noScrape), ShouldBeNil)
+		So(second["requests_total"].GetMetric(), ShouldHaveLength, 1)
+		metric := second["requests_total"].GetMetric()[0]
+		So(metric.GetCounter().GetValue(), ShouldEqual, 1)
+		So(metric.GetLabel(), ShouldHaveLength, 2)
+		So(metric.GetLabel()[1].GetValue(), ShouldEqual, "avg_rate")
+	})
+
+	Convey("Resets within the window should be accounted for", t, func() {
+		d := newDownsampler()
+		So(d.apply(config, "http://a/metrics", counterFamilies(100), start, noScrape), ShouldBeNil)
+		state := d.targets[configFingerprint(config)+"\x00http://a/metrics"]
+		d.mutex.Lock()
+		d.record(state, counterFamilies(160), start.Add(2*time.Minute))
+		d.mutex.Unlock()
+
+		last := counterFamilies(30)
+		So(d.apply(config, "http://a/metrics", last, start.Add(5*time.Minute), noScrape), ShouldBeNil)
+		So(last["requests_total"].GetMetric()[0].GetCounter().GetValue(), ShouldEqual, 0.3)
+	})
+
+	Convey("Families should be left alone without a downsample_window", t, func() {
+		d := newDownsampler()
+		families := counterFamilies(100)
+		So(d.apply(plugin.Config{}, "http://a/metrics", families, start, noScrape), ShouldBeNil)
+		So(families["requests_total"].GetMetric()[0].GetCounter().GetValue(), ShouldEqual, 100)
+	})
+
+	Convey("A too short downsample_window should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"downsample_window": "1s"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,111 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// durationHistogram accumulates the collection durations of a task config
+type durationHistogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// durationTracker keeps a cumulative histogram of collection durations per
+// task config, so an SLO or alert can be put on the collector itself
+type durationTracker struct {
+	mutex      sync.Mutex
+	histograms map[string]*durationHistogram
+}
+
+func newDurationTracker() *durationTracker {
+	return &durationTracker{
+		histograms: make(map[string]*durationHistogram),
+	}
+}
+
+// parseDurationBuckets parses collection_duration_buckets, a comma separated
+// list of upper bounds in seconds
+func parseDurationBuckets(spec string) ([]float64, error) {
+	var bounds []float64
+	for _, item := range splitList(spec) {
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("Invalid collection_duration_buckets bound: %s", item)
+		}
+		bounds = append(bounds, bound)
+	}
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("collection_duration_buckets is empty")
+	}
+	sort.Float64s(bounds)
+	return bounds, nil
+}
+
+// observe adds the duration of a collection and returns the histogram as a
+// collection_duration_seconds series per bucket, tagged with its upper bound
+// le, plus collection_duration_seconds_sum and _count, like a Prometheus
+// histogram
+func (t *durationTracker) observe(config plugin.Config, prefix []string, currentTime time.Time, duration time.Duration) []plugin.Metric {
+	if t == nil || !getBoolConfig(config, "emit_collection_duration") {
+		return nil
+	}
+	bounds, err := parseDurationBuckets(getStringConfig(config, "collection_duration_buckets"))
+	if err != nil {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	histogram, ok := t.histograms[key]
+	if !ok {
+		histogram = &durationHistogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		t.histograms[key] = histogram
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range histogram.bounds {
+		if seconds <= bound {
+			histogram.counts[i]++
+		}
+	}
+	histogram.sum += seconds
+	histogram.count++
+
+	metric := func(name string, tags map[string]string, value float64) plugin.Metric {
+		return plugin.Metric{
+			Namespace:   prefixedNamespace(prefix, name),
+			Timestamp:   currentTime,
+			Description: "end-to-end duration of the collections of the task",
+			Version:     pluginVersion,
+			Tags:        tags,
+			Data:        value,
+		}
+	}
+	metrics := make([]plugin.Metric, 0, len(histogram.bounds)+3)
+	for i, bound := range histogram.bounds {
+		metrics = append(metrics, metric("collection_duration_seconds", map[string]string{"le": formatBound(bound)}, float64(histogram.counts[i])))
+	}
+	metrics = append(metrics, metric("collection_duration_seconds", map[string]string{"le": "+Inf"}, float64(histogram.count)))
+	sum := metric("collection_duration_seconds_sum", nil, histogram.sum)
+	sum.Unit = "s"
+	metrics = append(metrics, sum)
+	metrics = append(metrics, metric("collection_duration_seconds_count", nil, float64(histogram.count)))
+	return metrics
+}
+
+func checkDurationBuckets(config plugin.Config) ([]string, error) {
+	if !getBoolConfig(config, "emit_collection_duration") {
+		return nil, nil
+	}
+	_, err := parseDurationBuckets(getStringConfig(config, "collection_duration_buckets"))
+	return nil, err
+}
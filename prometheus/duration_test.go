@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDurationTracker(t *testing.T) {
+	config := plugin.Config{
+		"emit_collection_duration":    true,
+		"collection_duration_buckets": "1,0.5",
+	}
+	prefix := []string{"hyperpilot", "prometheus", "job"}
+
+	Convey("Collection durations should be accumulated into buckets", t, func() {
+		tracker := newDurationTracker()
+		tracker.observe(config, prefix, time.Now(), 300*time.Millisecond)
+		metrics := tracker.observe(config, prefix, time.Now(), 800*time.Millisecond)
+
+		values := make(map[string]float64)
+		for _, metric := range metrics {
+			values[metricName(metric)+"/"+metric.Tags["le"]] = metric.Data.(float64)
+		}
+		So(values["collection_duration_seconds/0.5"], ShouldEqual, 1)
+		So(values["collection_duration_seconds/1"], ShouldEqual, 2)
+		So(values["collection_duration_seconds/+Inf"], ShouldEqual, 2)
+		So(values["collection_duration_seconds_count/"], ShouldEqual, 2)
+		So(values["collection_duration_seconds_sum/"], ShouldAlmostEqual, 1.1)
+	})
+
+	Convey("Nothing should be emitted unless enabled", t, func() {
+		So(newDurationTracker().observe(plugin.Config{}, prefix, time.Now(), time.Second), ShouldBeEmpty)
+	})
+
+	Convey("Invalid buckets should fail validation", t, func() {
+		_, err := checkDurationBuckets(plugin.Config{"emit_collection_duration": true, "collection_duration_buckets": "1,fast"})
+		So(err, ShouldNotBeNil)
+		_, err = checkDurationBuckets(config)
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,107 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// fileSDGroup is a target group of the Prometheus file_sd format
+type fileSDGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// fileSDGroups converts targets to file_sd groups, one per target, keeping
+// the scheme and path of their URLs in the __scheme__ and __metrics_path__
+// labels Prometheus relabels with
+func fileSDGroups(targets []target) []fileSDGroup {
+	groups := make([]fileSDGroup, 0, len(targets))
+	for _, t := range targets {
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			continue
+		}
+		labels := make(map[string]string, len(t.Tags)+2)
+		for key, value := range t.Tags {
+			labels[key] = value
+		}
+		labels["__scheme__"] = u.Scheme
+		labels["__metrics_path__"] = u.Path
+		groups = append(groups, fileSDGroup{Targets: []string{u.Host}, Labels: labels})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Targets[0] < groups[j].Targets[0]
+	})
+	return groups
+}
+
+// targetExporter writes the active targets of tasks to their
+// targets_file_export path, rewriting a file only when its targets change
+type targetExporter struct {
+	mutex   sync.Mutex
+	written map[string][]byte
+}
+
+func newTargetExporter() *targetExporter {
+	return &targetExporter{
+		written: make(map[string][]byte),
+	}
+}
+
+// export writes targets in file_sd JSON format. The file is replaced
+// atomically so readers never see a partial target list.
+func (e *targetExporter) export(config plugin.Config, targets []target) {
+	path := getStringConfig(config, "targets_file_export")
+	if e == nil || path == "" {
+		return
+	}
+
+	content, err := json.MarshalIndent(fileSDGroups(targets), "", "  ")
+	if err != nil {
+		glog.Warningf("Unable to encode targets for export: %s", err.Error())
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if bytes.Equal(e.written[path], content) {
+		return
+	}
+	if err := writeFileAtomic(path, content); err != nil {
+		glog.Warningf("Unable to export targets to %s: %s", path, err.Error())
+		return
+	}
+	e.written[path] = content
+}
+
+// writeFileAtomic writes content to a temp file next to path and renames it
+// over path
+func writeFileAtomic(path string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
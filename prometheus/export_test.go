@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTargetExporter(t *testing.T) {
+	Convey("Active targets should be exported in file_sd format", t, func() {
+		dir, err := ioutil.TempDir("", "export-test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "targets.json")
+		config := plugin.Config{"targets_file_export": path}
+
+		exporter := newTargetExporter()
+		exporter.export(config, []target{
+			{URL: "https://10.0.0.2:9100/metrics", Tags: map[string]string{"rack": "a1"}},
+			{URL: "http://10.0.0.1:8080/custom/metrics"},
+		})
+
+		content, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		var groups []fileSDGroup
+		So(json.Unmarshal(content, &groups), ShouldBeNil)
+		So(len(groups), ShouldEqual, 2)
+		So(groups[0].Targets, ShouldResemble, []string{"10.0.0.1:8080"})
+		So(groups[0].Labels["__metrics_path__"], ShouldEqual, "/custom/metrics")
+		So(groups[1].Labels["__scheme__"], ShouldEqual, "https")
+		So(groups[1].Labels["rack"], ShouldEqual, "a1")
+
+		Convey("and only rewritten when the targets change", func() {
+			So(os.Remove(path), ShouldBeNil)
+			exporter.export(config, []target{
+				{URL: "http://10.0.0.1:8080/custom/metrics"},
+				{URL: "https://10.0.0.2:9100/metrics", Tags: map[string]string{"rack": "a1"}},
+			})
+			_, err := os.Stat(path)
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			exporter.export(config, []target{{URL: "http://10.0.0.1:8080/custom/metrics"}})
+			_, err = os.Stat(path)
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// exporterTag is the tag naming the exporter a target was detected as
+const exporterTag = "exporter"
+
+// wellKnownExporter is an exporter recognized by the families only it
+// exposes, with the relabel configs its metrics get unless the task turns
+// exporter_profile off
+type wellKnownExporter struct {
+	families []string
+	profile  []relabelConfig
+}
+
+// wellKnownExporters are the exporters exporter=auto detects. Their
+// profiles run before the task's relabel_configs, which can override them.
+var wellKnownExporters = map[string]wellKnownExporter{
+	// node_exporter: pseudo filesystems only add noise to the filesystem
+	// families
+	"node_exporter": {
+		families: []string{"node_exporter_build_info", "node_uname_info", "node_cpu_seconds_total"},
+		profile: mustCompileRelabelConfigs(`[
+			{"source_labels": ["__name__", "fstype"], "regex": "node_filesystem_.*;(tmpfs|overlay|squashfs|nsfs|autofs)", "action": "drop"}
+		]`),
+	},
+	// kube-state-metrics: object labels are tagged like the kubernetes
+	// discovery tags them
+	"kube-state-metrics": {
+		families: []string{"kube_pod_info", "kube_node_info", "kube_deployment_created"},
+		profile: mustCompileRelabelConfigs(`[
+			{"regex": "label_(.+)", "replacement": "kube_label_$1", "action": "labelmap"}
+		]`),
+	},
+	// cadvisor: the pause container and pod level aggregates duplicate the
+	// containers' series
+	"cadvisor": {
+		families: []string{"cadvisor_version_info", "container_last_seen"},
+		profile: mustCompileRelabelConfigs(`[
+			{"source_labels": ["__name__", "container"], "regex": "container_.*;(|POD)", "action": "drop"}
+		]`),
+	},
+	// envoy: envoy_cluster_name is the upstream cluster a series is about
+	"envoy": {
+		families: []string{"envoy_server_live", "envoy_server_uptime"},
+		profile: mustCompileRelabelConfigs(`[
+			{"source_labels": ["envoy_cluster_name"], "regex": "(.+)", "target_label": "upstream_cluster"}
+		]`),
+	},
+}
+
+func mustCompileRelabelConfigs(spec string) []relabelConfig {
+	configs, err := compileRelabelConfigs(spec)
+	if err != nil {
+		panic(err)
+	}
+	return configs
+}
+
+// detectExporter returns the exporter a target serving metricFamilies is,
+// "" when it isn't a well known one. An exporter tag of the target, e.g. a
+// static target's, overrides detection, as does an exporter other than auto.
+func detectExporter(config plugin.Config, t target, metricFamilies map[string]*dto.MetricFamily) string {
+	exporter := getStringConfig(config, "exporter")
+	if exporter == "" {
+		return ""
+	}
+	if tagged := t.Tags[exporterTag]; tagged != "" {
+		return tagged
+	}
+	if exporter != "auto" {
+		return exporter
+	}
+	// Names are tried in order so a scrape matching several exporters, e.g.
+	// a federation endpoint, always gets the same one
+	names := make([]string, 0, len(wellKnownExporters))
+	for name := range wellKnownExporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, family := range wellKnownExporters[name].families {
+			if _, ok := metricFamilies[family]; ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// applyExporterProfile tags the metrics of a target with the exporter it was
+// detected as and runs that exporter's profile on them
+func applyExporterProfile(config plugin.Config, t target, metricFamilies map[string]*dto.MetricFamily, metrics []plugin.Metric) []plugin.Metric {
+	exporter := detectExporter(config, t, metricFamilies)
+	if exporter == "" {
+		return metrics
+	}
+	for i := range metrics {
+		relabelSet(&metrics[i], exporterTag, exporter)
+	}
+	if !getBoolConfig(config, "exporter_profile") {
+		return metrics
+	}
+	return relabel(wellKnownExporters[exporter].profile, metrics)
+}
+
+func checkExporter(config plugin.Config) ([]string, error) {
+	exporter := getStringConfig(config, "exporter")
+	if exporter == "" || exporter == "auto" {
+		return nil, nil
+	}
+	if _, ok := wellKnownExporters[exporter]; !ok {
+		return nil, fmt.Errorf("exporter must be auto or one of node_exporter, kube-state-metrics, cadvisor and envoy")
+	}
+	return nil, nil
+}
@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const cadvisorPage = `# TYPE cadvisor_version_info gauge
+cadvisor_version_info{cadvisorVersion="v0.47.0"} 1
+# TYPE container_memory_usage_bytes gauge
+container_memory_usage_bytes{container="api",pod="api-1"} 1024
+container_memory_usage_bytes{container="POD",pod="api-1"} 12
+container_memory_usage_bytes{container="",pod="api-1"} 1036
+# TYPE machine_cpu_cores gauge
+machine_cpu_cores 8
+`
+
+func TestExporterProfiles(t *testing.T) {
+	families, err := parseMetrics(strings.NewReader(cadvisorPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &PrometheusCollector{}
+	node := target{URL: "http://node-a:8080/metrics"}
+	convert := func() []plugin.Metric {
+		return collector.convertFamilies(time.Now(), []string{"hyperpilot", "prometheus"}, node, families, 1)
+	}
+
+	Convey("Exporters should only be detected when asked for", t, func() {
+		So(detectExporter(plugin.Config{}, node, families), ShouldEqual, "")
+		So(detectExporter(plugin.Config{"exporter": "auto"}, node, families), ShouldEqual, "cadvisor")
+		So(detectExporter(plugin.Config{"exporter": "envoy"}, node, families), ShouldEqual, "envoy")
+		tagged := node
+		tagged.Tags = map[string]string{"exporter": "node_exporter"}
+		So(detectExporter(plugin.Config{"exporter": "auto"}, tagged, families), ShouldEqual, "node_exporter")
+		So(detectExporter(plugin.Config{"exporter": "auto"}, node, nil), ShouldEqual, "")
+	})
+
+	Convey("Detected exporters should be tagged and get their profile", t, func() {
+		metrics := applyExporterProfile(plugin.Config{"exporter": "auto", "exporter_profile": true}, node, families, convert())
+		So(metrics, ShouldHaveLength, 3)
+		for _, metric := range metrics {
+			So(metric.Tags["exporter"], ShouldEqual, "cadvisor")
+			So(metric.Tags["container"], ShouldNotEqual, "POD")
+		}
+
+		metrics = applyExporterProfile(plugin.Config{"exporter": "auto", "exporter_profile": false}, node, families, convert())
+		So(metrics, ShouldHaveLength, 5)
+	})
+
+	Convey("Unknown exporters should be rejected", t, func() {
+		_, err := checkExporter(plugin.Config{"exporter": "mysqld_exporter"})
+		So(err, ShouldNotBeNil)
+		_, err = checkExporter(plugin.Config{"exporter": "auto"})
+		So(err, ShouldBeNil)
+	})
+}
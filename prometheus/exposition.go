@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// protobufAccept asks targets for the delimited protobuf exposition format,
+// falling back to the text format
+const protobufAccept = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3`
+
+// isProtobuf tells a delimited protobuf body from a text one by its first
+// bytes: the varint length of the first family followed by the tag of its
+// name field. A text body starts with a comment, a metric name or
+// whitespace, none of which reads that way. Sniffing rather than trusting
+// the Content-Type keeps recorded and replayed scrapes, and downloaders
+// without headers, working.
+func isProtobuf(r *bufio.Reader) bool {
+	head, _ := r.Peek(binary.MaxVarintLen64 + 1)
+	if len(head) < 2 {
+		return false
+	}
+	switch head[0] {
+	case ' ', '\t', '\r', '\n':
+		return false
+	}
+	length, n := binary.Uvarint(head)
+	return n > 0 && length > 0 && n < len(head) && head[n] == 0x0a
+}
+
+// parseProtobufMetrics parses a delimited protobuf body
+func parseProtobufMetrics(r io.Reader) (map[string]*dto.MetricFamily, error) {
+	metricFamilies := make(map[string]*dto.MetricFamily)
+	decoder := expfmt.NewDecoder(r, expfmt.FmtProtoDelim)
+	for {
+		metricFamily := &dto.MetricFamily{}
+		if err := decoder.Decode(metricFamily); err != nil {
+			if err == io.EOF {
+				return metricFamilies, nil
+			}
+			return metricFamilies, err
+		}
+		if existing, ok := metricFamilies[metricFamily.GetName()]; ok {
+			existing.Metric = append(existing.Metric, metricFamily.Metric...)
+			continue
+		}
+		metricFamilies[metricFamily.GetName()] = metricFamily
+	}
+}
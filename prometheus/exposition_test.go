@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	"github.com/prometheus/common/expfmt"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// protobufDownloader serves TEST_DATA in the delimited protobuf format
+type protobufDownloader struct {
+	body []byte
+}
+
+func (d *protobufDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "", nil
+}
+
+func (d *protobufDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	return bytes.NewReader(d.body), nil
+}
+
+func TestProtobufExposition(t *testing.T) {
+	textFamilies, err := parseMetrics(strings.NewReader(TEST_DATA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	encoder := expfmt.NewEncoder(&body, expfmt.FmtProtoDelim)
+	for _, metricFamily := range textFamilies {
+		if err := encoder.Encode(metricFamily); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Convey("Protobuf bodies should be parsed like their text equivalent", t, func() {
+		families, err := parseMetrics(bytes.NewReader(body.Bytes()))
+		So(err, ShouldBeNil)
+		So(len(families), ShouldEqual, len(textFamilies))
+		So(families["api_booking_service_request_count"].GetMetric(), ShouldHaveLength, 5)
+	})
+
+	Convey("Protobuf bodies should be collected, line limits not applying", t, func() {
+		collector := &PrometheusCollector{Downloader: &protobufDownloader{body: body.Bytes()}}
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{"max_line_length": int64(10)})
+		So(err, ShouldBeNil)
+		So(len(families), ShouldEqual, len(textFamilies))
+	})
+
+	Convey("Text bodies should not be mistaken for protobuf", t, func() {
+		for _, text := range []string{TEST_DATA, HISTOGRAM_DATA, "up 1\n", "\n\nup 1\n", "# TYPE up gauge\nup 1\n", ""} {
+			So(isProtobuf(bufio.NewReader(strings.NewReader(text))), ShouldBeFalse)
+		}
+		So(isProtobuf(bufio.NewReader(bytes.NewReader(body.Bytes()))), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// getExtraTags returns the extra_tags of a task, e.g.
+// {"cluster": "prod", "region": "us-east"}
+func getExtraTags(config plugin.Config) (map[string]string, error) {
+	spec := getStringConfig(config, "extra_tags")
+	if spec == "" {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	if err := json.Unmarshal([]byte(spec), &tags); err != nil {
+		return nil, fmt.Errorf("extra_tags must be a JSON object of tag name to value: %s", err.Error())
+	}
+	for name := range tags {
+		if name == "" {
+			return nil, fmt.Errorf("extra_tags must not have an empty tag name")
+		}
+	}
+	return tags, nil
+}
+
+// addExtraTags tags every metric with the task's extra_tags, so downstream
+// systems can tell deployments apart. Tags a metric already has win, like
+// target tags win over host metadata tags.
+func addExtraTags(config plugin.Config, metrics []plugin.Metric) {
+	tags, err := getExtraTags(config)
+	if err != nil || len(tags) == 0 {
+		return
+	}
+	for i := range metrics {
+		if metrics[i].Tags == nil {
+			metrics[i].Tags = make(map[string]string, len(tags))
+		}
+		for name, value := range tags {
+			if _, ok := metrics[i].Tags[name]; !ok {
+				metrics[i].Tags[name] = value
+			}
+		}
+	}
+}
+
+func checkExtraTags(config plugin.Config) ([]string, error) {
+	_, err := getExtraTags(config)
+	return nil, err
+}
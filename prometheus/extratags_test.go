@@ -0,0 +1,25 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAddExtraTags(t *testing.T) {
+	Convey("extra_tags should be merged into the tags of every metric", t, func() {
+		config := plugin.Config{"extra_tags": `{"cluster": "prod", "region": "us-east"}`}
+		So(validateConfig(config), ShouldBeNil)
+
+		metrics := []plugin.Metric{{Tags: map[string]string{"region": "eu-west", "job": "api"}}, {}}
+		addExtraTags(config, metrics)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"cluster": "prod", "region": "eu-west", "job": "api"})
+		So(metrics[1].Tags, ShouldResemble, map[string]string{"cluster": "prod", "region": "us-east"})
+	})
+
+	Convey("Invalid extra_tags should be refused", t, func() {
+		So(validateConfig(plugin.Config{"extra_tags": `["prod"]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"extra_tags": `{"": "prod"}`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// getFallbackTargets returns the fallback_endpoints of a task, scraped only
+// while every primary target fails, e.g. the standby of a leader exporter.
+// They are enriched and paused like the primary targets.
+func (c *PrometheusCollector) getFallbackTargets(config plugin.Config) ([]target, error) {
+	if getStringConfig(config, "fallback_endpoints") == "" {
+		return nil, nil
+	}
+	fallback, err := parseEndpointList(config, "fallback_endpoints")
+	if err != nil {
+		return nil, err
+	}
+	if fallback, err = c.enrichTargets(config, fallback); err != nil {
+		return nil, err
+	}
+	return tagScrapeSource(c.paused.activeTargets(fallback), "secondary"), nil
+}
+
+// tagScrapeSource returns copies of targets tagged with the scrape_source
+// providing their data
+func tagScrapeSource(targets []target, source string) []target {
+	tagged := make([]target, 0, len(targets))
+	for _, t := range targets {
+		tags := make(map[string]string, len(t.Tags)+1)
+		for key, value := range t.Tags {
+			tags[key] = value
+		}
+		tags["scrape_source"] = source
+		tagged = append(tagged, target{URL: t.URL, Tags: tags})
+	}
+	return tagged
+}
+
+func checkFallback(config plugin.Config) ([]string, error) {
+	if getStringConfig(config, "fallback_endpoints") == "" {
+		return nil, nil
+	}
+	_, err := parseEndpointList(config, "fallback_endpoints")
+	return nil, err
+}
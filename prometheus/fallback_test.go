@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// downURLsDownloader serves TEST_DATA except for the URLs marked down
+type downURLsDownloader struct {
+	down map[string]bool
+}
+
+func (d *downURLsDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "", nil
+}
+
+func (d *downURLsDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	if d.down[url] {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return strings.NewReader(TEST_DATA), nil
+}
+
+func TestFallbackEndpoints(t *testing.T) {
+	config := plugin.Config{
+		"endpoints":          "http://leader:9100",
+		"fallback_endpoints": "http://standby:9100",
+	}
+	sources := func(metrics []plugin.Metric) map[string]bool {
+		seen := make(map[string]bool)
+		for _, metric := range metrics {
+			seen[metric.Tags["scrape_source"]] = true
+		}
+		return seen
+	}
+
+	Convey("Fallback endpoints should only be scraped while the primary fails", t, func() {
+		downloader := &downURLsDownloader{down: map[string]bool{}}
+		collector := &PrometheusCollector{Downloader: downloader}
+		mts := []plugin.Metric{{Config: config}}
+
+		metrics, err := collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		So(sources(metrics), ShouldResemble, map[string]bool{"primary": true})
+
+		downloader.down["http://leader:9100/metrics"] = true
+		metrics, err = collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		So(sources(metrics), ShouldResemble, map[string]bool{"secondary": true})
+	})
+
+	Convey("Invalid fallback endpoints should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"fallback_endpoints": "standby:9100"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,107 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// faultKinds are the faults a FaultInjectingDownloader injects
+var faultKinds = map[string]bool{
+	"delay":    true,
+	"error":    true,
+	"truncate": true,
+	"corrupt":  true,
+}
+
+// FaultInjectingDownloader wraps a downloader, injecting faults into
+// fault_rate of the scrapes of tasks enabling it, to exercise error handling
+// in staging
+type FaultInjectingDownloader struct {
+	Downloader MetricsDownloader
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// NewFaultInjectingDownloader returns a downloader injecting faults into the
+// scrapes of downloader
+func NewFaultInjectingDownloader(downloader MetricsDownloader) *FaultInjectingDownloader {
+	return &FaultInjectingDownloader{
+		Downloader: downloader,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (d *FaultInjectingDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return d.Downloader.GetEndpoint(config)
+}
+
+func (d *FaultInjectingDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	fault := d.pickFault(config)
+	if fault == "" {
+		return d.Downloader.GetMetricsReader(url, config)
+	}
+	glog.Infof("Injecting %s fault into scrape of %s", fault, url)
+
+	switch fault {
+	case "error":
+		return nil, fmt.Errorf("Injected fault")
+	case "delay":
+		delay, err := getDurationConfig(config, "fault_delay")
+		if err != nil {
+			return nil, err
+		}
+		time.Sleep(delay)
+		return d.Downloader.GetMetricsReader(url, config)
+	}
+
+	reader, err := d.Downloader.GetMetricsReader(url, config)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(reader)
+	if closer, ok := reader.(io.Closer); ok {
+		closer.Close()
+	}
+	if err != nil || len(body) == 0 {
+		return bytes.NewReader(body), err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if fault == "truncate" {
+		body = body[:d.random.Intn(len(body))]
+	} else {
+		for i := 0; i < 1+len(body)/1000; i++ {
+			body[d.random.Intn(len(body))] = byte(d.random.Intn(256))
+		}
+	}
+	return bytes.NewReader(body), nil
+}
+
+// pickFault returns the fault injected into a scrape, none when empty
+func (d *FaultInjectingDownloader) pickFault(config plugin.Config) string {
+	rate := getFloatConfig(config, "fault_rate")
+	if rate <= 0 {
+		return ""
+	}
+	kinds := splitList(getStringConfig(config, "fault_kinds"))
+	if len(kinds) == 0 {
+		return ""
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.random.Float64() >= rate {
+		return ""
+	}
+	return kinds[d.random.Intn(len(kinds))]
+}
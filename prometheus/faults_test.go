@@ -0,0 +1,89 @@
+package prometheus
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const faultTestBody = "# TYPE requests_total counter\nrequests_total{code=\"200\"} 3\nrequests_total{code=\"500\"} 1\n"
+
+type faultTestDownloader struct{}
+
+func (faultTestDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	return strings.NewReader(faultTestBody), nil
+}
+
+func (faultTestDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "http://localhost:9100/metrics", nil
+}
+
+func TestFaultInjection(t *testing.T) {
+	Convey("Faults should be injected into fault_rate of the scrapes", t, func() {
+		d := NewFaultInjectingDownloader(faultTestDownloader{})
+		d.random = rand.New(rand.NewSource(1))
+
+		read := func(config plugin.Config) (string, error) {
+			reader, err := d.GetMetricsReader("http://localhost:9100/metrics", config)
+			if err != nil {
+				return "", err
+			}
+			body, err := ioutil.ReadAll(reader)
+			return string(body), err
+		}
+
+		Convey("never by default", func() {
+			for i := 0; i < 100; i++ {
+				body, err := read(plugin.Config{})
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, faultTestBody)
+			}
+		})
+
+		Convey("as errors", func() {
+			_, err := read(plugin.Config{"fault_rate": 1.0, "fault_kinds": "error"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("as truncated bodies", func() {
+			body, err := read(plugin.Config{"fault_rate": 1.0, "fault_kinds": "truncate"})
+			So(err, ShouldBeNil)
+			So(len(body), ShouldBeLessThan, len(faultTestBody))
+			So(faultTestBody, ShouldStartWith, body)
+		})
+
+		Convey("as corrupted bodies", func() {
+			body, err := read(plugin.Config{"fault_rate": 1.0, "fault_kinds": "corrupt"})
+			So(err, ShouldBeNil)
+			So(body, ShouldHaveLength, len(faultTestBody))
+			So(body, ShouldNotEqual, faultTestBody)
+		})
+
+		Convey("as delays", func() {
+			body, err := read(plugin.Config{"fault_rate": 1.0, "fault_kinds": "delay", "fault_delay": "1ms"})
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, faultTestBody)
+		})
+
+		Convey("at roughly the configured rate", func() {
+			failed := 0
+			for i := 0; i < 1000; i++ {
+				if _, err := read(plugin.Config{"fault_rate": 0.1, "fault_kinds": "error"}); err != nil {
+					failed++
+				}
+			}
+			So(failed, ShouldBeBetween, 50, 150)
+		})
+	})
+
+	Convey("Unknown fault kinds should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"fault_rate": 0.5, "fault_kinds": "error,explode"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"fault_rate": 0.5}), ShouldBeNil)
+	})
+}
@@ -0,0 +1,120 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// compileMetricFilter compiles an include_metrics or exclude_metrics regex,
+// anchored like every name regex of the task config. An empty regex
+// compiles to nil, filtering nothing.
+func compileMetricFilter(key, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	regex, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", key, err.Error())
+	}
+	return regex, nil
+}
+
+// labelMatchers match a series when the value of every label, "" when the
+// series doesn't have it, matches the label's anchored regex
+type labelMatchers map[string]*regexp.Regexp
+
+// compileLabelMatchers compiles keep_label_matchers or drop_label_matchers, a
+// JSON object of label name to regex such as {"namespace": "kube-system"}.
+// An empty spec compiles to nil, matching nothing.
+func compileLabelMatchers(key, spec string) (labelMatchers, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var exprs map[string]string
+	if err := json.Unmarshal([]byte(spec), &exprs); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object of label name to regex: %s", key, err.Error())
+	}
+	matchers := make(labelMatchers, len(exprs))
+	for label, expr := range exprs {
+		regex, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s of label %s: %s", key, label, err.Error())
+		}
+		matchers[label] = regex
+	}
+	return matchers, nil
+}
+
+func (m labelMatchers) matches(metric *dto.Metric) bool {
+	for name, regex := range m {
+		value := ""
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == name {
+				value = label.GetValue()
+				break
+			}
+		}
+		if !regex.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterFamilies drops the scraped families not matching include_metrics or
+// matching exclude_metrics, and the series not matching keep_label_matchers
+// or matching drop_label_matchers, before they are converted. It returns the
+// samples dropped by each of the filters the task sets.
+func (r *conversionRules) filterFamilies(metricFamilies map[string]*dto.MetricFamily) map[string]int64 {
+	if r.includeMetrics == nil && r.excludeMetrics == nil && r.keepSeries == nil && r.dropSeries == nil {
+		return nil
+	}
+	dropped := make(map[string]int64, 4)
+	for rule, set := range map[string]bool{
+		"include_metrics":     r.includeMetrics != nil,
+		"exclude_metrics":     r.excludeMetrics != nil,
+		"keep_label_matchers": r.keepSeries != nil,
+		"drop_label_matchers": r.dropSeries != nil,
+	} {
+		if set {
+			dropped[rule] = 0
+		}
+	}
+
+	for name, metricFamily := range metricFamilies {
+		if r.includeMetrics != nil && !r.includeMetrics.MatchString(name) {
+			dropped["include_metrics"] += int64(len(metricFamily.GetMetric()))
+			delete(metricFamilies, name)
+			continue
+		}
+		if r.excludeMetrics != nil && r.excludeMetrics.MatchString(name) {
+			dropped["exclude_metrics"] += int64(len(metricFamily.GetMetric()))
+			delete(metricFamilies, name)
+			continue
+		}
+		if r.keepSeries == nil && r.dropSeries == nil {
+			continue
+		}
+		kept := metricFamily.Metric[:0]
+		for _, metric := range metricFamily.GetMetric() {
+			if r.keepSeries != nil && !r.keepSeries.matches(metric) {
+				dropped["keep_label_matchers"]++
+				continue
+			}
+			if r.dropSeries != nil && r.dropSeries.matches(metric) {
+				dropped["drop_label_matchers"]++
+				continue
+			}
+			kept = append(kept, metric)
+		}
+		if len(kept) == 0 {
+			delete(metricFamilies, name)
+			continue
+		}
+		metricFamily.Metric = kept
+	}
+	return dropped
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricFilters(t *testing.T) {
+	collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+
+	Convey("include_metrics should keep only matching families", t, func() {
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{"include_metrics": "go_memstats_.*"})
+		So(err, ShouldBeNil)
+		So(families, ShouldContainKey, "go_memstats_alloc_bytes")
+		So(families, ShouldNotContainKey, "go_goroutines")
+		So(families, ShouldNotContainKey, "api_booking_service_request_count")
+	})
+
+	Convey("exclude_metrics should drop matching families after include_metrics", t, func() {
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{
+			"include_metrics": "go_.*",
+			"exclude_metrics": "go_memstats_.*_total|go_gc_.*",
+		})
+		So(err, ShouldBeNil)
+		So(families, ShouldContainKey, "go_memstats_alloc_bytes")
+		So(families, ShouldContainKey, "go_goroutines")
+		So(families, ShouldNotContainKey, "go_memstats_frees_total")
+		So(families, ShouldNotContainKey, "go_gc_duration_seconds")
+	})
+
+	Convey("keep_label_matchers should keep only the series matching every matcher", t, func() {
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{"keep_label_matchers": `{"method": "book|list_.*"}`})
+		So(err, ShouldBeNil)
+		So(families["api_booking_service_request_count"].GetMetric(), ShouldHaveLength, 3)
+		So(families["api_booking_service_request_latency_microseconds"].GetMetric(), ShouldHaveLength, 3)
+		So(families, ShouldNotContainKey, "go_goroutines")
+	})
+
+	Convey("drop_label_matchers should drop the series matching every matcher", t, func() {
+		families, err := collector.Collect("http://localhost:8080/metrics", plugin.Config{"drop_label_matchers": `{"method": "book"}`})
+		So(err, ShouldBeNil)
+		So(families["api_booking_service_request_count"].GetMetric(), ShouldHaveLength, 4)
+		So(families, ShouldContainKey, "go_goroutines")
+	})
+
+	Convey("Invalid filters should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"exclude_metrics": "go_(.*"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"keep_label_matchers": `["container"]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"drop_label_matchers": `{"namespace": "kube-(.*"}`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,92 @@
+package prometheus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// filteredTracker counts the samples the filters of every task config
+// dropped, by filter rule, and reports the totals every
+// filtered_summary_interval, so filters don't drop series invisibly
+type filteredTracker struct {
+	mutex   sync.Mutex
+	counts  map[string]map[string]int64
+	emitted map[string]time.Time
+}
+
+func newFilteredTracker() *filteredTracker {
+	return &filteredTracker{
+		counts:  make(map[string]map[string]int64),
+		emitted: make(map[string]time.Time),
+	}
+}
+
+// record adds the samples a scrape's filters dropped, by rule
+func (t *filteredTracker) record(config plugin.Config, dropped map[string]int64) {
+	if t == nil || len(dropped) == 0 {
+		return
+	}
+	if interval, err := getDurationConfig(config, "filtered_summary_interval"); err != nil || interval <= 0 {
+		return
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	counts, ok := t.counts[key]
+	if !ok {
+		counts = make(map[string]int64, len(dropped))
+		t.counts[key] = counts
+	}
+	for rule, samples := range dropped {
+		counts[rule] += samples
+	}
+}
+
+// summary returns a filtered_samples_total metric per filter rule, the
+// samples it dropped since the task started, once every
+// filtered_summary_interval
+func (t *filteredTracker) summary(config plugin.Config, prefix []string, currentTime time.Time) []plugin.Metric {
+	if t == nil {
+		return nil
+	}
+	interval, err := getDurationConfig(config, "filtered_summary_interval")
+	if err != nil || interval <= 0 {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	emitted, ok := t.emitted[key]
+	if !ok {
+		t.emitted[key] = currentTime
+		return nil
+	}
+	if currentTime.Sub(emitted) < interval {
+		return nil
+	}
+	t.emitted[key] = currentTime
+
+	rules := make([]string, 0, len(t.counts[key]))
+	for rule := range t.counts[key] {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	namespace := prefixedNamespace(prefix, "filtered_samples_total")
+	metrics := make([]plugin.Metric, 0, len(rules))
+	for _, rule := range rules {
+		metrics = append(metrics, plugin.Metric{
+			Namespace:   namespace,
+			Timestamp:   currentTime,
+			Description: "samples dropped by a filter rule since the task started",
+			Version:     pluginVersion,
+			Tags:        map[string]string{"rule": rule},
+			Data:        t.counts[key][rule],
+		})
+	}
+	return metrics
+}
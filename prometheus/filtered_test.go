@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilteredSummary(t *testing.T) {
+	Convey("Filtered samples should be summed by rule and reported every interval", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}, filtered: newFilteredTracker()}
+		config := plugin.Config{
+			"filtered_summary_interval": "1m",
+			"exclude_metrics":           "go_.*",
+			"drop_label_matchers":       `{"method": "book"}`,
+		}
+		So(validateConfig(config), ShouldBeNil)
+		prefix := []string{"intel", "prometheus"}
+		start := time.Now()
+
+		So(collector.filtered.summary(config, prefix, start), ShouldBeEmpty)
+		_, err := collector.Collect("http://localhost:8080/metrics", config)
+		So(err, ShouldBeNil)
+		_, err = collector.Collect("http://localhost:8080/metrics", config)
+		So(err, ShouldBeNil)
+		So(collector.filtered.summary(config, prefix, start.Add(30*time.Second)), ShouldBeEmpty)
+
+		metrics := collector.filtered.summary(config, prefix, start.Add(time.Minute))
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "prometheus", "filtered_samples_total"})
+		So(metrics[0].Tags["rule"], ShouldEqual, "drop_label_matchers")
+		So(metrics[1].Tags["rule"], ShouldEqual, "exclude_metrics")
+		So(metrics[0].Data.(int64), ShouldBeGreaterThan, 0)
+		So(metrics[1].Data.(int64)%2, ShouldEqual, 0)
+		So(metrics[1].Data.(int64), ShouldBeGreaterThan, 0)
+
+		So(collector.filtered.summary(config, prefix, start.Add(90*time.Second)), ShouldBeEmpty)
+	})
+
+	Convey("Filtered samples should not be counted without an interval", t, func() {
+		tracker := newFilteredTracker()
+		config := plugin.Config{"exclude_metrics": "go_.*"}
+		tracker.record(config, map[string]int64{"exclude_metrics": 3})
+		So(tracker.counts, ShouldBeEmpty)
+		So(tracker.summary(config, nil, time.Now()), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// targetHealthMetrics returns the up and scrape_duration_seconds metrics of
+// a scraped target, tagged like its series, when the task asks for them with
+// scrape_health_metrics
+func targetHealthMetrics(config plugin.Config, prefix []string, currentTime time.Time, t target, result scrapeResult) []plugin.Metric {
+	if !getBoolConfig(config, "scrape_health_metrics") {
+		return nil
+	}
+	up := 1.0
+	if result.err != nil {
+		up = 0.0
+	}
+	return []plugin.Metric{
+		upMetric(prefix, currentTime, t, up),
+		{
+			Namespace:   prefixedNamespace(prefix, "scrape_duration_seconds"),
+			Timestamp:   currentTime,
+			Description: "time the scrape of the target took, failed scrapes included",
+			Version:     pluginVersion,
+			Unit:        "s",
+			Tags:        copyTags(t.Tags),
+			Data:        result.duration.Seconds(),
+		},
+	}
+}
+
+// upMetric returns the up metric of a target, 1 if it was scraped
+// successfully, 0 otherwise
+func upMetric(prefix []string, currentTime time.Time, t target, up float64) plugin.Metric {
+	return plugin.Metric{
+		Namespace:   prefixedNamespace(prefix, "up"),
+		Timestamp:   currentTime,
+		Description: "1 if the target was scraped successfully, 0 otherwise",
+		Version:     pluginVersion,
+		Tags:        copyTags(t.Tags),
+		Data:        up,
+	}
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
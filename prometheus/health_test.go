@@ -0,0 +1,38 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTargetHealthMetrics(t *testing.T) {
+	prefix := []string{"hyperpilot", "prometheus"}
+	node := target{URL: "http://node-a:9100/metrics", Tags: map[string]string{"instance": "node-a:9100"}}
+	config := plugin.Config{"scrape_health_metrics": true}
+
+	Convey("Scraped targets should get up and scrape_duration_seconds", t, func() {
+		metrics := targetHealthMetrics(config, prefix, time.Now(), node, scrapeResult{duration: 250 * time.Millisecond})
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "up"})
+		So(metrics[0].Data, ShouldEqual, 1)
+		So(metrics[0].Tags["instance"], ShouldEqual, "node-a:9100")
+		So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "scrape_duration_seconds"})
+		So(metrics[1].Data, ShouldEqual, 0.25)
+
+		metrics[0].Tags["instance"] = "changed"
+		So(node.Tags["instance"], ShouldEqual, "node-a:9100")
+	})
+
+	Convey("Failed targets should be down", t, func() {
+		metrics := targetHealthMetrics(config, prefix, time.Now(), node, scrapeResult{err: errors.New("connection refused")})
+		So(metrics[0].Data, ShouldEqual, 0)
+	})
+
+	Convey("Health metrics should only be emitted when asked for", t, func() {
+		So(targetHealthMetrics(plugin.Config{}, prefix, time.Now(), node, scrapeResult{}), ShouldBeEmpty)
+	})
+}
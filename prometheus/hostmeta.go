@@ -0,0 +1,177 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// hostTagNames are the tags stamped for each supported host_tags entry
+var hostTagNames = map[string]string{
+	"hostname":    "collector_host",
+	"node_name":   "collector_node",
+	"instance_id": "collector_instance_id",
+}
+
+// instanceIDSource is a cloud metadata service endpoint returning the id of
+// the instance the collector runs on
+type instanceIDSource struct {
+	url     string
+	headers map[string]string
+	// tokenURL is the IMDSv2 endpoint handing out the session token the
+	// query is sent with, the query going without a token when it fails
+	tokenURL string
+}
+
+// instanceIDSources are tried in order until one answers
+var instanceIDSources = []instanceIDSource{
+	{url: "http://169.254.169.254/latest/meta-data/instance-id", tokenURL: "http://169.254.169.254/latest/api/token"},
+	{url: gceMetadataURL + "/instance/id", headers: map[string]string{"Metadata-Flavor": "Google"}},
+	{url: "http://169.254.169.254/metadata/instance/compute/vmId?api-version=2017-08-01&format=text", headers: map[string]string{"Metadata": "true"}},
+}
+
+// metadataClient queries cloud metadata services, which answer quickly when
+// present
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// Backoff between attempts to get the instance id after a failure, which
+// may be a metadata service that isn't reachable yet
+const (
+	instanceIDRetryBackoff    = 30 * time.Second
+	instanceIDMaxRetryBackoff = 30 * time.Minute
+)
+
+// hostMetadata resolves the metadata of the collector host once, since it
+// doesn't change while the collector runs. The cloud metadata services are
+// only queried when instance_id is asked for, so tasks tagging the hostname
+// don't wait on them off cloud, and are queried again with backoff until
+// one answers.
+type hostMetadata struct {
+	hostnameOnce sync.Once
+	hostname     string
+
+	instanceIDMutex sync.Mutex
+	instanceID      string
+	nextAttempt     time.Time
+	backoff         time.Duration
+}
+
+func (m *hostMetadata) resolveHostname() string {
+	m.hostnameOnce.Do(func() {
+		hostname, err := os.Hostname()
+		if err != nil {
+			glog.Warningf("Unable to get hostname: %s", err.Error())
+		}
+		m.hostname = hostname
+	})
+	return m.hostname
+}
+
+func (m *hostMetadata) resolveInstanceID() string {
+	m.instanceIDMutex.Lock()
+	defer m.instanceIDMutex.Unlock()
+	if m.instanceID != "" || time.Now().Before(m.nextAttempt) {
+		return m.instanceID
+	}
+	for _, source := range instanceIDSources {
+		id, err := getInstanceID(source)
+		if err == nil {
+			m.instanceID = id
+			return id
+		}
+	}
+	if m.backoff = 2 * m.backoff; m.backoff == 0 {
+		m.backoff = instanceIDRetryBackoff
+	} else if m.backoff > instanceIDMaxRetryBackoff {
+		m.backoff = instanceIDMaxRetryBackoff
+	}
+	m.nextAttempt = time.Now().Add(m.backoff)
+	glog.Warningf("Unable to get instance id from any cloud metadata service, trying again in %s", m.backoff)
+	return ""
+}
+
+func getInstanceID(source instanceIDSource) (string, error) {
+	req, err := http.NewRequest("GET", source.url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range source.headers {
+		req.Header.Set(key, value)
+	}
+	if source.tokenURL != "" {
+		if token, err := getMetadataToken(source.tokenURL); err == nil {
+			req.Header.Set("X-aws-ec2-metadata-token", token)
+		} else {
+			glog.V(2).Infof("Querying %s without a session token: %s", source.url, err.Error())
+		}
+	}
+	return readMetadata(req)
+}
+
+// getMetadataToken asks an IMDSv2 metadata service for a session token
+func getMetadataToken(tokenURL string) (string, error) {
+	req, err := http.NewRequest("PUT", tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	return readMetadata(req)
+}
+
+// readMetadata returns the trimmed body of a metadata service answer
+func readMetadata(req *http.Request) (string, error) {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected status %d from %s", resp.StatusCode, req.URL)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", fmt.Errorf("Empty answer from %s", req.URL)
+	}
+	return value, nil
+}
+
+// hostTags returns the host metadata tags a task asks for with host_tags.
+// Metadata that can't be resolved is left out.
+func (c *PrometheusCollector) hostTags(config plugin.Config) map[string]string {
+	kinds := splitList(getStringConfig(config, "host_tags"))
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	metadata := c.hostMetadata
+	if metadata == nil {
+		metadata = &hostMetadata{}
+	}
+	tags := make(map[string]string, len(kinds))
+	for _, kind := range kinds {
+		var value string
+		switch kind {
+		case "hostname":
+			value = metadata.resolveHostname()
+		case "node_name":
+			value = os.Getenv(getStringConfig(config, "node_name_env"))
+		case "instance_id":
+			value = metadata.resolveInstanceID()
+		}
+		if value != "" {
+			tags[hostTagNames[kind]] = value
+		}
+	}
+	return tags
+}
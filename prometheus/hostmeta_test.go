@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHostTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "4520031799277581759\n")
+	}))
+	defer server.Close()
+
+	sources := instanceIDSources
+	defer func() { instanceIDSources = sources }()
+	instanceIDSources = []instanceIDSource{
+		{url: server.URL + "/latest/meta-data/instance-id"},
+		{url: server.URL + "/instance/id", headers: map[string]string{"Metadata-Flavor": "Google"}},
+	}
+
+	Convey("Targets should be tagged with host metadata", t, func() {
+		os.Setenv("TEST_NODE_NAME", "node-7")
+		defer os.Unsetenv("TEST_NODE_NAME")
+		hostname, _ := os.Hostname()
+
+		c := &PrometheusCollector{hostMetadata: &hostMetadata{}}
+		targets, err := c.enrichTargets(plugin.Config{
+			"host_tags":     "hostname, node_name, instance_id",
+			"node_name_env": "TEST_NODE_NAME",
+		}, []target{
+			{URL: "http://10.0.0.1:9100/metrics", Tags: map[string]string{"instance": "10.0.0.1:9100"}},
+		})
+		So(err, ShouldBeNil)
+		So(targets[0].Tags, ShouldResemble, map[string]string{
+			"instance":              "10.0.0.1:9100",
+			"collector_host":        hostname,
+			"collector_node":        "node-7",
+			"collector_instance_id": "4520031799277581759",
+		})
+	})
+
+	Convey("Hostnames should be tagged without querying metadata services", t, func() {
+		queried := false
+		metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queried = true
+		}))
+		defer metadataServer.Close()
+		instanceIDSources = []instanceIDSource{{url: metadataServer.URL + "/instance/id"}}
+
+		c := &PrometheusCollector{hostMetadata: &hostMetadata{}}
+		So(c.hostTags(plugin.Config{"host_tags": "hostname"}), ShouldContainKey, "collector_host")
+		So(queried, ShouldBeFalse)
+	})
+
+	Convey("Instance ids should be queried with an IMDSv2 session token", t, func() {
+		metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "PUT" && r.URL.Path == "/latest/api/token" && r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") != "":
+				fmt.Fprint(w, "session-token")
+			case r.Method == "GET" && r.Header.Get("X-aws-ec2-metadata-token") == "session-token":
+				fmt.Fprint(w, "i-0123456789abcdef0\n")
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer metadataServer.Close()
+		instanceIDSources = []instanceIDSource{{url: metadataServer.URL + "/latest/meta-data/instance-id", tokenURL: metadataServer.URL + "/latest/api/token"}}
+
+		metadata := &hostMetadata{}
+		So(metadata.resolveInstanceID(), ShouldEqual, "i-0123456789abcdef0")
+	})
+
+	Convey("Instance ids should be queried again with backoff after a failure", t, func() {
+		queries := 0
+		available := false
+		metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queries++
+			if !available {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "vm-1")
+		}))
+		defer metadataServer.Close()
+		instanceIDSources = []instanceIDSource{{url: metadataServer.URL + "/instance/id"}}
+
+		metadata := &hostMetadata{}
+		So(metadata.resolveInstanceID(), ShouldBeEmpty)
+		So(metadata.backoff, ShouldEqual, instanceIDRetryBackoff)
+		available = true
+		So(metadata.resolveInstanceID(), ShouldBeEmpty)
+		So(queries, ShouldEqual, 1)
+
+		metadata.nextAttempt = time.Now()
+		So(metadata.resolveInstanceID(), ShouldEqual, "vm-1")
+		So(queries, ShouldEqual, 2)
+		So(metadata.resolveInstanceID(), ShouldEqual, "vm-1")
+		So(queries, ShouldEqual, 2)
+	})
+
+	Convey("Unresolved host metadata should be left out", t, func() {
+		c := &PrometheusCollector{}
+		So(c.hostTags(plugin.Config{"host_tags": "node_name", "node_name_env": "TEST_UNSET_NODE_NAME"}), ShouldBeEmpty)
+	})
+
+	Convey("Unknown host tags should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"host_tags": "hostname,rack"}), ShouldNotBeNil)
+	})
+}
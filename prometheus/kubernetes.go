@@ -0,0 +1,93 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// kubeAPIServer returns the API server URL, configured or in-cluster
+func kubeAPIServer(config plugin.Config) (string, error) {
+	if server := getStringConfig(config, "kube_apiserver"); server != "" {
+		return strings.TrimRight(server, "/"), nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return "", fmt.Errorf("kube_apiserver is not set and the plugin is not running in a Kubernetes cluster")
+	}
+	return "https://" + net.JoinHostPort(host, port), nil
+}
+
+// kubeCAFile returns the CA bundle verifying the API server; the service
+// account's when kube_ca_file isn't set
+func kubeCAFile(config plugin.Config) string {
+	if caFile := getStringConfig(config, "kube_ca_file"); caFile != "" {
+		return caFile
+	}
+	if _, err := os.Stat(serviceAccountCAFile); err == nil {
+		return serviceAccountCAFile
+	}
+	return ""
+}
+
+// kubeToken reads the bearer token authenticating to the API server. It is
+// read on every scrape as projected service account tokens rotate.
+func kubeToken(config plugin.Config) (string, error) {
+	tokenFile := getStringConfig(config, "kube_token_file")
+	if tokenFile == "" {
+		tokenFile = serviceAccountTokenFile
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("Unable to read Kubernetes token: " + err.Error())
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// isKubeProxy reports whether the task scrapes through the API server proxy
+func isKubeProxy(config plugin.Config) bool {
+	return getStringConfig(config, "kube_pod") != "" || getStringConfig(config, "kube_service") != ""
+}
+
+// kubeProxyEndpoint builds the API server proxy URL of the configured pod or
+// service, for clusters where pod IPs aren't routable from the Snap node:
+// /api/v1/namespaces/<ns>/pods/<pod>:<port>/proxy/metrics
+func kubeProxyEndpoint(config plugin.Config) (string, error) {
+	server, err := kubeAPIServer(config)
+	if err != nil {
+		return "", err
+	}
+
+	kind, name := "pods", getStringConfig(config, "kube_pod")
+	if service := getStringConfig(config, "kube_service"); service != "" {
+		if name != "" {
+			return "", fmt.Errorf("Only one of kube_pod and kube_service may be set")
+		}
+		kind, name = "services", service
+	}
+	if port := getStringConfig(config, "kube_port"); port != "" {
+		name += ":" + port
+	}
+
+	path := getStringConfig(config, "kube_metrics_path")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s/api/v1/namespaces/%s/%s/%s/proxy%s",
+		server,
+		url.PathEscape(getStringConfig(config, "kube_namespace")),
+		kind,
+		url.PathEscape(name),
+		path), nil
+}
@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKubeProxy(t *testing.T) {
+	Convey("Proxy endpoints should be built from the pod or service", t, func() {
+		downloader := NewHTTPMetricsDownloader()
+		endpoint, err := downloader.GetEndpoint(plugin.Config{
+			"kube_apiserver": "https://10.0.0.1:443/",
+			"kube_namespace": "istio-system",
+			"kube_pod":       "istio-pilot-1",
+			"kube_port":      "9093",
+		})
+		So(err, ShouldBeNil)
+		So(endpoint, ShouldEqual, "https://10.0.0.1:443/api/v1/namespaces/istio-system/pods/istio-pilot-1:9093/proxy/metrics")
+
+		endpoint, err = downloader.GetEndpoint(plugin.Config{
+			"kube_apiserver": "https://10.0.0.1:443",
+			"kube_service":   "kube-state-metrics",
+		})
+		So(err, ShouldBeNil)
+		So(endpoint, ShouldEqual, "https://10.0.0.1:443/api/v1/namespaces/default/services/kube-state-metrics/proxy/metrics")
+
+		_, err = downloader.GetEndpoint(plugin.Config{
+			"kube_apiserver": "https://10.0.0.1:443",
+			"kube_pod":       "a",
+			"kube_service":   "b",
+		})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Proxy scrapes should authenticate with the service account token", t, func() {
+		dir, err := ioutil.TempDir("", "kube-test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		tokenFile := filepath.Join(dir, "token")
+		So(ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600), ShouldBeNil)
+
+		var authorization, path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			path = r.URL.Path
+			w.Write([]byte(TEST_DATA))
+		}))
+		defer server.Close()
+
+		config := plugin.Config{
+			"kube_apiserver":  server.URL,
+			"kube_pod":        "app-0",
+			"kube_token_file": tokenFile,
+		}
+		collector := New().(*PrometheusCollector)
+		endpoint, err := collector.Downloader.GetEndpoint(config)
+		So(err, ShouldBeNil)
+		families, err := collector.Collect(endpoint, config)
+		So(err, ShouldBeNil)
+		So(len(families), ShouldBeGreaterThan, 0)
+		So(authorization, ShouldEqual, "Bearer secret")
+		So(path, ShouldEqual, "/api/v1/namespaces/default/pods/app-0/proxy/metrics")
+	})
+}
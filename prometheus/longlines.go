@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// lineLimitReader removes the exposition lines longer than max bytes, such
+// as samples with huge label values from tracing-integrated exporters,
+// before they reach the parser. A line is never buffered past max bytes.
+type lineLimitReader struct {
+	reader *bufio.Reader
+	max    int
+	// truncate cuts HELP lines to max bytes instead of skipping them;
+	// samples and other lines can't be cut without breaking their syntax
+	// and are always skipped
+	truncate  bool
+	pending   []byte
+	err       error
+	skipped   int
+	truncated int
+}
+
+func newLineLimitReader(reader io.Reader, max int64, mode string) *lineLimitReader {
+	return &lineLimitReader{
+		reader:   bufio.NewReader(reader),
+		max:      int(max),
+		truncate: mode == "truncate",
+	}
+}
+
+func (r *lineLimitReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.pending, r.err = r.nextLine()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// nextLine returns the next line, with its newline, or nothing when the
+// line was too long and skipped
+func (r *lineLimitReader) nextLine() ([]byte, error) {
+	var line []byte
+	length := 0
+	for {
+		fragment, err := r.reader.ReadSlice('\n')
+		length += len(fragment)
+		if room := r.max + 1 - len(line); room > 0 {
+			if len(fragment) < room {
+				room = len(fragment)
+			}
+			line = append(line, fragment[:room]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		content := length
+		if err == nil {
+			// the newline isn't counted
+			content--
+		}
+		if content <= r.max {
+			return line, err
+		}
+		if r.truncate && bytes.HasPrefix(line, []byte("# HELP ")) {
+			r.truncated++
+			return append(line[:r.max], '\n'), err
+		}
+		r.skipped++
+		return nil, err
+	}
+}
+
+// problems reports how many lines were removed, nil when none were
+func (r *lineLimitReader) problems() error {
+	if r.skipped == 0 && r.truncated == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d lines skipped and %d truncated for exceeding max_line_length %d", r.skipped, r.truncated, r.max)
+}
+
+func checkMaxLineLength(config plugin.Config) ([]string, error) {
+	switch mode := getStringConfig(config, "long_lines"); mode {
+	case "skip", "truncate":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown long_lines: %s", mode)
+	}
+}
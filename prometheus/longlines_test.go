@@ -0,0 +1,50 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLineLimitReader(t *testing.T) {
+	longValue := strings.Repeat("x", 10000)
+	page := "# HELP spans_total Spans " + longValue + "\n" +
+		"# TYPE spans_total counter\n" +
+		"spans_total{trace=\"" + longValue + "\"} 1\n" +
+		"spans_total{trace=\"short\"} 2\n" +
+		"up 1\n"
+
+	Convey("Long lines should be skipped and counted", t, func() {
+		reader := newLineLimitReader(strings.NewReader(page), 100, "skip")
+		body, err := ioutil.ReadAll(reader)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "# TYPE spans_total counter\nspans_total{trace=\"short\"} 2\nup 1\n")
+		So(reader.skipped, ShouldEqual, 2)
+		So(reader.problems(), ShouldNotBeNil)
+	})
+
+	Convey("Long HELP lines should be truncated when asked", t, func() {
+		reader := newLineLimitReader(strings.NewReader(page), 100, "truncate")
+		families, err := parseMetrics(reader)
+		So(err, ShouldBeNil)
+		So(families["spans_total"].GetHelp(), ShouldHaveLength, 100-len("# HELP spans_total "))
+		So(families["spans_total"].GetMetric(), ShouldHaveLength, 1)
+		So(reader.truncated, ShouldEqual, 1)
+		So(reader.skipped, ShouldEqual, 1)
+	})
+
+	Convey("Pages without long lines should be left alone", t, func() {
+		reader := newLineLimitReader(strings.NewReader(TEST_DATA), 1000, "skip")
+		body, err := ioutil.ReadAll(reader)
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, TEST_DATA)
+		So(reader.problems(), ShouldBeNil)
+	})
+
+	Convey("Unknown long_lines modes should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"long_lines": "wrap"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,90 @@
+package prometheus
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Limits of tasks in low_memory mode, sized for a collector scraping a
+// couple of small exporters within a 64MB cgroup
+const (
+	// lowMemorySpillThreshold streams larger bodies to a temp file, so the
+	// parser reads them from disk instead of a copy held in memory
+	lowMemorySpillThreshold = 256 << 10
+	lowMemoryMaxBodySize    = 4 << 20
+	lowMemoryMaxSamples     = 10000
+	// lowMemoryGCPercent makes the garbage collector run twice as often as
+	// by default, trading CPU for a lower heap peak
+	lowMemoryGCPercent = 50
+)
+
+var lowMemoryGC sync.Once
+
+func isLowMemory(config plugin.Config) bool {
+	return getBoolConfig(config, "low_memory")
+}
+
+// enableLowMemoryGC tunes the garbage collector of the plugin process the
+// first time a low_memory task collects
+func enableLowMemoryGC() {
+	lowMemoryGC.Do(func() {
+		previous := debug.SetGCPercent(lowMemoryGCPercent)
+		glog.Infof("low_memory task: GC target lowered from %d%% to %d%%", previous, lowMemoryGCPercent)
+	})
+}
+
+// lowMemorySpillThresholdOf returns the spill threshold of a low_memory
+// task, spill_threshold unless it keeps larger bodies in memory
+func lowMemorySpillThresholdOf(config plugin.Config) int64 {
+	threshold := getIntConfig(config, "spill_threshold")
+	if threshold <= 0 || threshold > lowMemorySpillThreshold {
+		return lowMemorySpillThreshold
+	}
+	return threshold
+}
+
+// checkLowMemoryLimits rejects scrapes with more samples than a low_memory
+// task handles
+func checkLowMemoryLimits(metricFamilies map[string]*dto.MetricFamily) error {
+	samples := 0
+	for _, metricFamily := range metricFamilies {
+		samples += len(metricFamily.GetMetric())
+	}
+	if samples > lowMemoryMaxSamples {
+		return fmt.Errorf("Scrape exceeds %d samples", lowMemoryMaxSamples)
+	}
+	return nil
+}
+
+// dropDescriptions clears metric descriptions, which low_memory tasks don't
+// hand to Snap
+func dropDescriptions(metrics []plugin.Metric) {
+	for i := range metrics {
+		metrics[i].Description = ""
+	}
+}
+
+// checkLowMemory warns about options keeping per-series state, which
+// defeat low_memory
+func checkLowMemory(config plugin.Config) ([]string, error) {
+	if !isLowMemory(config) {
+		return nil, nil
+	}
+	var warnings []string
+	for _, key := range []string{"emit_tombstones", "emit_new_families"} {
+		if getBoolConfig(config, key) {
+			warnings = append(warnings, fmt.Sprintf("%s keeps per-series state in low_memory mode", key))
+		}
+	}
+	for _, key := range []string{"catchup_url", "slos", "recording_rules"} {
+		if getStringConfig(config, key) != "" {
+			warnings = append(warnings, fmt.Sprintf("%s keeps per-series state in low_memory mode", key))
+		}
+	}
+	return warnings, nil
+}
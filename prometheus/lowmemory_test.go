@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLowMemory(t *testing.T) {
+	Convey("low_memory should spill all but small bodies", t, func() {
+		So(lowMemorySpillThresholdOf(plugin.Config{}), ShouldEqual, lowMemorySpillThreshold)
+		So(lowMemorySpillThresholdOf(plugin.Config{"spill_threshold": int64(1 << 30)}), ShouldEqual, lowMemorySpillThreshold)
+		So(lowMemorySpillThresholdOf(plugin.Config{"spill_threshold": int64(1024)}), ShouldEqual, 1024)
+	})
+
+	Convey("low_memory should reject scrapes with too many samples", t, func() {
+		families, err := parseMetrics(strings.NewReader(TEST_DATA))
+		So(err, ShouldBeNil)
+		So(checkLowMemoryLimits(families), ShouldBeNil)
+
+		var payload bytes.Buffer
+		So(WriteSynthetic(&payload, 20, lowMemoryMaxSamples/20+1, 0), ShouldBeNil)
+		families, err = parseMetrics(strings.NewReader(payload.String()))
+		So(err, ShouldBeNil)
+		So(checkLowMemoryLimits(families), ShouldNotBeNil)
+	})
+
+	Convey("low_memory collections should not carry descriptions", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+		metricTypes, err := collector.GetMetricTypes(plugin.Config{})
+		So(err, ShouldBeNil)
+		metricTypes[0].Config = plugin.Config{"low_memory": true}
+		metrics, err := collector.CollectMetrics(metricTypes)
+		So(err, ShouldBeNil)
+		So(len(metrics), ShouldBeGreaterThan, 0)
+		for _, metric := range metrics {
+			So(metric.Description, ShouldBeEmpty)
+		}
+	})
+
+	Convey("low_memory should warn about per-series state", t, func() {
+		warnings, err := checkLowMemory(plugin.Config{"low_memory": true, "emit_tombstones": true})
+		So(err, ShouldBeNil)
+		So(len(warnings), ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,191 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// snapForbiddenChars are the characters snapteld rejects in namespace
+// elements: brackets, spaces, punctuation, slashes, carets and quotes
+const snapForbiddenChars = "()[]{} .,;?!|\\/^\"`'"
+
+// sanitizeNamespaceElement replaces the characters Snap rejects in a
+// namespace element with underscores
+func sanitizeNamespaceElement(element string) string {
+	if !strings.ContainsAny(element, snapForbiddenChars) {
+		return element
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(snapForbiddenChars, r) {
+			return '_'
+		}
+		return r
+	}, element)
+}
+
+// checkNamespaceElement returns an error naming the first character of
+// element Snap would reject
+func checkNamespaceElement(element string) error {
+	if element == "" {
+		return fmt.Errorf("namespace element must not be empty")
+	}
+	if i := strings.IndexAny(element, snapForbiddenChars); i >= 0 {
+		return fmt.Errorf("%q must not contain %q", element, element[i])
+	}
+	return nil
+}
+
+// prefixedNamespace returns the namespace of elements under prefix, leaving
+// the backing array of prefix alone
+func prefixedNamespace(prefix []string, elements ...string) plugin.Namespace {
+	return plugin.NewNamespace(append(append([]string{}, prefix...), elements...)...)
+}
+
+// maxReportedNames bounds the names the sanitizer remembers warning about,
+// so a target exposing ever changing names can't grow it forever
+const maxReportedNames = 100000
+
+// namespaceSanitizer sanitizes metric names used as namespace elements,
+// warning once about every name it had to change
+type namespaceSanitizer struct {
+	mutex    sync.Mutex
+	reported map[string]bool
+}
+
+func newNamespaceSanitizer() *namespaceSanitizer {
+	return &namespaceSanitizer{
+		reported: make(map[string]bool),
+	}
+}
+
+func (s *namespaceSanitizer) sanitize(name string) string {
+	sanitized := sanitizeNamespaceElement(name)
+	if sanitized == name || s == nil {
+		return sanitized
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.reported[name] && len(s.reported) < maxReportedNames {
+		s.reported[name] = true
+		glog.Warningf("Metric family %q contains characters Snap rejects in namespaces, emitting it as %q", name, sanitized)
+	}
+	return sanitized
+}
+
+// appendLabelsToNamespace appends a dynamic namespace element per label of
+// labels_in_namespace to every metric, named after the label and valued
+// with its sanitized tag value, or "_" when the metric doesn't have it. The
+// tags are kept. It runs after every rule, as they look the metric name up
+// as the last namespace element.
+func appendLabelsToNamespace(config plugin.Config, metrics []plugin.Metric) {
+	labels := splitList(getStringConfig(config, "labels_in_namespace"))
+	if len(labels) == 0 {
+		return
+	}
+	for i := range metrics {
+		namespace := make(plugin.Namespace, len(metrics[i].Namespace), len(metrics[i].Namespace)+len(labels))
+		copy(namespace, metrics[i].Namespace)
+		for _, label := range labels {
+			value := sanitizeNamespaceElement(metrics[i].Tags[label])
+			if value == "" {
+				value = "_"
+			}
+			namespace = append(namespace, plugin.NamespaceElement{
+				Name:        label,
+				Description: "value of the " + label + " label",
+				Value:       value,
+			})
+		}
+		metrics[i].Namespace = namespace
+	}
+}
+
+func checkLabelsInNamespace(config plugin.Config) ([]string, error) {
+	for _, label := range splitList(getStringConfig(config, "labels_in_namespace")) {
+		if err := checkNamespaceElement(label); err != nil {
+			return nil, fmt.Errorf("Invalid labels_in_namespace entry: %s", err.Error())
+		}
+	}
+	return nil, nil
+}
+
+// namespaceRoute moves the metrics whose label matches an anchored regex
+// under a sub-prefix, e.g. team=payments under /hyperpilot/prometheus/payments,
+// so Snap ACLs can scope access by subtree. The prefix may have several
+// "/" separated elements and $1 style references to the regex groups.
+type namespaceRoute struct {
+	Label  string `json:"label"`
+	Value  string `json:"value"`
+	Prefix string `json:"prefix"`
+	regex  *regexp.Regexp
+}
+
+func compileNamespaceRoutes(spec string) ([]namespaceRoute, error) {
+	var routes []namespaceRoute
+	if err := json.Unmarshal([]byte(spec), &routes); err != nil {
+		return nil, fmt.Errorf("namespace_routes must be a JSON list of routes: %s", err.Error())
+	}
+	for i := range routes {
+		if routes[i].Label == "" || routes[i].Prefix == "" {
+			return nil, fmt.Errorf("Invalid namespace route %d: label and prefix must be set", i)
+		}
+		for _, element := range strings.Split(routes[i].Prefix, "/") {
+			if strings.Contains(element, "$") {
+				continue
+			}
+			if err := checkNamespaceElement(element); err != nil {
+				return nil, fmt.Errorf("Invalid namespace route %d: %s", i, err.Error())
+			}
+		}
+		regex, err := regexp.Compile("^(?:" + routes[i].Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid namespace route %d: %s", i, err.Error())
+		}
+		routes[i].regex = regex
+	}
+	return routes, nil
+}
+
+// routeNamespaces inserts the sub-prefix of the first namespace route
+// matching each metric right after the first prefixLength elements of its
+// namespace. Metrics no route matches keep their namespace. Like
+// appendLabelsToNamespace it runs after every rule.
+func (r *conversionRules) routeNamespaces(prefixLength int, metrics []plugin.Metric) {
+	if r == nil || len(r.namespaceRoutes) == 0 {
+		return
+	}
+	for i := range metrics {
+		if len(metrics[i].Namespace) <= prefixLength {
+			continue
+		}
+		for _, route := range r.namespaceRoutes {
+			value, ok := metrics[i].Tags[route.Label]
+			if !ok {
+				continue
+			}
+			match := route.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			elements := strings.Split(string(route.regex.ExpandString(nil, route.Prefix, value, match)), "/")
+			namespace := make(plugin.Namespace, 0, len(metrics[i].Namespace)+len(elements))
+			namespace = append(namespace, metrics[i].Namespace[:prefixLength]...)
+			for _, element := range elements {
+				element = sanitizeNamespaceElement(element)
+				if element == "" {
+					element = "_"
+				}
+				namespace = append(namespace, plugin.NamespaceElement{Value: element})
+			}
+			metrics[i].Namespace = append(namespace, metrics[i].Namespace[prefixLength:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNamespaceSanitizer(t *testing.T) {
+	Convey("Namespace elements should be sanitized for Snap", t, func() {
+		So(sanitizeNamespaceElement("http_requests_total"), ShouldEqual, "http_requests_total")
+		So(sanitizeNamespaceElement("job:http_requests:rate5m"), ShouldEqual, "job:http_requests:rate5m")
+		So(sanitizeNamespaceElement("http.requests total/{api}"), ShouldEqual, "http_requests_total__api_")
+	})
+
+	Convey("Converted families should get sanitized namespaces", t, func() {
+		c := &PrometheusCollector{sanitizer: newNamespaceSanitizer()}
+		families := map[string]*dto.MetricFamily{
+			"http.requests": {
+				Name: proto.String("http.requests"),
+				Type: dto.MetricType_COUNTER.Enum(),
+				Metric: []*dto.Metric{
+					{Counter: &dto.Counter{Value: proto.Float64(1)}},
+				},
+			},
+		}
+		for i := 0; i < 2; i++ {
+			metrics := c.convertFamilies(time.Now(), []string{"hyperpilot", "prometheus"}, target{}, families, 1)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "http_requests"})
+		}
+		So(c.sanitizer.reported, ShouldHaveLength, 1)
+	})
+
+	Convey("Configured namespace elements should be rejected when Snap would", t, func() {
+		So(validateConfig(plugin.Config{"job": "node exporter"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"recording_rules": `[{"record": "http.rate", "metric": "up", "aggregate": "sum"}]`}), ShouldNotBeNil)
+	})
+}
+
+func TestLabelsInNamespace(t *testing.T) {
+	Convey("Selected labels should be appended as dynamic namespace elements", t, func() {
+		metrics := []plugin.Metric{
+			{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"), Tags: map[string]string{"code": "200", "path": "/api/v1"}},
+			{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "up")},
+		}
+		appendLabelsToNamespace(plugin.Config{"labels_in_namespace": "code,path"}, metrics)
+
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "http_requests_total", "200", "_api_v1"})
+		So(metrics[0].Namespace[3].Name, ShouldEqual, "code")
+		So(metrics[0].Namespace[4].IsDynamic(), ShouldBeTrue)
+		So(metrics[0].Tags["code"], ShouldEqual, "200")
+		So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "up", "_", "_"})
+	})
+
+	Convey("Labels Snap rejects as namespace element names should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"labels_in_namespace": "code,a/b"}), ShouldNotBeNil)
+	})
+}
+
+func TestNamespaceRoutes(t *testing.T) {
+	Convey("Metrics should be moved under the sub-prefix of their first matching route", t, func() {
+		rules, err := compileConversionRules(plugin.Config{"namespace_routes": `[
+			{"label": "team", "value": "payments|billing", "prefix": "$0"},
+			{"label": "team", "value": "(.+)", "prefix": "teams/$1"}
+		]`})
+		So(err, ShouldBeNil)
+		metrics := []plugin.Metric{
+			{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"), Tags: map[string]string{"team": "payments"}},
+			{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"), Tags: map[string]string{"team": "search ops"}},
+			{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "up")},
+		}
+		rules.routeNamespaces(2, metrics)
+
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "payments", "http_requests_total"})
+		So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "teams", "search_ops", "http_requests_total"})
+		So(metrics[2].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "up"})
+	})
+
+	Convey("Invalid namespace routes should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"namespace_routes": `[{"label": "team", "prefix": "pay ments"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"namespace_routes": `[{"label": "team"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"namespace_routes": `[{"label": "team", "value": "(", "prefix": "x"}]`}), ShouldNotBeNil)
+	})
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	Convey("Metrics should be rooted at namespace_prefix", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+		config := plugin.Config{"namespace_prefix": "/acme/monitoring/", "job": "istio"}
+		mts, err := collector.GetMetricTypes(config)
+		So(err, ShouldBeNil)
+		So(mts[0].Namespace.Strings(), ShouldResemble, []string{"acme", "monitoring"})
+
+		mts[0].Config = config
+		metrics, err := collector.CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		So(len(metrics), ShouldBeGreaterThan, 0)
+		for _, metric := range metrics {
+			So(metric.Namespace.Strings()[:3], ShouldResemble, []string{"acme", "monitoring", "istio"})
+		}
+	})
+
+	Convey("Invalid namespace prefixes should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"namespace_prefix": "acme/node exporter"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"namespace_prefix": "acme//monitoring"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// nanPolicy is how a task handles NaN and infinite values, which break some
+// publishers:
+//
+// drop: metrics with such values are dropped, whatever their type
+//
+// zero: such values are replaced by 0
+//
+// pass: such values are published as is, NaN quantiles included
+//
+// Without a policy NaN quantiles are dropped and every other value passes.
+type nanPolicy string
+
+func getNaNPolicy(config plugin.Config) (nanPolicy, error) {
+	policy := nanPolicy(getStringConfig(config, "nan_policy"))
+	switch policy {
+	case "", "drop", "zero", "pass":
+		return policy, nil
+	}
+	return "", fmt.Errorf("nan_policy must be one of drop, zero and pass")
+}
+
+// keepsNaNQuantiles tells whether the converter keeps NaN quantiles for the
+// policy to handle, rather than skipping them
+func (p nanPolicy) keepsNaNQuantiles() bool {
+	return p == "zero" || p == "pass"
+}
+
+// apply drops or zeroes the NaN and infinite values of metrics, following
+// the policy
+func (p nanPolicy) apply(metrics []plugin.Metric) []plugin.Metric {
+	if p != "drop" && p != "zero" {
+		return metrics
+	}
+	kept := metrics[:0]
+	handled := 0
+	for _, metric := range metrics {
+		if value, ok := metric.Data.(float64); ok && (math.IsNaN(value) || math.IsInf(value, 0)) {
+			handled++
+			if p == "drop" {
+				continue
+			}
+			metric.Data = 0.0
+		}
+		kept = append(kept, metric)
+	}
+	if handled > 0 {
+		glog.V(2).Infof("nan_policy %s: %d NaN or infinite values", p, handled)
+	}
+	return kept
+}
+
+func checkNaNPolicy(config plugin.Config) ([]string, error) {
+	_, err := getNaNPolicy(config)
+	return nil, err
+}
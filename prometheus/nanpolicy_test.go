@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const nanPage = `# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} NaN
+rpc_duration_seconds_sum 0
+rpc_duration_seconds_count 0
+# TYPE queue_ratio gauge
+queue_ratio +Inf
+# TYPE jobs_total counter
+jobs_total 3
+`
+
+func TestNaNPolicy(t *testing.T) {
+	convert := func(policy string, schema int64) []plugin.Metric {
+		families, err := parseMetrics(strings.NewReader(nanPage))
+		So(err, ShouldBeNil)
+		nans, err := getNaNPolicy(plugin.Config{"nan_policy": policy})
+		So(err, ShouldBeNil)
+		collector := &PrometheusCollector{nans: nans}
+		return nans.apply(collector.convertFamilies(time.Now(), []string{"hyperpilot", "prometheus"}, target{}, families, schema))
+	}
+
+	Convey("Without a policy NaN quantiles should be dropped and other values pass", t, func() {
+		metrics := convert("", 1)
+		So(len(metrics), ShouldEqual, 4)
+		So(math.IsInf(findMetric(metrics, "queue_ratio").Data.(float64), 1), ShouldBeTrue)
+	})
+
+	Convey("The drop policy should drop every non-finite value", t, func() {
+		So(len(convert("drop", 1)), ShouldEqual, 3)
+		So(len(convert("drop", 2)), ShouldEqual, 3)
+	})
+
+	Convey("The zero policy should zero every non-finite value", t, func() {
+		for _, schema := range []int64{1, 2} {
+			metrics := convert("zero", schema)
+			So(len(metrics), ShouldEqual, 5)
+			for _, metric := range metrics {
+				So(math.IsNaN(metric.Data.(float64)) || math.IsInf(metric.Data.(float64), 0), ShouldBeFalse)
+			}
+		}
+	})
+
+	Convey("The pass policy should keep NaN quantiles", t, func() {
+		metrics := convert("pass", 1)
+		So(len(metrics), ShouldEqual, 5)
+	})
+
+	Convey("Unknown policies should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"nan_policy": "ignore"}), ShouldNotBeNil)
+	})
+}
+
+func findMetric(metrics []plugin.Metric, name string) plugin.Metric {
+	for _, metric := range metrics {
+		if metricName(metric) == name {
+			return metric
+		}
+	}
+	return plugin.Metric{}
+}
@@ -0,0 +1,103 @@
+package prometheus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// familyTracker keeps the families every target of a task config exposed so
+// far, to report families a target starts exposing
+type familyTracker struct {
+	mutex    sync.Mutex
+	families map[string]map[string]map[string]bool
+}
+
+func newFamilyTracker() *familyTracker {
+	return &familyTracker{
+		families: make(map[string]map[string]map[string]bool),
+	}
+}
+
+// track returns a new_metric_family metric, tagged with the family and the
+// target tags, for every family of a scrape the target never exposed before.
+// The first scrape of a target sets the baseline and reports nothing.
+func (t *familyTracker) track(config plugin.Config, prefix []string, currentTime time.Time, tg target, metricFamilies map[string]*dto.MetricFamily) []plugin.Metric {
+	if t == nil || !getBoolConfig(config, "emit_new_families") {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	targets, ok := t.families[key]
+	if !ok {
+		targets = make(map[string]map[string]bool)
+		t.families[key] = targets
+	}
+	seen, baseline := targets[tg.URL]
+	if !baseline {
+		seen = make(map[string]bool, len(metricFamilies))
+		targets[tg.URL] = seen
+	}
+
+	var added []string
+	for name := range metricFamilies {
+		if !seen[name] {
+			seen[name] = true
+			if baseline {
+				added = append(added, name)
+			}
+		}
+	}
+	sort.Strings(added)
+
+	namespace := prefixedNamespace(prefix, "new_metric_family")
+	metrics := make([]plugin.Metric, 0, len(added))
+	for _, name := range added {
+		tags := make(map[string]string, len(tg.Tags)+1)
+		for key, value := range tg.Tags {
+			tags[key] = value
+		}
+		tags["family"] = name
+		metrics = append(metrics, plugin.Metric{
+			Namespace:   namespace,
+			Timestamp:   currentTime,
+			Description: "1 for a metric family the target exposed for the first time",
+			Version:     pluginVersion,
+			Tags:        tags,
+			Data:        1.0,
+		})
+	}
+	return metrics
+}
+
+// forget drops the families of every target of the config with fingerprint
+// key
+func (t *familyTracker) forget(key string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.families, key)
+}
+
+// retain drops the families of the targets of config not in targets
+func (t *familyTracker) retain(config plugin.Config, targets []target) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	urls := targetURLs(targets)
+	byURL := t.families[configFingerprint(config)]
+	for url := range byURL {
+		if !urls[url] {
+			delete(byURL, url)
+		}
+	}
+}
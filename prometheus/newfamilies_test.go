@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewFamilies(t *testing.T) {
+	parse := func(body string) map[string]*dto.MetricFamily {
+		families, err := parseMetrics(strings.NewReader(body))
+		if err != nil {
+			panic(err)
+		}
+		return families
+	}
+
+	Convey("New families should be reported once per target", t, func() {
+		config := plugin.Config{"emit_new_families": true}
+		tracker := newFamilyTracker()
+		prefix := []string{"hyperpilot", "prometheus"}
+		a := target{URL: "http://a/metrics", Tags: map[string]string{"instance": "a"}}
+		b := target{URL: "http://b/metrics"}
+
+		So(tracker.track(config, prefix, time.Now(), a, parse("up 1\n")), ShouldBeEmpty)
+		So(tracker.track(config, prefix, time.Now(), b, parse("up 1\nqueue_depth 3\n")), ShouldBeEmpty)
+
+		notices := tracker.track(config, prefix, time.Now(), a, parse("up 1\nqueue_depth 3\njobs_total 1\n"))
+		So(notices, ShouldHaveLength, 2)
+		So(notices[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "new_metric_family"})
+		So(notices[0].Tags, ShouldResemble, map[string]string{"instance": "a", "family": "jobs_total"})
+		So(notices[1].Tags["family"], ShouldEqual, "queue_depth")
+
+		So(tracker.track(config, prefix, time.Now(), a, parse("up 1\nqueue_depth 3\njobs_total 1\n")), ShouldBeEmpty)
+	})
+
+	Convey("New families should not be reported by default", t, func() {
+		tracker := newFamilyTracker()
+		a := target{URL: "http://a/metrics"}
+		tracker.track(plugin.Config{}, nil, time.Now(), a, parse("up 1\n"))
+		So(tracker.track(plugin.Config{}, nil, time.Now(), a, parse("up 1\njobs_total 1\n")), ShouldBeEmpty)
+	})
+
+	Convey("Families of targets no longer scraped should be forgotten", t, func() {
+		config := plugin.Config{"emit_new_families": true}
+		tracker := newFamilyTracker()
+		a := target{URL: "http://a/metrics"}
+		b := target{URL: "http://b/metrics"}
+		tracker.track(config, nil, time.Now(), a, parse("up 1\n"))
+		tracker.track(config, nil, time.Now(), b, parse("up 1\n"))
+
+		tracker.retain(config, []target{a})
+		So(tracker.families[configFingerprint(config)], ShouldContainKey, a.URL)
+		So(tracker.families[configFingerprint(config)], ShouldNotContainKey, b.URL)
+
+		tracker.forget(configFingerprint(config))
+		So(tracker.families, ShouldBeEmpty)
+	})
+}
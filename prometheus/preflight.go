@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// preflightResult is the readiness of a target: the step it failed at and
+// why, or "ready" when it passed them all. The steps are, in order:
+//
+// dns: its host resolves
+//
+// tcp: its port accepts connections
+//
+// tls: the handshake of https targets succeeds and verifies
+//
+// auth: it doesn't answer 401 or 403
+//
+// http: it answers 200
+//
+// content: its body parses as an exposition format
+type preflightResult struct {
+	step string
+	err  error
+}
+
+// preflight checks every target of a task on its first collection, logging
+// a report and returning a preflight_ready metric per target, tagged with
+// the step it failed at, so misconfigured targets surface right away rather
+// than as empty collections
+func (c *PrometheusCollector) preflight(config plugin.Config, prefix []string, currentTime time.Time, targets []target) []plugin.Metric {
+	if !getBoolConfig(config, "preflight") {
+		return nil
+	}
+	timeout, _ := getDurationConfig(config, "timeout")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	metrics := make([]plugin.Metric, 0, len(targets))
+	ready := 0
+	for _, t := range targets {
+		result := c.preflightTarget(config, t.URL, timeout)
+		tags := copyTags(t.Tags)
+		tags["preflight_step"] = result.step
+		data := int64(1)
+		if result.err != nil {
+			glog.Warningf("Preflight of %s failed at %s: %s", t.URL, result.step, result.err.Error())
+			tags["preflight_error"] = result.err.Error()
+			data = 0
+		} else {
+			ready++
+		}
+		metrics = append(metrics, plugin.Metric{
+			Namespace:   prefixedNamespace(prefix, "preflight_ready"),
+			Timestamp:   currentTime,
+			Description: "1 if the target passed the preflight checks of its task's first collection, 0 otherwise",
+			Version:     pluginVersion,
+			Tags:        tags,
+			Data:        data,
+		})
+	}
+	glog.Infof("Preflight: %d of %d targets ready", ready, len(targets))
+	return metrics
+}
+
+// preflightTarget runs the preflight steps on url. The network steps are
+// checked directly, the others by classifying the error of a scrape.
+func (c *PrometheusCollector) preflightTarget(config plugin.Config, rawURL string, timeout time.Duration) preflightResult {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return preflightResult{step: "dns", err: err}
+	}
+	// Proxied and custom downloader scrapes don't dial the target
+	direct := getStringConfig(config, "proxy_url") == "" && getStringConfig(config, "downloader") == "" && !isKubeProxy(config)
+	if direct && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+		host, port := parsed.Hostname(), parsed.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[parsed.Scheme]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if net.ParseIP(host) == nil {
+			if _, err := lookupIPAddr(ctx, host); err != nil {
+				return preflightResult{step: "dns", err: err}
+			}
+		}
+		conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return preflightResult{step: "tcp", err: err}
+		}
+		conn.Close()
+	}
+
+	if _, err := c.Collect(rawURL, config); err != nil {
+		return preflightResult{step: preflightStepOf(err), err: err}
+	}
+	return preflightResult{step: "ready"}
+}
+
+// preflightStepOf returns the step a scrape error fails
+func preflightStepOf(err error) string {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "x509:") || strings.Contains(message, "tls:"):
+		return "tls"
+	case strings.Contains(message, "Status code: 401") || strings.Contains(message, "Status code: 403"):
+		return "auth"
+	case strings.Contains(message, "Status code:"):
+		return "http"
+	case strings.HasPrefix(message, "Unable to parse metrics"):
+		return "content"
+	}
+	return "http"
+}
@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPreflight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			fmt.Fprint(w, TEST_DATA)
+		case "/private":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/html":
+			fmt.Fprint(w, "<html><body>{not metrics}</body></html>\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + closed.Addr().String() + "/metrics"
+	closed.Close()
+
+	lookup := lookupIPAddr
+	defer func() { lookupIPAddr = lookup }()
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, errors.New("no such host")
+	}
+
+	collector := &PrometheusCollector{Downloader: NewHTTPMetricsDownloader()}
+	config := plugin.Config{"preflight": true}
+
+	Convey("Preflights should report the step each target fails at", t, func() {
+		for url, step := range map[string]string{
+			server.URL + "/metrics":                "ready",
+			server.URL + "/private":                "auth",
+			server.URL + "/missing":                "http",
+			server.URL + "/html":                   "content",
+			closedURL:                              "tcp",
+			"http://exporter.invalid:9100/metrics": "dns",
+		} {
+			So(collector.preflightTarget(config, url, time.Second).step, ShouldEqual, step)
+		}
+	})
+
+	Convey("Preflights should emit a readiness metric per target", t, func() {
+		targets := []target{{URL: server.URL + "/metrics"}, {URL: server.URL + "/private", Tags: map[string]string{"instance": "private"}}}
+		metrics := collector.preflight(config, []string{"hyperpilot", "prometheus"}, time.Now(), targets)
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "preflight_ready"})
+		So(metrics[0].Data, ShouldEqual, 1)
+		So(metrics[1].Data, ShouldEqual, 0)
+		So(metrics[1].Tags["preflight_step"], ShouldEqual, "auth")
+		So(metrics[1].Tags["instance"], ShouldEqual, "private")
+
+		So(collector.preflight(plugin.Config{}, nil, time.Now(), targets), ShouldBeEmpty)
+	})
+}
@@ -1,12 +1,12 @@
 package prometheus
 
 import (
-	"bytes"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"math"
-	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,33 +31,95 @@ var (
 
 var prometheusEndpoint string = "http://localhost:8080/metrics"
 
-type MetricsDownloader interface {
-	GetMetricsReader(url string) (io.Reader, error)
-	GetEndpoint(config plugin.Config) (string, error)
-}
-
-type HTTPMetricsDownloader struct {
-}
-
 // PrometheusCollector struct
 type PrometheusCollector struct {
 	Downloader MetricsDownloader
+
+	sanitizer *namespaceSanitizer
+	validator *configValidator
+	discovery *discoveryManager
+	rules     *rulesCache
+	topology  *topologyCache
+	catalog   *catalogCache
+
+	hostMetadata     *hostMetadata
+	started          time.Time
+	firstCollections *firstCollectionTracker
+	alerts           *alertTracker
+	derived          *derivedTracker
+	slos             *sloTracker
+	tombstones       *tombstoneTracker
+	families         *familyTracker
+	durations        *durationTracker
+	exporter         *targetExporter
+	paused           *pauseRegistry
+	admin            *adminServer
+	taskStates       *taskStateTracker
+	downsampler      *downsampler
+	pool             *scrapePool
+	counterRates     *counterRateTracker
+	activity         *activityTracker
+	filtered         *filteredTracker
+	anomalies        *anomalyTracker
+
+	// quantiles is the quantile format of the task a copy of the collector
+	// converts for, legacy on the shared collector
+	quantiles quantileFormat
+	// nans is the NaN policy of the task a copy of the collector converts
+	// for
+	nans nanPolicy
+	// safeMode runs every task in safe mode, see WithSafeMode
+	safeMode bool
+	// deniedCIDRs are refused to every task, see WithDeniedCIDRs
+	deniedCIDRs string
 }
 
-// New return an instance of PrometheusCollector
-func New() plugin.Collector {
-	return &PrometheusCollector{
-		Downloader: HTTPMetricsDownloader{},
+// New return an instance of PrometheusCollector, customized by opts
+func New(opts ...Option) plugin.Collector {
+	c := &PrometheusCollector{
+		Downloader: NewFaultInjectingDownloader(NewRecordingDownloader(NewHTTPMetricsDownloader())),
+		sanitizer:  newNamespaceSanitizer(),
+		validator:  newConfigValidator(),
+		discovery:  newDiscoveryManager(),
+		rules:      newRulesCache(),
+		topology:   newTopologyCache(),
+		catalog:    newCatalogCache(),
+
+		hostMetadata:     &hostMetadata{},
+		started:          time.Now(),
+		firstCollections: newFirstCollectionTracker(),
+		alerts:           newAlertTracker(),
+		derived:          newDerivedTracker(),
+		slos:             newSLOTracker(),
+		tombstones:       newTombstoneTracker(),
+		families:         newFamilyTracker(),
+		durations:        newDurationTracker(),
+		exporter:         newTargetExporter(),
+		paused:           newPauseRegistry(),
+		taskStates:       newTaskStateTracker(),
+		downsampler:      newDownsampler(),
+		pool:             newScrapePool(),
+		counterRates:     newCounterRateTracker(),
+		activity:         newActivityTracker(),
+		filtered:         newFilteredTracker(),
+		anomalies:        newAnomalyTracker(),
 	}
+	c.admin = newAdminServer(c.paused, c.dumpDebugState)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *PrometheusCollector) createMetricFromFamily(currentTime time.Time, prefix []string, metricFamily *dto.MetricFamily) plugin.Metric {
+	return c.createMetric(currentTime, prefix, metricFamily.GetName(), metricFamily.GetHelp())
 }
 
-func createMetricFromFamily(currentTime time.Time, metricFamily *dto.MetricFamily) plugin.Metric {
-	fullNamespace := namespacePrefix
-	fullNamespace = append(fullNamespace, *metricFamily.Name)
+func (c *PrometheusCollector) createMetric(currentTime time.Time, prefix []string, name string, help string) plugin.Metric {
 	return plugin.Metric{
-		Namespace:   plugin.NewNamespace(fullNamespace...),
+		Namespace:   prefixedNamespace(prefix, c.sanitizer.sanitize(name)),
 		Timestamp:   currentTime,
-		Description: metricFamily.GetHelp(),
+		Description: help,
 		Version:     pluginVersion,
 	}
 }
@@ -70,54 +132,310 @@ func (c *PrometheusCollector) _collectMetrics(mts []plugin.Metric) ([]plugin.Met
 	if len(mts) == 0 {
 		return metrics, fmt.Errorf("array of metric type is empty\nPlease check GetMetricTypes()")
 	}
+	config := c.enforce(mts[0].Config)
 
-	endpoint, err := c.Downloader.GetEndpoint(mts[0].Config)
+	if err := c.validator.validate(config); err != nil {
+		return metrics, err
+	}
+	c.forgetStale(config, currentTime)
+	if err := c.admin.ensureStarted(config); err != nil {
+		return metrics, err
+	}
+	if c.paused.jobPaused(config) {
+		glog.V(2).Infof("Skipping collection of paused job")
+		return metrics, nil
+	}
+
+	targets, err := c.getTargets(config)
+	if err != nil {
+		return metrics, err
+	}
+	targets, err = c.enrichTargets(config, targets)
 	if err != nil {
-		return metrics, fmt.Errorf("Unable to get endpoint: " + err.Error())
+		return metrics, err
 	}
+	targets = c.paused.activeTargets(targets)
+	c.exporter.export(config, targets)
+	fallback, err := c.getFallbackTargets(config)
+	if err != nil {
+		return metrics, err
+	}
+	if len(fallback) > 0 {
+		targets = tagScrapeSource(targets, "primary")
+	}
+	allTargets := append(append([]target{}, targets...), fallback...)
+	c.taskStates.collecting(config, currentTime, allTargets)
+	c.families.retain(config, allTargets)
+	c.counterRates.retain(config, allTargets)
 
-	metricFamilies, err := c.Collect(endpoint)
+	prefix, err := getNamespacePrefix(config)
 	if err != nil {
-		glog.Warningf("Unable to collect metrics, skipping to next cycle. endpoint: %s, error: %s", endpoint, err.Error())
+		return metrics, err
+	}
+
+	rules, err := c.rules.get(config)
+	if err != nil {
+		return metrics, err
+	}
+
+	active, err := inActiveWindow(config, currentTime)
+	if err != nil {
+		return metrics, err
+	}
+	if !active {
+		if getStringConfig(config, "outside_window") == "up_only" {
+			up := c.collectUp(currentTime, prefix, targets, config)
+			addExtraTags(config, up)
+			return newRequestedNamespaces(mts).filter(up), nil
+		}
+		return metrics, nil
+	}
+
+	warmingUp, err := c.warmingUp(config, currentTime)
+	if err != nil {
+		return metrics, err
+	}
+	if warmingUp {
+		glog.V(2).Infof("Skipping collection during warmup_delay")
 		return metrics, nil
 	}
 
+	plan, err := getSchemaPlan(config, currentTime)
+	if err != nil {
+		return metrics, err
+	}
+
+	// Conversions run on a copy of the collector carrying the task's
+	// quantile format and NaN policy
+	quantiles, err := getQuantileFormat(config)
+	if err != nil {
+		return metrics, err
+	}
+	nans, err := getNaNPolicy(config)
+	if err != nil {
+		return metrics, err
+	}
+	converter := c
+	if isLowMemory(config) || quantiles != (quantileFormat{}) || nans != "" {
+		task := *c
+		task.quantiles = quantiles
+		task.nans = nans
+		if isLowMemory(config) {
+			enableLowMemoryGC()
+		}
+		converter = &task
+	}
+
+	first := c.firstCollections.first(config)
+	if first {
+		metrics = append(metrics, rules.apply(c.catchUp(config, prefix, currentTime))...)
+		metrics = append(metrics, c.preflight(config, prefix, currentTime, append(append([]target{}, targets...), fallback...))...)
+	}
+
+	var scraped []plugin.Metric
+	scrapedByTarget := make(map[string][]plugin.Metric, len(targets))
+	var notices []plugin.Metric
+	var parallel []plugin.Metric
+	scrapedTargets, failedTargets := 0, 0
+	process := func(t target, result scrapeResult) {
+		metricFamilies, err := result.metricFamilies, result.err
+		c.taskStates.scraped(config, t.URL, currentTime, err)
+		scraped = append(scraped, targetHealthMetrics(config, prefix, currentTime, t, result)...)
+		scrapedTargets++
+		if err != nil {
+			failedTargets++
+			glog.Warningf("Unable to collect metrics, skipping to next cycle. endpoint: %s, error: %s", redactURLs(t.URL), redactURLs(err.Error()))
+			return
+		}
+		if err := c.downsampler.apply(config, t.URL, metricFamilies, currentTime, c.Collect); err != nil {
+			glog.Warningf("Unable to downsample metrics of %s: %s", redactURLs(t.URL), redactURLs(err.Error()))
+		}
+		converted := converter.convertFamilies(currentTime, prefix, t, metricFamilies, plan.primary)
+		converted = append(converted, c.counterRates.derive(config, converter, currentTime, prefix, t, metricFamilies)...)
+		converted = rules.apply(applyExporterProfile(config, t, metricFamilies, converted))
+		if plan.parallel != 0 {
+			parallel = append(parallel, rules.apply(converter.convertFamilies(currentTime, prefix, t, metricFamilies, plan.parallel))...)
+		}
+		scrapedByTarget[t.URL] = converted
+		scraped = append(scraped, converted...)
+		notices = append(notices, c.families.track(config, prefix, currentTime, t, metricFamilies)...)
+		c.catalog.observe(t.URL, metricFamilies)
+	}
+	results, stats := c.scrapeAll(config, targets)
+	if err := scrapeErrors(targets, results); err != nil && len(targets) > 1 {
+		glog.Warningf("Collection incomplete, %s", err.Error())
+	}
+	for i, t := range targets {
+		process(t, results[i])
+	}
+	if len(scrapedByTarget) == 0 && len(fallback) > 0 {
+		glog.Warningf("All primary targets failed, scraping fallback_endpoints")
+		var fallbackStats scrapeStats
+		results, fallbackStats = c.scrapeAll(config, fallback)
+		stats.merge(fallbackStats)
+		for i, t := range fallback {
+			process(t, results[i])
+		}
+	}
+	scraped = append(scraped, queueWaitMetric(config, prefix, currentTime, stats.queueWait)...)
+	scraped = append(scraped, parseQueueMetric(config, prefix, currentTime, stats.parseQueueDepth)...)
+	// Observed before rules run on the scrape so slos and alert_rules can
+	// select the collector's own durations
+	scraped = append(scraped, c.durations.observe(config, prefix, currentTime, time.Since(currentTime))...)
+	scraped = append(scraped, c.anomalies.flag(config, rules.anomalyFamilies, prefix, currentTime, scraped)...)
+	scraped = append(scraped, c.filtered.summary(config, prefix, currentTime)...)
+	tombstones := c.tombstones.track(config, prefix, currentTime, scrapedByTarget, targets)
+	derived := c.derived.record(config, rules.recordingRules, prefix, currentTime, scraped)
+	derived = append(derived, c.slos.evaluate(config, rules.slos, prefix, currentTime, scraped)...)
+	scraped = append(scraped, derived...)
+	metrics = append(metrics, scraped...)
+	metrics = append(metrics, c.alerts.evaluate(config, rules.alertRules, prefix, currentTime, scraped)...)
+	metrics = append(metrics, tombstones...)
+	metrics = append(metrics, notices...)
+
+	// The parallel schema of a migration only carries the scraped series,
+	// self-metrics are derived from the primary schema
+	stampSchema(plan, plan.primary, metrics)
+	stampSchema(plan, plan.parallel, parallel)
+	metrics = append(metrics, parallel...)
+	rules.route(metrics)
+	if isLowMemory(config) {
+		dropDescriptions(metrics)
+	}
+
+	if first {
+		tagWarmup(config, metrics)
+	}
+	copyTimestamps(config, metrics)
+	metrics = nans.apply(metrics)
+	addExtraTags(config, metrics)
+	rules.routeNamespaces(len(prefix), metrics)
+	appendLabelsToNamespace(config, metrics)
+	enforceTagBudget(config, metrics)
+	requested := newRequestedNamespaces(mts)
+	metrics = requested.filter(metrics)
+	metadata := batchMetadata(config, prefix, currentTime, scrapedTargets, failedTargets, len(metrics))
+	addExtraTags(config, metadata)
+	metrics = append(metrics, requested.filter(metadata)...)
+	return metrics, nil
+}
+
+// collectUp scrapes targets only to report whether they are up, as a 0/1
+// up metric per target
+func (c *PrometheusCollector) collectUp(currentTime time.Time, prefix []string, targets []target, config plugin.Config) []plugin.Metric {
+	metrics := make([]plugin.Metric, 0, len(targets))
+	for _, t := range targets {
+		up := 1.0
+		if _, err := c.Collect(t.URL, config); err != nil {
+			glog.Warningf("Target is down. endpoint: %s, error: %s", redactURLs(t.URL), redactURLs(err.Error()))
+			up = 0.0
+		}
+		metrics = append(metrics, upMetric(prefix, currentTime, t, up))
+	}
+	return metrics
+}
+
+// getTargets returns the targets a task scrapes: its static targets, its
+// endpoints list, the discovered targets when the task configures discovery,
+// otherwise its single endpoint
+func (c *PrometheusCollector) getTargets(config plugin.Config) ([]target, error) {
+	if getStringConfig(config, "static_targets") != "" {
+		return parseStaticTargets(config)
+	}
+	if getStringConfig(config, "endpoints") != "" {
+		return parseEndpoints(config)
+	}
+	if getStringConfig(config, "discovery") != "" {
+		targets, err := c.discovery.targets(config)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to discover targets: " + err.Error())
+		}
+		return targets, nil
+	}
+
+	downloader, err := c.downloaderOf(config)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := downloader.GetEndpoint(config)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get endpoint: " + err.Error())
+	}
+	return []target{{URL: endpoint}}, nil
+}
+
+// convertFamilies converts the scraped families of t to metrics following
+// the given schema version, see schemas
+func (c *PrometheusCollector) convertFamilies(currentTime time.Time, prefix []string, t target, metricFamilies map[string]*dto.MetricFamily, schema int64) []plugin.Metric {
+	var metrics []plugin.Metric
 	for _, metricFamily := range metricFamilies {
 		for _, metricItem := range metricFamily.GetMetric() {
 			switch metricFamily.GetType() {
 			case dto.MetricType_GAUGE:
-				metric := createMetricFromFamily(currentTime, metricFamily)
+				metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
 				if strings.Contains(metricFamily.GetName(), "bytes") {
 					metric.Unit = "B"
 				}
 				metric.Data = metricItem.GetGauge().GetValue()
-				metric.Tags = getTagsOfMetric(metricItem)
+				metric.Tags = c.getTagsOfMetric(metricItem, t.Tags)
 				metrics = append(metrics, metric)
 
 			case dto.MetricType_COUNTER:
-				metric := createMetricFromFamily(currentTime, metricFamily)
+				metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
 				metric.Data = metricItem.GetCounter().GetValue()
-				metric.Tags = getTagsOfMetric(metricItem)
+				metric.Tags = c.getTagsOfMetric(metricItem, t.Tags)
 				metrics = append(metrics, metric)
 
 			case dto.MetricType_SUMMARY:
-				summaryData, err := processSummaryMetric(metricItem)
+				if schema >= 2 {
+					metrics = append(metrics, c.splitSummary(currentTime, prefix, metricFamily, metricItem, t.Tags)...)
+					break
+				}
+				if c.quantiles.format == "tag" {
+					metrics = append(metrics, c.tagSummary(currentTime, prefix, metricFamily, metricItem, t.Tags)...)
+					break
+				}
+				summaryData, err := processSummaryMetric(metricItem, metricFamily.GetName(), c.quantiles, c.nans.keepsNaNQuantiles())
 				if err != nil {
 					continue
 				}
 				for key, val := range summaryData {
-					metric := createMetricFromFamily(currentTime, metricFamily)
-					tags := getTagsOfMetric(metricItem)
+					metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+					tags := c.getTagsOfMetric(metricItem, t.Tags)
 					tags["summary"] = key
 					metric.Tags = tags
 					metric.Data = val
 					metrics = append(metrics, metric)
 				}
+
+			case dto.MetricType_HISTOGRAM:
+				if schema >= 2 {
+					metrics = append(metrics, c.splitHistogram(currentTime, prefix, metricFamily, metricItem, t.Tags)...)
+					break
+				}
+				buckets, sum, count := processHistogramMetric(metricItem)
+				for le, val := range buckets {
+					metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+					tags := c.getTagsOfMetric(metricItem, t.Tags)
+					tags["histogram"] = "bucket"
+					tags["le"] = le
+					metric.Tags = tags
+					metric.Data = val
+					metrics = append(metrics, metric)
+				}
+				for key, val := range map[string]float64{"sum": sum, "count": count} {
+					metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+					tags := c.getTagsOfMetric(metricItem, t.Tags)
+					tags["histogram"] = key
+					metric.Tags = tags
+					metric.Data = val
+					metrics = append(metrics, metric)
+				}
 			}
 		}
 	}
 
-	return metrics, nil
+	return metrics
 }
 
 // CollectMetrics will be called by Snap when a task that collects one of the metrics returned from this plugins
@@ -135,22 +453,67 @@ func (c *PrometheusCollector) CollectMetrics(mts []plugin.Metric) ([]plugin.Metr
 	return metrics, nil
 }
 
-func getTagsOfMetric(metric *dto.Metric) map[string]string {
-	tags := make(map[string]string)
+// getNamespacePrefix returns the namespace collected metrics live under,
+// /hyperpilot/prometheus unless namespace_prefix roots it elsewhere. A task
+// naming its job gets the job as an extra segment, so subscriptions can be
+// organized per job, e.g. /hyperpilot/prometheus/istio/...
+func getNamespacePrefix(config plugin.Config) ([]string, error) {
+	prefix, err := getNamespaceRoot(config)
+	if err != nil {
+		return nil, err
+	}
+	job, err := config.GetString("job")
+	if err != nil || job == "" {
+		return prefix, nil
+	}
+	if err := checkNamespaceElement(job); err != nil {
+		return nil, fmt.Errorf("Invalid job: " + err.Error())
+	}
+	return append(prefix, job), nil
+}
+
+// getNamespaceRoot returns the elements of namespace_prefix, the vendor and
+// plugin name by default
+func getNamespaceRoot(config plugin.Config) ([]string, error) {
+	root := strings.Trim(getStringConfig(config, "namespace_prefix"), "/")
+	if root == "" {
+		return append([]string{}, namespacePrefix...), nil
+	}
+	prefix := strings.Split(root, "/")
+	for _, element := range prefix {
+		if err := checkNamespaceElement(element); err != nil {
+			return nil, fmt.Errorf("Invalid namespace_prefix: " + err.Error())
+		}
+	}
+	return prefix, nil
+}
+
+// getTagsOfMetric returns the labels of metric as tags, along with the tags
+// of the target it was scraped from. Scraped labels win over target tags.
+func (c *PrometheusCollector) getTagsOfMetric(metric *dto.Metric, targetTags map[string]string) map[string]string {
+	tags := make(map[string]string, len(metric.GetLabel())+len(targetTags))
+	for key, value := range targetTags {
+		tags[key] = value
+	}
 	for _, label := range metric.GetLabel() {
 		tags[label.GetName()] = label.GetValue()
 	}
 	return tags
 }
 
-func processSummaryMetric(metric *dto.Metric) (map[string]float64, error) {
+func processSummaryMetric(metric *dto.Metric, family string, format quantileFormat, keepNaN bool) (map[string]float64, error) {
 	summary := make(map[string]float64)
 	summary["count"] = float64(metric.GetSummary().GetSampleCount())
 	summary["sum"] = float64(metric.GetSummary().GetSampleSum())
 
-	for _, quantile := range metric.GetSummary().GetQuantile() {
-		key := fmt.Sprintf("quantile_%d", int(quantile.GetQuantile()*100))
-		if !math.IsNaN(quantile.GetValue()) {
+	quantiles := make([]float64, len(metric.GetSummary().GetQuantile()))
+	for i, quantile := range metric.GetSummary().GetQuantile() {
+		quantiles[i] = quantile.GetQuantile()
+	}
+	keys := format.summaryKeys(family, quantiles)
+	for i, quantile := range metric.GetSummary().GetQuantile() {
+		key := keys[i]
+		if keepNaN || !math.IsNaN(quantile.GetValue()) {
 			summary[key] = quantile.GetValue()
 		} else {
 			glog.Warningf("Skipping to write metric %s as it's value is NaN", key)
@@ -160,42 +523,37 @@ func processSummaryMetric(metric *dto.Metric) (map[string]float64, error) {
 	return summary, nil
 }
 
-func (downloader HTTPMetricsDownloader) GetEndpoint(config plugin.Config) (string, error) {
-	address, err := config.GetString("endpoint")
-	if err != nil {
-		return "", err
+// processHistogramMetric returns the cumulative bucket counts of a histogram
+// keyed by their upper bound, including the +Inf bucket, and its sum and
+// count
+func processHistogramMetric(metric *dto.Metric) (map[string]float64, float64, float64) {
+	histogram := metric.GetHistogram()
+	count := float64(histogram.GetSampleCount())
+	buckets := make(map[string]float64, len(histogram.GetBucket())+1)
+	for _, bucket := range histogram.GetBucket() {
+		buckets[formatBound(bucket.GetUpperBound())] = float64(bucket.GetCumulativeCount())
 	}
-
-	if strings.Contains(address, "/metrics") {
-		return address, nil
-	}
-
-	return address + "/metrics", nil
+	buckets["+Inf"] = count
+	return buckets, histogram.GetSampleSum(), count
 }
 
-func (downloader HTTPMetricsDownloader) GetMetricsReader(url string) (io.Reader, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	} else if resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-
-		// Copy content from the body of http request
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(resp.Body)
-		b := buf.Bytes()
-		httpBody := bytes.NewReader(b)
-
-		return httpBody, nil
-	} else {
-		return nil, fmt.Errorf("Status code: %d Response: %v\n", resp.StatusCode, resp)
+// formatBound formats a bucket bound like Prometheus does in le labels
+func formatBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
 	}
+	return strconv.FormatFloat(bound, 'g', -1, 64)
 }
 
+// parseMetrics parses a body in the text or the delimited protobuf
+// exposition format
 func parseMetrics(httpBody io.Reader) (map[string]*dto.MetricFamily, error) {
+	body := bufio.NewReader(httpBody)
+	if isProtobuf(body) {
+		return parseProtobufMetrics(body)
+	}
 	var parser expfmt.TextParser
-	metricFamilies, err := parser.TextToMetricFamilies(httpBody)
+	metricFamilies, err := parser.TextToMetricFamilies(body)
 	if err != nil {
 		fmt.Println(err)
 		return make(map[string]*dto.MetricFamily), err
@@ -203,38 +561,102 @@ func parseMetrics(httpBody io.Reader) (map[string]*dto.MetricFamily, error) {
 	return metricFamilies, nil
 }
 
-func (c PrometheusCollector) Collect(endpoint string) (map[string]*dto.MetricFamily, error) {
-	reader, err := c.Downloader.GetMetricsReader(endpoint)
+func (c PrometheusCollector) Collect(endpoint string, config plugin.Config) (map[string]*dto.MetricFamily, error) {
+	reader, err := c.download(endpoint, config)
+	if err != nil {
+		return nil, err
+	}
+	return c.parse(endpoint, config, reader)
+}
+
+// download fetches the body of endpoint, the network bound half of Collect
+func (c PrometheusCollector) download(endpoint string, config plugin.Config) (io.Reader, error) {
+	downloader, err := c.downloaderOf(config)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := downloader.GetMetricsReader(endpoint, config)
 	if err != nil {
 		return nil, errors.New("Unable to download metrics: " + err.Error())
 	}
+	return reader, nil
+}
+
+// parse parses and filters a body download returned, the CPU bound half of
+// Collect, and closes it
+func (c PrometheusCollector) parse(endpoint string, config plugin.Config, reader io.Reader) (map[string]*dto.MetricFamily, error) {
+	defer closeBody(reader)
+	var limited *lineLimitReader
+	if max := getIntConfig(config, "max_line_length"); max > 0 {
+		// Protobuf bodies have no lines to limit
+		body := bufio.NewReader(reader)
+		reader = body
+		if !isProtobuf(body) {
+			limited = newLineLimitReader(body, max, getStringConfig(config, "long_lines"))
+			reader = limited
+		}
+	}
 	metricFamilies, err := parseMetrics(reader)
+	if streamed, ok := reader.(*streamedBody); ok && streamed.err != nil {
+		return nil, errors.New("Unable to download metrics: " + streamed.err.Error())
+	}
 	if err != nil {
 		return nil, errors.New("Unable to parse metrics: " + err.Error())
 	}
+	if limited != nil {
+		if err := limited.problems(); err != nil {
+			glog.Warningf("Scrape of %s: %s", redactURLs(endpoint), err.Error())
+		}
+	}
+	rules, err := c.rules.get(config)
+	if err != nil {
+		return nil, err
+	}
+	c.filtered.record(config, rules.filterFamilies(metricFamilies))
+	if getBoolConfig(config, "safe_mode") {
+		if err := checkSafeModeLimits(metricFamilies); err != nil {
+			return nil, errors.New("Scrape rejected by safe mode: " + err.Error())
+		}
+	}
+	if isLowMemory(config) {
+		if err := checkLowMemoryLimits(metricFamilies); err != nil {
+			return nil, errors.New("Scrape rejected by low_memory: " + err.Error())
+		}
+	}
 	return metricFamilies, nil
 }
 
 func (c *PrometheusCollector) GetMetricTypes(cfg plugin.Config) ([]plugin.Metric, error) {
+	cfg = c.enforce(cfg)
+	if mts, ok := c.catalog.get(cfg); ok {
+		return mts, nil
+	}
+
 	mts := []plugin.Metric{}
+	root, err := getNamespaceRoot(cfg)
+	if err != nil {
+		return nil, err
+	}
 	mts = append(mts, plugin.Metric{
-		Namespace: plugin.NewNamespace(namespacePrefix...),
+		Namespace: plugin.NewNamespace(root...),
 		Version:   pluginVersion,
 	})
 
-	return mts, nil
-}
-
-//GetConfigPolicy returns a ConfigPolicyTree for testing
-func (c *PrometheusCollector) GetConfigPolicy() (plugin.ConfigPolicy, error) {
-	policy := plugin.NewConfigPolicy()
-
-	// namespace
-	configKey := namespacePrefix
-	policy.AddNewStringRule(configKey,
-		"endpoint",
-		false,
-		plugin.SetDefaultString(prometheusEndpoint))
+	// The prefix stays in the catalog, so tasks written against it keep
+	// loading when the probe fails or a family disappears. A failed probe
+	// isn't cached, to probe again on the next call.
+	endpoint := ""
+	var families map[string]bool
+	if getBoolConfig(cfg, "probe_metric_types") {
+		probed, probedEndpoint, probedFamilies, err := c.probeMetricTypes(cfg)
+		if err != nil {
+			glog.Warningf("Unable to probe metric types, only the namespace prefix is listed: %s", err.Error())
+			return mts, nil
+		}
+		mts = append(mts, probed...)
+		endpoint, families = probedEndpoint, probedFamilies
+	}
 
-	return *policy, nil
+	c.catalog.put(cfg, endpoint, families, mts)
+	return mts, nil
 }
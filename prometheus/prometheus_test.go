@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
 
@@ -174,7 +175,7 @@ process_start_time_seconds 1.49073556736e+09
 process_virtual_memory_bytes 1.21430016e+08
 `
 
-func (downloader MockMetricsDownloader) GetMetricsReader(url string) (io.Reader, error) {
+func (downloader MockMetricsDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
 	return strings.NewReader(TEST_DATA), nil
 }
 
@@ -219,5 +220,70 @@ func TestPrometheusPlugin(t *testing.T) {
 				}
 			})
 		})
+
+		Convey("Prometheus collector should add the job to the namespace", func() {
+			metricTypes, err := collector.GetMetricTypes(plugin.Config{})
+			So(err, ShouldBeNil)
+			metricTypes[0].Config = plugin.Config{"job": "istio"}
+			metrics, err := collector.CollectMetrics(metricTypes)
+			So(err, ShouldBeNil)
+			So(len(metrics), ShouldBeGreaterThan, 0)
+			for _, metric := range metrics {
+				So(metric.Namespace.Strings()[:3], ShouldResemble, []string{"hyperpilot", "prometheus", "istio"})
+			}
+
+			metricTypes[0].Config = plugin.Config{"job": "istio/pilot"}
+			_, err = collector.CollectMetrics(metricTypes)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+const HISTOGRAM_DATA = `
+# HELP http_request_duration_milliseconds Request latency.
+# TYPE http_request_duration_milliseconds histogram
+http_request_duration_milliseconds_bucket{handler="api",le="100"} 3
+http_request_duration_milliseconds_bucket{handler="api",le="500"} 7
+http_request_duration_milliseconds_bucket{handler="api",le="+Inf"} 8
+http_request_duration_milliseconds_sum{handler="api"} 2100
+http_request_duration_milliseconds_count{handler="api"} 8
+`
+
+func TestHistogramMetrics(t *testing.T) {
+	Convey("Histograms should be emitted as buckets, sum and count", t, func() {
+		families, err := parseMetrics(strings.NewReader(HISTOGRAM_DATA))
+		So(err, ShouldBeNil)
+		collector := &PrometheusCollector{}
+		metrics := collector.convertFamilies(time.Now(), namespacePrefix, target{}, families, 1)
+
+		values := make(map[string]float64)
+		for _, metric := range metrics {
+			So(metric.Tags["handler"], ShouldEqual, "api")
+			values[metric.Tags["histogram"]+"/"+metric.Tags["le"]] = metric.Data.(float64)
+		}
+		So(values, ShouldResemble, map[string]float64{
+			"bucket/100":  3,
+			"bucket/500":  7,
+			"bucket/+Inf": 8,
+			"sum/":        2100,
+			"count/":      8,
+		})
+
+		Convey("and unit conversion should rescale the bucket bounds, not the counts", func() {
+			conversions, err := compileUnitConversions("")
+			So(err, ShouldBeNil)
+			for i := range metrics {
+				convertUnit(conversions, &metrics[i])
+			}
+			values := make(map[string]float64)
+			for _, metric := range metrics {
+				values[metric.Tags["histogram"]+"/"+metric.Tags["le"]] = metric.Data.(float64)
+			}
+			So(values["bucket/0.1"], ShouldEqual, 3)
+			So(values["bucket/0.5"], ShouldEqual, 7)
+			So(values["bucket/+Inf"], ShouldEqual, 8)
+			So(values["count/"], ShouldEqual, 8)
+			So(values["sum/"], ShouldAlmostEqual, 2.1)
+		})
 	})
 }
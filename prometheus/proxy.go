@@ -0,0 +1,75 @@
+package prometheus
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// getNoProxy returns the no_proxy option, or the NO_PROXY environment
+// variable when the task doesn't set it
+func getNoProxy(value string) string {
+	if value != "" {
+		return value
+	}
+	if env := os.Getenv("NO_PROXY"); env != "" {
+		return env
+	}
+	return os.Getenv("no_proxy")
+}
+
+// proxyBypassing returns the transport proxy of proxy_url, which targets
+// listed in noProxy are scraped without, as NO_PROXY does for the
+// environment's proxy
+func proxyBypassing(proxy *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	entries := splitList(noProxy)
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(entries, req.URL) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// bypassProxy tells whether target matches an entry of a NO_PROXY list: *,
+// an IP, a CIDR, or a domain matching itself and its subdomains, each
+// optionally with a port
+func bypassProxy(entries []string, target *url.URL) bool {
+	host, port := target.Hostname(), target.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[target.Scheme]
+	}
+	ip := net.ParseIP(host)
+	for _, entry := range entries {
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.ToLower(strings.TrimPrefix(entryHost, "."))
+		host := strings.ToLower(host)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
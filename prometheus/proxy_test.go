@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNoProxy(t *testing.T) {
+	bypass := func(noProxy, target string) bool {
+		parsed, err := url.Parse(target)
+		So(err, ShouldBeNil)
+		return bypassProxy(splitList(noProxy), parsed)
+	}
+
+	Convey("NO_PROXY entries should match hosts, domains, IPs and CIDRs", t, func() {
+		So(bypass("*", "http://node-a:9100/metrics"), ShouldBeTrue)
+		So(bypass("example.com", "http://metrics.example.com/metrics"), ShouldBeTrue)
+		So(bypass(".example.com", "http://example.com/metrics"), ShouldBeTrue)
+		So(bypass("example.com", "http://badexample.com/metrics"), ShouldBeFalse)
+		So(bypass("10.0.0.0/8", "http://10.1.2.3:9100/metrics"), ShouldBeTrue)
+		So(bypass("10.0.0.0/8", "http://192.168.1.1:9100/metrics"), ShouldBeFalse)
+		So(bypass("192.168.1.1", "http://192.168.1.1:9100/metrics"), ShouldBeTrue)
+		So(bypass("node-a:9100", "http://node-a:9100/metrics"), ShouldBeTrue)
+		So(bypass("node-a:9100", "http://node-a:8080/metrics"), ShouldBeFalse)
+		So(bypass("node-a:443", "https://node-a/metrics"), ShouldBeTrue)
+		So(bypass("", "http://node-a:9100/metrics"), ShouldBeFalse)
+	})
+
+	Convey("Scrapes should go through proxy_url unless listed in no_proxy", t, func() {
+		proxied := 0
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied++
+			w.Write([]byte(TEST_DATA))
+		}))
+		defer proxy.Close()
+		downloader := NewHTTPMetricsDownloader()
+
+		_, err := downloader.GetMetricsReader("http://10.0.0.1:9100/metrics", plugin.Config{"proxy_url": proxy.URL})
+		So(err, ShouldBeNil)
+		So(proxied, ShouldEqual, 1)
+
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(TEST_DATA))
+		}))
+		defer target.Close()
+		_, err = downloader.GetMetricsReader(target.URL, plugin.Config{"proxy_url": proxy.URL, "no_proxy": "127.0.0.0/8"})
+		So(err, ShouldBeNil)
+		So(proxied, ShouldEqual, 1)
+	})
+
+	Convey("NO_PROXY should be the default of no_proxy", t, func() {
+		os.Setenv("NO_PROXY", ".cluster.local")
+		defer os.Unsetenv("NO_PROXY")
+		So(getNoProxy(""), ShouldEqual, ".cluster.local")
+		So(getNoProxy("10.0.0.0/8"), ShouldEqual, "10.0.0.0/8")
+	})
+}
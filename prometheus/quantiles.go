@@ -0,0 +1,118 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// quantileFormat is how schema 1 names the summary tag of quantiles:
+//
+// legacy: quantile_<whole percent>, 0.999 truncating to quantile_99
+//
+// percent: the percentage rounded to digits significant digits, 0.999 as
+// quantile_99_9
+//
+// fraction: the quantile rounded to digits significant digits, 0.999 as
+// quantile_0_999
+//
+// tag: summary=quantile with the exact quantile in a quantile tag, like
+// histogram buckets are tagged with le
+//
+// The zero value is legacy.
+type quantileFormat struct {
+	format string
+	digits int
+}
+
+func getQuantileFormat(config plugin.Config) (quantileFormat, error) {
+	format := getStringConfig(config, "quantile_format")
+	switch format {
+	case "", "legacy":
+		return quantileFormat{}, nil
+	case "tag":
+		return quantileFormat{format: format}, nil
+	case "percent", "fraction":
+	default:
+		return quantileFormat{}, fmt.Errorf("quantile_format must be one of legacy, percent, fraction and tag")
+	}
+	digits := getIntConfig(config, "quantile_digits")
+	if digits < 1 || digits > 17 {
+		return quantileFormat{}, fmt.Errorf("quantile_digits must be between 1 and 17")
+	}
+	return quantileFormat{format: format, digits: int(digits)}, nil
+}
+
+func (f quantileFormat) key(quantile float64) string {
+	switch f.format {
+	case "percent":
+		return "quantile_" + formatQuantile(quantile*100, f.digits)
+	case "fraction":
+		return "quantile_" + formatQuantile(quantile, f.digits)
+	default:
+		return fmt.Sprintf("quantile_%d", int(quantile*100))
+	}
+}
+
+// formatQuantile rounds value to digits significant digits, with an
+// underscore for the decimal point
+func formatQuantile(value float64, digits int) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', digits, 64), 64)
+	return strings.Replace(strconv.FormatFloat(rounded, 'f', -1, 64), ".", "_", 1)
+}
+
+// summaryKeys names the quantiles of a summary. Quantiles a format names
+// alike, e.g. 0.99 and 0.999 with legacy, are told apart by their exact
+// value rather than overwriting each other.
+func (f quantileFormat) summaryKeys(family string, quantiles []float64) []string {
+	keys := make([]string, len(quantiles))
+	seen := make(map[string]float64, len(quantiles))
+	for i, quantile := range quantiles {
+		key := f.key(quantile)
+		if other, ok := seen[key]; ok && other != quantile {
+			exact := "quantile_" + strings.Replace(formatBound(quantile), ".", "_", 1)
+			glog.V(2).Infof("Quantiles %v and %v of %s are both named %s, naming %v %s", other, quantile, family, key, quantile, exact)
+			key = exact
+		}
+		seen[key] = quantile
+		keys[i] = key
+	}
+	return keys
+}
+
+// tagSummary converts a schema 1 summary with the tag quantile format
+func (c *PrometheusCollector) tagSummary(currentTime time.Time, prefix []string, metricFamily *dto.MetricFamily, metricItem *dto.Metric, targetTags map[string]string) []plugin.Metric {
+	summary := metricItem.GetSummary()
+	metrics := make([]plugin.Metric, 0, len(summary.GetQuantile())+2)
+	for _, quantile := range summary.GetQuantile() {
+		if math.IsNaN(quantile.GetValue()) && !c.nans.keepsNaNQuantiles() {
+			glog.Warningf("Skipping to write metric %s quantile %v as it's value is NaN", metricFamily.GetName(), quantile.GetQuantile())
+			continue
+		}
+		metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+		metric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+		metric.Tags["summary"] = "quantile"
+		metric.Tags["quantile"] = formatBound(quantile.GetQuantile())
+		metric.Data = quantile.GetValue()
+		metrics = append(metrics, metric)
+	}
+	for key, value := range map[string]float64{"sum": summary.GetSampleSum(), "count": float64(summary.GetSampleCount())} {
+		metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+		metric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+		metric.Tags["summary"] = key
+		metric.Data = value
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func checkQuantileFormat(config plugin.Config) ([]string, error) {
+	_, err := getQuantileFormat(config)
+	return nil, err
+}
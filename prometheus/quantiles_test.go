@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const quantilesPage = `# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.01
+rpc_duration_seconds{quantile="0.99"} 0.2
+rpc_duration_seconds{quantile="0.999"} 0.9
+rpc_duration_seconds_sum 12
+rpc_duration_seconds_count 100
+`
+
+func TestQuantileFormats(t *testing.T) {
+	Convey("Quantiles should be named following quantile_format", t, func() {
+		legacy := quantileFormat{}
+		So(legacy.key(0.5), ShouldEqual, "quantile_50")
+		So(legacy.key(0.999), ShouldEqual, "quantile_99")
+
+		percent, err := getQuantileFormat(plugin.Config{"quantile_format": "percent"})
+		So(err, ShouldBeNil)
+		So(percent.key(0.5), ShouldEqual, "quantile_50")
+		So(percent.key(0.999), ShouldEqual, "quantile_99_9")
+		So(percent.key(0.29), ShouldEqual, "quantile_29")
+
+		fraction, err := getQuantileFormat(plugin.Config{"quantile_format": "fraction", "quantile_digits": int64(2)})
+		So(err, ShouldBeNil)
+		So(fraction.key(0.999), ShouldEqual, "quantile_1")
+		So(fraction.key(0.95), ShouldEqual, "quantile_0_95")
+	})
+
+	Convey("Quantiles named alike should not overwrite each other", t, func() {
+		keys := quantileFormat{}.summaryKeys("rpc_duration_seconds", []float64{0.5, 0.99, 0.999})
+		So(keys, ShouldResemble, []string{"quantile_50", "quantile_99", "quantile_0_999"})
+	})
+
+	Convey("Schema 1 summaries should be converted with the task's quantile format", t, func() {
+		families, err := parseMetrics(strings.NewReader(quantilesPage))
+		So(err, ShouldBeNil)
+		collector := &PrometheusCollector{quantiles: quantileFormat{format: "percent", digits: 6}}
+		summaries := map[string]bool{}
+		for _, metric := range collector.convertFamilies(time.Now(), []string{"hyperpilot", "prometheus"}, target{}, families, 1) {
+			summaries[metric.Tags["summary"]] = true
+		}
+		So(summaries, ShouldResemble, map[string]bool{"quantile_50": true, "quantile_99": true, "quantile_99_9": true, "sum": true, "count": true})
+	})
+
+	Convey("Schema 1 summaries should tag their quantiles with the tag format", t, func() {
+		families, err := parseMetrics(strings.NewReader(quantilesPage))
+		So(err, ShouldBeNil)
+		format, err := getQuantileFormat(plugin.Config{"quantile_format": "tag"})
+		So(err, ShouldBeNil)
+		collector := &PrometheusCollector{quantiles: format}
+		parts := map[string]bool{}
+		for _, metric := range collector.convertFamilies(time.Now(), []string{"hyperpilot", "prometheus"}, target{}, families, 1) {
+			parts[metric.Tags["summary"]+" "+metric.Tags["quantile"]] = true
+		}
+		So(parts, ShouldResemble, map[string]bool{"quantile 0.5": true, "quantile 0.99": true, "quantile 0.999": true, "sum ": true, "count ": true})
+	})
+
+	Convey("Invalid quantile formats should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"quantile_format": "permille"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"quantile_format": "percent", "quantile_digits": int64(0)}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// idleReadConn fails a read waiting for timeout without receiving any byte,
+// however long the reads before it took altogether
+type idleReadConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleReadConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// idleReadDialer returns dial with the reads of its connections timing out
+// when idle for timeout
+func idleReadDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &idleReadConn{Conn: conn, timeout: timeout}, nil
+	}
+}
+
+// streamedBody is a response body parsed as it is read, closed by Collect
+// once parsed. The text parser takes a read error at the start of a line for
+// the end of the body, so the error is kept to fail the scrape regardless.
+type streamedBody struct {
+	reader io.Reader
+	body   io.Closer
+	err    error
+}
+
+func (b *streamedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *streamedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,43 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadTimeout(t *testing.T) {
+	// The body takes about 300ms to arrive, 50ms at a time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE windows_service_state gauge")
+		w.(http.Flusher).Flush()
+		for i := 0; i < 6; i++ {
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprintf(w, "windows_service_state{name=\"svc%d\"} 1\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+	collector := &PrometheusCollector{Downloader: NewHTTPMetricsDownloader()}
+
+	Convey("A slow body should be cut off by timeout alone", t, func() {
+		_, err := collector.Collect(server.URL, plugin.Config{"timeout": "150ms"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("A slow but progressing body should be read with read_timeout", t, func() {
+		families, err := collector.Collect(server.URL, plugin.Config{"timeout": "150ms", "read_timeout": "150ms"})
+		So(err, ShouldBeNil)
+		So(families["windows_service_state"].GetMetric(), ShouldHaveLength, 6)
+	})
+
+	Convey("A stalled body should time out with read_timeout", t, func() {
+		_, err := collector.Collect(server.URL, plugin.Config{"timeout": "150ms", "read_timeout": "20ms"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,157 @@
+package prometheus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// RecordingDownloader wraps a downloader. In record mode it saves every
+// scrape of a task to recording_dir; in replay mode it serves the recorded
+// scrapes instead of downloading, in order and starting over once all were
+// served, so the whole pipeline can be tested without live targets.
+//
+// A recording holds a directory per target URL, named after the URL's
+// hash, holding the URL in a url file and numbered scrapes: .prom files
+// with the body of a successful scrape and .err files with the error of a
+// failed one. Recordings are only readable by the plugin's user, as scrapes
+// and URLs may hold secrets, and stop at recording_max_scrapes per target.
+type RecordingDownloader struct {
+	Downloader MetricsDownloader
+
+	mutex sync.Mutex
+	// next is the number of the next scrape recorded or replayed per target
+	// directory
+	next map[string]int
+}
+
+// NewRecordingDownloader returns a downloader recording or replaying the
+// scrapes of downloader
+func NewRecordingDownloader(downloader MetricsDownloader) *RecordingDownloader {
+	return &RecordingDownloader{
+		Downloader: downloader,
+		next:       make(map[string]int),
+	}
+}
+
+func (d *RecordingDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return d.Downloader.GetEndpoint(config)
+}
+
+func (d *RecordingDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	switch getStringConfig(config, "recording_mode") {
+	case "record":
+		return d.record(url, config)
+	case "replay":
+		return d.replay(url, config)
+	default:
+		return d.Downloader.GetMetricsReader(url, config)
+	}
+}
+
+func recordingTargetDir(config plugin.Config, url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(getStringConfig(config, "recording_dir"), hex.EncodeToString(hash[:8]))
+}
+
+// recordedScrapes returns the scrape files of a target directory in order
+func recordedScrapes(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var scrapes []string
+	for _, file := range files {
+		if ext := filepath.Ext(file.Name()); ext == ".prom" || ext == ".err" {
+			scrapes = append(scrapes, file.Name())
+		}
+	}
+	sort.Strings(scrapes)
+	return scrapes, nil
+}
+
+func (d *RecordingDownloader) record(url string, config plugin.Config) (io.Reader, error) {
+	reader, scrapeErr := d.Downloader.GetMetricsReader(url, config)
+	var body []byte
+	if scrapeErr == nil {
+		var err error
+		body, err = ioutil.ReadAll(reader)
+		if closer, ok := reader.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dir := recordingTargetDir(config, url)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	number, ok := d.next[dir]
+	if !ok {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("Unable to create recording: " + err.Error())
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "url"), []byte(url+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("Unable to create recording: " + err.Error())
+		}
+		scrapes, err := recordedScrapes(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create recording: " + err.Error())
+		}
+		number = len(scrapes)
+	}
+	d.next[dir] = number + 1
+
+	if max := getIntConfig(config, "recording_max_scrapes"); int64(number) >= max {
+		if int64(number) == max || !ok {
+			glog.Warningf("Recording of %s holds recording_max_scrapes (%d) scrapes, later scrapes aren't recorded", redactURLs(url), max)
+		}
+	} else {
+		name, data := fmt.Sprintf("%06d.prom", number), body
+		if scrapeErr != nil {
+			name, data = fmt.Sprintf("%06d.err", number), []byte(scrapeErr.Error())
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return nil, fmt.Errorf("Unable to record scrape: " + err.Error())
+		}
+	}
+
+	if scrapeErr != nil {
+		return nil, scrapeErr
+	}
+	return bytes.NewReader(body), nil
+}
+
+func (d *RecordingDownloader) replay(url string, config plugin.Config) (io.Reader, error) {
+	dir := recordingTargetDir(config, url)
+	scrapes, err := recordedScrapes(dir)
+	if err != nil || len(scrapes) == 0 {
+		return nil, fmt.Errorf("No recorded scrapes of %s in %s", url, getStringConfig(config, "recording_dir"))
+	}
+
+	d.mutex.Lock()
+	scrape := scrapes[d.next[dir]%len(scrapes)]
+	d.next[dir]++
+	d.mutex.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, scrape))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to replay scrape: " + err.Error())
+	}
+	if strings.HasSuffix(scrape, ".err") {
+		return nil, fmt.Errorf("Replayed error: %s", data)
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,110 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sequenceDownloader answers scrapes with its bodies in order, an empty body
+// standing for a failed scrape
+type sequenceDownloader struct {
+	bodies []string
+	calls  int
+}
+
+func (d *sequenceDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	body := d.bodies[d.calls%len(d.bodies)]
+	d.calls++
+	if body == "" {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return strings.NewReader(body), nil
+}
+
+func (d *sequenceDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "http://localhost:9100/metrics", nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	read := func(d MetricsDownloader, url string, config plugin.Config) (string, error) {
+		reader, err := d.GetMetricsReader(url, config)
+		if err != nil {
+			return "", err
+		}
+		body, err := ioutil.ReadAll(reader)
+		return string(body), err
+	}
+
+	Convey("Recorded scrapes should be replayed in order", t, func() {
+		live := &sequenceDownloader{bodies: []string{"up 1\n", "", "up 0\n"}}
+		recorder := NewRecordingDownloader(live)
+		config := plugin.Config{"recording_mode": "record", "recording_dir": dir}
+		for _, expected := range []string{"up 1\n", "", "up 0\n"} {
+			body, err := read(recorder, "http://a:9100/metrics", config)
+			So(body, ShouldEqual, expected)
+			So(err == nil, ShouldEqual, expected != "")
+		}
+
+		replayer := NewRecordingDownloader(&sequenceDownloader{bodies: []string{""}})
+		config = plugin.Config{"recording_mode": "replay", "recording_dir": dir}
+		for _, expected := range []string{"up 1\n", "", "up 0\n", "up 1\n"} {
+			body, err := read(replayer, "http://a:9100/metrics", config)
+			So(body, ShouldEqual, expected)
+			So(err == nil, ShouldEqual, expected != "")
+		}
+		So(live.calls, ShouldEqual, 3)
+
+		_, err := read(replayer, "http://b:9100/metrics", config)
+		So(err, ShouldNotBeNil)
+
+		Convey("and recording should continue an existing recording", func() {
+			recorder := NewRecordingDownloader(&sequenceDownloader{bodies: []string{"up 2\n"}})
+			_, err := read(recorder, "http://a:9100/metrics", plugin.Config{"recording_mode": "record", "recording_dir": dir})
+			So(err, ShouldBeNil)
+			scrapes, err := recordedScrapes(recordingTargetDir(config, "http://a:9100/metrics"))
+			So(err, ShouldBeNil)
+			So(scrapes, ShouldResemble, []string{"000000.prom", "000001.err", "000002.prom", "000003.prom"})
+		})
+	})
+
+	Convey("Recordings should be private and capped", t, func() {
+		capped := filepath.Join(dir, "capped")
+		recorder := NewRecordingDownloader(&sequenceDownloader{bodies: []string{"up 1\n"}})
+		config := plugin.Config{"recording_mode": "record", "recording_dir": capped, "recording_max_scrapes": int64(2)}
+		for i := 0; i < 3; i++ {
+			body, err := read(recorder, "http://a:9100/metrics", config)
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "up 1\n")
+		}
+		target := recordingTargetDir(config, "http://a:9100/metrics")
+		scrapes, err := recordedScrapes(target)
+		So(err, ShouldBeNil)
+		So(scrapes, ShouldResemble, []string{"000000.prom", "000001.prom"})
+
+		info, err := os.Stat(filepath.Join(target, scrapes[0]))
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+		info, err = os.Stat(target)
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0700))
+	})
+
+	Convey("Recording should need a directory", t, func() {
+		So(validateConfig(plugin.Config{"recording_mode": "replay"}), ShouldNotBeNil)
+	})
+}
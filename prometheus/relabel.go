@@ -0,0 +1,176 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// relabelConfig rewrites the tags of converted metrics like a Prometheus
+// relabel_config. The metric name reads and writes as __name__.
+type relabelConfig struct {
+	SourceLabels []string `json:"source_labels"`
+	Separator    *string  `json:"separator"`
+	Regex        *string  `json:"regex"`
+	TargetLabel  string   `json:"target_label"`
+	Replacement  *string  `json:"replacement"`
+	Action       string   `json:"action"`
+	separator    string
+	replacement  string
+	regex        *regexp.Regexp
+}
+
+// relabelActions are the supported actions:
+//
+// replace: sets target_label to replacement, expanded with the groups of
+// regex, when regex matches the joined source_labels
+//
+// keep, drop: keep or drop the metrics whose joined source_labels match
+// regex
+//
+// labelmap: copies the tags whose name matches regex to the name given by
+// replacement
+var relabelActions = map[string]bool{"replace": true, "keep": true, "drop": true, "labelmap": true}
+
+func compileRelabelConfigs(spec string) ([]relabelConfig, error) {
+	var configs []relabelConfig
+	if err := json.Unmarshal([]byte(spec), &configs); err != nil {
+		return nil, fmt.Errorf("relabel_configs must be a JSON list of relabel configs: %s", err.Error())
+	}
+	for i := range configs {
+		if err := configs[i].compile(); err != nil {
+			return nil, fmt.Errorf("Invalid relabel config %d: %s", i, err.Error())
+		}
+	}
+	return configs, nil
+}
+
+// compile applies the Prometheus defaults and compiles the regex
+func (r *relabelConfig) compile() error {
+	if r.Action == "" {
+		r.Action = "replace"
+	}
+	if !relabelActions[r.Action] {
+		return fmt.Errorf("action must be one of replace, keep, drop and labelmap")
+	}
+	r.separator = ";"
+	if r.Separator != nil {
+		r.separator = *r.Separator
+	}
+	r.replacement = "$1"
+	if r.Replacement != nil {
+		r.replacement = *r.Replacement
+	}
+	expr := "(.*)"
+	if r.Regex != nil {
+		expr = *r.Regex
+	}
+	regex, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return err
+	}
+	r.regex = regex
+
+	switch r.Action {
+	case "replace":
+		if r.TargetLabel == "" {
+			return fmt.Errorf("replace needs a target_label")
+		}
+	case "keep", "drop":
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("%s needs source_labels", r.Action)
+		}
+	}
+	return nil
+}
+
+// relabel applies the relabel configs in order to every metric and returns
+// the metrics kept
+func relabel(configs []relabelConfig, metrics []plugin.Metric) []plugin.Metric {
+	if len(configs) == 0 {
+		return metrics
+	}
+	kept := metrics[:0]
+	for _, metric := range metrics {
+		if relabelMetric(configs, &metric) {
+			kept = append(kept, metric)
+		}
+	}
+	return kept
+}
+
+// relabelMetric applies configs to metric and reports whether it is kept
+func relabelMetric(configs []relabelConfig, metric *plugin.Metric) bool {
+	for i := range configs {
+		r := &configs[i]
+		values := make([]string, len(r.SourceLabels))
+		for j, label := range r.SourceLabels {
+			values[j] = relabelGet(metric, label)
+		}
+		value := strings.Join(values, r.separator)
+
+		switch r.Action {
+		case "keep":
+			if !r.regex.MatchString(value) {
+				return false
+			}
+		case "drop":
+			if r.regex.MatchString(value) {
+				return false
+			}
+		case "replace":
+			match := r.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			target := string(r.regex.ExpandString(nil, r.TargetLabel, value, match))
+			if target == "" {
+				continue
+			}
+			relabelSet(metric, target, string(r.regex.ExpandString(nil, r.replacement, value, match)))
+		case "labelmap":
+			mapped := make(map[string]string)
+			for name, tagValue := range metric.Tags {
+				if match := r.regex.FindStringSubmatchIndex(name); match != nil {
+					mapped[string(r.regex.ExpandString(nil, r.replacement, name, match))] = tagValue
+				}
+			}
+			for name, tagValue := range mapped {
+				relabelSet(metric, name, tagValue)
+			}
+		}
+	}
+	return true
+}
+
+func relabelGet(metric *plugin.Metric, label string) string {
+	if label == metricNameSource {
+		return metricName(*metric)
+	}
+	return metric.Tags[label]
+}
+
+// relabelSet sets a tag, removing it when value is empty like Prometheus
+// does with labels, or renames the metric when label is __name__
+func relabelSet(metric *plugin.Metric, label, value string) {
+	if label == metricNameSource {
+		if value == "" {
+			return
+		}
+		namespace := append(plugin.Namespace{}, metric.Namespace...)
+		namespace[len(namespace)-1].Value = value
+		metric.Namespace = namespace
+		return
+	}
+	if value == "" {
+		delete(metric.Tags, label)
+		return
+	}
+	if metric.Tags == nil {
+		metric.Tags = make(map[string]string)
+	}
+	metric.Tags[label] = value
+}
@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRelabel(t *testing.T) {
+	newMetrics := func() []plugin.Metric {
+		return []plugin.Metric{
+			{
+				Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"),
+				Tags:      map[string]string{"pod": "api-7d9f8", "namespace": "shop", "__meta_zone": "eu-1"},
+			},
+			{
+				Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "go_goroutines"),
+				Tags:      map[string]string{"pod": "db", "namespace": "kube-system"},
+			},
+		}
+	}
+	run := func(spec string) []plugin.Metric {
+		configs, err := compileRelabelConfigs(spec)
+		So(err, ShouldBeNil)
+		return relabel(configs, newMetrics())
+	}
+
+	Convey("replace should set the target label from the regex groups", t, func() {
+		metrics := run(`[{"source_labels": ["namespace", "pod"], "regex": "(.+);(.+)-[a-z0-9]+", "target_label": "app", "replacement": "$1/$2"}]`)
+		So(metrics[0].Tags["app"], ShouldEqual, "shop/api")
+		So(metrics[1].Tags, ShouldNotContainKey, "app")
+	})
+
+	Convey("replace should remove a tag set to an empty value and rename __name__", t, func() {
+		metrics := run(`[
+			{"target_label": "pod", "replacement": ""},
+			{"source_labels": ["__name__"], "regex": "http_(.*)", "target_label": "__name__", "replacement": "web_$1"}
+		]`)
+		So(metrics[0].Tags, ShouldNotContainKey, "pod")
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "web_requests_total"})
+		So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "go_goroutines"})
+	})
+
+	Convey("keep and drop should filter metrics", t, func() {
+		So(run(`[{"source_labels": ["namespace"], "regex": "kube-system", "action": "drop"}]`), ShouldHaveLength, 1)
+		kept := run(`[{"source_labels": ["__name__"], "regex": "go_.*", "action": "keep"}]`)
+		So(kept, ShouldHaveLength, 1)
+		So(metricName(kept[0]), ShouldEqual, "go_goroutines")
+	})
+
+	Convey("labelmap should copy matching tags to the replacement name", t, func() {
+		metrics := run(`[{"regex": "__meta_(.+)", "action": "labelmap"}]`)
+		So(metrics[0].Tags["zone"], ShouldEqual, "eu-1")
+		So(metrics[0].Tags["__meta_zone"], ShouldEqual, "eu-1")
+	})
+
+	Convey("Invalid relabel configs should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"relabel_configs": `[{"action": "hashmod"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"relabel_configs": `[{"source_labels": ["pod"]}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"relabel_configs": `[{"action": "keep"}]`}), ShouldNotBeNil)
+	})
+}
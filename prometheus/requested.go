@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// requestedNamespaces are the namespaces a task asked Snap for, nil when it
+// asked for every metric of the plugin
+type requestedNamespaces [][]string
+
+// newRequestedNamespaces returns the namespaces of mts. A metric type
+// without a namespace, the catalog's root, /hyperpilot/prometheus by
+// default, or the root followed by * requests everything, as tasks did
+// before requests were honored.
+func newRequestedNamespaces(mts []plugin.Metric) requestedNamespaces {
+	root, err := getNamespaceRoot(mts[0].Config)
+	if err != nil {
+		return nil
+	}
+	requested := make(requestedNamespaces, 0, len(mts))
+	for _, mt := range mts {
+		namespace := mt.Namespace.Strings()
+		if requestsEverything(root, namespace) {
+			return nil
+		}
+		requested = append(requested, namespace)
+	}
+	return requested
+}
+
+func requestsEverything(root, namespace []string) bool {
+	if len(namespace) > len(root)+1 {
+		return false
+	}
+	for i, element := range namespace {
+		if i == len(root) {
+			return element == "*"
+		}
+		if element != "*" && element != root[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matches tells whether namespace was requested. A * element matches any
+// element, and a trailing * every namespace below it like Snap's own
+// wildcards. The dynamic elements labels_in_namespace appends may be left
+// out of a request.
+func (r requestedNamespaces) matches(namespace plugin.Namespace) bool {
+	if r == nil {
+		return true
+	}
+	static := len(namespace)
+	for static > 0 && namespace[static-1].IsDynamic() {
+		static--
+	}
+	elements := namespace.Strings()
+	for _, request := range r {
+		if namespaceMatches(request, elements) || namespaceMatches(request, elements[:static]) {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceMatches(request, namespace []string) bool {
+	for i, element := range request {
+		if i >= len(namespace) {
+			return false
+		}
+		if element == "*" && i == len(request)-1 {
+			return true
+		}
+		if element != "*" && element != namespace[i] {
+			return false
+		}
+	}
+	return len(request) == len(namespace)
+}
+
+// filter returns the metrics of metrics that were requested
+func (r requestedNamespaces) filter(metrics []plugin.Metric) []plugin.Metric {
+	if r == nil {
+		return metrics
+	}
+	kept := metrics[:0]
+	for _, metric := range metrics {
+		if r.matches(metric.Namespace) {
+			kept = append(kept, metric)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestedNamespaces(t *testing.T) {
+	request := func(namespaces ...[]string) requestedNamespaces {
+		mts := make([]plugin.Metric, len(namespaces))
+		for i, namespace := range namespaces {
+			mts[i].Namespace = plugin.NewNamespace(namespace...)
+		}
+		return newRequestedNamespaces(mts)
+	}
+
+	Convey("Catalog and empty requests should ask for everything", t, func() {
+		So(request([]string{}), ShouldBeNil)
+		So(request([]string{"hyperpilot", "prometheus"}), ShouldBeNil)
+		So(request([]string{"hyperpilot", "prometheus", "*"}), ShouldBeNil)
+		So(request([]string{"hyperpilot", "prometheus", "up"}, []string{"hyperpilot", "*"}), ShouldBeNil)
+		So(request([]string{"hyperpilot", "prometheus", "up"}), ShouldNotBeNil)
+	})
+
+	Convey("Requested families should be the only ones collected", t, func() {
+		requested := request(
+			[]string{"hyperpilot", "prometheus", "http_requests_total"},
+			[]string{"hyperpilot", "prometheus", "*", "go_goroutines"},
+			[]string{"hyperpilot", "prometheus", "payments", "*"},
+		)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total")), ShouldBeTrue)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "up")), ShouldBeFalse)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "istio", "go_goroutines")), ShouldBeTrue)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "istio", "up")), ShouldBeFalse)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "payments", "checkout", "up")), ShouldBeTrue)
+		So(requested.matches(plugin.NewNamespace("hyperpilot", "prometheus", "payments")), ShouldBeFalse)
+
+		withLabels := plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total").AddDynamicElement("code", "status code")
+		withLabels[3].Value = "200"
+		So(requested.matches(withLabels), ShouldBeTrue)
+	})
+
+	Convey("Collections should only return the requested families", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}}
+		metrics, err := collector.CollectMetrics([]plugin.Metric{{
+			Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "go_goroutines"),
+			Config:    plugin.Config{},
+		}})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+		So(metricName(metrics[0]), ShouldEqual, "go_goroutines")
+	})
+}
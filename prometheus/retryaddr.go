@@ -0,0 +1,134 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// addressFailureThreshold is how many consecutive failed dials open the
+	// circuit of an address
+	addressFailureThreshold = 3
+	// addressCooldown is how long an open address is only dialed when every
+	// other address of its host is open too
+	addressCooldown = 30 * time.Second
+)
+
+// lookupIPAddr resolves the hosts retryingDialer dials
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// addressHealth counts the consecutive dial failures of every address, a
+// circuit breaker per IP shared by the scrapes of a client
+type addressHealth struct {
+	mutex     sync.Mutex
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+func newAddressHealth() *addressHealth {
+	return &addressHealth{
+		failures:  make(map[string]int),
+		openUntil: make(map[string]time.Time),
+	}
+}
+
+func (h *addressHealth) record(ip string, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if err == nil {
+		delete(h.failures, ip)
+		delete(h.openUntil, ip)
+		return
+	}
+	h.failures[ip]++
+	if h.failures[ip] >= addressFailureThreshold {
+		h.openUntil[ip] = time.Now().Add(addressCooldown)
+	}
+}
+
+// order sorts ips so the addresses with a closed circuit come first, keeping
+// the order of ips otherwise
+func (h *addressHealth) order(ips []string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	now := time.Now()
+	sort.SliceStable(ips, func(i, j int) bool {
+		return !now.Before(h.openUntil[ips[i]]) && now.Before(h.openUntil[ips[j]])
+	})
+}
+
+// preferFamily orders or filters ips following ip_family, like the family
+// dialer does for the addresses Go resolves itself
+func preferFamily(ips []string, family string) []string {
+	networks := ipFamilies[family]
+	isFamily := func(ip, network string) bool {
+		v4 := net.ParseIP(ip).To4() != nil
+		return (network == "tcp4") == v4
+	}
+	if networks[0] == "tcp" || networks[0] == "" {
+		return ips
+	}
+	ordered := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if isFamily(ip, networks[0]) {
+			ordered = append(ordered, ip)
+		}
+	}
+	if networks[1] != "" {
+		for _, ip := range ips {
+			if isFamily(ip, networks[1]) {
+				ordered = append(ordered, ip)
+			}
+		}
+	}
+	return ordered
+}
+
+// retryingDialer dials the addresses of a host resolving to several of them
+// one after the other until one connects, each with the dialer's full
+// timeout, starting with the addresses whose circuit is closed. Hosts with a
+// single address and IP literals are dialed by fallback as before.
+func retryingDialer(dialer *net.Dialer, family string, health *addressHealth,
+	fallback func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return fallback(ctx, network, addr)
+		}
+		resolved, err := lookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]string, 0, len(resolved))
+		for _, ip := range resolved {
+			ips = append(ips, ip.IP.String())
+		}
+		ips = preferFamily(ips, family)
+		if len(ips) < 2 {
+			return fallback(ctx, network, addr)
+		}
+
+		health.order(ips)
+		var firstErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+			health.record(ip, err)
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			glog.V(2).Infof("Unable to dial %s of %s, trying its next address: %s", ip, host, err.Error())
+		}
+		return nil, firstErr
+	}
+}
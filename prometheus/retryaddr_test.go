@@ -0,0 +1,67 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryingDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// 127.0.0.2 has no listener on the port and refuses connections
+	defer func(lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	dialer := &net.Dialer{Timeout: time.Second}
+
+	Convey("The next address should be dialed when the first fails", t, func() {
+		health := newAddressHealth()
+		dial := retryingDialer(dialer, "any", health, dialer.DialContext)
+		conn, err := dial(context.Background(), "tcp", net.JoinHostPort("target.example", port))
+		So(err, ShouldBeNil)
+		So(conn.RemoteAddr().String(), ShouldEqual, net.JoinHostPort("127.0.0.1", port))
+		conn.Close()
+		So(health.failures["127.0.0.2"], ShouldEqual, 1)
+	})
+
+	Convey("Addresses failing repeatedly should be dialed last", t, func() {
+		health := newAddressHealth()
+		for i := 0; i < addressFailureThreshold; i++ {
+			health.record("127.0.0.2", net.UnknownNetworkError("refused"))
+		}
+		ips := []string{"127.0.0.2", "127.0.0.1"}
+		health.order(ips)
+		So(ips, ShouldResemble, []string{"127.0.0.1", "127.0.0.2"})
+
+		health.record("127.0.0.2", nil)
+		ips = []string{"127.0.0.2", "127.0.0.1"}
+		health.order(ips)
+		So(ips, ShouldResemble, []string{"127.0.0.2", "127.0.0.1"})
+	})
+
+	Convey("Addresses should follow ip_family", t, func() {
+		ips := []string{"2001:db8::1", "10.0.0.1", "10.0.0.2"}
+		So(preferFamily(ips, "any"), ShouldResemble, ips)
+		So(preferFamily(ips, "ipv6"), ShouldResemble, []string{"2001:db8::1"})
+		So(preferFamily(ips, "prefer_ipv4"), ShouldResemble, []string{"10.0.0.1", "10.0.0.2", "2001:db8::1"})
+	})
+}
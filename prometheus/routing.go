@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// routingRule tags the metrics whose name matches an anchored regex with a
+// route, a hint downstream Snap processors and publishers filter on to send
+// families to different sinks
+type routingRule struct {
+	Metric string `json:"metric"`
+	Route  string `json:"route"`
+	regex  *regexp.Regexp
+}
+
+func compileRoutingRules(spec string) ([]routingRule, error) {
+	var rules []routingRule
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		return nil, fmt.Errorf("routing_rules must be a JSON list of rules: %s", err.Error())
+	}
+	for i := range rules {
+		if rules[i].Route == "" {
+			return nil, fmt.Errorf("Invalid routing rule %d: route must be set", i)
+		}
+		regex, err := regexp.Compile("^(?:" + rules[i].Metric + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid routing rule %d: %s", i, err.Error())
+		}
+		rules[i].regex = regex
+	}
+	return rules, nil
+}
+
+// route tags every metric with the route of the first routing rule matching
+// its name, or the default route when none does
+func (r *conversionRules) route(metrics []plugin.Metric) {
+	if len(r.routingRules) == 0 && r.defaultRoute == "" {
+		return
+	}
+	for i := range metrics {
+		route := r.defaultRoute
+		name := metricName(metrics[i])
+		for _, rule := range r.routingRules {
+			if rule.regex.MatchString(name) {
+				route = rule.Route
+				break
+			}
+		}
+		if route == "" {
+			continue
+		}
+		if metrics[i].Tags == nil {
+			metrics[i].Tags = map[string]string{}
+		}
+		metrics[i].Tags[r.routeTag] = route
+	}
+}
@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouting(t *testing.T) {
+	metric := func(name string) plugin.Metric {
+		return plugin.Metric{Namespace: plugin.NewNamespace("hyperpilot", "prometheus", name)}
+	}
+
+	Convey("Metrics should be tagged with the route of the first matching rule", t, func() {
+		rules, err := compileConversionRules(plugin.Config{
+			"routing_rules": `[{"metric": "http_.*", "route": "kafka"}, {"metric": "http_requests_total|go_.*", "route": "influx"}]`,
+			"default_route": "archive",
+		})
+		So(err, ShouldBeNil)
+
+		metrics := []plugin.Metric{metric("http_requests_total"), metric("go_goroutines"), metric("up")}
+		rules.route(metrics)
+		So(metrics[0].Tags["route"], ShouldEqual, "kafka")
+		So(metrics[1].Tags["route"], ShouldEqual, "influx")
+		So(metrics[2].Tags["route"], ShouldEqual, "archive")
+	})
+
+	Convey("Metrics should be left untagged without routing rules", t, func() {
+		rules, err := compileConversionRules(plugin.Config{})
+		So(err, ShouldBeNil)
+		metrics := []plugin.Metric{metric("up")}
+		rules.route(metrics)
+		So(metrics[0].Tags, ShouldBeNil)
+	})
+
+	Convey("Invalid routing rules should be rejected", t, func() {
+		_, err := compileConversionRules(plugin.Config{"routing_rules": `[{"metric": "http_.*"}]`})
+		So(err, ShouldNotBeNil)
+		_, err = compileConversionRules(plugin.Config{"routing_rules": `[{"metric": "(", "route": "kafka"}]`})
+		So(err, ShouldNotBeNil)
+		_, err = compileConversionRules(plugin.Config{"default_route": "archive", "routing_tag": ""})
+		So(err, ShouldNotBeNil)
+	})
+}
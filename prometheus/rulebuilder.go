@@ -0,0 +1,198 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// RelabelRule is a relabel_configs entry built from code, for tools
+// generating task manifests. The zero values of the pointer fields take the
+// Prometheus defaults: separator ;, regex (.*) and replacement $1.
+type RelabelRule struct {
+	SourceLabels []string `json:"source_labels,omitempty"`
+	Separator    *string  `json:"separator,omitempty"`
+	Regex        *string  `json:"regex,omitempty"`
+	TargetLabel  string   `json:"target_label,omitempty"`
+	Replacement  *string  `json:"replacement,omitempty"`
+	Action       string   `json:"action,omitempty"`
+}
+
+// Replace returns a rule setting target to replacement, expanded with the
+// groups of the regex, (.*) unless WithRegex sets one, matching the joined
+// sourceLabels. The metric name is __name__.
+func Replace(target, replacement string, sourceLabels ...string) RelabelRule {
+	return RelabelRule{SourceLabels: sourceLabels, TargetLabel: target, Replacement: &replacement, Action: "replace"}
+}
+
+// Keep returns a rule keeping only the metrics whose joined sourceLabels
+// match regex
+func Keep(regex string, sourceLabels ...string) RelabelRule {
+	return RelabelRule{SourceLabels: sourceLabels, Regex: &regex, Action: "keep"}
+}
+
+// Drop returns a rule dropping the metrics whose joined sourceLabels match
+// regex
+func Drop(regex string, sourceLabels ...string) RelabelRule {
+	return RelabelRule{SourceLabels: sourceLabels, Regex: &regex, Action: "drop"}
+}
+
+// LabelMap returns a rule copying the tags whose name matches regex to the
+// name given by replacement, e.g. LabelMap("label_(.+)", "$1")
+func LabelMap(regex, replacement string) RelabelRule {
+	return RelabelRule{Regex: &regex, Replacement: &replacement, Action: "labelmap"}
+}
+
+// WithRegex returns the rule with its regex set
+func (r RelabelRule) WithRegex(regex string) RelabelRule {
+	r.Regex = &regex
+	return r
+}
+
+// WithSeparator returns the rule with the separator its source labels are
+// joined with set
+func (r RelabelRule) WithSeparator(separator string) RelabelRule {
+	r.Separator = &separator
+	return r
+}
+
+func (r RelabelRule) compile() (relabelConfig, error) {
+	config := relabelConfig{
+		SourceLabels: r.SourceLabels,
+		Separator:    r.Separator,
+		Regex:        r.Regex,
+		TargetLabel:  r.TargetLabel,
+		Replacement:  r.Replacement,
+		Action:       r.Action,
+	}
+	return config, config.compile()
+}
+
+// Validate checks a rule like the plugin checks relabel_configs
+func (r RelabelRule) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+// RelabelRules are the relabel_configs of a task, applied in order
+type RelabelRules []RelabelRule
+
+// ParseRelabelRules parses relabel_configs as a task config holds them
+func ParseRelabelRules(spec string) (RelabelRules, error) {
+	var rules RelabelRules
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		return nil, fmt.Errorf("relabel_configs must be a JSON list of relabel configs: %s", err.Error())
+	}
+	return rules, rules.Validate()
+}
+
+// Validate checks every rule like the plugin checks relabel_configs
+func (r RelabelRules) Validate() error {
+	for i, rule := range r {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("Invalid relabel config %d: %s", i, err.Error())
+		}
+	}
+	return nil
+}
+
+// String returns the rules as the relabel_configs JSON of a task config
+func (r RelabelRules) String() string {
+	if len(r) == 0 {
+		return ""
+	}
+	spec, _ := json.Marshal(r)
+	return string(spec)
+}
+
+// Apply validates the rules and sets them as the relabel_configs of config
+func (r RelabelRules) Apply(config plugin.Config) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	config["relabel_configs"] = r.String()
+	return nil
+}
+
+// Filters are the family and series filters of a task, run on scrapes
+// before they are converted. Empty fields filter nothing.
+type Filters struct {
+	// IncludeMetrics and ExcludeMetrics are anchored regexes of family names
+	IncludeMetrics string
+	ExcludeMetrics string
+	// KeepLabelMatchers and DropLabelMatchers map label names to anchored
+	// regexes a series must match every one of
+	KeepLabelMatchers map[string]string
+	DropLabelMatchers map[string]string
+}
+
+// ParseFilters returns the filters a task config sets
+func ParseFilters(config plugin.Config) (Filters, error) {
+	filters := Filters{
+		IncludeMetrics: getStringConfig(config, "include_metrics"),
+		ExcludeMetrics: getStringConfig(config, "exclude_metrics"),
+	}
+	for key, matchers := range map[string]*map[string]string{
+		"keep_label_matchers": &filters.KeepLabelMatchers,
+		"drop_label_matchers": &filters.DropLabelMatchers,
+	} {
+		if spec := getStringConfig(config, key); spec != "" {
+			if err := json.Unmarshal([]byte(spec), matchers); err != nil {
+				return filters, fmt.Errorf("%s must be a JSON object of label name to regex: %s", key, err.Error())
+			}
+		}
+	}
+	return filters, filters.Validate()
+}
+
+// config returns the filters as task config values
+func (f Filters) config() map[string]string {
+	values := map[string]string{
+		"include_metrics":     f.IncludeMetrics,
+		"exclude_metrics":     f.ExcludeMetrics,
+		"keep_label_matchers": "",
+		"drop_label_matchers": "",
+	}
+	if len(f.KeepLabelMatchers) > 0 {
+		spec, _ := json.Marshal(f.KeepLabelMatchers)
+		values["keep_label_matchers"] = string(spec)
+	}
+	if len(f.DropLabelMatchers) > 0 {
+		spec, _ := json.Marshal(f.DropLabelMatchers)
+		values["drop_label_matchers"] = string(spec)
+	}
+	return values
+}
+
+// Validate checks the filters like the plugin checks them
+func (f Filters) Validate() error {
+	values := f.config()
+	for _, key := range []string{"include_metrics", "exclude_metrics"} {
+		if _, err := compileMetricFilter(key, values[key]); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"keep_label_matchers", "drop_label_matchers"} {
+		if _, err := compileLabelMatchers(key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply validates the filters and sets them in config, removing the filters
+// left empty
+func (f Filters) Apply(config plugin.Config) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	for key, value := range f.config() {
+		if value == "" {
+			delete(config, key)
+			continue
+		}
+		config[key] = value
+	}
+	return nil
+}
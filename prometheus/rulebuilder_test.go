@@ -0,0 +1,54 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRuleBuilders(t *testing.T) {
+	Convey("Built relabel rules should round trip through the task config", t, func() {
+		rules := RelabelRules{
+			Drop("node_filesystem_.*;tmpfs", "__name__", "fstype"),
+			Replace("cluster", "${1}", "instance").WithRegex("([^.]+)\\..*"),
+			LabelMap("label_(.+)", "kube_label_$1"),
+		}
+		config := plugin.Config{}
+		So(rules.Apply(config), ShouldBeNil)
+		So(validateConfig(config), ShouldBeNil)
+
+		parsed, err := ParseRelabelRules(config["relabel_configs"].(string))
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, rules)
+
+		compiled, err := compileRelabelConfigs(config["relabel_configs"].(string))
+		So(err, ShouldBeNil)
+		So(compiled, ShouldHaveLength, 3)
+	})
+
+	Convey("Invalid relabel rules should fail validation", t, func() {
+		So(Keep("(", "job").Validate(), ShouldNotBeNil)
+		So(Drop(".*").Validate(), ShouldNotBeNil)
+		So(RelabelRules{{Action: "hashmod"}}.Apply(plugin.Config{}), ShouldNotBeNil)
+		_, err := ParseRelabelRules(`{"action": "drop"}`)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Filters should round trip through the task config", t, func() {
+		filters := Filters{
+			IncludeMetrics:    "go_.*",
+			DropLabelMatchers: map[string]string{"namespace": "kube-system"},
+		}
+		config := plugin.Config{"exclude_metrics": "up"}
+		So(filters.Apply(config), ShouldBeNil)
+		So(config, ShouldNotContainKey, "exclude_metrics")
+		So(validateConfig(config), ShouldBeNil)
+
+		parsed, err := ParseFilters(config)
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, filters)
+
+		So(Filters{KeepLabelMatchers: map[string]string{"job": "("}}.Validate(), ShouldNotBeNil)
+	})
+}
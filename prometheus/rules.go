@@ -0,0 +1,322 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// conversionRules are the task's rules applied to converted metrics,
+// compiled once per distinct task config
+type conversionRules struct {
+	tagExtractions []tagExtraction
+	valueMappings  map[string][]valueMapping
+	alertRules     []alertRule
+	recordingRules []recordingRule
+	slos           []slo
+	// unitConversions are only set when the task converts units
+	unitConversions []unitConversion
+	routingRules    []routingRule
+	routeTag        string
+	defaultRoute    string
+	// includeMetrics and excludeMetrics filter scraped families by name, nil
+	// when unset
+	includeMetrics *regexp.Regexp
+	excludeMetrics *regexp.Regexp
+	// keepSeries and dropSeries filter scraped series by label, nil when
+	// unset
+	keepSeries labelMatchers
+	dropSeries labelMatchers
+	// relabelConfigs run last, on the tags the other rules produced
+	relabelConfigs []relabelConfig
+	// namespaceRoutes move metrics under a sub-prefix once every rule ran
+	namespaceRoutes []namespaceRoute
+	// anomalyFamilies are the families flagged for anomalies, nil when unset
+	anomalyFamilies *regexp.Regexp
+}
+
+// tagExtraction adds the named capture groups of an anchored regex matching
+// the value of the source tag, or the metric name when source is __name__,
+// as tags
+type tagExtraction struct {
+	Source string `json:"source"`
+	Regex  string `json:"regex"`
+	regex  *regexp.Regexp
+}
+
+// metricNameSource is the tag extraction source naming the metric name
+const metricNameSource = "__name__"
+
+// valueMapping replaces tag values matching an exact value, a numeric range
+// or a regex (anchored, $1 style references expand in the replacement)
+type valueMapping struct {
+	Equals *string   `json:"equals"`
+	Range  []float64 `json:"range"`
+	Regex  string    `json:"regex"`
+	Value  string    `json:"value"`
+	regex  *regexp.Regexp
+}
+
+func compileConversionRules(config plugin.Config) (*conversionRules, error) {
+	rules := &conversionRules{}
+
+	var err error
+	if rules.includeMetrics, err = compileMetricFilter("include_metrics", getStringConfig(config, "include_metrics")); err != nil {
+		return nil, err
+	}
+	if rules.excludeMetrics, err = compileMetricFilter("exclude_metrics", getStringConfig(config, "exclude_metrics")); err != nil {
+		return nil, err
+	}
+	if rules.anomalyFamilies, err = compileMetricFilter("anomaly_families", getStringConfig(config, "anomaly_families")); err != nil {
+		return nil, err
+	}
+	if rules.keepSeries, err = compileLabelMatchers("keep_label_matchers", getStringConfig(config, "keep_label_matchers")); err != nil {
+		return nil, err
+	}
+	if rules.dropSeries, err = compileLabelMatchers("drop_label_matchers", getStringConfig(config, "drop_label_matchers")); err != nil {
+		return nil, err
+	}
+
+	if extractions := getStringConfig(config, "tag_extractions"); extractions != "" {
+		if err := json.Unmarshal([]byte(extractions), &rules.tagExtractions); err != nil {
+			return nil, fmt.Errorf("tag_extractions must be a JSON list of extractions: %s", err.Error())
+		}
+		for i := range rules.tagExtractions {
+			if err := rules.tagExtractions[i].compile(); err != nil {
+				return nil, fmt.Errorf("Invalid tag extraction %d: %s", i, err.Error())
+			}
+		}
+	}
+
+	if mappings := getStringConfig(config, "value_mappings"); mappings != "" {
+		if err := json.Unmarshal([]byte(mappings), &rules.valueMappings); err != nil {
+			return nil, fmt.Errorf("value_mappings must be a JSON object of tag name to mapping list: %s", err.Error())
+		}
+		for tag, mappingList := range rules.valueMappings {
+			for i := range mappingList {
+				if err := mappingList[i].compile(); err != nil {
+					return nil, fmt.Errorf("Invalid value mapping of tag %s: %s", tag, err.Error())
+				}
+			}
+		}
+	}
+
+	if recordings := getStringConfig(config, "recording_rules"); recordings != "" {
+		var err error
+		if rules.recordingRules, err = compileRecordingRules(recordings); err != nil {
+			return nil, err
+		}
+	}
+
+	if getBoolConfig(config, "convert_units") {
+		var err error
+		if rules.unitConversions, err = compileUnitConversions(getStringConfig(config, "unit_mappings")); err != nil {
+			return nil, err
+		}
+	}
+
+	if slos := getStringConfig(config, "slos"); slos != "" {
+		var err error
+		if rules.slos, err = compileSLOs(slos); err != nil {
+			return nil, err
+		}
+	}
+
+	if routing := getStringConfig(config, "routing_rules"); routing != "" {
+		var err error
+		if rules.routingRules, err = compileRoutingRules(routing); err != nil {
+			return nil, err
+		}
+	}
+	rules.routeTag = getStringConfig(config, "routing_tag")
+	rules.defaultRoute = getStringConfig(config, "default_route")
+	if rules.routeTag == "" && (len(rules.routingRules) > 0 || rules.defaultRoute != "") {
+		return nil, fmt.Errorf("routing_tag must be set to route metrics")
+	}
+
+	if relabels := getStringConfig(config, "relabel_configs"); relabels != "" {
+		var err error
+		if rules.relabelConfigs, err = compileRelabelConfigs(relabels); err != nil {
+			return nil, err
+		}
+	}
+
+	if routes := getStringConfig(config, "namespace_routes"); routes != "" {
+		var err error
+		if rules.namespaceRoutes, err = compileNamespaceRoutes(routes); err != nil {
+			return nil, err
+		}
+	}
+
+	if alerts := getStringConfig(config, "alert_rules"); alerts != "" {
+		var err error
+		if rules.alertRules, err = compileAlertRules(alerts); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
+
+func (e *tagExtraction) compile() error {
+	if e.Source == "" {
+		return fmt.Errorf("source must be set")
+	}
+	regex, err := regexp.Compile("^(?:" + e.Regex + ")$")
+	if err != nil {
+		return err
+	}
+	named := false
+	for _, name := range regex.SubexpNames() {
+		if name != "" {
+			named = true
+		}
+	}
+	if !named {
+		return fmt.Errorf("regex must have a named capture group, e.g. (?P<queue>[^.]+)")
+	}
+	e.regex = regex
+	return nil
+}
+
+// extract adds the tags captured from metric to tags
+func (e *tagExtraction) extract(metric plugin.Metric) {
+	var value string
+	if e.Source == metricNameSource {
+		value = metric.Namespace[len(metric.Namespace)-1].Value
+	} else {
+		var ok bool
+		if value, ok = metric.Tags[e.Source]; !ok {
+			return
+		}
+	}
+
+	match := e.regex.FindStringSubmatch(value)
+	if match == nil {
+		return
+	}
+	for i, name := range e.regex.SubexpNames() {
+		if name != "" && match[i] != "" {
+			metric.Tags[name] = match[i]
+		}
+	}
+}
+
+func (m *valueMapping) compile() error {
+	conditions := 0
+	if m.Equals != nil {
+		conditions++
+	}
+	if m.Range != nil {
+		if len(m.Range) != 2 || m.Range[0] > m.Range[1] {
+			return fmt.Errorf("range must be [min, max]")
+		}
+		conditions++
+	}
+	if m.Regex != "" {
+		regex, err := regexp.Compile("^(?:" + m.Regex + ")$")
+		if err != nil {
+			return err
+		}
+		m.regex = regex
+		conditions++
+	}
+	if conditions != 1 {
+		return fmt.Errorf("exactly one of equals, range and regex must be set")
+	}
+	return nil
+}
+
+// mapValue returns the replacement of value, and whether the mapping matched
+func (m *valueMapping) mapValue(value string) (string, bool) {
+	switch {
+	case m.Equals != nil:
+		return m.Value, value == *m.Equals
+	case m.Range != nil:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < m.Range[0] || number > m.Range[1] {
+			return value, false
+		}
+		return m.Value, true
+	default:
+		match := m.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			return value, false
+		}
+		return string(m.regex.ExpandString(nil, m.Value, value, match)), true
+	}
+}
+
+// apply rewrites the tags of metrics in place and converts their units.
+// Extracted tags are added before value mappings run, so they can be mapped
+// as well.
+func (r *conversionRules) apply(metrics []plugin.Metric) []plugin.Metric {
+	for i := range metrics {
+		if len(r.unitConversions) > 0 {
+			convertUnit(r.unitConversions, &metrics[i])
+		}
+	}
+	for _, metric := range metrics {
+		for i := range r.tagExtractions {
+			r.tagExtractions[i].extract(metric)
+		}
+		for tag, mappingList := range r.valueMappings {
+			value, ok := metric.Tags[tag]
+			if !ok {
+				continue
+			}
+			for i := range mappingList {
+				if mapped, matched := mappingList[i].mapValue(value); matched {
+					metric.Tags[tag] = mapped
+					break
+				}
+			}
+		}
+	}
+	return relabel(r.relabelConfigs, metrics)
+}
+
+// rulesCache keeps the compiled conversion rules of every task config
+type rulesCache struct {
+	mutex sync.Mutex
+	rules map[string]*conversionRules
+}
+
+func newRulesCache() *rulesCache {
+	return &rulesCache{
+		rules: make(map[string]*conversionRules),
+	}
+}
+
+func (c *rulesCache) get(config plugin.Config) (*conversionRules, error) {
+	if c == nil {
+		return compileConversionRules(config)
+	}
+
+	key := configFingerprint(config)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if rules, ok := c.rules[key]; ok {
+		return rules, nil
+	}
+	rules, err := compileConversionRules(config)
+	if err != nil {
+		return nil, err
+	}
+	c.rules[key] = rules
+	return rules, nil
+}
+
+// size returns the number of task configs with compiled rules, for debug dumps
+func (c *rulesCache) size() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.rules)
+}
@@ -0,0 +1,70 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func metricWithTags(tags map[string]string) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"),
+		Tags:      tags,
+		Data:      1.0,
+	}
+}
+
+func TestValueMappings(t *testing.T) {
+	Convey("Value mappings should rewrite tag values", t, func() {
+		rules, err := compileConversionRules(plugin.Config{"value_mappings": `{
+			"code": [{"range": [200, 299], "value": "2xx"}, {"regex": "5..", "value": "5xx"}],
+			"handler": [{"regex": "/users/([^/]+)/orders", "value": "/users/:id/orders"}, {"equals": "/", "value": "root"}],
+			"method": [{"regex": "(get|post)", "value": "${1}_request"}]
+		}`})
+		So(err, ShouldBeNil)
+
+		metrics := rules.apply([]plugin.Metric{
+			metricWithTags(map[string]string{"code": "204", "handler": "/users/42/orders", "method": "get"}),
+			metricWithTags(map[string]string{"code": "503", "handler": "/", "method": "delete"}),
+			metricWithTags(map[string]string{"code": "404", "handler": "/users/42"}),
+		})
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"code": "2xx", "handler": "/users/:id/orders", "method": "get_request"})
+		So(metrics[1].Tags, ShouldResemble, map[string]string{"code": "5xx", "handler": "root", "method": "delete"})
+		So(metrics[2].Tags, ShouldResemble, map[string]string{"code": "404", "handler": "/users/42"})
+	})
+
+	Convey("Invalid value mappings should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"value_mappings": `{"code": [{"value": "2xx"}]}`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"value_mappings": `{"code": [{"range": [299, 200], "value": "2xx"}]}`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"value_mappings": `{"code": [{"regex": "(", "value": "2xx"}]}`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"value_mappings": `[]`}), ShouldNotBeNil)
+	})
+}
+
+func TestTagExtractions(t *testing.T) {
+	Convey("Tag extractions should add captured tags", t, func() {
+		rules, err := compileConversionRules(plugin.Config{
+			"tag_extractions": `[
+				{"source": "queue_name", "regex": "(?P<queue>[^.]+)\\.(?P<shard>\\d+)"},
+				{"source": "__name__", "regex": "(?P<subsystem>[^_]+)_.*"}
+			]`,
+			"value_mappings": `{"shard": [{"range": [0, 9], "value": "low"}]}`,
+		})
+		So(err, ShouldBeNil)
+
+		metrics := rules.apply([]plugin.Metric{
+			metricWithTags(map[string]string{"queue_name": "orders.3"}),
+			metricWithTags(map[string]string{"queue_name": "orders"}),
+		})
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"queue_name": "orders.3", "queue": "orders", "shard": "low", "subsystem": "http"})
+		So(metrics[1].Tags, ShouldResemble, map[string]string{"queue_name": "orders", "subsystem": "http"})
+	})
+
+	Convey("Invalid tag extractions should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"tag_extractions": `[{"regex": "(?P<queue>.*)"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"tag_extractions": `[{"source": "queue_name", "regex": "(.*)"}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"tag_extractions": `{}`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,119 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Resource ceilings of tasks running in safe mode
+const (
+	safeModeMaxBodySize    = 10 << 20
+	safeModeMaxSamples     = 50000
+	safeModeMaxLabels      = 30
+	safeModeMaxLabelLength = 1024
+	safeModeMaxNameLength  = 256
+)
+
+// privateNetworks are the only networks tasks in safe mode may scrape
+var privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+// safeModeFileOptions access local files, which tasks in safe mode may not
+var safeModeFileOptions = []string{"topology_file", "kube_token_file", "kube_ca_file", "gce_credentials_file", "spill_dir", "recording_dir", "targets_file", "targets_file_export", "ca_file", "cert_file", "key_file", "bearer_token_file", "audit_log", "admin_dump_dir"}
+
+// WithSafeMode runs every task in safe mode, whatever its safe_mode option,
+// for plugins shared by less-trusted task authors
+func WithSafeMode() Option {
+	return func(c *PrometheusCollector) {
+		c.safeMode = true
+	}
+}
+
+// privateOnlyControl is a dialer Control hook refusing connections to
+// addresses outside privateNetworks
+var privateOnlyControl = addressControl(addressPolicy{allowed: privateNetworks})
+
+// refuseRedirect refuses to follow redirects in safe mode, so a target
+// can't point the collector somewhere else
+func refuseRedirect(req *http.Request, via []*http.Request) error {
+	return fmt.Errorf("Refusing to follow redirect to %s in safe mode", req.URL)
+}
+
+// limitedBody fails reads past limit bytes instead of silently truncating
+// the body like io.LimitReader
+type limitedBody struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if remaining := b.limit - b.read; int64(len(p)) > remaining+1 {
+		p = p[:remaining+1]
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return 0, fmt.Errorf("Response body exceeds %d bytes", b.limit)
+	}
+	return n, err
+}
+
+// checkSafeModeLimits rejects scrapes exceeding the sample and label
+// ceilings of safe mode
+func checkSafeModeLimits(metricFamilies map[string]*dto.MetricFamily) error {
+	samples := 0
+	for name, metricFamily := range metricFamilies {
+		if len(name) > safeModeMaxNameLength {
+			return fmt.Errorf("Metric name %.32s... exceeds %d characters", name, safeModeMaxNameLength)
+		}
+		for _, metric := range metricFamily.GetMetric() {
+			samples++
+			if samples > safeModeMaxSamples {
+				return fmt.Errorf("Scrape exceeds %d samples", safeModeMaxSamples)
+			}
+			if len(metric.GetLabel()) > safeModeMaxLabels {
+				return fmt.Errorf("Series of %s exceeds %d labels", name, safeModeMaxLabels)
+			}
+			for _, label := range metric.GetLabel() {
+				if len(label.GetName()) > safeModeMaxLabelLength || len(label.GetValue()) > safeModeMaxLabelLength {
+					return fmt.Errorf("Label %.32s of %s exceeds %d characters", label.GetName(), name, safeModeMaxLabelLength)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkSafeMode(config plugin.Config) ([]string, error) {
+	if !getBoolConfig(config, "safe_mode") {
+		return nil, nil
+	}
+	for _, key := range safeModeFileOptions {
+		if getStringConfig(config, key) != "" {
+			return nil, fmt.Errorf("%s accesses local files and is not allowed in safe mode", key)
+		}
+	}
+	// Discovery backends and catch-up talk to servers of the task's choosing
+	// with the plugin's credentials, e.g. the service account token of
+	// kubernetes discovery, and file discovery reads the plugin config file
+	if backend := getStringConfig(config, "discovery"); backend != "" {
+		return nil, fmt.Errorf("%s discovery is not allowed in safe mode", backend)
+	}
+	if getStringConfig(config, "catchup_url") != "" {
+		return nil, fmt.Errorf("catchup_url is not allowed in safe mode")
+	}
+	if isKubeProxy(config) {
+		return nil, fmt.Errorf("scraping through the Kubernetes API server is not allowed in safe mode")
+	}
+	if getStringConfig(config, "proxy_url") != "" {
+		return nil, fmt.Errorf("proxy_url is not allowed in safe mode")
+	}
+	if getBoolConfig(config, "insecure_skip_verify") {
+		return nil, fmt.Errorf("insecure_skip_verify is not allowed in safe mode")
+	}
+	return nil, nil
+}
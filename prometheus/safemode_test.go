@@ -0,0 +1,123 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSafeMode(t *testing.T) {
+	Convey("Safe mode should only dial private addresses", t, func() {
+		So(privateOnlyControl("tcp", "10.1.2.3:9100", nil), ShouldBeNil)
+		So(privateOnlyControl("tcp", "[fd00::1]:9100", nil), ShouldBeNil)
+		So(privateOnlyControl("tcp", "169.254.169.254:80", nil), ShouldNotBeNil)
+		So(privateOnlyControl("tcp", "127.0.0.1:9100", nil), ShouldNotBeNil)
+		So(privateOnlyControl("tcp", "8.8.8.8:53", nil), ShouldNotBeNil)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "up 1\n")
+		}))
+		defer server.Close()
+
+		downloader := NewHTTPMetricsDownloader()
+		_, err := downloader.GetMetricsReader(server.URL, plugin.Config{"safe_mode": true})
+		So(err, ShouldNotBeNil)
+		_, err = downloader.GetMetricsReader(server.URL, plugin.Config{})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Safe mode should not follow redirects", t, func() {
+		client, err := newClient(clientOptions{safeMode: true, ipFamily: "any"})
+		So(err, ShouldBeNil)
+		So(client.client.CheckRedirect, ShouldNotBeNil)
+		So(client.transport.Proxy, ShouldBeNil)
+	})
+
+	Convey("Safe mode should cap the body size", t, func() {
+		body := &limitedBody{reader: strings.NewReader(strings.Repeat("x", 100)), limit: 100}
+		data, err := ioutil.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(data, ShouldHaveLength, 100)
+
+		body = &limitedBody{reader: strings.NewReader(strings.Repeat("x", 101)), limit: 100}
+		_, err = ioutil.ReadAll(body)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Safe mode should cap samples and labels", t, func() {
+		families, err := parseMetrics(strings.NewReader("up{a=\"1\"} 1\n"))
+		So(err, ShouldBeNil)
+		So(checkSafeModeLimits(families), ShouldBeNil)
+
+		labels := make([]string, safeModeMaxLabels+1)
+		for i := range labels {
+			labels[i] = fmt.Sprintf("l%d=\"v\"", i)
+		}
+		families, err = parseMetrics(strings.NewReader("up{" + strings.Join(labels, ",") + "} 1\n"))
+		So(err, ShouldBeNil)
+		So(checkSafeModeLimits(families), ShouldNotBeNil)
+
+		families, err = parseMetrics(strings.NewReader("up{a=\"" + strings.Repeat("x", safeModeMaxLabelLength+1) + "\"} 1\n"))
+		So(err, ShouldBeNil)
+		So(checkSafeModeLimits(families), ShouldNotBeNil)
+
+		var series []string
+		for i := 0; i <= safeModeMaxSamples; i++ {
+			series = append(series, fmt.Sprintf("up{i=\"%d\"} 1", i))
+		}
+		families, err = parseMetrics(strings.NewReader(strings.Join(series, "\n") + "\n"))
+		So(err, ShouldBeNil)
+		So(checkSafeModeLimits(families), ShouldNotBeNil)
+	})
+
+	Convey("Safe mode should refuse options reading local files", t, func() {
+		So(validateConfig(plugin.Config{"safe_mode": true, "topology_file": "/etc/passwd"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"safe_mode": true, "kube_pod": "web-0"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"safe_mode": true, "discovery": "file"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"safe_mode": true, "audit_log": "/etc/cron.d/job"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"topology_file": "/etc/topology.csv"}), ShouldBeNil)
+	})
+
+	Convey("Safe mode should refuse discovery and catch-up", t, func() {
+		So(validateConfig(plugin.Config{"safe_mode": true, "discovery": "kubernetes", "kube_apiserver": "https://10.0.0.1"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"safe_mode": true, "discovery": "nomad"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"safe_mode": true, "catchup_url": "http://10.0.0.2:9090"}), ShouldNotBeNil)
+	})
+
+	Convey("Safe mode enforced by the plugin should apply to every task", t, func() {
+		c := New(WithSafeMode()).(*PrometheusCollector)
+		So(getBoolConfig(c.enforce(plugin.Config{"safe_mode": false}), "safe_mode"), ShouldBeTrue)
+		So(New().(*PrometheusCollector).enforce(plugin.Config{}), ShouldResemble, plugin.Config{})
+
+		_, err := c.CollectMetrics([]plugin.Metric{{Config: plugin.Config{"safe_mode": false, "topology_file": "/etc/passwd"}}})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestPolicyClient(t *testing.T) {
+	Convey("Discovery and catch-up requests should honour the address policy", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "{}")
+		}))
+		defer server.Close()
+
+		client, err := newPolicyClient(plugin.Config{"denied_cidrs": "127.0.0.0/8"}, time.Second)
+		So(err, ShouldBeNil)
+		_, err = client.Get(server.URL)
+		So(err, ShouldNotBeNil)
+
+		client, err = newPolicyClient(plugin.Config{}, time.Second)
+		So(err, ShouldBeNil)
+		resp, err := client.Get(server.URL)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+	})
+}
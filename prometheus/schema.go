@@ -0,0 +1,129 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Schema versions describe how scraped families are mapped to metrics:
+//
+// 1: summary and histogram series share the family namespace, told apart by
+// a summary tag (quantile_50, sum, count) or a histogram tag (bucket, sum,
+// count)
+//
+// 2: like Prometheus, sums, counts and buckets are split into their own
+// <family>_sum, <family>_count and <family>_bucket namespaces, and summary
+// quantiles keep their exact value in a quantile tag
+const (
+	oldestSchema = int64(1)
+	latestSchema = int64(2)
+)
+
+// schemaPlan is which schemas a collection emits
+type schemaPlan struct {
+	// primary is the schema rules, trackers and alerts work on
+	primary int64
+	// parallel is the schema also emitted during a migration window, 0 when
+	// not migrating
+	parallel int64
+	// tagged stamps every metric with a schema tag
+	tagged bool
+}
+
+func getSchemaPlan(config plugin.Config, currentTime time.Time) (schemaPlan, error) {
+	plan := schemaPlan{
+		primary: getIntConfig(config, "schema"),
+		tagged:  getBoolConfig(config, "schema_tag"),
+	}
+	if plan.primary < oldestSchema || plan.primary > latestSchema {
+		return plan, fmt.Errorf("Unknown schema: %d", plan.primary)
+	}
+
+	from := getIntConfig(config, "schema_migration_from")
+	if from == 0 {
+		return plan, nil
+	}
+	if from < oldestSchema || from > latestSchema {
+		return plan, fmt.Errorf("Unknown schema_migration_from: %d", from)
+	}
+	until, err := time.Parse(time.RFC3339, getStringConfig(config, "schema_migration_until"))
+	if err != nil {
+		return plan, fmt.Errorf("Invalid schema_migration_until, expecting an RFC 3339 time: " + err.Error())
+	}
+	if from != plan.primary && currentTime.Before(until) {
+		plan.parallel = from
+		// Consumers need the tag to tell both schemas apart
+		plan.tagged = true
+	}
+	return plan, nil
+}
+
+// stampSchema tags metrics with schema when the plan asks for it
+func stampSchema(plan schemaPlan, schema int64, metrics []plugin.Metric) {
+	if !plan.tagged {
+		return
+	}
+	version := strconv.FormatInt(schema, 10)
+	for i := range metrics {
+		tags := make(map[string]string, len(metrics[i].Tags)+1)
+		for key, value := range metrics[i].Tags {
+			tags[key] = value
+		}
+		tags["schema"] = version
+		metrics[i].Tags = tags
+	}
+}
+
+// splitSummary converts a summary following schema 2
+func (c *PrometheusCollector) splitSummary(currentTime time.Time, prefix []string, metricFamily *dto.MetricFamily, metricItem *dto.Metric, targetTags map[string]string) []plugin.Metric {
+	summary := metricItem.GetSummary()
+	metrics := make([]plugin.Metric, 0, len(summary.GetQuantile())+2)
+	for _, quantile := range summary.GetQuantile() {
+		if math.IsNaN(quantile.GetValue()) && !c.nans.keepsNaNQuantiles() {
+			glog.Warningf("Skipping to write metric %s quantile %v as it's value is NaN", metricFamily.GetName(), quantile.GetQuantile())
+			continue
+		}
+		metric := c.createMetricFromFamily(currentTime, prefix, metricFamily)
+		metric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+		metric.Tags["quantile"] = formatBound(quantile.GetQuantile())
+		metric.Data = quantile.GetValue()
+		metrics = append(metrics, metric)
+	}
+	return append(metrics, c.splitSumAndCount(currentTime, prefix, metricFamily, metricItem, targetTags,
+		summary.GetSampleSum(), float64(summary.GetSampleCount()))...)
+}
+
+// splitHistogram converts a histogram following schema 2
+func (c *PrometheusCollector) splitHistogram(currentTime time.Time, prefix []string, metricFamily *dto.MetricFamily, metricItem *dto.Metric, targetTags map[string]string) []plugin.Metric {
+	buckets, sum, count := processHistogramMetric(metricItem)
+	metrics := make([]plugin.Metric, 0, len(buckets)+2)
+	for le, val := range buckets {
+		metric := c.createMetric(currentTime, prefix, metricFamily.GetName()+"_bucket", metricFamily.GetHelp())
+		metric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+		metric.Tags["le"] = le
+		metric.Data = val
+		metrics = append(metrics, metric)
+	}
+	return append(metrics, c.splitSumAndCount(currentTime, prefix, metricFamily, metricItem, targetTags, sum, count)...)
+}
+
+func (c *PrometheusCollector) splitSumAndCount(currentTime time.Time, prefix []string, metricFamily *dto.MetricFamily, metricItem *dto.Metric, targetTags map[string]string, sum, count float64) []plugin.Metric {
+	sumMetric := c.createMetric(currentTime, prefix, metricFamily.GetName()+"_sum", metricFamily.GetHelp())
+	sumMetric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+	sumMetric.Data = sum
+	countMetric := c.createMetric(currentTime, prefix, metricFamily.GetName()+"_count", metricFamily.GetHelp())
+	countMetric.Tags = c.getTagsOfMetric(metricItem, targetTags)
+	countMetric.Data = count
+	return []plugin.Metric{sumMetric, countMetric}
+}
+
+func checkSchema(config plugin.Config) ([]string, error) {
+	_, err := getSchemaPlan(config, time.Now())
+	return nil, err
+}
@@ -0,0 +1,89 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchema(t *testing.T) {
+	families, err := parseMetrics(strings.NewReader(HISTOGRAM_DATA + `
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.999"} 0.3
+rpc_duration_seconds_sum 12
+rpc_duration_seconds_count 40
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &PrometheusCollector{}
+
+	Convey("Schema 2 should split sums, counts and buckets into their own namespaces", t, func() {
+		metrics := collector.convertFamilies(time.Now(), namespacePrefix, target{}, families, 2)
+		names := make(map[string]int)
+		for _, metric := range metrics {
+			names[metricName(metric)]++
+			So(metric.Tags["summary"], ShouldBeEmpty)
+			So(metric.Tags["histogram"], ShouldBeEmpty)
+			if metricName(metric) == "rpc_duration_seconds" {
+				So(metric.Tags["quantile"], ShouldEqual, "0.999")
+			}
+		}
+		So(names, ShouldResemble, map[string]int{
+			"http_request_duration_milliseconds_bucket": 3,
+			"http_request_duration_milliseconds_sum":    1,
+			"http_request_duration_milliseconds_count":  1,
+			"rpc_duration_seconds":                      1,
+			"rpc_duration_seconds_sum":                  1,
+			"rpc_duration_seconds_count":                1,
+		})
+
+		Convey("and unit conversion should follow the split names", func() {
+			conversions, err := compileUnitConversions("")
+			So(err, ShouldBeNil)
+			for i := range metrics {
+				convertUnit(conversions, &metrics[i])
+				switch metricName(metrics[i]) {
+				case "http_request_duration_milliseconds_sum":
+					So(metrics[i].Data, ShouldAlmostEqual, 2.1)
+				case "http_request_duration_milliseconds_count":
+					So(metrics[i].Data, ShouldEqual, 8)
+				case "http_request_duration_milliseconds_bucket":
+					So([]string{"0.1", "0.5", "+Inf"}, ShouldContain, metrics[i].Tags["le"])
+				}
+			}
+		})
+	})
+
+	Convey("Both schemas should be emitted during a migration window", t, func() {
+		now := time.Now()
+		config := plugin.Config{
+			"schema":                 int64(2),
+			"schema_migration_from":  int64(1),
+			"schema_migration_until": now.Add(time.Hour).Format(time.RFC3339),
+		}
+		plan, err := getSchemaPlan(config, now)
+		So(err, ShouldBeNil)
+		So(plan, ShouldResemble, schemaPlan{primary: 2, parallel: 1, tagged: true})
+
+		plan, err = getSchemaPlan(config, now.Add(2*time.Hour))
+		So(err, ShouldBeNil)
+		So(plan, ShouldResemble, schemaPlan{primary: 2})
+
+		metrics := []plugin.Metric{{Tags: map[string]string{"job": "api"}}}
+		stampSchema(schemaPlan{primary: 2, tagged: true}, 2, metrics)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"job": "api", "schema": "2"})
+	})
+
+	Convey("Invalid schema settings should fail validation", t, func() {
+		_, err := checkSchema(plugin.Config{"schema": int64(3)})
+		So(err, ShouldNotBeNil)
+		_, err = checkSchema(plugin.Config{"schema_migration_from": int64(1)})
+		So(err, ShouldNotBeNil)
+		_, err = checkSchema(plugin.Config{})
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,302 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// scrapePool bounds the scrapes running at once across all tasks to
+// max_concurrent_scrapes. When it is saturated, waiting scrapes are started
+// round-robin across jobs rather than in arrival order, so a job with
+// hundreds of targets can't starve a small one.
+type scrapePool struct {
+	mutex   sync.Mutex
+	limit   int64
+	running int64
+	// queues holds the waiting scrapes of each job, and jobs the jobs with
+	// waiting scrapes in the order they are served
+	queues map[string][]chan struct{}
+	jobs   []string
+	next   int
+}
+
+func newScrapePool() *scrapePool {
+	return &scrapePool{
+		queues: make(map[string][]chan struct{}),
+	}
+}
+
+// scrapeJob is the key a task's scrapes are queued under: its job, or its
+// config for tasks without a job
+func scrapeJob(config plugin.Config) string {
+	if job := getStringConfig(config, "job"); job != "" {
+		return "job:" + job
+	}
+	return "config:" + configFingerprint(config)
+}
+
+// enqueue queues a scrape of job and returns the channel closed when it may
+// start. limit is the max_concurrent_scrapes of the task, the latest task's
+// value applies to the whole pool.
+func (p *scrapePool) enqueue(job string, limit int64) chan struct{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.limit = limit
+	ready := make(chan struct{})
+	if _, ok := p.queues[job]; !ok {
+		p.jobs = append(p.jobs, job)
+	}
+	p.queues[job] = append(p.queues[job], ready)
+	p.dispatch()
+	return ready
+}
+
+// acquire waits for a slot for a scrape of job and returns how long it
+// waited. Every acquire must be followed by a release.
+func (p *scrapePool) acquire(job string, limit int64) time.Duration {
+	if p == nil {
+		return 0
+	}
+	start := time.Now()
+	<-p.enqueue(job, limit)
+	return time.Since(start)
+}
+
+func (p *scrapePool) release() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.running--
+	p.dispatch()
+}
+
+// dispatch starts waiting scrapes while slots are free, taking one scrape
+// of each job in turn. It's called with the mutex held.
+func (p *scrapePool) dispatch() {
+	for len(p.jobs) > 0 && (p.limit <= 0 || p.running < p.limit) {
+		if p.next >= len(p.jobs) {
+			p.next = 0
+		}
+		job := p.jobs[p.next]
+		queue := p.queues[job]
+		close(queue[0])
+		p.running++
+		if len(queue) > 1 {
+			p.queues[job] = queue[1:]
+			p.next++
+			continue
+		}
+		// The job's queue is empty, the next job moves up to p.next
+		delete(p.queues, job)
+		p.jobs = append(p.jobs[:p.next], p.jobs[p.next+1:]...)
+	}
+}
+
+// scrapeResult is the outcome of scraping a target
+type scrapeResult struct {
+	metricFamilies map[string]*dto.MetricFamily
+	err            error
+	// duration is how long the scrape took, waiting for the pool excluded
+	duration time.Duration
+	// body is the downloaded body waiting in the parse queue
+	body io.Reader
+}
+
+// scrapeStats describe how scrapes queued during a collection
+type scrapeStats struct {
+	// queueWait is the longest time a scrape waited for the scrape pool
+	queueWait time.Duration
+	// parseQueueDepth is the most downloaded bodies that waited for a
+	// parser at once
+	parseQueueDepth int
+}
+
+func (s *scrapeStats) merge(other scrapeStats) {
+	if other.queueWait > s.queueWait {
+		s.queueWait = other.queueWait
+	}
+	if other.parseQueueDepth > s.parseQueueDepth {
+		s.parseQueueDepth = other.parseQueueDepth
+	}
+}
+
+// scrapeAll scrapes targets, scrape_concurrency of them at a time, each
+// through the scrape pool. It returns the results in target order and how
+// scrapes queued.
+//
+// With a parse_concurrency, downloading and parsing run in two pools: the
+// scrape workers only download, handing bodies to parse_concurrency parsers
+// through a queue of parse_queue_size bodies. A full queue blocks the
+// scrape workers, so bodies waiting for a parser are bounded.
+func (c *PrometheusCollector) scrapeAll(config plugin.Config, targets []target) ([]scrapeResult, scrapeStats) {
+	results := make([]scrapeResult, len(targets))
+	job := scrapeJob(config)
+	limit := getIntConfig(config, "max_concurrent_scrapes")
+	concurrency := getIntConfig(config, "scrape_concurrency")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	timeout, _ := getDurationConfig(config, "target_timeout")
+	waits := make([]time.Duration, len(targets))
+	parsers := getIntConfig(config, "parse_concurrency")
+	scrape := func(url string, config plugin.Config) (result scrapeResult) {
+		result.metricFamilies, result.err = c.Collect(url, config)
+		return result
+	}
+	if parsers > 0 {
+		scrape = func(url string, config plugin.Config) (result scrapeResult) {
+			result.body, result.err = c.download(url, config)
+			return result
+		}
+	}
+
+	var stats scrapeStats
+	var statsMutex sync.Mutex
+	queue := make(chan int, getIntConfig(config, "parse_queue_size"))
+	var parsing sync.WaitGroup
+	for parser := int64(0); parser < parsers; parser++ {
+		parsing.Add(1)
+		go func() {
+			defer parsing.Done()
+			for i := range queue {
+				start := time.Now()
+				results[i].metricFamilies, results[i].err = c.parse(targets[i].URL, config, results[i].body)
+				results[i].body = nil
+				results[i].duration += time.Since(start)
+			}
+		}()
+	}
+
+	// Workers take targets in order, so a task scraping one target at a time
+	// scrapes them in order
+	indexes := make(chan int, len(targets))
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	var wg sync.WaitGroup
+	for worker := int64(0); worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				waits[i] = c.pool.acquire(job, limit)
+				results[i] = c.scrapeTarget(targets[i].URL, config, timeout, scrape)
+				if parsers <= 0 || results[i].err != nil {
+					continue
+				}
+				queue <- i
+				statsMutex.Lock()
+				if depth := len(queue); depth > stats.parseQueueDepth {
+					stats.parseQueueDepth = depth
+				}
+				statsMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	close(queue)
+	parsing.Wait()
+
+	for _, wait := range waits {
+		if wait > stats.queueWait {
+			stats.queueWait = wait
+		}
+	}
+	return results, stats
+}
+
+// scrapeTarget runs scrape on url and releases the pool slot acquired for
+// it. With a target_timeout the scrape is given up on after it, so a custom
+// downloader or a huge body can't hold the worker, but the slot is only
+// released when the abandoned scrape returns.
+func (c *PrometheusCollector) scrapeTarget(url string, config plugin.Config, timeout time.Duration,
+	scrape func(url string, config plugin.Config) scrapeResult) scrapeResult {
+	start := time.Now()
+	done := make(chan scrapeResult, 1)
+	go func() {
+		defer c.pool.release()
+		result := scrape(url, config)
+		result.duration = time.Since(start)
+		done <- result
+	}()
+	if timeout <= 0 {
+		return <-done
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+		// A body downloaded too late is never parsed, and spilled ones
+		// must still be removed
+		go func() {
+			closeBody((<-done).body)
+		}()
+		return scrapeResult{err: fmt.Errorf("scrape timed out after %s", timeout), duration: timeout}
+	}
+}
+
+// closeBody closes body when it's an io.Closer, such as a spilled body
+func closeBody(body io.Reader) {
+	if closer, ok := body.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// scrapeErrors sums up the failed scrapes of a collection in one error, nil
+// when every target was scraped
+func scrapeErrors(targets []target, results []scrapeResult) error {
+	var failures []string
+	for i, result := range results {
+		if result.err != nil {
+			failures = append(failures, targets[i].URL+": "+result.err.Error())
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d targets failed: %s", len(failures), len(targets), strings.Join(failures, "; "))
+}
+
+// queueWaitMetric returns scrape_queue_wait_seconds, the longest time a
+// scrape of the collection waited for the scrape pool, when the task bounds
+// it with max_concurrent_scrapes
+func queueWaitMetric(config plugin.Config, prefix []string, currentTime time.Time, wait time.Duration) []plugin.Metric {
+	if getIntConfig(config, "max_concurrent_scrapes") <= 0 {
+		return nil
+	}
+	return []plugin.Metric{{
+		Namespace:   prefixedNamespace(prefix, "scrape_queue_wait_seconds"),
+		Timestamp:   currentTime,
+		Description: "longest time a scrape of the collection waited for max_concurrent_scrapes",
+		Version:     pluginVersion,
+		Unit:        "s",
+		Data:        wait.Seconds(),
+	}}
+}
+
+// parseQueueMetric returns parse_queue_depth, the most downloaded bodies of
+// the collection that waited for a parser at once, when the task parses in
+// its own pool
+func parseQueueMetric(config plugin.Config, prefix []string, currentTime time.Time, depth int) []plugin.Metric {
+	if getIntConfig(config, "parse_concurrency") <= 0 {
+		return nil
+	}
+	return []plugin.Metric{{
+		Namespace:   prefixedNamespace(prefix, "parse_queue_depth"),
+		Timestamp:   currentTime,
+		Description: "most downloaded bodies of the collection waiting for a parser at once",
+		Version:     pluginVersion,
+		Data:        int64(depth),
+	}}
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScrapePool(t *testing.T) {
+	closed := func(ready chan struct{}) bool {
+		select {
+		case <-ready:
+			return true
+		default:
+			return false
+		}
+	}
+
+	Convey("A saturated pool should serve jobs round-robin", t, func() {
+		pool := newScrapePool()
+		So(closed(pool.enqueue("big", 1)), ShouldBeTrue)
+
+		big := []chan struct{}{pool.enqueue("big", 1), pool.enqueue("big", 1), pool.enqueue("big", 1)}
+		small := pool.enqueue("small", 1)
+		So(closed(big[0]), ShouldBeFalse)
+
+		pool.release()
+		So(closed(big[0]), ShouldBeTrue)
+		So(closed(small), ShouldBeFalse)
+
+		pool.release()
+		So(closed(small), ShouldBeTrue)
+		So(closed(big[1]), ShouldBeFalse)
+
+		pool.release()
+		So(closed(big[1]), ShouldBeTrue)
+		pool.release()
+		So(closed(big[2]), ShouldBeTrue)
+	})
+
+	Convey("An unbounded pool should never make scrapes wait", t, func() {
+		pool := newScrapePool()
+		for i := 0; i < 5; i++ {
+			So(closed(pool.enqueue("big", 0)), ShouldBeTrue)
+		}
+	})
+
+	Convey("Queue waits should be reported when the pool is bounded", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}, pool: newScrapePool()}
+		config := plugin.Config{"scrape_concurrency": int64(3), "max_concurrent_scrapes": int64(1)}
+		results, _ := collector.scrapeAll(config, []target{{URL: "http://a:9100/metrics"}, {URL: "http://b:9100/metrics"}})
+		So(results, ShouldHaveLength, 2)
+		So(results[0].err, ShouldBeNil)
+		So(results[1].metricFamilies, ShouldContainKey, "go_goroutines")
+
+		metrics := queueWaitMetric(config, []string{"hyperpilot", "prometheus"}, time.Now(), time.Second)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "scrape_queue_wait_seconds"})
+		So(metrics[0].Data, ShouldEqual, 1)
+		So(queueWaitMetric(plugin.Config{}, nil, time.Now(), time.Second), ShouldBeEmpty)
+	})
+	Convey("Scrapes should be given up on after target_timeout", t, func() {
+		collector := &PrometheusCollector{Downloader: &slowDownloader{delay: time.Second}, pool: newScrapePool()}
+		config := plugin.Config{"scrape_concurrency": int64(2), "target_timeout": "50ms"}
+		targets := []target{{URL: "http://a:9100/metrics"}, {URL: "http://b:9100/metrics"}}
+		start := time.Now()
+		results, _ := collector.scrapeAll(config, targets)
+		So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+		So(results[0].err, ShouldNotBeNil)
+		So(results[1].err, ShouldNotBeNil)
+
+		err := scrapeErrors(targets, results)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "2 of 2 targets failed: http://a:9100/metrics: scrape timed out after 50ms")
+		So(scrapeErrors(targets, make([]scrapeResult, 2)), ShouldBeNil)
+	})
+}
+
+// slowDownloader serves the mock page after delay
+type slowDownloader struct {
+	MockMetricsDownloader
+	delay time.Duration
+}
+
+func (d *slowDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	time.Sleep(d.delay)
+	return d.MockMetricsDownloader.GetMetricsReader(url, config)
+}
+
+func TestParsePool(t *testing.T) {
+	Convey("Bodies should be parsed in a pool of their own with parse_concurrency", t, func() {
+		collector := &PrometheusCollector{Downloader: &MockMetricsDownloader{}, pool: newScrapePool()}
+		config := plugin.Config{"scrape_concurrency": int64(4), "parse_concurrency": int64(1), "parse_queue_size": int64(2)}
+		targets := make([]target, 6)
+		for i := range targets {
+			targets[i].URL = fmt.Sprintf("http://node-%d:9100/metrics", i)
+		}
+		results, stats := collector.scrapeAll(config, targets)
+		So(results, ShouldHaveLength, 6)
+		for _, result := range results {
+			So(result.err, ShouldBeNil)
+			So(result.body, ShouldBeNil)
+			So(result.metricFamilies, ShouldContainKey, "go_goroutines")
+		}
+		So(stats.parseQueueDepth, ShouldBeBetweenOrEqual, 0, 2)
+
+		metrics := parseQueueMetric(config, []string{"hyperpilot", "prometheus"}, time.Now(), stats.parseQueueDepth)
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "parse_queue_depth"})
+		So(parseQueueMetric(plugin.Config{}, nil, time.Now(), 1), ShouldBeEmpty)
+	})
+
+	Convey("Failed downloads should not be parsed", t, func() {
+		collector := &PrometheusCollector{Downloader: &slowDownloader{delay: time.Second}, pool: newScrapePool()}
+		config := plugin.Config{"parse_concurrency": int64(1), "target_timeout": "50ms"}
+		results, stats := collector.scrapeAll(config, []target{{URL: "http://a:9100/metrics"}})
+		So(results[0].err, ShouldNotBeNil)
+		So(stats.parseQueueDepth, ShouldEqual, 0)
+	})
+}
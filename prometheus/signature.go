@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// signatureVerifier checks the detached signature of a scraped body
+type signatureVerifier interface {
+	verify(body, signature []byte) bool
+}
+
+type hmacVerifier struct {
+	key []byte
+}
+
+func (v hmacVerifier) verify(body, signature []byte) bool {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+type ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+func (v ed25519Verifier) verify(body, signature []byte) bool {
+	return ed25519.Verify(v.key, body, signature)
+}
+
+// getSignatureVerifier returns the verifier configured by
+// signature_algorithm, or nil when signatures aren't verified. HMAC keys are
+// used as given; Ed25519 public keys are base64 encoded.
+func getSignatureVerifier(config plugin.Config) (signatureVerifier, error) {
+	algorithm := getStringConfig(config, "signature_algorithm")
+	key := getStringConfig(config, "signature_key")
+	if algorithm != "" && key == "" {
+		return nil, fmt.Errorf("signature_algorithm needs signature_key")
+	}
+
+	switch algorithm {
+	case "":
+		return nil, nil
+	case "hmac-sha256":
+		return hmacVerifier{key: []byte(key)}, nil
+	case "ed25519":
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid signature_key: " + err.Error())
+		}
+		if len(decoded) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Invalid signature_key: Ed25519 public keys are %d bytes", ed25519.PublicKeySize)
+		}
+		return ed25519Verifier{key: ed25519.PublicKey(decoded)}, nil
+	default:
+		return nil, fmt.Errorf("Unknown signature_algorithm: %s", algorithm)
+	}
+}
+
+// verifySignature reads body whole and checks it against the base64
+// signature in header, returning the body when it matches. The body is kept
+// in memory since the whole payload is needed before it can be trusted.
+func verifySignature(verifier signatureVerifier, header http.Header, name string, body io.Reader) (io.Reader, error) {
+	value := header.Get(name)
+	if value == "" {
+		return nil, fmt.Errorf("Missing signature header %s", name)
+	}
+	signature, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signature header %s: %v", name, err)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(body); err != nil {
+		return nil, err
+	}
+	if !verifier.verify(buf.Bytes(), signature) {
+		return nil, fmt.Errorf("Signature mismatch, rejecting payload")
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+func checkSignature(config plugin.Config) ([]string, error) {
+	_, err := getSignatureVerifier(config)
+	return nil, err
+}
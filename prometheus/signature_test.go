@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func signedHeader(signature []byte) http.Header {
+	header := http.Header{}
+	header.Set("X-Signature", base64.StdEncoding.EncodeToString(signature))
+	return header
+}
+
+func TestVerifySignature(t *testing.T) {
+	Convey("HMAC signed payloads should be verified", t, func() {
+		config := plugin.Config{"signature_algorithm": "hmac-sha256", "signature_key": "secret"}
+		verifier, err := getSignatureVerifier(config)
+		So(err, ShouldBeNil)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(TEST_DATA))
+		header := signedHeader(mac.Sum(nil))
+
+		reader, err := verifySignature(verifier, header, "X-Signature", strings.NewReader(TEST_DATA))
+		So(err, ShouldBeNil)
+		body, _ := ioutil.ReadAll(reader)
+		So(string(body), ShouldEqual, TEST_DATA)
+
+		_, err = verifySignature(verifier, header, "X-Signature", strings.NewReader(TEST_DATA+"tampered 1\n"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Ed25519 signed payloads should be verified", t, func() {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		So(err, ShouldBeNil)
+		config := plugin.Config{
+			"signature_algorithm": "ed25519",
+			"signature_key":       base64.StdEncoding.EncodeToString(public),
+		}
+		verifier, err := getSignatureVerifier(config)
+		So(err, ShouldBeNil)
+
+		header := signedHeader(ed25519.Sign(private, []byte(TEST_DATA)))
+		_, err = verifySignature(verifier, header, "X-Signature", strings.NewReader(TEST_DATA))
+		So(err, ShouldBeNil)
+		_, err = verifySignature(verifier, header, "X-Signature", strings.NewReader("tampered 1\n"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Unsigned payloads should be rejected", t, func() {
+		verifier := hmacVerifier{key: []byte("secret")}
+		_, err := verifySignature(verifier, http.Header{}, "X-Signature", strings.NewReader(TEST_DATA))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Invalid signature settings should fail validation", t, func() {
+		_, err := checkSignature(plugin.Config{"signature_algorithm": "hmac-sha256"})
+		So(err, ShouldNotBeNil)
+		_, err = checkSignature(plugin.Config{"signature_algorithm": "ed25519", "signature_key": "c2hvcnQ="})
+		So(err, ShouldNotBeNil)
+		_, err = checkSignature(plugin.Config{"signature_algorithm": "rsa", "signature_key": "x"})
+		So(err, ShouldNotBeNil)
+		_, err = checkSignature(plugin.Config{})
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,188 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// slo defines a service level objective as the ratio of good events to total
+// events, both counted by counters
+type slo struct {
+	Name      string         `json:"name"`
+	Good      seriesSelector `json:"good"`
+	Total     seriesSelector `json:"total"`
+	Objective float64        `json:"objective"`
+	Windows   []string       `json:"windows"`
+	windows   []time.Duration
+}
+
+// defaultSLOWindows are the windows of the multi-window burn rate alerts of
+// the SRE workbook
+var defaultSLOWindows = []string{"5m", "30m", "1h", "6h"}
+
+func compileSLOs(spec string) ([]slo, error) {
+	var slos []slo
+	if err := json.Unmarshal([]byte(spec), &slos); err != nil {
+		return nil, fmt.Errorf("slos must be a JSON list of objectives: %s", err.Error())
+	}
+	names := map[string]bool{}
+	for i := range slos {
+		s := &slos[i]
+		if s.Name == "" || s.Good.Metric == "" || s.Total.Metric == "" {
+			return nil, fmt.Errorf("Invalid SLO %d: name, good and total must be set", i)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("Invalid SLO %s: duplicate name", s.Name)
+		}
+		names[s.Name] = true
+		if s.Objective <= 0 || s.Objective >= 1 {
+			return nil, fmt.Errorf("Invalid SLO %s: objective must be between 0 and 1, e.g. 0.999", s.Name)
+		}
+		if len(s.Windows) == 0 {
+			s.Windows = defaultSLOWindows
+		}
+		for _, window := range s.Windows {
+			duration, err := time.ParseDuration(window)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("Invalid SLO %s: window %q must be a positive duration", s.Name, window)
+			}
+			s.windows = append(s.windows, duration)
+		}
+	}
+	return slos, nil
+}
+
+// sloPoint is the number of good and total events counted since an SLO was
+// first evaluated
+type sloPoint struct {
+	time  time.Time
+	good  float64
+	total float64
+}
+
+type sloState struct {
+	counters map[string]counterSample
+	history  map[string][]sloPoint
+}
+
+// sloTracker keeps the event counts of the SLOs of every task config over
+// their longest window
+type sloTracker struct {
+	mutex  sync.Mutex
+	states map[string]*sloState
+}
+
+func newSLOTracker() *sloTracker {
+	return &sloTracker{
+		states: make(map[string]*sloState),
+	}
+}
+
+// evaluate returns the slo_ratio and slo_burn_rate metrics of every SLO
+// window, tagged with slo and window. Windows longer than the time the SLO
+// has been evaluated cover that time instead.
+func (t *sloTracker) evaluate(config plugin.Config, slos []slo, prefix []string, currentTime time.Time, metrics []plugin.Metric) []plugin.Metric {
+	if len(slos) == 0 || t == nil {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, ok := t.states[key]
+	if !ok {
+		state = &sloState{counters: map[string]counterSample{}, history: map[string][]sloPoint{}}
+		t.states[key] = state
+	}
+	counters := make(map[string]counterSample)
+
+	ratioNamespace := prefixedNamespace(prefix, "slo_ratio")
+	burnRateNamespace := prefixedNamespace(prefix, "slo_burn_rate")
+	var results []plugin.Metric
+	for i := range slos {
+		s := &slos[i]
+		good, total := 0.0, 0.0
+		for _, metric := range metrics {
+			value, ok := metric.Data.(float64)
+			if !ok {
+				continue
+			}
+			if s.Good.matches(metric) {
+				if increase, _, ok := counterIncrease(state.counters, counters, s.Name+"\xfegood\xfe"+seriesKey(metric), value, currentTime); ok {
+					good += increase
+				}
+			}
+			if s.Total.matches(metric) {
+				if increase, _, ok := counterIncrease(state.counters, counters, s.Name+"\xfetotal\xfe"+seriesKey(metric), value, currentTime); ok {
+					total += increase
+				}
+			}
+		}
+
+		history := state.history[s.Name]
+		last := sloPoint{}
+		if len(history) > 0 {
+			last = history[len(history)-1]
+		}
+		current := sloPoint{time: currentTime, good: last.good + good, total: last.total + total}
+		history = pruneSLOHistory(append(history, current), currentTime, s.windows)
+		state.history[s.Name] = history
+
+		for j, window := range s.windows {
+			start := history[0]
+			for _, point := range history {
+				if point.time.After(currentTime.Add(-window)) {
+					break
+				}
+				start = point
+			}
+			events := current.total - start.total
+			if events <= 0 {
+				continue
+			}
+			ratio := (current.good - start.good) / events
+			tags := map[string]string{"slo": s.Name, "window": s.Windows[j]}
+			results = append(results, plugin.Metric{
+				Namespace:   ratioNamespace,
+				Timestamp:   currentTime,
+				Description: "ratio of good to total events over the window",
+				Version:     pluginVersion,
+				Tags:        tags,
+				Data:        ratio,
+			}, plugin.Metric{
+				Namespace:   burnRateNamespace,
+				Timestamp:   currentTime,
+				Description: "rate the error budget is spent at over the window, 1 spends it exactly over the SLO period",
+				Version:     pluginVersion,
+				Tags:        map[string]string{"slo": s.Name, "window": s.Windows[j]},
+				Data:        (1 - ratio) / (1 - s.Objective),
+			})
+		}
+	}
+
+	state.counters = counters
+	return results
+}
+
+// pruneSLOHistory drops the points no window starts at anymore, keeping the
+// last point before the longest window
+func pruneSLOHistory(history []sloPoint, currentTime time.Time, windows []time.Duration) []sloPoint {
+	longest := time.Duration(0)
+	for _, window := range windows {
+		if window > longest {
+			longest = window
+		}
+	}
+	first := 0
+	for i, point := range history {
+		if point.time.After(currentTime.Add(-longest)) {
+			break
+		}
+		first = i
+	}
+	return history[first:]
+}
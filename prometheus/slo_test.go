@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func codeRequestsMetric(code string, value float64) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace("hyperpilot", "prometheus", "http_requests_total"),
+		Tags:      map[string]string{"code": code},
+		Data:      value,
+	}
+}
+
+func TestSLOs(t *testing.T) {
+	Convey("SLOs should emit ratios and burn rates per window", t, func() {
+		config := plugin.Config{"slos": `[{"name": "api", "good": {"metric": "http_requests_total", "match": {"code": "200"}}, "total": {"metric": "http_requests_total"}, "objective": 0.9, "windows": ["1m", "5m"]}]`}
+		rules, err := compileConversionRules(config)
+		So(err, ShouldBeNil)
+		tracker := newSLOTracker()
+		prefix := []string{"hyperpilot", "prometheus"}
+		start := time.Now()
+
+		evaluate := func(at time.Time, ok float64, failed float64) []plugin.Metric {
+			return tracker.evaluate(config, rules.slos, prefix, at, []plugin.Metric{codeRequestsMetric("200", ok), codeRequestsMetric("500", failed)})
+		}
+
+		So(evaluate(start, 0, 0), ShouldBeEmpty)
+		// 100 requests, all good
+		So(evaluate(start.Add(time.Minute), 100, 0), ShouldHaveLength, 4)
+		// 100 more requests, 20 failed
+		results := evaluate(start.Add(2*time.Minute), 180, 20)
+		So(results, ShouldHaveLength, 4)
+
+		So(results[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "slo_ratio"})
+		So(results[0].Tags, ShouldResemble, map[string]string{"slo": "api", "window": "1m"})
+		So(results[0].Data, ShouldAlmostEqual, 0.8)
+		So(results[1].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "slo_burn_rate"})
+		So(results[1].Data, ShouldAlmostEqual, 2.0)
+
+		So(results[2].Tags, ShouldResemble, map[string]string{"slo": "api", "window": "5m"})
+		So(results[2].Data, ShouldAlmostEqual, 0.9)
+		So(results[3].Data, ShouldAlmostEqual, 1.0)
+	})
+
+	Convey("SLO history should be pruned to the longest window", t, func() {
+		start := time.Now()
+		history := []sloPoint{{time: start}, {time: start.Add(time.Minute)}, {time: start.Add(2 * time.Minute)}, {time: start.Add(3 * time.Minute)}}
+		So(pruneSLOHistory(history, start.Add(3*time.Minute), []time.Duration{time.Minute, 90 * time.Second}), ShouldResemble, history[1:])
+	})
+
+	Convey("Invalid SLOs should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"slos": `[{"name": "api", "good": {"metric": "ok"}, "total": {"metric": "all"}, "objective": 99.9}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"slos": `[{"name": "api", "good": {"metric": "ok"}, "objective": 0.999}]`}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"slos": `[{"name": "api", "good": {"metric": "ok"}, "total": {"metric": "all"}, "objective": 0.999, "windows": ["1x"]}]`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,96 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// statusCodes are the HTTP status codes a scrape succeeds with, either
+// exact codes or classes such as 2xx
+type statusCodes struct {
+	codes   map[int]bool
+	classes map[int]bool
+}
+
+// parseStatusCodes parses a comma separated list of status codes and
+// classes, e.g. 200,203,206 or 2xx
+func parseStatusCodes(spec string) (statusCodes, error) {
+	codes := statusCodes{codes: map[int]bool{}, classes: map[int]bool{}}
+	for _, entry := range splitList(spec) {
+		if len(entry) == 3 && strings.HasSuffix(strings.ToLower(entry), "xx") && entry[0] >= '1' && entry[0] <= '5' {
+			codes.classes[int(entry[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(entry)
+		if err != nil || code < 100 || code > 599 {
+			return codes, fmt.Errorf("Invalid status code %q: must be a code such as 203 or a class such as 2xx", entry)
+		}
+		codes.codes[code] = true
+	}
+	if len(codes.codes) == 0 && len(codes.classes) == 0 {
+		return codes, fmt.Errorf("no status code given")
+	}
+	return codes, nil
+}
+
+func (s statusCodes) accepts(code int) bool {
+	return s.codes[code] || s.classes[code/100]
+}
+
+// getSuccessStatusCodes returns the status codes a scrape of url succeeds
+// with: those target_success_status_codes lists for the URL, or for its
+// host:port, otherwise success_status_codes
+func getSuccessStatusCodes(config plugin.Config, scrapeURL string) (statusCodes, error) {
+	spec := getStringConfig(config, "success_status_codes")
+	if targets := getStringConfig(config, "target_success_status_codes"); targets != "" {
+		overrides, err := parseTargetStatusCodes(targets)
+		if err != nil {
+			return statusCodes{}, err
+		}
+		if codes, ok := overrides[scrapeURL]; ok {
+			spec = codes
+		} else if u, err := url.Parse(scrapeURL); err == nil {
+			if codes, ok := overrides[u.Host]; ok {
+				spec = codes
+			}
+		}
+	}
+	codes, err := parseStatusCodes(spec)
+	if err != nil {
+		return codes, fmt.Errorf("Invalid success status codes of %s: %s", scrapeURL, err.Error())
+	}
+	return codes, nil
+}
+
+func parseTargetStatusCodes(spec string) (map[string]string, error) {
+	overrides := map[string]string{}
+	if err := json.Unmarshal([]byte(spec), &overrides); err != nil {
+		return nil, fmt.Errorf("target_success_status_codes must be a JSON object of target URL or host:port to status codes: %s", err.Error())
+	}
+	return overrides, nil
+}
+
+func checkStatusCodes(config plugin.Config) ([]string, error) {
+	if _, err := parseStatusCodes(getStringConfig(config, "success_status_codes")); err != nil {
+		return nil, fmt.Errorf("Invalid success_status_codes: %s", err.Error())
+	}
+	targets := getStringConfig(config, "target_success_status_codes")
+	if targets == "" {
+		return nil, nil
+	}
+	overrides, err := parseTargetStatusCodes(targets)
+	if err != nil {
+		return nil, err
+	}
+	for target, codes := range overrides {
+		if _, err := parseStatusCodes(codes); err != nil {
+			return nil, fmt.Errorf("Invalid target_success_status_codes of %s: %s", target, err.Error())
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,87 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSuccessStatusCodes(t *testing.T) {
+	Convey("Status codes and classes should be parsed", t, func() {
+		codes, err := parseStatusCodes("200, 203,4xx")
+		So(err, ShouldBeNil)
+		So(codes.accepts(200), ShouldBeTrue)
+		So(codes.accepts(203), ShouldBeTrue)
+		So(codes.accepts(404), ShouldBeTrue)
+		So(codes.accepts(206), ShouldBeFalse)
+
+		_, err = parseStatusCodes("2yy")
+		So(err, ShouldNotBeNil)
+		_, err = parseStatusCodes("")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Targets should get their own status codes", t, func() {
+		config := DefaultConfig()
+		config["success_status_codes"] = "200,203"
+		config["target_success_status_codes"] = `{"10.0.0.1:9100": "206", "http://10.0.0.2:9100/federate": "2xx"}`
+		So(validateConfig(config), ShouldBeNil)
+
+		codes, err := getSuccessStatusCodes(config, "http://10.0.0.1:9100/metrics")
+		So(err, ShouldBeNil)
+		So(codes.accepts(206), ShouldBeTrue)
+		So(codes.accepts(200), ShouldBeFalse)
+		codes, err = getSuccessStatusCodes(config, "http://10.0.0.2:9100/federate")
+		So(err, ShouldBeNil)
+		So(codes.accepts(204), ShouldBeTrue)
+		codes, err = getSuccessStatusCodes(config, "http://10.0.0.3:9100/metrics")
+		So(err, ShouldBeNil)
+		So(codes.accepts(203), ShouldBeTrue)
+
+		config["target_success_status_codes"] = `{"10.0.0.1:9100": "ok"}`
+		So(validateConfig(config), ShouldNotBeNil)
+	})
+
+	Convey("Scrapes should succeed with the configured status codes only", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("up 1\n"))
+		}))
+		defer server.Close()
+		_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+		defer func(lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+		lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+
+		downloader := NewHTTPMetricsDownloader()
+		config := plugin.Config{"retry_addresses": true}
+		for key, value := range DefaultConfig() {
+			if _, ok := config[key]; !ok {
+				config[key] = value
+			}
+		}
+		_, err := downloader.GetMetricsReader(server.URL+"/metrics", config)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "Status code: 206")
+
+		opts, err := getClientOptions(config)
+		So(err, ShouldBeNil)
+		health := downloader.clients.healthOf(opts)
+		So(health, ShouldNotBeNil)
+		So(health.failures["127.0.0.1"], ShouldEqual, 1)
+
+		config["success_status_codes"] = "200,206"
+		reader, err := downloader.GetMetricsReader("http://localhost:"+port+"/metrics", config)
+		So(err, ShouldBeNil)
+		closeBody(reader)
+		So(health.failures["127.0.0.1"], ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,66 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseSyntheticSize parses a synthetic exposition size given as
+// <families>x<series>, e.g. 100x50
+func ParseSyntheticSize(size string) (int, int, error) {
+	parts := strings.SplitN(size, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid size %q: must be <families>x<series>", size)
+	}
+	families, err := strconv.Atoi(parts[0])
+	if err != nil || families <= 0 {
+		return 0, 0, fmt.Errorf("Invalid size %q: families must be a positive number", size)
+	}
+	series, err := strconv.Atoi(parts[1])
+	if err != nil || series <= 0 {
+		return 0, 0, fmt.Errorf("Invalid size %q: series must be a positive number", size)
+	}
+	return families, series, nil
+}
+
+// WriteSynthetic writes a deterministic exposition of families metric
+// families of series series each, cycling through counters, gauges and
+// summaries. Values only depend on the family, the series and generation,
+// so successive generations look like successive scrapes of a live target.
+func WriteSynthetic(w io.Writer, families int, series int, generation int) error {
+	b := bufio.NewWriter(w)
+	for f := 0; f < families; f++ {
+		var kind string
+		switch f % 3 {
+		case 0:
+			kind = "counter"
+		case 1:
+			kind = "gauge"
+		default:
+			kind = "summary"
+		}
+		name := fmt.Sprintf("synthetic_%s_%d", kind, f)
+		fmt.Fprintf(b, "# HELP %s Synthetic %s %d.\n# TYPE %s %s\n", name, kind, f, name, kind)
+
+		for s := 0; s < series; s++ {
+			labels := fmt.Sprintf(`instance="synthetic-%d",shard="%d",path="/api/v1/resource/%d"`, s%10, s%7, s)
+			base := float64((f + 1) * (s + 1))
+			switch kind {
+			case "counter":
+				fmt.Fprintf(b, "%s{%s} %g\n", name, labels, base*float64(generation+1))
+			case "gauge":
+				fmt.Fprintf(b, "%s{%s} %g\n", name, labels, base+float64((generation*(s+1))%100))
+			default:
+				for _, quantile := range []string{"0.5", "0.9", "0.99"} {
+					fmt.Fprintf(b, "%s{%s,quantile=\"%s\"} %g\n", name, labels, quantile, base/1000)
+				}
+				fmt.Fprintf(b, "%s_sum{%s} %g\n", name, labels, base*float64(generation+1)/10)
+				fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, (f+s+1)*(generation+1))
+			}
+		}
+	}
+	return b.Flush()
+}
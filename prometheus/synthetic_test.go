@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSynthetic(t *testing.T) {
+	Convey("Synthetic expositions should have the requested size", t, func() {
+		families, series, err := ParseSyntheticSize("6x4")
+		So(err, ShouldBeNil)
+
+		var buf bytes.Buffer
+		So(WriteSynthetic(&buf, families, series, 0), ShouldBeNil)
+		parsed, err := parseMetrics(&buf)
+		So(err, ShouldBeNil)
+		So(parsed, ShouldHaveLength, 6)
+		for _, family := range parsed {
+			So(family.GetMetric(), ShouldHaveLength, 4)
+		}
+	})
+
+	Convey("Synthetic expositions should be deterministic", t, func() {
+		var first, second, next bytes.Buffer
+		So(WriteSynthetic(&first, 3, 3, 1), ShouldBeNil)
+		So(WriteSynthetic(&second, 3, 3, 1), ShouldBeNil)
+		So(WriteSynthetic(&next, 3, 3, 2), ShouldBeNil)
+		So(first.String(), ShouldEqual, second.String())
+		So(first.String(), ShouldNotEqual, next.String())
+	})
+
+	Convey("Invalid sizes should be rejected", t, func() {
+		for _, size := range []string{"100", "0x10", "10x", "ax1"} {
+			_, _, err := ParseSyntheticSize(size)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
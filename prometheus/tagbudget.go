@@ -0,0 +1,98 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// trimmedTag marks the metrics that lost tags to max_tag_bytes
+const trimmedTag = "trimmed"
+
+// tagBytes is the size of a metric's tags as publishers count it, the sum of
+// the lengths of their names and values
+func tagBytes(tags map[string]string) int64 {
+	var size int64
+	for name, value := range tags {
+		size += int64(len(name) + len(value))
+	}
+	return size
+}
+
+// trimOrder returns the order tags are dropped in: the tag_trim_order tags
+// first, then the largest remaining tags, by name when as large, so the same
+// series always keeps the same tags
+func trimOrder(lowPriority []string, tags map[string]string) []string {
+	order := make([]string, 0, len(tags))
+	listed := make(map[string]bool, len(lowPriority))
+	for _, name := range lowPriority {
+		if _, ok := tags[name]; ok && !listed[name] {
+			order = append(order, name)
+		}
+		listed[name] = true
+	}
+
+	rest := make([]string, 0, len(tags))
+	for name := range tags {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		si, sj := len(rest[i])+len(tags[rest[i]]), len(rest[j])+len(tags[rest[j]])
+		if si != sj {
+			return si > sj
+		}
+		return rest[i] < rest[j]
+	})
+	return append(order, rest...)
+}
+
+// enforceTagBudget drops tags of the metrics whose tags are larger than
+// max_tag_bytes until they fit, marker included, and tags them trimmed=true,
+// so publishers limiting tag payloads get a degraded series instead of a
+// failed publish
+func enforceTagBudget(config plugin.Config, metrics []plugin.Metric) {
+	budget := getIntConfig(config, "max_tag_bytes")
+	if budget <= 0 {
+		return
+	}
+	lowPriority := splitList(getStringConfig(config, "tag_trim_order"))
+	marker := int64(len(trimmedTag) + len("true"))
+
+	trimmed := 0
+	for i := range metrics {
+		size := tagBytes(metrics[i].Tags)
+		if size <= budget {
+			continue
+		}
+		// Tag maps may be shared between the metrics of a series
+		tags := copyTags(metrics[i].Tags)
+		for _, name := range trimOrder(lowPriority, tags) {
+			if size+marker <= budget {
+				break
+			}
+			size -= int64(len(name) + len(tags[name]))
+			delete(tags, name)
+		}
+		tags[trimmedTag] = "true"
+		metrics[i].Tags = tags
+		trimmed++
+	}
+	if trimmed > 0 {
+		glog.Warningf("Trimmed the tags of %d metrics to max_tag_bytes %d", trimmed, budget)
+	}
+}
+
+func checkTagBudget(config plugin.Config) ([]string, error) {
+	budget := getIntConfig(config, "max_tag_bytes")
+	if budget > 0 && budget < int64(len(trimmedTag)+len("true")) {
+		return nil, fmt.Errorf("max_tag_bytes must leave room for the %s=true tag", trimmedTag)
+	}
+	if budget <= 0 && getStringConfig(config, "tag_trim_order") != "" {
+		return []string{"tag_trim_order is ignored without max_tag_bytes"}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,34 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnforceTagBudget(t *testing.T) {
+	Convey("Metrics over max_tag_bytes should lose tags in a fixed order", t, func() {
+		tags := map[string]string{"instance": "10.0.0.1:9100", "pod": "api-7d9f", "trace": "0123456789abcdef", "job": "api"}
+		metrics := []plugin.Metric{{Tags: tags}, {Tags: map[string]string{"job": "db"}}}
+
+		config := plugin.Config{"max_tag_bytes": int64(40), "tag_trim_order": "pod, trace"}
+		So(validateConfig(config), ShouldBeNil)
+		enforceTagBudget(config, metrics)
+
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"instance": "10.0.0.1:9100", "job": "api", "trimmed": "true"})
+		So(tagBytes(metrics[0].Tags), ShouldBeLessThanOrEqualTo, 40)
+		So(metrics[1].Tags, ShouldResemble, map[string]string{"job": "db"})
+		So(len(tags), ShouldEqual, 4)
+	})
+
+	Convey("Tags should be kept without a budget", t, func() {
+		metrics := []plugin.Metric{{Tags: map[string]string{"trace": "0123456789abcdef"}}}
+		enforceTagBudget(plugin.Config{}, metrics)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"trace": "0123456789abcdef"})
+	})
+
+	Convey("A budget without room for the marker should be refused", t, func() {
+		So(validateConfig(plugin.Config{"max_tag_bytes": int64(4)}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,50 @@
+package prometheus
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// copyTimestamps copies the timestamp of every metric, in milliseconds since
+// the epoch, for publishers ignoring Metric.Timestamp:
+//
+// tag: into the timestamp_tag tag
+//
+// data: into the data, which becomes {"value": <data>, "ts_ms": <timestamp>}
+func copyTimestamps(config plugin.Config, metrics []plugin.Metric) {
+	mode := getStringConfig(config, "timestamp_copy")
+	if mode == "" {
+		return
+	}
+	tag := getStringConfig(config, "timestamp_tag")
+	for i := range metrics {
+		ms := metrics[i].Timestamp.UnixNano() / 1e6
+		switch mode {
+		case "tag":
+			tags := make(map[string]string, len(metrics[i].Tags)+1)
+			for key, value := range metrics[i].Tags {
+				tags[key] = value
+			}
+			tags[tag] = strconv.FormatInt(ms, 10)
+			metrics[i].Tags = tags
+		case "data":
+			metrics[i].Data = map[string]interface{}{"value": metrics[i].Data, "ts_ms": ms}
+		}
+	}
+}
+
+func checkTimestampCopy(config plugin.Config) ([]string, error) {
+	switch getStringConfig(config, "timestamp_copy") {
+	case "":
+		return nil, nil
+	case "tag", "data":
+	default:
+		return nil, fmt.Errorf("Unknown timestamp_copy: %s", getStringConfig(config, "timestamp_copy"))
+	}
+	if getStringConfig(config, "timestamp_tag") == "" {
+		return nil, fmt.Errorf("timestamp_tag must be set")
+	}
+	return nil, nil
+}
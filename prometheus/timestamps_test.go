@@ -0,0 +1,40 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCopyTimestamps(t *testing.T) {
+	timestamp := time.Unix(1500000000, 250*int64(time.Millisecond))
+	newMetrics := func() []plugin.Metric {
+		return []plugin.Metric{{Timestamp: timestamp, Data: 1.5, Tags: map[string]string{"instance": "a"}}}
+	}
+
+	Convey("Timestamps should be copied into a tag", t, func() {
+		metrics := newMetrics()
+		copyTimestamps(plugin.Config{"timestamp_copy": "tag"}, metrics)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{"instance": "a", "ts_ms": "1500000000250"})
+		So(metrics[0].Data, ShouldEqual, 1.5)
+	})
+
+	Convey("Timestamps should be copied into the data", t, func() {
+		metrics := newMetrics()
+		copyTimestamps(plugin.Config{"timestamp_copy": "data"}, metrics)
+		So(metrics[0].Data, ShouldResemble, map[string]interface{}{"value": 1.5, "ts_ms": int64(1500000000250)})
+		So(metrics[0].Tags, ShouldNotContainKey, "ts_ms")
+	})
+
+	Convey("Metrics should be left alone by default", t, func() {
+		metrics := newMetrics()
+		copyTimestamps(plugin.Config{}, metrics)
+		So(metrics, ShouldResemble, newMetrics())
+	})
+
+	Convey("Unknown timestamp_copy modes should fail validation", t, func() {
+		So(validateConfig(plugin.Config{"timestamp_copy": "field"}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// tombstoneTracker keeps the series every target of a task config exposed at
+// its last successful scrape, so vanished series can be reported once
+type tombstoneTracker struct {
+	mutex  sync.Mutex
+	series map[string]map[string]map[string]plugin.Metric
+}
+
+func newTombstoneTracker() *tombstoneTracker {
+	return &tombstoneTracker{
+		series: make(map[string]map[string]map[string]plugin.Metric),
+	}
+}
+
+// track records the series scraped from every target and returns a
+// series_removed metric for every series gone since the previous scrape,
+// tagged with the series tags and its metric name. Series of targets that
+// failed to scrape are kept, those of targets no longer scraped are removed.
+func (t *tombstoneTracker) track(config plugin.Config, prefix []string, currentTime time.Time, scraped map[string][]plugin.Metric, targets []target) []plugin.Metric {
+	if t == nil || !getBoolConfig(config, "emit_tombstones") {
+		return nil
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	previous := t.series[key]
+	current := make(map[string]map[string]plugin.Metric, len(targets))
+
+	var vanished []plugin.Metric
+	for _, target := range targets {
+		metrics, ok := scraped[target.URL]
+		if !ok {
+			if series, ok := previous[target.URL]; ok {
+				current[target.URL] = series
+			}
+			continue
+		}
+		series := make(map[string]plugin.Metric, len(metrics))
+		for _, metric := range metrics {
+			series[seriesKey(metric)] = plugin.Metric{Namespace: metric.Namespace, Tags: metric.Tags}
+		}
+		for seriesKey, metric := range previous[target.URL] {
+			if _, ok := series[seriesKey]; !ok {
+				vanished = append(vanished, metric)
+			}
+		}
+		current[target.URL] = series
+	}
+	for url, series := range previous {
+		if _, ok := current[url]; ok {
+			continue
+		}
+		for _, metric := range series {
+			vanished = append(vanished, metric)
+		}
+	}
+	t.series[key] = current
+
+	namespace := prefixedNamespace(prefix, "series_removed")
+	tombstones := make([]plugin.Metric, 0, len(vanished))
+	for _, metric := range vanished {
+		tags := make(map[string]string, len(metric.Tags)+1)
+		for key, value := range metric.Tags {
+			tags[key] = value
+		}
+		tags["metric_name"] = metricName(metric)
+		tombstones = append(tombstones, plugin.Metric{
+			Namespace:   namespace,
+			Timestamp:   currentTime,
+			Description: "1 for a series that disappeared since the previous scrape",
+			Version:     pluginVersion,
+			Tags:        tags,
+			Data:        1.0,
+		})
+	}
+	return tombstones
+}
+
+// forget drops the series of every target of the config with fingerprint
+// key
+func (t *tombstoneTracker) forget(key string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.series, key)
+}
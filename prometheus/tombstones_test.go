@@ -0,0 +1,54 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTombstones(t *testing.T) {
+	Convey("Vanished series should be reported once", t, func() {
+		config := plugin.Config{"emit_tombstones": true}
+		tracker := newTombstoneTracker()
+		prefix := []string{"hyperpilot", "prometheus"}
+		targets := []target{{URL: "http://a/metrics"}, {URL: "http://b/metrics"}}
+
+		So(tracker.track(config, prefix, time.Now(), map[string][]plugin.Metric{
+			"http://a/metrics": {codeRequestsMetric("200", 1), codeRequestsMetric("500", 1)},
+			"http://b/metrics": {requestsMetric("api", "b", 1)},
+		}, targets), ShouldBeEmpty)
+
+		tombstones := tracker.track(config, prefix, time.Now(), map[string][]plugin.Metric{
+			"http://a/metrics": {codeRequestsMetric("200", 2)},
+		}, targets)
+		So(tombstones, ShouldHaveLength, 1)
+		So(tombstones[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "series_removed"})
+		So(tombstones[0].Tags, ShouldResemble, map[string]string{"code": "500", "metric_name": "http_requests_total"})
+		So(tombstones[0].Data, ShouldEqual, 1.0)
+
+		Convey("keeping the series of targets that failed to scrape", func() {
+			So(tracker.track(config, prefix, time.Now(), map[string][]plugin.Metric{
+				"http://a/metrics": {codeRequestsMetric("200", 3)},
+				"http://b/metrics": {requestsMetric("api", "b", 2)},
+			}, targets), ShouldBeEmpty)
+		})
+
+		Convey("and for targets no longer scraped", func() {
+			tombstones := tracker.track(config, prefix, time.Now(), map[string][]plugin.Metric{
+				"http://a/metrics": {codeRequestsMetric("200", 3)},
+			}, targets[:1])
+			So(tombstones, ShouldHaveLength, 1)
+			So(tombstones[0].Tags, ShouldResemble, map[string]string{"job": "api", "instance": "b", "metric_name": "http_requests_total"})
+		})
+	})
+
+	Convey("Tombstones should be off by default", t, func() {
+		tracker := newTombstoneTracker()
+		targets := []target{{URL: "http://a/metrics"}}
+		tracker.track(plugin.Config{}, nil, time.Now(), map[string][]plugin.Metric{"http://a/metrics": {codeRequestsMetric("200", 1)}}, targets)
+		So(tracker.track(plugin.Config{}, nil, time.Now(), map[string][]plugin.Metric{"http://a/metrics": {}}, targets), ShouldBeEmpty)
+	})
+}
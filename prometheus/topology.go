@@ -0,0 +1,179 @@
+package prometheus
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// topology maps target addresses, host:port or bare hosts, to the tags
+// describing where they run, e.g. site, rack and region
+type topology map[string]map[string]string
+
+// topologyCache keeps every topology file loaded, reloading a file when its
+// modification time changes. When a reload fails the previous mapping is
+// kept.
+type topologyCache struct {
+	mutex sync.Mutex
+	files map[string]*topologyFile
+}
+
+type topologyFile struct {
+	modTime  time.Time
+	topology topology
+}
+
+func newTopologyCache() *topologyCache {
+	return &topologyCache{
+		files: make(map[string]*topologyFile),
+	}
+}
+
+// get returns the current topology of path
+func (c *topologyCache) get(path string) (topology, error) {
+	if c == nil {
+		return loadTopology(path)
+	}
+
+	info, err := os.Stat(path)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	file, ok := c.files[path]
+	if err != nil {
+		if ok {
+			glog.Warningf("Unable to stat topology file %s, keeping previous topology: %s", path, err.Error())
+			return file.topology, nil
+		}
+		return nil, err
+	}
+	if ok && file.modTime.Equal(info.ModTime()) {
+		return file.topology, nil
+	}
+
+	loaded, err := loadTopology(path)
+	if err != nil {
+		if ok {
+			glog.Warningf("Unable to reload topology file %s, keeping previous topology: %s", path, err.Error())
+			return file.topology, nil
+		}
+		return nil, err
+	}
+	c.files[path] = &topologyFile{modTime: info.ModTime(), topology: loaded}
+	return loaded, nil
+}
+
+// loadTopology reads a topology file. A .json file holds an object of
+// address to tags; any other file is CSV with a header row naming the tags,
+// whose first column is the address.
+func loadTopology(path string) (topology, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := topology{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.NewDecoder(f).Decode(&result); err != nil {
+			return nil, fmt.Errorf("Unable to decode topology: " + err.Error())
+		}
+		return result, nil
+	}
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read topology: " + err.Error())
+	}
+	if len(records) == 0 {
+		return result, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		tags := make(map[string]string, len(header)-1)
+		for i := 1; i < len(header) && i < len(record); i++ {
+			if record[i] != "" {
+				tags[strings.TrimSpace(header[i])] = strings.TrimSpace(record[i])
+			}
+		}
+		result[strings.TrimSpace(record[0])] = tags
+	}
+	return result, nil
+}
+
+// lookup returns the tags of the target at address, matching host:port
+// ahead of the bare host
+func (t topology) lookup(address string) map[string]string {
+	if tags, ok := t[address]; ok {
+		return tags
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return t[host]
+	}
+	return nil
+}
+
+// targetAddress returns the address a target is scraped at
+func targetAddress(t target) string {
+	if instance, ok := t.Tags["instance"]; ok {
+		return instance
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// enrichTargets adds the host metadata and topology tags of every target.
+// Target tags win over topology tags, which win over host metadata tags.
+func (c *PrometheusCollector) enrichTargets(config plugin.Config, targets []target) ([]target, error) {
+	hostTags := c.hostTags(config)
+	var topo topology
+	if path := getStringConfig(config, "topology_file"); path != "" {
+		var err error
+		if topo, err = c.topology.get(path); err != nil {
+			return nil, fmt.Errorf("Unable to load topology file: " + err.Error())
+		}
+	}
+	if len(hostTags) == 0 && topo == nil {
+		return targets, nil
+	}
+
+	enriched := make([]target, 0, len(targets))
+	for _, t := range targets {
+		topologyTags := topo.lookup(targetAddress(t))
+		if len(hostTags) == 0 && len(topologyTags) == 0 {
+			enriched = append(enriched, t)
+			continue
+		}
+		tags := make(map[string]string, len(hostTags)+len(topologyTags)+len(t.Tags))
+		for _, extra := range []map[string]string{hostTags, topologyTags, t.Tags} {
+			for key, value := range extra {
+				tags[key] = value
+			}
+		}
+		enriched = append(enriched, target{URL: t.URL, Tags: tags})
+	}
+	return enriched, nil
+}
+
+// size returns the number of topology files loaded, for debug dumps
+func (c *topologyCache) size() int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.files)
+}
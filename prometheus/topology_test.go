@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTopologyEnrichment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Targets should be tagged from a CSV topology file", t, func() {
+		path := filepath.Join(dir, "topology.csv")
+		So(ioutil.WriteFile(path, []byte("address,site,rack\n10.0.0.1,ams1,r12\n10.0.0.2:9100,fra2,\n"), 0644), ShouldBeNil)
+
+		c := &PrometheusCollector{topology: newTopologyCache()}
+		config := plugin.Config{"topology_file": path}
+		targets, err := c.enrichTargets(config, []target{
+			{URL: "http://10.0.0.1:9100/metrics", Tags: map[string]string{"instance": "10.0.0.1:9100", "site": "override"}},
+			{URL: "http://10.0.0.2:9100/metrics"},
+			{URL: "http://10.0.0.3:9100/metrics"},
+		})
+		So(err, ShouldBeNil)
+		So(targets[0].Tags, ShouldResemble, map[string]string{"instance": "10.0.0.1:9100", "site": "override", "rack": "r12"})
+		So(targets[1].Tags, ShouldResemble, map[string]string{"site": "fra2"})
+		So(targets[2].Tags, ShouldBeNil)
+
+		Convey("and reloaded when the file changes", func() {
+			So(ioutil.WriteFile(path, []byte("address,site\n10.0.0.3,lon1\n"), 0644), ShouldBeNil)
+			later := time.Now().Add(time.Minute)
+			So(os.Chtimes(path, later, later), ShouldBeNil)
+
+			targets, err := c.enrichTargets(config, []target{{URL: "http://10.0.0.3:9100/metrics"}})
+			So(err, ShouldBeNil)
+			So(targets[0].Tags, ShouldResemble, map[string]string{"site": "lon1"})
+		})
+
+		Convey("keeping the previous topology when the reload fails", func() {
+			So(ioutil.WriteFile(path, []byte("address,site\n\"10.0.0.3,lon1\n"), 0644), ShouldBeNil)
+			later := time.Now().Add(2 * time.Minute)
+			So(os.Chtimes(path, later, later), ShouldBeNil)
+
+			targets, err := c.enrichTargets(config, []target{{URL: "http://10.0.0.2:9100/metrics"}})
+			So(err, ShouldBeNil)
+			So(targets[0].Tags, ShouldResemble, map[string]string{"site": "fra2"})
+		})
+	})
+
+	Convey("Targets should be tagged from a JSON topology file", t, func() {
+		path := filepath.Join(dir, "topology.json")
+		So(ioutil.WriteFile(path, []byte(`{"db-1": {"region": "eu-west"}}`), 0644), ShouldBeNil)
+
+		c := &PrometheusCollector{}
+		targets, err := c.enrichTargets(plugin.Config{"topology_file": path}, []target{{URL: "http://db-1:9187/metrics"}})
+		So(err, ShouldBeNil)
+		So(targets[0].Tags, ShouldResemble, map[string]string{"region": "eu-west"})
+	})
+
+	Convey("A missing topology file should fail the collection", t, func() {
+		c := &PrometheusCollector{}
+		_, err := c.enrichTargets(plugin.Config{"topology_file": filepath.Join(dir, "missing.csv")}, []target{{URL: "http://db-1:9187/metrics"}})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,104 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// unitConversion converts the values of metrics named with a unit suffix to
+// a base unit
+type unitConversion struct {
+	Suffix string  `json:"-"`
+	Unit   string  `json:"unit"`
+	Factor float64 `json:"factor"`
+}
+
+// defaultUnitConversions map the unit suffixes of Prometheus naming
+// conventions to Snap's base units, seconds and bytes
+var defaultUnitConversions = map[string]unitConversion{
+	"_seconds":      {Unit: "s", Factor: 1},
+	"_milliseconds": {Unit: "s", Factor: 1e-3},
+	"_microseconds": {Unit: "s", Factor: 1e-6},
+	"_nanoseconds":  {Unit: "s", Factor: 1e-9},
+	"_bytes":        {Unit: "B", Factor: 1},
+	"_kilobytes":    {Unit: "B", Factor: 1e3},
+	"_megabytes":    {Unit: "B", Factor: 1e6},
+	"_gigabytes":    {Unit: "B", Factor: 1e9},
+	"_kibibytes":    {Unit: "B", Factor: 1 << 10},
+	"_mebibytes":    {Unit: "B", Factor: 1 << 20},
+	"_gibibytes":    {Unit: "B", Factor: 1 << 30},
+}
+
+// compileUnitConversions returns the default conversions extended by the
+// task's unit_mappings, longest suffix first
+func compileUnitConversions(spec string) ([]unitConversion, error) {
+	conversions := map[string]unitConversion{}
+	for suffix, conversion := range defaultUnitConversions {
+		conversions[suffix] = conversion
+	}
+	if spec != "" {
+		var extra map[string]unitConversion
+		if err := json.Unmarshal([]byte(spec), &extra); err != nil {
+			return nil, fmt.Errorf("unit_mappings must be a JSON object of name suffix to unit and factor: %s", err.Error())
+		}
+		for suffix, conversion := range extra {
+			if suffix == "" || conversion.Factor == 0 {
+				return nil, fmt.Errorf("Invalid unit mapping %q: suffix and a non-zero factor must be set", suffix)
+			}
+			conversions[suffix] = conversion
+		}
+	}
+
+	sorted := make([]unitConversion, 0, len(conversions))
+	for suffix, conversion := range conversions {
+		conversion.Suffix = suffix
+		sorted = append(sorted, conversion)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].Suffix) != len(sorted[j].Suffix) {
+			return len(sorted[i].Suffix) > len(sorted[j].Suffix)
+		}
+		return sorted[i].Suffix < sorted[j].Suffix
+	})
+	return sorted, nil
+}
+
+// convertUnit converts the value of metric in place when its name, less a
+// _total suffix and the _sum, _count or _bucket suffix of schema 2, ends
+// with a unit suffix. Summary and histogram counts and histogram buckets are
+// event counts and keep their value; the le bound of buckets is converted
+// instead.
+func convertUnit(conversions []unitConversion, metric *plugin.Metric) {
+	name := strings.TrimSuffix(metricName(*metric), "_total")
+	part := ""
+	for _, suffix := range []string{"_sum", "_count", "_bucket"} {
+		if strings.HasSuffix(name, suffix) {
+			name, part = strings.TrimSuffix(name, suffix), suffix
+			break
+		}
+	}
+	for _, conversion := range conversions {
+		if !strings.HasSuffix(name, conversion.Suffix) {
+			continue
+		}
+		if metric.Tags["summary"] == "count" || metric.Tags["histogram"] == "count" || part == "_count" {
+			return
+		}
+		if metric.Tags["histogram"] == "bucket" || part == "_bucket" {
+			if bound, err := strconv.ParseFloat(metric.Tags["le"], 64); err == nil {
+				metric.Tags["le"] = formatBound(bound * conversion.Factor)
+			}
+			return
+		}
+		if value, ok := metric.Data.(float64); ok {
+			metric.Data = value * conversion.Factor
+			metric.Unit = conversion.Unit
+		}
+		return
+	}
+}
@@ -0,0 +1,58 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func namedMetric(name string, tags map[string]string, value float64) plugin.Metric {
+	return plugin.Metric{
+		Namespace: plugin.NewNamespace("hyperpilot", "prometheus", name),
+		Tags:      tags,
+		Data:      value,
+	}
+}
+
+func TestUnitConversion(t *testing.T) {
+	Convey("Units should be converted to base units", t, func() {
+		rules, err := compileConversionRules(plugin.Config{
+			"convert_units": true,
+			"unit_mappings": `{"_minutes": {"unit": "s", "factor": 60}}`,
+		})
+		So(err, ShouldBeNil)
+
+		metrics := rules.apply([]plugin.Metric{
+			namedMetric("request_duration_milliseconds", map[string]string{"summary": "quantile_99"}, 250),
+			namedMetric("request_duration_milliseconds", map[string]string{"summary": "count"}, 12),
+			namedMetric("process_cpu_seconds_total", map[string]string{}, 3),
+			namedMetric("cache_size_kibibytes", map[string]string{}, 2),
+			namedMetric("uptime_minutes", map[string]string{}, 2),
+			namedMetric("requests_total", map[string]string{}, 7),
+		})
+		So(metrics[0].Data, ShouldAlmostEqual, 0.25)
+		So(metrics[0].Unit, ShouldEqual, "s")
+		So(metrics[1].Data, ShouldEqual, 12.0)
+		So(metrics[1].Unit, ShouldEqual, "")
+		So(metrics[2].Data, ShouldEqual, 3.0)
+		So(metrics[2].Unit, ShouldEqual, "s")
+		So(metrics[3].Data, ShouldEqual, 2048.0)
+		So(metrics[3].Unit, ShouldEqual, "B")
+		So(metrics[4].Data, ShouldEqual, 120.0)
+		So(metrics[5].Data, ShouldEqual, 7.0)
+		So(metrics[5].Unit, ShouldEqual, "")
+	})
+
+	Convey("Units should be left alone by default", t, func() {
+		rules, err := compileConversionRules(plugin.Config{})
+		So(err, ShouldBeNil)
+		metrics := rules.apply([]plugin.Metric{namedMetric("request_duration_milliseconds", map[string]string{}, 250)})
+		So(metrics[0].Data, ShouldEqual, 250.0)
+	})
+
+	Convey("Invalid unit mappings should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"convert_units": true, "unit_mappings": `{"_minutes": {"unit": "s"}}`}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// unixScheme prefixes the endpoints of exporters listening on a unix
+// socket, e.g. unix:///var/run/exporter.sock:/metrics
+const unixScheme = "unix://"
+
+// parseUnixEndpoint splits a unix socket endpoint into the socket path and
+// the HTTP path scraped through it, /metrics when the endpoint has none
+func parseUnixEndpoint(endpoint string) (socket, path string, ok bool) {
+	if !strings.HasPrefix(endpoint, unixScheme) {
+		return "", "", false
+	}
+	socket = strings.TrimPrefix(endpoint, unixScheme)
+	path = "/metrics"
+	if i := strings.LastIndex(socket, ":"); i >= 0 {
+		socket, path = socket[:i], socket[i+1:]
+	}
+	if socket == "" || !strings.HasPrefix(path, "/") {
+		return "", "", false
+	}
+	return socket, path, true
+}
+
+// unixDialer dials socket whatever address the transport asks for
+func unixDialer(socket string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
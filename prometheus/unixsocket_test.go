@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnixSocketEndpoints(t *testing.T) {
+	Convey("Unix socket endpoints should be split into socket and path", t, func() {
+		socket, path, ok := parseUnixEndpoint("unix:///var/run/exporter.sock:/internal/metrics")
+		So(ok, ShouldBeTrue)
+		So(socket, ShouldEqual, "/var/run/exporter.sock")
+		So(path, ShouldEqual, "/internal/metrics")
+
+		socket, path, ok = parseUnixEndpoint("unix:///var/run/exporter.sock")
+		So(ok, ShouldBeTrue)
+		So(socket, ShouldEqual, "/var/run/exporter.sock")
+		So(path, ShouldEqual, "/metrics")
+
+		_, _, ok = parseUnixEndpoint("http://localhost:9100/metrics")
+		So(ok, ShouldBeFalse)
+		_, _, ok = parseUnixEndpoint("unix://")
+		So(ok, ShouldBeFalse)
+
+		So(validateConfig(plugin.Config{"endpoint": "unix:///var/run/exporter.sock:/metrics"}), ShouldBeNil)
+		So(validateConfig(plugin.Config{"endpoint": "unix:///var/run/exporter.sock:metrics"}), ShouldNotBeNil)
+		targets, err := parseEndpointList(plugin.Config{"endpoints": "unix:///var/run/a.sock,http://b:9100"}, "endpoints")
+		So(err, ShouldBeNil)
+		So(targets[0].URL, ShouldEqual, "unix:///var/run/a.sock")
+		So(targets[1].URL, ShouldEqual, "http://b:9100/metrics")
+	})
+
+	Convey("Exporters on a unix socket should be scraped", t, func() {
+		dir, err := ioutil.TempDir("", "unix-scrape")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		socket := filepath.Join(dir, "exporter.sock")
+		listener, err := net.Listen("unix", socket)
+		So(err, ShouldBeNil)
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/internal/metrics" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, TEST_DATA)
+		})}
+		go server.Serve(listener)
+		defer server.Close()
+
+		downloader := NewHTTPMetricsDownloader()
+		endpoint, err := downloader.GetEndpoint(plugin.Config{"endpoint": "unix://" + socket + ":/internal/metrics"})
+		So(err, ShouldBeNil)
+		So(endpoint, ShouldEqual, "unix://"+socket+":/internal/metrics")
+
+		collector := &PrometheusCollector{Downloader: downloader}
+		families, err := collector.Collect(endpoint, plugin.Config{})
+		So(err, ShouldBeNil)
+		So(families, ShouldContainKey, "go_goroutines")
+
+		_, err = downloader.GetMetricsReader(endpoint, plugin.Config{"safe_mode": true})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,319 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// configCheck looks for dangerous or contradictory settings in a task
+// config. Warnings are logged, an error rejects the task.
+type configCheck func(config plugin.Config) (warnings []string, err error)
+
+var configChecks = []configCheck{
+	checkOptionTypes,
+	checkEndpoint,
+	checkJob,
+	checkKubeProxy,
+	checkDiscovery,
+	checkStaticTargets,
+	checkEndpoints,
+	checkConversionRules,
+	checkHostTags,
+	checkActiveWindows,
+	checkSafeMode,
+	checkAddressPolicy,
+	checkFaults,
+	checkRecording,
+	checkScrapeMethod,
+	checkSignature,
+	checkDurationBuckets,
+	checkAdmin,
+	checkAuditLog,
+	checkSchema,
+	checkLowMemory,
+	checkTLS,
+	checkAuth,
+	checkFallback,
+	checkDownsample,
+	checkTimestampCopy,
+	checkDownloader,
+	checkLabelsInNamespace,
+	checkMaxLineLength,
+	checkCounterRates,
+	checkNodeLocalOnly,
+	checkExporter,
+	checkQuantileFormat,
+	checkTagBudget,
+	checkExtraTags,
+	checkNaNPolicy,
+	checkStatusCodes,
+	checkAnomalies,
+	checkCatchup,
+}
+
+// configValidator runs configChecks once per distinct task config, which
+// in practice is the first collection after a task is created, and
+// remembers the verdict for later collections
+type configValidator struct {
+	mutex   sync.Mutex
+	results map[string]error
+}
+
+func newConfigValidator() *configValidator {
+	return &configValidator{
+		results: make(map[string]error),
+	}
+}
+
+func (v *configValidator) validate(config plugin.Config) error {
+	if v == nil {
+		return validateConfig(config)
+	}
+
+	key := configFingerprint(config)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if err, ok := v.results[key]; ok {
+		return err
+	}
+	err := validateConfig(config)
+	v.results[key] = err
+	audit(config, "config_loaded", nil, err)
+	return err
+}
+
+func validateConfig(config plugin.Config) error {
+	problems := []string{}
+	for _, check := range configChecks {
+		warnings, err := check(config)
+		for _, warning := range warnings {
+			glog.Warningf("Task config warning: %s", warning)
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid task config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func configFingerprint(config plugin.Config) string {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, config[key]))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func checkOptionTypes(config plugin.Config) ([]string, error) {
+	for _, option := range configOptions {
+		if _, ok := config[option.Key]; !ok {
+			continue
+		}
+
+		var err error
+		switch option.Type {
+		case stringOption:
+			_, err = config.GetString(option.Key)
+		case integerOption:
+			_, err = config.GetInt(option.Key)
+		case booleanOption:
+			_, err = config.GetBool(option.Key)
+		case numberOption:
+			_, err = config.GetFloat(option.Key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a %s", option.Key, option.Type)
+		}
+		if option.Format == durationFormat {
+			if _, err := getDurationConfig(config, option.Key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+func checkEndpoint(config plugin.Config) ([]string, error) {
+	endpoint, err := config.GetString("endpoint")
+	if err != nil {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(endpoint, unixScheme) {
+		if _, _, ok := parseUnixEndpoint(endpoint); !ok {
+			return nil, fmt.Errorf("endpoint %q must be unix:///path/to.sock:/path", endpoint)
+		}
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %q is not a valid URL: %s", endpoint, err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %q must be an http or https URL", endpoint)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("endpoint %q has no host", endpoint)
+	}
+	return nil, nil
+}
+
+func checkJob(config plugin.Config) ([]string, error) {
+	_, err := getNamespacePrefix(config)
+	return nil, err
+}
+
+func checkKubeProxy(config plugin.Config) ([]string, error) {
+	if !isKubeProxy(config) {
+		return nil, nil
+	}
+
+	if _, err := kubeProxyEndpoint(config); err != nil {
+		return nil, err
+	}
+	if endpoint, err := config.GetString("endpoint"); err == nil && endpoint != prometheusEndpoint {
+		return []string{"endpoint is ignored when scraping through the Kubernetes API server proxy"}, nil
+	}
+	return nil, nil
+}
+
+func checkDiscovery(config plugin.Config) ([]string, error) {
+	backend := getStringConfig(config, "discovery")
+	if backend == "" {
+		return nil, nil
+	}
+
+	if _, ok := discoverers[backend]; !ok {
+		return nil, fmt.Errorf("unknown discovery backend %q", backend)
+	}
+	if isKubeProxy(config) {
+		return nil, fmt.Errorf("discovery and kube_pod/kube_service are mutually exclusive")
+	}
+	if endpoint, err := config.GetString("endpoint"); err == nil && endpoint != prometheusEndpoint {
+		return nil, fmt.Errorf("discovery and a static endpoint are mutually exclusive")
+	}
+	return nil, nil
+}
+
+func checkStaticTargets(config plugin.Config) ([]string, error) {
+	if getStringConfig(config, "static_targets") == "" {
+		return nil, nil
+	}
+
+	if _, err := parseStaticTargets(config); err != nil {
+		return nil, err
+	}
+	if getStringConfig(config, "discovery") != "" || isKubeProxy(config) {
+		return nil, fmt.Errorf("static_targets and discovery are mutually exclusive")
+	}
+	if endpoint, err := config.GetString("endpoint"); err == nil && endpoint != prometheusEndpoint {
+		return nil, fmt.Errorf("static_targets and endpoint are mutually exclusive")
+	}
+	return nil, nil
+}
+
+func checkEndpoints(config plugin.Config) ([]string, error) {
+	if getStringConfig(config, "endpoints") == "" {
+		return nil, nil
+	}
+
+	if _, err := parseEndpoints(config); err != nil {
+		return nil, err
+	}
+	if getStringConfig(config, "static_targets") != "" {
+		return nil, fmt.Errorf("endpoints and static_targets are mutually exclusive")
+	}
+	if getStringConfig(config, "discovery") != "" || isKubeProxy(config) {
+		return nil, fmt.Errorf("endpoints and discovery are mutually exclusive")
+	}
+	if endpoint, err := config.GetString("endpoint"); err == nil && endpoint != prometheusEndpoint {
+		return nil, fmt.Errorf("endpoints and endpoint are mutually exclusive")
+	}
+	return nil, nil
+}
+
+func checkConversionRules(config plugin.Config) ([]string, error) {
+	_, err := compileConversionRules(config)
+	return nil, err
+}
+
+func checkHostTags(config plugin.Config) ([]string, error) {
+	for _, kind := range splitList(getStringConfig(config, "host_tags")) {
+		if _, ok := hostTagNames[kind]; !ok {
+			return nil, fmt.Errorf("Invalid host_tags entry %q: must be one of hostname, node_name and instance_id", kind)
+		}
+	}
+	return nil, nil
+}
+
+func checkActiveWindows(config plugin.Config) ([]string, error) {
+	if _, err := parseActiveWindows(getStringConfig(config, "active_windows")); err != nil {
+		return nil, err
+	}
+	if _, err := time.LoadLocation(getStringConfig(config, "active_windows_timezone")); err != nil {
+		return nil, fmt.Errorf("Invalid active_windows_timezone: " + err.Error())
+	}
+	return nil, nil
+}
+
+func checkAddressPolicy(config plugin.Config) ([]string, error) {
+	_, err := newAddressPolicy(getStringConfig(config, "allowed_cidrs"), getStringConfig(config, "denied_cidrs"))
+	return nil, err
+}
+
+func checkFaults(config plugin.Config) ([]string, error) {
+	if getFloatConfig(config, "fault_rate") <= 0 {
+		return nil, nil
+	}
+	for _, kind := range splitList(getStringConfig(config, "fault_kinds")) {
+		if !faultKinds[kind] {
+			return nil, fmt.Errorf("Invalid fault_kinds entry %q: must be one of delay, error, truncate and corrupt", kind)
+		}
+	}
+	return []string{"fault_rate is set, scrapes will fail on purpose"}, nil
+}
+
+func checkRecording(config plugin.Config) ([]string, error) {
+	if getStringConfig(config, "recording_mode") != "" && getStringConfig(config, "recording_dir") == "" {
+		return nil, fmt.Errorf("recording_mode needs a recording_dir")
+	}
+	return nil, nil
+}
+
+func checkScrapeMethod(config plugin.Config) ([]string, error) {
+	method := getStringConfig(config, "method")
+	if method != "GET" && method != "POST" {
+		return nil, fmt.Errorf("Unknown method: %s", method)
+	}
+	if getStringConfig(config, "request_body") != "" && getStringConfig(config, "method") != "POST" {
+		return nil, fmt.Errorf("request_body needs method POST")
+	}
+	return nil, nil
+}
+
+// size returns the number of task configs validated, for debug dumps
+func (v *configValidator) size() int {
+	if v == nil {
+		return 0
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.results)
+}
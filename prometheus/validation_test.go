@@ -0,0 +1,33 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfigValidation(t *testing.T) {
+	Convey("Default config should be valid", t, func() {
+		So(validateConfig(DefaultConfig()), ShouldBeNil)
+	})
+
+	Convey("Invalid configs should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"endpoint": "localhost:9100"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoint": "ftp://localhost/metrics"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"endpoint": "http:///metrics"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"job": "a/b"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"job": int64(3)}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"connection_max_age": "often"}), ShouldNotBeNil)
+	})
+
+	Convey("Validator should remember the verdict per config", t, func() {
+		validator := newConfigValidator()
+		config := plugin.Config{"job": "a/b"}
+		So(validator.validate(config), ShouldNotBeNil)
+		So(validator.validate(plugin.Config{"job": "a/b"}), ShouldNotBeNil)
+		So(validator.validate(plugin.Config{"job": "ab"}), ShouldBeNil)
+		So(len(validator.results), ShouldEqual, 2)
+	})
+}
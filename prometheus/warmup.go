@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// firstCollectionTracker remembers the task configs that collected already,
+// to tell the first collection of a task apart
+type firstCollectionTracker struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+func newFirstCollectionTracker() *firstCollectionTracker {
+	return &firstCollectionTracker{
+		seen: make(map[string]bool),
+	}
+}
+
+// first tells whether config collects for the first time, and remembers it
+// collected
+func (t *firstCollectionTracker) first(config plugin.Config) bool {
+	if t == nil {
+		return true
+	}
+
+	key := configFingerprint(config)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.seen[key] {
+		return false
+	}
+	t.seen[key] = true
+	return true
+}
+
+// warmingUp tells whether the collector started less than warmup_delay ago,
+// during which tasks don't collect
+func (c *PrometheusCollector) warmingUp(config plugin.Config, currentTime time.Time) (bool, error) {
+	delay, err := getDurationConfig(config, "warmup_delay")
+	if err != nil {
+		return false, err
+	}
+	return delay > 0 && currentTime.Sub(c.started) < delay, nil
+}
+
+// tagWarmup tags the metrics of the first collection of a task with
+// warmup=true, when the task asks for it
+func tagWarmup(config plugin.Config, metrics []plugin.Metric) {
+	if !getBoolConfig(config, "warmup_tag") {
+		return
+	}
+	for i := range metrics {
+		if metrics[i].Tags == nil {
+			metrics[i].Tags = map[string]string{}
+		}
+		metrics[i].Tags["warmup"] = "true"
+	}
+}
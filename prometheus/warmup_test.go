@@ -0,0 +1,54 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWarmup(t *testing.T) {
+	Convey("Tasks should not collect during warmup_delay", t, func() {
+		c := &PrometheusCollector{Downloader: windowTestDownloader{}, started: time.Now()}
+		metrics, err := c.CollectMetrics([]plugin.Metric{{Config: plugin.Config{"warmup_delay": "1h"}}})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldBeEmpty)
+
+		c.started = time.Now().Add(-2 * time.Hour)
+		metrics, err = c.CollectMetrics([]plugin.Metric{{Config: plugin.Config{"warmup_delay": "1h"}}})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+	})
+
+	Convey("The first collection of a task should be tagged with warmup_tag", t, func() {
+		c := &PrometheusCollector{Downloader: windowTestDownloader{}, firstCollections: newFirstCollectionTracker()}
+		config := plugin.Config{"warmup_tag": true}
+
+		metrics, err := c.CollectMetrics([]plugin.Metric{{Config: config}})
+		So(err, ShouldBeNil)
+		So(metrics[0].Tags["warmup"], ShouldEqual, "true")
+
+		metrics, err = c.CollectMetrics([]plugin.Metric{{Config: config}})
+		So(err, ShouldBeNil)
+		So(metrics[0].Tags, ShouldNotContainKey, "warmup")
+
+		metrics, err = c.CollectMetrics([]plugin.Metric{{Config: plugin.Config{"warmup_tag": true, "job": "other"}}})
+		So(err, ShouldBeNil)
+		So(metrics[0].Tags["warmup"], ShouldEqual, "true")
+	})
+
+	Convey("Rates should wait for a second sample", t, func() {
+		c := &PrometheusCollector{Downloader: windowTestDownloader{}, derived: newDerivedTracker()}
+		config := plugin.Config{"recording_rules": `[{"record": "requests:rate", "metric": "requests_total", "rate": true}]`}
+
+		metrics, err := c.CollectMetrics([]plugin.Metric{{Config: config}})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 1)
+
+		metrics, err = c.CollectMetrics([]plugin.Metric{{Config: config}})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 2)
+	})
+}
@@ -0,0 +1,142 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+)
+
+// activeWindow is a daily time of day range on a set of weekdays. A window
+// ending before it starts runs past midnight into the next day.
+type activeWindow struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseActiveWindows parses windows separated by ';', each an optional list
+// of days or day ranges followed by a time range, e.g.
+// "Mon-Fri 09:00-18:00; Sat,Sun 10:00-12:00"
+func parseActiveWindows(spec string) ([]activeWindow, error) {
+	windows := []activeWindow{}
+	for _, item := range strings.Split(spec, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("Invalid window %q: must be [days] HH:MM-HH:MM", strings.TrimSpace(item))
+		}
+
+		window := activeWindow{}
+		if len(fields) == 2 {
+			if err := window.parseDays(fields[0]); err != nil {
+				return nil, fmt.Errorf("Invalid window %q: %s", strings.TrimSpace(item), err.Error())
+			}
+		} else {
+			for i := range window.days {
+				window.days[i] = true
+			}
+		}
+		if err := window.parseTimes(fields[len(fields)-1]); err != nil {
+			return nil, fmt.Errorf("Invalid window %q: %s", strings.TrimSpace(item), err.Error())
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func (w *activeWindow) parseDays(spec string) error {
+	for _, days := range strings.Split(spec, ",") {
+		bounds := strings.SplitN(days, "-", 2)
+		first, ok := weekdays[strings.ToLower(bounds[0])]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (w *activeWindow) parseTimes(spec string) error {
+	bounds := strings.SplitN(spec, "-", 2)
+	if len(bounds) != 2 {
+		return fmt.Errorf("time range must be HH:MM-HH:MM")
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return err
+	}
+	if w.end, err = parseTimeOfDay(bounds[1]); err != nil {
+		return err
+	}
+	if w.start == w.end {
+		return fmt.Errorf("time range must not be empty")
+	}
+	return nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil || hours < 0 || hours > 24 || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func (w activeWindow) contains(t time.Time) bool {
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return w.days[t.Weekday()] && sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	// the window runs past midnight: it started either today or yesterday
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && sinceMidnight >= w.start) || (w.days[yesterday] && sinceMidnight < w.end)
+}
+
+// inActiveWindow tells whether a task collects at t. Tasks without
+// active_windows always do.
+func inActiveWindow(config plugin.Config, t time.Time) (bool, error) {
+	spec := getStringConfig(config, "active_windows")
+	if strings.TrimSpace(spec) == "" {
+		return true, nil
+	}
+	windows, err := parseActiveWindows(spec)
+	if err != nil {
+		return false, err
+	}
+	location, err := time.LoadLocation(getStringConfig(config, "active_windows_timezone"))
+	if err != nil {
+		return false, err
+	}
+	t = t.In(location)
+	for _, window := range windows {
+		if window.contains(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
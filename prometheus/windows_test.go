@@ -0,0 +1,99 @@
+package prometheus
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpra1113/snap-plugin-lib-go/v1/plugin"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestActiveWindows(t *testing.T) {
+	// 2018-01-01 is a Monday
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2018, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	Convey("Windows should contain their days and times", t, func() {
+		config := plugin.Config{"active_windows": "Mon-Fri 09:00-18:00; Sat,Sun 22:00-02:00", "active_windows_timezone": "UTC"}
+
+		for _, c := range []struct {
+			at     time.Time
+			active bool
+		}{
+			{at(1, 9, 0), true},
+			{at(5, 17, 59), true},
+			{at(5, 18, 0), false},
+			{at(1, 8, 59), false},
+			{at(6, 12, 0), false},
+			{at(6, 23, 0), true},
+			{at(7, 1, 0), true},
+			{at(8, 1, 0), true},
+			{at(9, 1, 0), false},
+		} {
+			active, err := inActiveWindow(config, c.at)
+			So(err, ShouldBeNil)
+			So(active, ShouldEqual, c.active)
+		}
+	})
+
+	Convey("Day ranges should wrap around the week", t, func() {
+		windows, err := parseActiveWindows("Fri-Mon 00:00-24:00")
+		So(err, ShouldBeNil)
+		So(windows[0].days, ShouldResemble, [7]bool{true, true, false, false, false, true, true})
+	})
+
+	Convey("Windows should be evaluated in their time zone", t, func() {
+		active, err := inActiveWindow(plugin.Config{"active_windows": "09:00-10:00", "active_windows_timezone": "Asia/Tokyo"}, at(1, 0, 30))
+		So(err, ShouldBeNil)
+		So(active, ShouldBeTrue)
+	})
+
+	Convey("Invalid windows should be rejected", t, func() {
+		So(validateConfig(plugin.Config{"active_windows": "Mon-Fri"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"active_windows": "Funday 09:00-10:00"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"active_windows": "09:00-25:00"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"active_windows": "09:00-09:00"}), ShouldNotBeNil)
+		So(validateConfig(plugin.Config{"active_windows": "09:00-10:00", "active_windows_timezone": "Mars/Olympus"}), ShouldNotBeNil)
+	})
+}
+
+type windowTestDownloader struct{}
+
+func (windowTestDownloader) GetMetricsReader(url string, config plugin.Config) (io.Reader, error) {
+	return strings.NewReader("# TYPE requests_total counter\nrequests_total 3\n"), nil
+}
+
+func (windowTestDownloader) GetEndpoint(config plugin.Config) (string, error) {
+	return "http://localhost:9100/metrics", nil
+}
+
+func TestOutsideWindow(t *testing.T) {
+	Convey("Outside its windows a task", t, func() {
+		now := time.Now().UTC()
+		closed := time.Date(2018, 1, 1, now.Hour(), now.Minute(), 0, 0, time.UTC).Add(2 * time.Hour)
+		config := plugin.Config{
+			"active_windows":          closed.Format("15:04") + "-" + closed.Add(time.Hour).Format("15:04"),
+			"active_windows_timezone": "UTC",
+		}
+		c := &PrometheusCollector{Downloader: windowTestDownloader{}}
+
+		Convey("should skip collecting", func() {
+			metrics, err := c.CollectMetrics([]plugin.Metric{{Config: config}})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldBeEmpty)
+		})
+
+		Convey("should only emit up with up_only", func() {
+			config["outside_window"] = "up_only"
+			metrics, err := c.CollectMetrics([]plugin.Metric{{Config: config}})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"hyperpilot", "prometheus", "up"})
+			So(metrics[0].Data, ShouldEqual, 1.0)
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-collector-prometheus/prometheus"
+)
+
+// runSelftest replays an exposition corpus through the pipeline with a task
+// config and reports, for every exporter, the variants converting to other
+// series than its first one, so a config can be verified against the
+// formats exporters serve before it is deployed. It returns the process
+// exit code, 1 when a payload fails or converts inconsistently.
+func runSelftest(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.Bool("selftest", true, "replay the exposition corpus and exit")
+	configPath := flags.String("config", "", "JSON file holding the task config the pipeline runs with")
+	corpus := flags.String("corpus", "", "corpus directory of <exporter>/<variant> payloads, the built-in corpus when empty")
+	verbose := flags.Bool("selftest-verbose", false, "list the series a variant is missing or adds")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	flag.Set("logtostderr", "true")
+
+	config, err := loadDaemonConfig(*configPath)
+	if err != nil {
+		glog.Errorf("Unable to load config: %s", err.Error())
+		return 1
+	}
+	payloads, err := loadCorpus(*corpus)
+	if err != nil {
+		glog.Errorf("Unable to load corpus: %s", err.Error())
+		return 1
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PAYLOAD\tBYTES\tSAMPLES\tMISSING\tEXTRA\tRESULT")
+	for _, result := range prometheus.ReplayCorpus(config, payloads) {
+		verdict := "ok"
+		switch {
+		case result.Err != nil:
+			verdict = "error: " + result.Err.Error()
+		case !result.Consistent():
+			verdict = "inconsistent"
+		}
+		if verdict != "ok" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", result.Payload.Name(), len(result.Payload.Body), result.Samples, len(result.Missing), len(result.Extra), verdict)
+		if *verbose {
+			for _, series := range result.Missing {
+				fmt.Fprintf(w, "\t\t\t\t\t- %s\n", series)
+			}
+			for _, series := range result.Extra {
+				fmt.Fprintf(w, "\t\t\t\t\t+ %s\n", series)
+			}
+		}
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d payloads failed\n", failed, len(payloads))
+		return 1
+	}
+	return 0
+}
+
+// loadCorpus returns the payloads of a corpus directory, the built-in corpus
+// when dir is empty
+func loadCorpus(dir string) ([]prometheus.CorpusPayload, error) {
+	if dir == "" {
+		return prometheus.Corpus()
+	}
+	return prometheus.LoadCorpus(dir)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/jpra1113/snap-plugin-collector-prometheus/prometheus"
+)
+
+// serveSynthetic hosts a deterministic exposition endpoint of a configurable
+// size, for measuring collector throughput and tuning limits before
+// scraping production targets. It returns the process exit code.
+func serveSynthetic(args []string) int {
+	flags := flag.NewFlagSet("serve-synthetic", flag.ContinueOnError)
+	size := flags.String("serve-synthetic", "100x10", "exposition size as <families>x<series>")
+	listen := flags.String("listen", ":9999", "address the exposition is served on, at /metrics")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	flag.Set("logtostderr", "true")
+
+	families, series, err := prometheus.ParseSyntheticSize(*size)
+	if err != nil {
+		glog.Errorf("%s", err.Error())
+		return 2
+	}
+
+	var mutex sync.Mutex
+	generation := 0
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		current := generation
+		generation++
+		mutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := prometheus.WriteSynthetic(w, families, series, current); err != nil {
+			glog.Warningf("Unable to write exposition: %s", err.Error())
+		}
+	})
+
+	glog.Infof("Serving %d families of %d series on %s/metrics", families, series, *listen)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		glog.Errorf("Unable to serve: %s", err.Error())
+		return 1
+	}
+	return 0
+}